@@ -19,8 +19,11 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/sero-cash/go-sero/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -144,3 +147,23 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// revertSelector is the method id of the Error(string) function, as which
+// solidity encodes the revert reasons.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the revert reason returned by a failed contract call.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("abi: invalid revert data")
+	}
+	typ, err := NewType("string")
+	if err != nil {
+		return "", err
+	}
+	var reason string
+	if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
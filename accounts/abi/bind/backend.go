@@ -110,3 +110,10 @@ type ContractBackend interface {
 	ContractTransactor
 	ContractFilterer
 }
+
+// DeployContractBackend defines the methods needed to deploy a contract and
+// bind it once deployed.
+type DeployContractBackend interface {
+	ContractBackend
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
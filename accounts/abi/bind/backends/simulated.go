@@ -391,8 +391,13 @@ func (m callmsg) Data() []byte                   { return m.CallMsg.Data }
 func (m callmsg) Fee() assets.Token {
 	return assets.Token{}
 }
-func (m callmsg) Asset() *assets.Asset { return &assets.Asset{} }
-func (m callmsg) TxHash() common.Hash  { return common.Hash{} }
+func (m callmsg) Asset() *assets.Asset {
+	if m.CallMsg.Asset == nil {
+		return &assets.Asset{}
+	}
+	return m.CallMsg.Asset
+}
+func (m callmsg) TxHash() common.Hash { return common.Hash{} }
 
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
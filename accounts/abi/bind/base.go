@@ -18,6 +18,8 @@ package bind
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 
 	sero "github.com/sero-cash/go-sero"
@@ -25,7 +27,12 @@ import (
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
 	"github.com/sero-cash/go-sero/zero/txs/tx"
+	"github.com/sero-cash/go-sero/zero/utils"
+
+	"github.com/sero-cash/go-czero-import/keys"
 )
 
 // SignerFn is a abi function callback when a contract requires a method to
@@ -36,6 +43,7 @@ type EncrypterFn func(common.Address, *types.Transaction, *tx.T) (*types.Transac
 type CallOpts struct {
 	Pending bool           // Whether to operate on the pending state or the last known one
 	From    common.Address // Optional the sender address, otherwise the first account is used
+	Asset   *assets.Asset  // Optional token and ticket sent along the call
 
 	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
 }
@@ -47,9 +55,13 @@ type TransactOpts struct {
 	Nonce     *big.Int       // Nonce to use for the transaction execution (nil = use pending state)
 	Encrypter EncrypterFn    // Method to use for signing the transaction (mandatory)
 
-	Value    *big.Int // Funds to transfer along along the transaction (nil = 0 = no funds)
-	GasPrice *big.Int // Gas price to use for the transaction execution (nil = gas price oracle)
-	GasLimit uint64   // Gas limit to set for the transaction execution (0 = estimate)
+	Asset    *assets.Asset // Token and ticket to transfer along the transaction (nil = no funds)
+	GasPrice *big.Int      // Gas price to use for the transaction execution (nil = gas price oracle)
+	GasLimit uint64        // Gas limit to set for the transaction execution (0 = estimate)
+
+	// Deprecated: use Asset. Value is the amount of SERO to transfer along
+	// the transaction, it can not be set together with Asset.
+	Value *big.Int
 
 	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
 }
@@ -108,7 +120,7 @@ func (c *BoundContract) Call(opts *CallOpts, result interface{}, method string,
 		return err
 	}
 	var (
-		msg    = sero.CallMsg{From: opts.From, To: &c.address, Data: input}
+		msg    = sero.CallMsg{From: opts.From, To: &c.address, Data: input, Asset: opts.Asset}
 		ctx    = ensureContext(opts.Context)
 		code   []byte
 		output []byte
@@ -144,6 +156,119 @@ func (c *BoundContract) Call(opts *CallOpts, result interface{}, method string,
 	return c.abi.Unpack(result, method, output)
 }
 
+// DeployContract deploys a contract onto the Sero blockchain. The address of the
+// contract depends on the chain state at execution, use WaitDeployed to get it.
+func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (*types.Transaction, error) {
+	input, err := abi.Pack("", params...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewBoundContract(common.Address{}, abi, backend, backend, backend)
+	return c.transact(opts, nil, append(bytecode, input...))
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (c *BoundContract) Transact(opts *TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	// Otherwise pack up the parameters and invoke the contract
+	input, err := c.abi.Pack(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	return c.transact(opts, &c.address, input)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (c *BoundContract) Transfer(opts *TransactOpts) (*types.Transaction, error) {
+	return c.transact(opts, &c.address, nil)
+}
+
+// asset returns the asset to transfer along the transaction, mapping the
+// deprecated Value to an amount of SERO.
+func (opts *TransactOpts) asset() (*assets.Asset, error) {
+	if opts.Value == nil || opts.Value.Sign() == 0 {
+		return opts.Asset, nil
+	}
+	if opts.Asset != nil {
+		return nil, errors.New("both Value and Asset set")
+	}
+	if opts.Value.Sign() < 0 {
+		return nil, errors.New("negative Value")
+	}
+	asset := assets.NewAssetByName(params.DefaultCurrency, opts.Value, "", keys.Empty_Uint256)
+	return &asset, nil
+}
+
+// transact executes an actual transaction invocation, first deriving any missing
+// authorization fields, and then scheduling the transaction for execution.
+func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, input []byte) (*types.Transaction, error) {
+	if opts.Encrypter == nil {
+		return nil, errors.New("no encrypter to authorize the transaction with")
+	}
+	value, err := opts.asset()
+	if err != nil {
+		return nil, err
+	}
+	ctx := ensureContext(opts.Context)
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		price, err := c.transactor.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+		gasPrice = price
+	}
+	gasLimit := opts.GasLimit
+	if gasLimit == 0 {
+		// Gas estimation cannot succeed without code for method invocations
+		if contract != nil {
+			if code, err := c.transactor.PendingCodeAt(ctx, c.address); err != nil {
+				return nil, err
+			} else if len(code) == 0 {
+				return nil, ErrNoCode
+			}
+		}
+		// If the contract surely has code (or code is not needed), estimate the transaction
+		msg := sero.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Data: input, Asset: value}
+		gas, err := c.transactor.EstimateGas(ctx, msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		}
+		gasLimit = gas
+	}
+	// Create the transaction, the outputs are sealed by the encrypter
+	rawTx := types.NewTransaction(gasPrice, gasLimit, input)
+	fee := assets.Token{
+		Currency: utils.CurrencyToUint256(params.DefaultCurrency),
+		Value:    utils.U256(*new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))),
+	}
+	var (
+		pkr     keys.PKr
+		fromRnd keys.Uint256
+		asset   assets.Asset
+	)
+	if contract != nil {
+		pkr = *contract.ToPKr()
+		copy(fromRnd[:16], contract[:16])
+	} else if len(input) >= 16 {
+		copy(fromRnd[:16], input[:16])
+	}
+	if value != nil {
+		asset = *value
+	}
+	out := &tx.Out{Addr: pkr, Asset: asset}
+	txt := types.NewTxt(&fromRnd, rawTx.Ehash(), fee, out, nil, nil, nil)
+
+	signedTx, err := opts.Encrypter(opts.From, rawTx, txt)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.transactor.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
+
 // FilterLogs filters contract logs for past blocks, returning the necessary
 // channels to construct a strongly typed bound iterator on top of them.
 func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
@@ -0,0 +1,145 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+
+	sero "github.com/sero-cash/go-sero"
+	"github.com/sero-cash/go-sero/accounts/abi"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/tx"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// mockTransactor records the gas estimations and the transactions sent.
+type mockTransactor struct {
+	estimated []sero.CallMsg
+	sent      []*types.Transaction
+}
+
+func (mt *mockTransactor) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (mt *mockTransactor) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1000000000), nil
+}
+
+func (mt *mockTransactor) EstimateGas(ctx context.Context, call sero.CallMsg) (uint64, error) {
+	mt.estimated = append(mt.estimated, call)
+	return 25000, nil
+}
+
+func (mt *mockTransactor) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	mt.sent = append(mt.sent, tx)
+	return nil
+}
+
+// transferOuts sends a transfer of the given options through a bound contract
+// and returns the outputs of the transaction handed to the encrypter.
+func transferOuts(t *testing.T, opts *TransactOpts) ([]tx.Out, *mockTransactor, error) {
+	var txt *tx.T
+	opts.Encrypter = func(from common.Address, rawTx *types.Transaction, encrypted *tx.T) (*types.Transaction, error) {
+		txt = encrypted
+		return rawTx, nil
+	}
+	transactor := new(mockTransactor)
+	contract := NewBoundContract(common.BytesToAddress([]byte("contract")), abi.ABI{}, nil, transactor, nil)
+	if _, err := contract.Transfer(opts); err != nil {
+		return nil, transactor, err
+	}
+	if txt == nil {
+		t.Fatalf("transaction not encrypted")
+	}
+	return txt.Outs, transactor, nil
+}
+
+func TestTransactValue(t *testing.T) {
+	// The deprecated Value is sent as an amount of SERO
+	outs, transactor, err := transferOuts(t, &TransactOpts{Value: big.NewInt(100)})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if len(outs) != 1 || outs[0].Asset.Tkn == nil || outs[0].Asset.Tkt != nil {
+		t.Fatalf("outputs mismatch: %+v", outs)
+	}
+	if currency := utils.Uint256ToCurrency(&outs[0].Asset.Tkn.Currency); currency != "SERO" {
+		t.Errorf("currency mismatch: have %s, want SERO", currency)
+	}
+	if value := outs[0].Asset.Tkn.Value.ToIntRef(); value.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("value mismatch: have %v, want 100", value)
+	}
+	if len(transactor.estimated) != 1 || transactor.estimated[0].Asset == nil || transactor.estimated[0].Asset.Tkn == nil {
+		t.Errorf("gas estimated without the value: %+v", transactor.estimated)
+	}
+	if len(transactor.sent) != 1 {
+		t.Errorf("sent transaction count mismatch: have %d, want 1", len(transactor.sent))
+	}
+}
+
+func TestTransactAsset(t *testing.T) {
+	var ticket keys.Uint256
+	ticket[31] = 1
+	asset := assets.NewAssetByName("ABC", big.NewInt(5), "CAT", ticket)
+
+	outs, transactor, err := transferOuts(t, &TransactOpts{Asset: &asset})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if len(outs) != 1 || outs[0].Asset.Tkn == nil || outs[0].Asset.Tkt == nil {
+		t.Fatalf("outputs mismatch: %+v", outs)
+	}
+	if currency := utils.Uint256ToCurrency(&outs[0].Asset.Tkn.Currency); currency != "ABC" {
+		t.Errorf("currency mismatch: have %s, want ABC", currency)
+	}
+	if outs[0].Asset.Tkt.Value != ticket {
+		t.Errorf("ticket mismatch: have %x, want %x", outs[0].Asset.Tkt.Value, ticket)
+	}
+	if len(transactor.estimated) != 1 || transactor.estimated[0].Asset != &asset {
+		t.Errorf("gas estimated without the asset: %+v", transactor.estimated)
+	}
+
+	// No funds are sent without Value and Asset
+	outs, _, err = transferOuts(t, &TransactOpts{Value: new(big.Int)})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if len(outs) != 1 || outs[0].Asset.Tkn != nil || outs[0].Asset.Tkt != nil {
+		t.Errorf("funds sent: %+v", outs)
+	}
+}
+
+func TestTransactInvalidValue(t *testing.T) {
+	asset := assets.NewAssetByName("ABC", big.NewInt(5), "", keys.Empty_Uint256)
+	for i, opts := range []*TransactOpts{
+		{Value: big.NewInt(1), Asset: &asset},
+		{Value: big.NewInt(-1)},
+	} {
+		if _, transactor, err := transferOuts(t, opts); err == nil {
+			t.Errorf("test %d: invalid value accepted", i)
+		} else if len(transactor.sent) != 0 {
+			t.Errorf("test %d: transaction sent", i)
+		}
+	}
+}
@@ -212,7 +212,7 @@ func bindUnnestedTypeGo(stringKind string) (int, string) {
 
 	switch {
 	case strings.HasPrefix(stringKind, "address"):
-		return len("address"), "common.Data"
+		return len("address"), "common.ContractAddress"
 
 	case strings.HasPrefix(stringKind, "bytes"):
 		parts := regexp.MustCompile(`bytes([0-9]*)`).FindStringSubmatch(stringKind)
@@ -0,0 +1,117 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ownedABI is the ABI of a contract with a constructor, address arguments and
+// results, and an event with an indexed address.
+const ownedABI = `[
+	{"type":"constructor","inputs":[{"name":"owner","type":"address"}]},
+	{"type":"function","name":"owner","constant":true,"inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"transferOwnership","constant":false,"inputs":[{"name":"newOwner","type":"address"}],"outputs":[]},
+	{"type":"event","name":"OwnershipTransferred","anonymous":false,"inputs":[{"name":"previousOwner","type":"address","indexed":true},{"name":"newOwner","type":"address","indexed":false}]}
+]`
+
+// ownedUsage uses the generated binding with the types its users hold, failing
+// the build if they don't match.
+const ownedUsage = `package bindtest
+
+import (
+	"context"
+
+	"github.com/sero-cash/go-sero/accounts/abi/bind"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+)
+
+var (
+	_ func(*bind.TransactOpts, bind.ContractBackend, common.ContractAddress) (*types.Transaction, error) = DeployOwned
+	_ func(context.Context, bind.DeployContractBackend, *types.Transaction) (common.Address, *Owned, error) = WaitOwnedDeployed
+	_ func(common.Address, bind.ContractBackend) (*Owned, error) = NewOwned
+)
+
+func use(owned *Owned, opts *bind.TransactOpts) error {
+	owner, err := owned.Owner(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := owned.TransferOwnership(opts, owner); err != nil {
+		return err
+	}
+	it, err := owned.FilterOwnershipTransferred(nil, []common.ContractAddress{owner})
+	if err != nil {
+		return err
+	}
+	for it.Next() {
+		var _ common.ContractAddress = it.Event.PreviousOwner
+		var _ common.ContractAddress = it.Event.NewOwner
+	}
+	return it.Close()
+}
+`
+
+// Tests that the binding of a contract using addresses matches the types of the
+// generated functions.
+func TestBindAddresses(t *testing.T) {
+	code, err := Bind([]string{"owned"}, []string{ownedABI}, []string{"0x6060"}, "bindtest", LangGo)
+	if err != nil {
+		t.Fatalf("failed to generate binding: %v", err)
+	}
+	for _, want := range []string{
+		"func DeployOwned(auth *bind.TransactOpts, backend bind.ContractBackend, owner common.ContractAddress) (*types.Transaction, error)",
+		"func WaitOwnedDeployed(ctx context.Context, backend bind.DeployContractBackend, tx *types.Transaction) (common.Address, *Owned, error)",
+		"func NewOwned(address common.Address, backend bind.ContractBackend) (*Owned, error)",
+		"func (_Owned *OwnedCaller) Owner(opts *bind.CallOpts) (common.ContractAddress, error)",
+		"func (_Owned *OwnedTransactor) TransferOwnership(opts *bind.TransactOpts, newOwner common.ContractAddress) (*types.Transaction, error)",
+		"func (_Owned *OwnedFilterer) FilterOwnershipTransferred(opts *bind.FilterOpts, previousOwner []common.ContractAddress) (*OwnedOwnershipTransferredIterator, error)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("binding misses %q", want)
+		}
+	}
+
+	// Build the binding if a go tool is at hand
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found, binding not built")
+	}
+	ws, err := ioutil.TempDir("", "bindtest")
+	if err != nil {
+		t.Fatalf("failed to create workspace: %v", err)
+	}
+	defer os.RemoveAll(ws)
+
+	if err := ioutil.WriteFile(filepath.Join(ws, "owned.go"), []byte(code), 0600); err != nil {
+		t.Fatalf("failed to write binding: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ws, "usage.go"), []byte(ownedUsage), 0600); err != nil {
+		t.Fatalf("failed to write usage: %v", err)
+	}
+	cmd := exec.Command(gocmd, "build", ".")
+	cmd.Dir = ws
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build binding: %v\n%s\n%s", err, out, code)
+	}
+}
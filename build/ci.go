@@ -72,6 +72,7 @@ var (
 	allToolsArchiveFiles = []string{
 		"COPYING",
 		executablePath("bootnode"),
+		executablePath("evm"),
 		executablePath("gero"),
 	}
 
@@ -87,6 +88,10 @@ var (
 			BinaryName:  "bootnode",
 			Description: "Sero bootnode.",
 		},
+		{
+			BinaryName:  "evm",
+			Description: "Sero contract runner.",
+		},
 		{
 			BinaryName:  "gero",
 			Description: "Sero CLI client.",
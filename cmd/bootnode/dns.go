@@ -0,0 +1,145 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sero-cash/go-sero/p2p/dnsdisc"
+	"github.com/sero-cash/go-sero/p2p/enr"
+)
+
+const (
+	rootTTL  = 60    // TTL of the tree root, which changes on every publication
+	entryTTL = 86400 // TTL of the tree entries, named after their content
+)
+
+// nodeRecord returns the text form of the record of the node at the given
+// public endpoint.
+func nodeRecord(key *ecdsa.PrivateKey, endpoint string) (string, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return "", fmt.Errorf("invalid IP address %q", host)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	portnum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	r, err := dnsdisc.NewRecord(key, ip, uint16(portnum), uint16(portnum))
+	if err != nil {
+		return "", err
+	}
+	return dnsdisc.RecordString(r)
+}
+
+// readRecords reads node records from a file, one per line.
+func readRecords(file string) ([]*enr.Record, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []*enr.Record
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		r, err := dnsdisc.ParseRecord(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// signTree builds the DNS discovery tree of the given records and links, signs
+// it and writes its zone file, returning the link of the tree.
+func signTree(key *ecdsa.PrivateKey, domain string, seq uint, recordsFile string, links []string, zoneFile string) (string, error) {
+	records, err := readRecords(recordsFile)
+	if err != nil {
+		return "", err
+	}
+	tree, err := dnsdisc.MakeTree(seq, records, links)
+	if err != nil {
+		return "", err
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(zoneFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := writeZone(f, domain, tree.ToTXT("")); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// writeZone writes the TXT records of a tree in the zone file format, the root
+// record being the one with an empty name.
+func writeZone(w io.Writer, domain string, records map[string]string) error {
+	names := make([]string, 0, len(records))
+	for name := range records {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s.\n@\t%d\tIN\tTXT\t%s\n", domain, rootTTL, quoteTXT(records[""])); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s\t%d\tIN\tTXT\t%s\n", name, entryTTL, quoteTXT(records[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteTXT quotes a TXT record, split in strings of at most 255 bytes.
+func quoteTXT(text string) string {
+	var parts []string
+	for len(text) > 255 {
+		parts = append(parts, strconv.Quote(text[:255]))
+		text = text[255:]
+	}
+	parts = append(parts, strconv.Quote(text))
+	return strings.Join(parts, " ")
+}
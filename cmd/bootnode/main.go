@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/sero-cash/go-sero/cmd/utils"
 	"github.com/sero-cash/go-sero/crypto"
@@ -38,6 +39,12 @@ func main() {
 		listenAddr  = flag.String("addr", ":53719", "listen address")
 		genKey      = flag.String("genkey", "", "generate a node key")
 		writeAddr   = flag.Bool("writeaddress", false, "write out the node's pubkey hash and quit")
+		writeENR    = flag.String("writeenr", "", "write out the signed record of the node at the given public ip:port and quit")
+		dnsRecords  = flag.String("dnsrecords", "", "sign a DNS discovery tree of the node records in the given file, one per line, and quit")
+		dnsDomain   = flag.String("dnsdomain", "", "domain the DNS discovery tree is published in")
+		dnsSeq      = flag.Uint("dnsseq", 1, "sequence number of the DNS discovery tree")
+		dnsLinks    = flag.String("dnslinks", "", "comma separated enrtree:// links to other trees")
+		dnsZone     = flag.String("dnszone", "", "zone file the DNS discovery tree is written to")
 		nodeKeyFile = flag.String("nodekey", "", "private key filename")
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
@@ -88,6 +95,29 @@ func main() {
 		fmt.Printf("%v\n", discover.PubkeyID(&nodeKey.PublicKey))
 		os.Exit(0)
 	}
+	if *writeENR != "" {
+		record, err := nodeRecord(nodeKey, *writeENR)
+		if err != nil {
+			utils.Fatalf("-writeenr: %v", err)
+		}
+		fmt.Println(record)
+		os.Exit(0)
+	}
+	if *dnsRecords != "" {
+		if *dnsDomain == "" || *dnsZone == "" {
+			utils.Fatalf("Use -dnsdomain and -dnszone to specify the domain and zone file of the tree")
+		}
+		var links []string
+		if *dnsLinks != "" {
+			links = strings.Split(*dnsLinks, ",")
+		}
+		url, err := signTree(nodeKey, *dnsDomain, *dnsSeq, *dnsRecords, links, *dnsZone)
+		if err != nil {
+			utils.Fatalf("-dnsrecords: %v", err)
+		}
+		fmt.Println(url)
+		os.Exit(0)
+	}
 
 	var restrictList *netutil.Netlist
 	if *netrestrict != "" {
@@ -0,0 +1,133 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// evm executes SERO contract bytecode locally, with tokens and tickets attached.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app = utils.NewApp(gitCommit, "the SERO evm command line interface")
+
+var (
+	CodeFlag = cli.StringFlag{
+		Name:  "code",
+		Usage: "hex encoded bytecode to run",
+	}
+	CodeFileFlag = cli.StringFlag{
+		Name:  "codefile",
+		Usage: "file containing the hex encoded bytecode to run, - for stdin",
+	}
+	InputFlag = cli.StringFlag{
+		Name:  "input",
+		Usage: "hex encoded call data",
+	}
+	CreateFlag = cli.BoolFlag{
+		Name:  "create",
+		Usage: "run the code as initcode and deploy the resulting contract",
+	}
+	GasFlag = cli.Uint64Flag{
+		Name:  "gas",
+		Usage: "gas limit of the execution",
+		Value: 10000000000,
+	}
+	PriceFlag = cli.Uint64Flag{
+		Name:  "price",
+		Usage: "gas price of the execution",
+		Value: 0,
+	}
+	SenderFlag = cli.StringFlag{
+		Name:  "sender",
+		Usage: "base58 address of the caller",
+	}
+	ReceiverFlag = cli.StringFlag{
+		Name:  "receiver",
+		Usage: "base58 address of a contract of the prestate to call instead of the given code",
+	}
+	PrestateFlag = cli.StringFlag{
+		Name:  "prestate",
+		Usage: "JSON genesis file to build the state from",
+	}
+	CurrencyFlag = cli.StringFlag{
+		Name:  "cy",
+		Usage: "currency of the token sent along the call",
+		Value: "SERO",
+	}
+	ValueFlag = cli.StringFlag{
+		Name:  "value",
+		Usage: "amount of the token sent along the call",
+	}
+	CategoryFlag = cli.StringFlag{
+		Name:  "catg",
+		Usage: "category of the ticket sent along the call",
+	}
+	TicketFlag = cli.StringFlag{
+		Name:  "tkt",
+		Usage: "hex encoded value of the ticket sent along the call",
+	}
+	TraceFlag = cli.BoolFlag{
+		Name:  "trace",
+		Usage: "output the struct logger trace of the execution",
+	}
+	DisableMemoryFlag = cli.BoolFlag{
+		Name:  "nomemory",
+		Usage: "disable memory output of the trace",
+	}
+	DisableStackFlag = cli.BoolFlag{
+		Name:  "nostack",
+		Usage: "disable stack output of the trace",
+	}
+)
+
+func init() {
+	app.Flags = []cli.Flag{
+		CodeFlag,
+		CodeFileFlag,
+		InputFlag,
+		CreateFlag,
+		GasFlag,
+		PriceFlag,
+		SenderFlag,
+		ReceiverFlag,
+		PrestateFlag,
+		CurrencyFlag,
+		ValueFlag,
+		CategoryFlag,
+		TicketFlag,
+		TraceFlag,
+		DisableMemoryFlag,
+		DisableStackFlag,
+	}
+	app.Action = runCmd
+}
+
+func main() {
+	cpt.ZeroInit_NoCircuit()
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,286 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/core/vm/runtime"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// transferRecord is an asset moved during the execution.
+type transferRecord struct {
+	from  common.Address
+	to    common.Address
+	asset assets.Asset
+}
+
+// multiTracer forwards the execution events to several tracers.
+type multiTracer []vm.Tracer
+
+func (self multiTracer) CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, asset *assets.Asset) error {
+	for _, t := range self {
+		t.CaptureStart(from, to, call, input, gas, asset)
+	}
+	return nil
+}
+
+func (self multiTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	for _, t := range self {
+		t.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
+	return nil
+}
+
+func (self multiTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	for _, t := range self {
+		t.CaptureFault(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
+	return nil
+}
+
+func (self multiTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	for _, tracer := range self {
+		tracer.CaptureEnd(output, gasUsed, t, err)
+	}
+	return nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	if len(s)%2 == 1 {
+		return nil, fmt.Errorf("odd length hex string")
+	}
+	ret := common.FromHex(s)
+	if len(s) != 0 && len(ret) == 0 {
+		return nil, fmt.Errorf("invalid hex string")
+	}
+	return ret, nil
+}
+
+func loadCode(ctx *cli.Context) ([]byte, error) {
+	if code := ctx.GlobalString(CodeFlag.Name); code != "" {
+		return decodeHex(code)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	switch file := ctx.GlobalString(CodeFileFlag.Name); file {
+	case "":
+		return nil, nil
+	case "-":
+		data, err = ioutil.ReadAll(os.Stdin)
+	default:
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load code: %v", err)
+	}
+	return decodeHex(string(bytes.TrimSpace(data)))
+}
+
+// loadState builds the state of the genesis file if any, or an empty one.
+func loadState(file string) (*state.StateDB, *params.ChainConfig, error) {
+	db := serodb.NewMemDatabase()
+	if file == "" {
+		statedb, err := state.New(state.NewDatabase(db), nil)
+		return statedb, nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, nil, fmt.Errorf("invalid prestate %s: %v", file, err)
+	}
+	block := genesis.ToBlock(db)
+	statedb, err := state.New(state.NewDatabase(db), block.Header())
+	return statedb, genesis.Config, err
+}
+
+func loadAsset(ctx *cli.Context) (assets.Asset, error) {
+	value := new(big.Int)
+	if s := ctx.GlobalString(ValueFlag.Name); s != "" {
+		if _, ok := value.SetString(s, 0); !ok || value.Sign() < 0 {
+			return assets.Asset{}, fmt.Errorf("invalid value %s", s)
+		}
+	}
+	ticket := common.Hash{}
+	if s := ctx.GlobalString(TicketFlag.Name); s != "" {
+		b, err := decodeHex(s)
+		if err != nil || len(b) > common.HashLength {
+			return assets.Asset{}, fmt.Errorf("invalid ticket %s", s)
+		}
+		ticket = common.BytesToHash(b)
+	}
+	category := strings.ToUpper(ctx.GlobalString(CategoryFlag.Name))
+	if category != "" && ticket == (common.Hash{}) {
+		return assets.Asset{}, fmt.Errorf("ticket category %s given without a ticket", category)
+	}
+	return assets.NewAssetByName(strings.ToUpper(ctx.GlobalString(CurrencyFlag.Name)), value, category, *ticket.HashToUint256()), nil
+}
+
+func runCmd(ctx *cli.Context) error {
+	code, err := loadCode(ctx)
+	if err != nil {
+		return err
+	}
+	input, err := decodeHex(ctx.GlobalString(InputFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	asset, err := loadAsset(ctx)
+	if err != nil {
+		return err
+	}
+	statedb, chainConfig, err := loadState(ctx.GlobalString(PrestateFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	sender := common.BytesToAddress([]byte("sender"))
+	if s := ctx.GlobalString(SenderFlag.Name); s != "" {
+		sender = common.Base58ToAddress(s)
+	}
+	receiver := common.BytesToAddress([]byte("receiver"))
+	if s := ctx.GlobalString(ReceiverFlag.Name); s != "" {
+		receiver = common.Base58ToAddress(s)
+		if len(statedb.GetCode(receiver)) == 0 {
+			return fmt.Errorf("no contract at %s in the prestate", s)
+		}
+	} else if len(code) == 0 {
+		return fmt.Errorf("no code given, use --%s, --%s or --%s", CodeFlag.Name, CodeFileFlag.Name, ReceiverFlag.Name)
+	}
+
+	// The runtime treats the caller as a contract, hand it the assets it sends.
+	if len(statedb.GetCode(sender)) == 0 {
+		if tkn := asset.Tkn; tkn != nil {
+			statedb.AddBalance(sender, utils.Uint256ToCurrency(&tkn.Currency), tkn.Value.ToInt())
+		}
+		if tkt := asset.Tkt; tkt != nil {
+			statedb.AddTicket(sender, utils.Uint256ToCurrency(&tkt.Category), common.BytesToHash(tkt.Value[:]))
+		}
+	}
+
+	var (
+		logger    = vm.NewStructLogger(&vm.LogConfig{DisableMemory: ctx.GlobalBool(DisableMemoryFlag.Name), DisableStack: ctx.GlobalBool(DisableStackFlag.Name)})
+		syscalls  = new(vm.SysCallTracer)
+		tracer    = multiTracer{syscalls}
+		transfers []transferRecord
+	)
+	if ctx.GlobalBool(TraceFlag.Name) {
+		tracer = append(tracer, logger)
+	}
+	cfg := &runtime.Config{
+		ChainConfig: chainConfig,
+		Origin:      sender,
+		State:       statedb,
+		GasLimit:    ctx.GlobalUint64(GasFlag.Name),
+		GasPrice:    new(big.Int).SetUint64(ctx.GlobalUint64(PriceFlag.Name)),
+		Asset:       &asset,
+		EVMConfig:   vm.Config{Debug: true, Tracer: tracer},
+		Transfer: func(db vm.StateDB, from, to common.Address, asset *assets.Asset, txHash common.Hash) bool {
+			if asset != nil && (asset.Tkn != nil || asset.Tkt != nil) {
+				transfers = append(transfers, transferRecord{from, to, *asset})
+			}
+			return core.Transfer(db, from, to, asset, txHash)
+		},
+	}
+
+	var (
+		ret         []byte
+		leftOverGas uint64
+		start       = time.Now()
+	)
+	if ctx.GlobalBool(CreateFlag.Name) {
+		var address common.Address
+		ret, address, leftOverGas, err = runtime.Create(append(code, input...), cfg)
+		if err == nil {
+			fmt.Printf("contract: %s\n", address.Base58())
+		}
+	} else {
+		if ctx.GlobalString(ReceiverFlag.Name) == "" {
+			statedb.SetCode(receiver, code)
+		}
+		ret, leftOverGas, err = runtime.Call(receiver, input, cfg)
+	}
+	elapsed := time.Since(start)
+
+	if ctx.GlobalBool(TraceFlag.Name) {
+		fmt.Fprintln(os.Stderr, "#### TRACE ####")
+		vm.WriteTrace(os.Stderr, logger.StructLogs())
+	}
+	fmt.Printf("gas used: %d\n", cfg.GasLimit-leftOverGas)
+	fmt.Printf("elapsed:  %v\n", elapsed)
+	fmt.Printf("return:   0x%x\n", ret)
+	if err != nil {
+		fmt.Printf("error:    %v\n", err)
+	}
+	if logs := statedb.Logs(); len(logs) > 0 {
+		fmt.Println("#### LOGS ####")
+		vm.WriteLogs(os.Stdout, logs)
+	}
+
+	var issued []vm.SysCallRecord
+	for _, record := range syscalls.Calls {
+		if _, ok := record.Call.(vm.IssueToken); ok {
+			issued = append(issued, record)
+		}
+	}
+	if len(issued) > 0 {
+		fmt.Println("#### TOKENS ####")
+		for _, record := range issued {
+			issue := record.Call.(vm.IssueToken)
+			fmt.Printf("%s issued %v %s\n", record.Contract.Base58(), issue.Total, issue.Currency)
+		}
+	}
+	if len(transfers) > 0 {
+		fmt.Println("#### TRANSFERS ####")
+		for _, transfer := range transfers {
+			fmt.Printf("%s -> %s:", transfer.from.Base58(), transfer.to.Base58())
+			if tkn := transfer.asset.Tkn; tkn != nil {
+				fmt.Printf(" %v %s", tkn.Value.ToInt(), utils.Uint256ToCurrency(&tkn.Currency))
+			}
+			if tkt := transfer.asset.Tkt; tkt != nil {
+				fmt.Printf(" ticket %s %x", utils.Uint256ToCurrency(&tkt.Category), tkt.Value[:])
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,622 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-czero-import/seroparam"
+
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/accounts/keystore"
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/node"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	devnetAPIs    = "sero,net,web3,admin,personal,miner,stake,exchange,light,debug,txpool"
+	devnetTimeout = 5 * time.Minute // Time to wait for the nodes and the staking transactions
+)
+
+var (
+	devnetRolesFlag = cli.StringFlag{
+		Name:  "devnet.roles",
+		Usage: "Comma separated roles of the nodes, one entry per node, each a '+' separated set of miner, voter, exchange and light",
+		Value: "miner,voter,exchange",
+	}
+	devnetDirFlag = cli.StringFlag{
+		Name:  "devnet.dir",
+		Usage: "Directory of the network, a temporary one if empty. An existing network is restarted",
+	}
+	devnetPortFlag = cli.IntFlag{
+		Name:  "devnet.port",
+		Usage: "First port of the nodes, each node listening on 3 ports: p2p, HTTP-RPC and WS-RPC",
+		Value: 31000,
+	}
+	devnetFundFlag = cli.Uint64Flag{
+		Name:  "devnet.fund",
+		Usage: "SERO allocated in the genesis to the account of each node",
+		Value: 1000000,
+	}
+	devnetSharesFlag = cli.Uint64Flag{
+		Name:  "devnet.shares",
+		Usage: "SERO spent buying shares of the stake pool (0 = no stake pool)",
+		Value: 100,
+	}
+	devnetInprocFlag = cli.BoolFlag{
+		Name:  "devnet.inproc",
+		Usage: "Run the first node in this process instead of a child one",
+	}
+	devnetGeroFlag = cli.StringFlag{
+		Name:  "devnet.gero",
+		Usage: "Executable of the child nodes, this one if empty",
+	}
+
+	devnetCommand = cli.Command{
+		Action:    utils.MigrateFlags(devnet),
+		Name:      "devnet",
+		Usage:     "Run a local network of developer nodes",
+		ArgsUsage: " ",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			devnetRolesFlag,
+			devnetDirFlag,
+			devnetPortFlag,
+			devnetFundFlag,
+			devnetSharesFlag,
+			devnetInprocFlag,
+			devnetGeroFlag,
+			utils.DeveloperPasswordFlag,
+		},
+		Description: `
+The devnet command creates an account and a node key for every node, and a
+developer genesis funding all the accounts. It then starts the nodes on
+localhost, connected to each other as static peers, with the HTTP-RPC, WS-RPC
+and IPC endpoints enabled and the accounts unlocked.
+
+The roles of the nodes are:
+
+  miner     mines with its account as serobase
+  voter     its account is the vote address of the stake pool
+  exchange  runs the exchange, registers a stake pool and buys its shares
+  light     runs the light node service
+
+Once the network is up, the endpoints of the nodes are written into
+devnet.json in the network directory, and the nodes run until interrupted.`,
+	}
+)
+
+// devnetNode is a node of the network, as written into devnet.json.
+type devnetNode struct {
+	Name    string   `json:"name"`
+	Roles   []string `json:"roles"`
+	DataDir string   `json:"datadir"`
+	Account string   `json:"account"`
+	Enode   string   `json:"enode"`
+	HTTP    string   `json:"http"`
+	WS      string   `json:"ws"`
+	IPC     string   `json:"ipc"`
+	Log     string   `json:"log,omitempty"`
+	Pid     int      `json:"pid,omitempty"`
+
+	account accounts.Account
+	port    int
+	args    []string
+	cmd     *exec.Cmd
+	stack   *node.Node
+	exited  chan struct{}
+}
+
+func (self *devnetNode) hasRole(role string) bool {
+	for _, r := range self.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// devnetManifest describes the network for the integration tests.
+type devnetManifest struct {
+	Genesis   string        `json:"genesis"`
+	NetworkId uint64        `json:"networkId"`
+	Password  string        `json:"password"`
+	Pool      *common.Hash  `json:"pool,omitempty"`
+	Nodes     []*devnetNode `json:"nodes"`
+}
+
+func devnet(ctx *cli.Context) error {
+	seroparam.Init_Dev(true)
+
+	var nodes []*devnetNode
+	for i, entry := range strings.Split(ctx.String(devnetRolesFlag.Name), ",") {
+		n := &devnetNode{Name: fmt.Sprintf("node%d", i), port: ctx.Int(devnetPortFlag.Name) + 3*i}
+		for _, role := range strings.Split(entry, "+") {
+			switch role = strings.TrimSpace(role); role {
+			case "miner", "voter", "exchange", "light":
+				n.Roles = append(n.Roles, role)
+			case "":
+			default:
+				return fmt.Errorf("unknown role %q", role)
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	password := ctx.GlobalString(utils.DeveloperPasswordFlag.Name)
+	if password == "" {
+		password = "devnet"
+	}
+	dir := ctx.String(devnetDirFlag.Name)
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "gero-devnet")
+		if err != nil {
+			return err
+		}
+		dir = tmp
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := setupDevnet(dir, nodes, password, ctx.Uint64(devnetFundFlag.Name)); err != nil {
+		return err
+	}
+	manifest := &devnetManifest{Genesis: filepath.Join(dir, "genesis.json"), NetworkId: 1024, Password: password, Nodes: nodes}
+
+	// Launch the nodes and wait for them to be connected
+	gero := ctx.String(devnetGeroFlag.Name)
+	if gero == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		gero = exe
+	}
+	for i, n := range nodes {
+		n.args = devnetArgs(n, manifest.Genesis, password)
+		var err error
+		if i == 0 && ctx.Bool(devnetInprocFlag.Name) {
+			err = startInprocNode(n)
+		} else {
+			err = startChildNode(n, gero)
+		}
+		if err != nil {
+			stopDevnet(nodes)
+			return fmt.Errorf("failed to start %s: %v", n.Name, err)
+		}
+	}
+	defer stopDevnet(nodes)
+
+	clients := make([]*rpc.Client, len(nodes))
+	for i, n := range nodes {
+		client, err := waitRPC(n)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+	if len(nodes) > 1 {
+		for i, n := range nodes {
+			err := retry(devnetTimeout, func() error {
+				var peers hexutil.Uint
+				if err := clients[i].Call(&peers, "net_peerCount"); err != nil {
+					return err
+				}
+				if peers == 0 {
+					return errors.New("no peer connected")
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %v", n.Name, err)
+			}
+		}
+	}
+
+	// Register a stake pool voted by the voter and buy its shares
+	if shares := ctx.Uint64(devnetSharesFlag.Name); shares > 0 {
+		miner, voter, exchange := -1, -1, -1
+		for i, n := range nodes {
+			if n.hasRole("miner") && miner < 0 {
+				miner = i
+			}
+			if n.hasRole("voter") && voter < 0 {
+				voter = i
+			}
+			if n.hasRole("exchange") && exchange < 0 {
+				exchange = i
+			}
+		}
+		if miner >= 0 && voter >= 0 && exchange >= 0 {
+			pool, err := setupStake(clients[exchange], nodes[exchange], nodes[voter], new(big.Int).Mul(new(big.Int).SetUint64(shares), big.NewInt(1e18)))
+			if err != nil {
+				return err
+			}
+			manifest.Pool = &pool
+		} else {
+			log.Warn("No stake pool registered, the network needs a miner, a voter and an exchange")
+		}
+	}
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return err
+	}
+	fmt.Printf("Devnet running in %s\n\n", dir)
+	for _, n := range nodes {
+		fmt.Printf("%s [%s]\n  account: %s\n  enode:   %s\n  http:    %s\n  ws:      %s\n  ipc:     %s\n", n.Name, strings.Join(n.Roles, ","), n.Account, n.Enode, n.HTTP, n.WS, n.IPC)
+	}
+	if manifest.Pool != nil {
+		fmt.Printf("\nStake pool: %s\n", manifest.Pool.Hex())
+	}
+	fmt.Println("\nPress Ctrl-C to stop the network")
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	exited := make(chan string, len(nodes))
+	for _, n := range nodes {
+		go func(n *devnetNode) {
+			<-n.exited
+			exited <- n.Name
+		}(n)
+	}
+	select {
+	case <-sigc:
+		log.Info("Stopping the devnet")
+	case name := <-exited:
+		log.Error("Devnet node exited, stopping the devnet", "node", name)
+	}
+	return nil
+}
+
+// setupDevnet creates the accounts, the node keys, the static peers and the
+// genesis of the nodes, or reuses them if the network already exists.
+func setupDevnet(dir string, nodes []*devnetNode, password string, fund uint64) error {
+	for _, n := range nodes {
+		n.DataDir = filepath.Join(dir, n.Name)
+		instanceDir := filepath.Join(n.DataDir, clientIdentifier)
+		if err := os.MkdirAll(instanceDir, 0700); err != nil {
+			return err
+		}
+		ks := keystore.NewKeyStore(filepath.Join(n.DataDir, "keystore"), keystore.LightScryptN, keystore.LightScryptP)
+		if existing := ks.Accounts(); len(existing) > 0 {
+			n.account = existing[0]
+		} else {
+			account, err := ks.NewAccount(password, 0)
+			if err != nil {
+				return err
+			}
+			n.account = account
+		}
+		n.Account = n.account.Address.String()
+
+		keyfile := filepath.Join(instanceDir, "nodekey")
+		key, err := crypto.LoadECDSA(keyfile)
+		if err != nil {
+			if key, err = crypto.GenerateKey(); err != nil {
+				return err
+			}
+			if err := crypto.SaveECDSA(keyfile, key); err != nil {
+				return err
+			}
+		}
+		id := discover.PubkeyID(&key.PublicKey)
+		n.Enode = discover.NewNode(id, net.IP{127, 0, 0, 1}, uint16(n.port), uint16(n.port)).String()
+		n.HTTP = fmt.Sprintf("http://127.0.0.1:%d", n.port+1)
+		n.WS = fmt.Sprintf("ws://127.0.0.1:%d", n.port+2)
+		n.IPC = filepath.Join(n.DataDir, "gero.ipc")
+	}
+	for _, n := range nodes {
+		var peers []string
+		for _, peer := range nodes {
+			if peer != n {
+				peers = append(peers, peer.Enode)
+			}
+		}
+		data, _ := json.MarshalIndent(peers, "", "  ")
+		if err := ioutil.WriteFile(filepath.Join(n.DataDir, clientIdentifier, "static-nodes.json"), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(dir, "genesis.json")
+	if data, err := ioutil.ReadFile(path); err == nil {
+		genesis := new(core.Genesis)
+		if err := json.Unmarshal(data, genesis); err != nil {
+			return fmt.Errorf("invalid genesis file: %v", err)
+		}
+		for _, n := range nodes {
+			if _, ok := genesis.Alloc[devnetAllocAddress(n.account)]; !ok {
+				return fmt.Errorf("account of %s not funded by the existing genesis %s", n.Name, path)
+			}
+		}
+		return nil
+	}
+	genesis := core.DeveloperGenesisBlock()
+	balance := new(big.Int).Mul(new(big.Int).SetUint64(fund), big.NewInt(1e18))
+	for _, n := range nodes {
+		genesis.Alloc[devnetAllocAddress(n.account)] = core.GenesisAccount{Balance: balance}
+	}
+	data, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// writeManifest writes the manifest into devnet.json in the network directory.
+func writeManifest(dir string, manifest *devnetManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "devnet.json"), data, 0644)
+}
+
+// devnetAllocAddress returns the genesis address funding the account, a PKr
+// of its address.
+func devnetAllocAddress(account accounts.Account) common.Address {
+	pkr := keys.Addr2PKr(account.Address.ToUint512(), nil)
+	return common.BytesToAddress(pkr[:])
+}
+
+// devnetArgs returns the command line arguments of the node.
+func devnetArgs(n *devnetNode, genesis string, password string) []string {
+	args := []string{
+		"--" + utils.DeveloperFlag.Name,
+		"--" + utils.DeveloperGenesisFlag.Name, genesis,
+		"--" + utils.DeveloperPasswordFlag.Name, password,
+		"--" + utils.DataDirFlag.Name, n.DataDir,
+		"--" + utils.ListenPortFlag.Name, strconv.Itoa(n.port),
+		"--" + utils.RPCEnabledFlag.Name,
+		"--" + utils.RPCListenAddrFlag.Name, "127.0.0.1",
+		"--" + utils.RPCPortFlag.Name, strconv.Itoa(n.port + 1),
+		"--" + utils.RPCApiFlag.Name, devnetAPIs,
+		"--" + utils.WSEnabledFlag.Name,
+		"--" + utils.WSListenAddrFlag.Name, "127.0.0.1",
+		"--" + utils.WSPortFlag.Name, strconv.Itoa(n.port + 2),
+		"--" + utils.WSApiFlag.Name, devnetAPIs,
+	}
+	if n.hasRole("miner") {
+		args = append(args, "--"+utils.MiningEnabledFlag.Name, "--"+utils.MinerThreadsFlag.Name, "1", "--"+utils.SerobaseFlag.Name, n.Account)
+	}
+	if n.hasRole("exchange") {
+		args = append(args, "--"+utils.ExchangeFlag.Name)
+	}
+	if n.hasRole("light") {
+		args = append(args, "--"+utils.LightNodeFlag.Name)
+	}
+	return args
+}
+
+// startChildNode runs the node in a child process logging into its datadir.
+func startChildNode(n *devnetNode, gero string) error {
+	n.Log = filepath.Join(n.DataDir, "gero.log")
+	logfile, err := os.OpenFile(n.Log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	n.cmd = exec.Command(gero, n.args...)
+	n.cmd.Stdout, n.cmd.Stderr = logfile, logfile
+	if err := n.cmd.Start(); err != nil {
+		logfile.Close()
+		return err
+	}
+	n.Pid = n.cmd.Process.Pid
+	n.exited = make(chan struct{})
+	go func() {
+		n.cmd.Wait()
+		logfile.Close()
+		close(n.exited)
+	}()
+	log.Info("Started devnet node", "name", n.Name, "pid", n.Pid, "log", n.Log)
+	return nil
+}
+
+// startInprocNode runs the node in this process. Only one node can run in a
+// process since the zero state and the exchange are process wide.
+func startInprocNode(n *devnetNode) error {
+	set := flag.NewFlagSet(n.Name, flag.ContinueOnError)
+	for _, f := range app.Flags {
+		f.Apply(set)
+	}
+	if err := set.Parse(n.args); err != nil {
+		return err
+	}
+	ctx := cli.NewContext(app, set, nil)
+	n.stack = makeFullNode(ctx)
+	startNode(ctx, n.stack)
+
+	n.Pid = os.Getpid()
+	n.exited = make(chan struct{})
+	go func() {
+		n.stack.Wait()
+		close(n.exited)
+	}()
+	log.Info("Started devnet node in process", "name", n.Name)
+	return nil
+}
+
+// stopDevnet interrupts the nodes and waits for them to exit.
+func stopDevnet(nodes []*devnetNode) {
+	for _, n := range nodes {
+		switch {
+		case n.cmd != nil:
+			n.cmd.Process.Signal(os.Interrupt)
+		case n.stack != nil:
+			n.stack.Stop()
+		}
+	}
+	for _, n := range nodes {
+		if n.exited == nil {
+			continue
+		}
+		select {
+		case <-n.exited:
+		case <-time.After(30 * time.Second):
+			if n.cmd != nil {
+				n.cmd.Process.Kill()
+			}
+		}
+	}
+}
+
+// waitRPC dials the HTTP-RPC endpoint of the node once it answers.
+func waitRPC(n *devnetNode) (*rpc.Client, error) {
+	var client *rpc.Client
+	err := retry(devnetTimeout, func() error {
+		select {
+		case <-n.exited:
+			return errNodeExited
+		default:
+		}
+		c, err := rpc.Dial(n.HTTP)
+		if err != nil {
+			return err
+		}
+		var version string
+		if err := c.Call(&version, "net_version"); err != nil {
+			c.Close()
+			return err
+		}
+		client = c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s not reachable on %s: %v", n.Name, n.HTTP, err)
+	}
+	return client, nil
+}
+
+var errNodeExited = errors.New("node exited")
+
+// retry calls fn every second until it succeeds, the node exits or the
+// timeout expires.
+func retry(timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil || err == errNodeExited || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// setupStake registers a stake pool of the exchange node voted by the voter
+// node, and buys its shares. It returns the id of the pool.
+func setupStake(client *rpc.Client, exchange *devnetNode, voter *devnetNode, value *big.Int) (common.Hash, error) {
+	fee := hexutil.Uint(seroparam.LOWEST_STAKING_NODE_FEE_RATE)
+	regist := map[string]interface{}{
+		"from":  exchange.Account,
+		"vote":  voter.Account,
+		"fee":   &fee,
+		"value": (*hexutil.Big)(stake.GetPoolValueThreshold()),
+	}
+	// The exchange only spends the outputs of confirmed blocks, so the
+	// transaction is rejected until the genesis funds are confirmed
+	var hash common.Hash
+	err := retry(devnetTimeout, func() error {
+		return client.Call(&hash, "stake_registStakePool", regist)
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("stake pool registration failed: %v", err)
+	}
+	if err := waitReceipt(client, hash); err != nil {
+		return common.Hash{}, err
+	}
+	var pools []struct {
+		Id common.Hash `json:"id"`
+		Tx common.Hash `json:"tx"`
+	}
+	if err := client.Call(&pools, "stake_stakePools"); err != nil {
+		return common.Hash{}, err
+	}
+	var pool *common.Hash
+	for i := range pools {
+		if pools[i].Tx == hash {
+			pool = &pools[i].Id
+		}
+	}
+	if pool == nil {
+		return common.Hash{}, fmt.Errorf("stake pool of transaction %s not found", hash.Hex())
+	}
+	log.Info("Registered devnet stake pool", "id", pool.Hex())
+
+	buy := map[string]interface{}{
+		"from":  exchange.Account,
+		"vote":  voter.Account,
+		"pool":  hexutil.Bytes(pool[:]),
+		"value": (*hexutil.Big)(value),
+	}
+	err = retry(devnetTimeout, func() error {
+		return client.Call(&hash, "stake_buyShare", buy)
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("share purchase failed: %v", err)
+	}
+	if err := waitReceipt(client, hash); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Bought devnet stake pool shares", "tx", hash.Hex())
+	return *pool, nil
+}
+
+// waitReceipt waits for the transaction to be mined successfully.
+func waitReceipt(client *rpc.Client, hash common.Hash) error {
+	deadline := time.Now().Add(devnetTimeout)
+	for time.Now().Before(deadline) {
+		var receipt *struct {
+			Status hexutil.Uint `json:"status"`
+		}
+		if err := client.Call(&receipt, "sero_getTransactionReceipt", hash); err == nil && receipt != nil {
+			if uint64(receipt.Status) != types.ReceiptStatusSuccessful {
+				return fmt.Errorf("transaction %s failed", hash.Hex())
+			}
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("transaction %s not mined after %v", hash.Hex(), devnetTimeout)
+}
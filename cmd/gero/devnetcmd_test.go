@@ -0,0 +1,142 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"github.com/sero-cash/go-sero/core"
+)
+
+func newDevnetNodes() []*devnetNode {
+	return []*devnetNode{
+		{Name: "node0", Roles: []string{"miner", "voter"}, port: 31000},
+		{Name: "node1", Roles: []string{"exchange"}, port: 31003},
+	}
+}
+
+func TestSetupDevnet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gero-devnet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nodes := newDevnetNodes()
+	if err := setupDevnet(dir, nodes, "secret", 10); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	genesisPath := filepath.Join(dir, "genesis.json")
+	manifest := &devnetManifest{Genesis: genesisPath, NetworkId: 1024, Password: "secret", Nodes: nodes}
+	if err := writeManifest(dir, manifest); err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	// The manifest lists the endpoints of every node
+	data, err := ioutil.ReadFile(filepath.Join(dir, "devnet.json"))
+	if err != nil {
+		t.Fatalf("manifest not found: %v", err)
+	}
+	var read devnetManifest
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if read.Genesis != genesisPath || read.Password != "secret" || len(read.Nodes) != 2 {
+		t.Fatalf("manifest mismatch: %+v", read)
+	}
+	for i, n := range read.Nodes {
+		if n.DataDir != filepath.Join(dir, nodes[i].Name) || n.Account == "" || !strings.HasPrefix(n.Enode, "snode://") {
+			t.Errorf("node %d mismatch: %+v", i, n)
+		}
+		if n.HTTP != [2]string{"http://127.0.0.1:31001", "http://127.0.0.1:31004"}[i] {
+			t.Errorf("node %d HTTP endpoint mismatch: %s", i, n.HTTP)
+		}
+	}
+
+	// The genesis funds every account
+	data, err = ioutil.ReadFile(genesisPath)
+	if err != nil {
+		t.Fatalf("genesis not found: %v", err)
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		t.Fatalf("invalid genesis: %v", err)
+	}
+	want := new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18))
+	for _, n := range nodes {
+		if alloc, ok := genesis.Alloc[devnetAllocAddress(n.account)]; !ok || alloc.Balance.Cmp(want) != 0 {
+			t.Errorf("%s not funded: %v", n.Name, alloc.Balance)
+		}
+	}
+
+	// Each node has the other one as static peer
+	for i, n := range nodes {
+		var peers []string
+		data, _ := ioutil.ReadFile(filepath.Join(n.DataDir, clientIdentifier, "static-nodes.json"))
+		if err := json.Unmarshal(data, &peers); err != nil || len(peers) != 1 || peers[0] != nodes[1-i].Enode {
+			t.Errorf("%s static peers mismatch: %v, %v", n.Name, peers, err)
+		}
+	}
+
+	// A restarted network reuses the accounts, the keys and the genesis
+	restarted := newDevnetNodes()
+	if err := setupDevnet(dir, restarted, "secret", 20); err != nil {
+		t.Fatalf("restart failed: %v", err)
+	}
+	for i, n := range restarted {
+		if n.Account != nodes[i].Account || n.Enode != nodes[i].Enode {
+			t.Errorf("%s not reused: have %s, want %s", n.Name, n.Account, nodes[i].Account)
+		}
+	}
+	if reread, _ := ioutil.ReadFile(genesisPath); string(reread) != string(data) {
+		t.Errorf("genesis rewritten on restart")
+	}
+}
+
+func TestDevnetArgs(t *testing.T) {
+	nodes := newDevnetNodes()
+	for _, n := range nodes {
+		n.DataDir, n.Account = "/devnet/"+n.Name, "account-"+n.Name
+	}
+	args := strings.Join(devnetArgs(nodes[0], "/devnet/genesis.json", "secret"), " ")
+	for _, want := range []string{
+		"--" + utils.DeveloperGenesisFlag.Name + " /devnet/genesis.json",
+		"--" + utils.DataDirFlag.Name + " /devnet/node0",
+		"--" + utils.ListenPortFlag.Name + " 31000",
+		"--" + utils.RPCPortFlag.Name + " 31001",
+		"--" + utils.WSPortFlag.Name + " 31002",
+		"--" + utils.SerobaseFlag.Name + " account-node0",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("miner arguments %q miss %q", args, want)
+		}
+	}
+	if strings.Contains(args, "--"+utils.ExchangeFlag.Name) {
+		t.Errorf("miner arguments %q run the exchange", args)
+	}
+	args = strings.Join(devnetArgs(nodes[1], "/devnet/genesis.json", "secret"), " ")
+	if !strings.Contains(args, "--"+utils.ExchangeFlag.Name) || strings.Contains(args, "--"+utils.MiningEnabledFlag.Name) {
+		t.Errorf("exchange arguments mismatch: %q", args)
+	}
+}
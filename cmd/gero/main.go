@@ -87,6 +87,8 @@ var (
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
+		utils.CheckpointFileFlag,
+		utils.CheckpointSignersFlag,
 		utils.MiningModeFlag,
 		utils.GCModeFlag,
 		utils.CacheFlag,
@@ -103,14 +105,18 @@ var (
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
+		utils.StratumAddrFlag,
+		utils.StratumDifficultyFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperPasswordFlag,
+		utils.DeveloperGenesisFlag,
 		utils.AlphanetFlag,
 		//utils.RinkebyFlag,
 		utils.ExchangeFlag,
@@ -118,11 +124,14 @@ var (
 		utils.AutoMergeFlag,
 		utils.ConfirmedBlockFlag,
 		utils.LightNodeFlag,
+		utils.LightServFlag,
+		utils.LightPeersFlag,
 		utils.ResetBlockNumber,
 
 		utils.DeveloperFlag,
 		utils.SnapshotFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMReceiptErrorsFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
@@ -152,9 +161,16 @@ var (
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.GraphQLEnabledFlag,
+		utils.GraphQLListenAddrFlag,
+		utils.GraphQLPortFlag,
+		utils.GraphQLCORSDomainFlag,
+		utils.GraphQLVirtualHostsFlag,
 	}
 
 	metricsFlags = []cli.Flag{
+		utils.MetricsHTTPFlag,
+		utils.MetricsPortFlag,
 		utils.MetricsEnableInfluxDBFlag,
 		utils.MetricsInfluxDBEndpointFlag,
 		utils.MetricsInfluxDBDatabaseFlag,
@@ -178,6 +194,8 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		//dumpCommand,
+		// See snapshotcmd.go:
+		snapshotCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -195,6 +213,8 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See devnetcmd.go:
+		devnetCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -217,7 +237,7 @@ func init() {
 			switch {
 			case ctx.GlobalBool(utils.AlphanetFlag.Name):
 				netType = cpt.NET_Alpha
-			case ctx.GlobalBool(utils.DeveloperFlag.Name):
+			case ctx.GlobalBool(utils.DeveloperFlag.Name), strings.EqualFold(subCommandName, "devnet"):
 				netType = cpt.NET_Dev
 			}
 			cpt.ZeroInit(getKeyStore(ctx), netType)
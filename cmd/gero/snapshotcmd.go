@@ -0,0 +1,151 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/snapshot"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotHashFlag = cli.StringFlag{
+		Name:  "snapshot.hash",
+		Usage: "Trusted hash of the snapshot block, needed unless the block is a checkpoint",
+	}
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Export or import the state of a block",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+A snapshot holds the state trie, the zstate, pkgstate and stake state of one
+block. A new node imports it and starts syncing from that block.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export the state of the current block into a file",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Writes the state of the current block and prints the content hash of the file.
+The zstate and stake data in the database are not kept per block, so no block
+older than the head can be exported. If the file ends with .gz the output is gzipped.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a state snapshot into an empty chain",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(importSnapshot),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					snapshotHashFlag,
+				},
+				Description: `
+Verifies the snapshot against the state root of its header and makes its block
+the head of the local chain. Only a chain holding just the genesis block can
+import a snapshot. The block has to be a checkpoint of the network or match the
+hash given with --snapshot.hash.`,
+			},
+		},
+	}
+)
+
+func exportSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.CurrentBlock()
+	td := chain.GetTd(block.Hash(), block.NumberU64())
+
+	fn := ctx.Args().First()
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+
+	start := time.Now()
+	hash, err := snapshot.Export(chainDb.(*serodb.LDBDatabase), block, td, writer)
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Exported block %v (%x) in %v\n", block.NumberU64(), block.Hash(), time.Since(start))
+	fmt.Printf("Content hash: %x\n", hash)
+	return nil
+}
+
+func importSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	if head := chain.CurrentBlock(); head.NumberU64() > 0 {
+		utils.Fatalf("Import error: chain is not empty, head is %v\n", head.NumberU64())
+	}
+	chain.Stop()
+
+	fn := ctx.Args().First()
+	fh, err := os.Open(fn)
+	if err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			utils.Fatalf("Import error: %v\n", err)
+		}
+	}
+
+	start := time.Now()
+	trusted := common.HexToHash(ctx.String(snapshotHashFlag.Name))
+	block, hash, err := snapshot.Import(chainDb, reader, trusted)
+	if err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Imported block %v (%x) in %v\n", block.NumberU64(), block.Hash(), time.Since(start))
+	fmt.Printf("Content hash: %x\n", hash)
+	return nil
+}
@@ -74,14 +74,24 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.AlphanetFlag,
 			utils.DeveloperFlag,
 			utils.SyncModeFlag,
+			utils.CheckpointFileFlag,
+			utils.CheckpointSignersFlag,
 			utils.SeroStatsURLFlag,
 			utils.IdentityFlag,
 		},
 	},
+	{
+		Name: "LIGHT CLIENT",
+		Flags: []cli.Flag{
+			utils.LightServFlag,
+			utils.LightPeersFlag,
+		},
+	},
 	{
 		Name: "DEVELOPER CHAIN",
 		Flags: []cli.Flag{
 			utils.DeveloperPeriodFlag,
+			utils.DeveloperGenesisFlag,
 		},
 	},
 	{
@@ -142,6 +152,11 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.GraphQLEnabledFlag,
+			utils.GraphQLListenAddrFlag,
+			utils.GraphQLPortFlag,
+			utils.GraphQLCORSDomainFlag,
+			utils.GraphQLVirtualHostsFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -159,6 +174,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DNSDiscoveryFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -173,6 +189,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.StratumAddrFlag,
+			utils.StratumDifficultyFlag,
 		},
 	},
 	{
@@ -186,6 +204,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMReceiptErrorsFlag,
 		},
 	},
 	{
@@ -199,6 +218,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "METRICS AND STATS",
 		Flags: []cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsHTTPFlag,
+			utils.MetricsPortFlag,
 			utils.MetricsEnableInfluxDBFlag,
 			utils.MetricsInfluxDBEndpointFlag,
 			utils.MetricsInfluxDBDatabaseFlag,
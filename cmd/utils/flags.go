@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -40,6 +41,7 @@ import (
 	"github.com/sero-cash/go-sero/accounts/keystore"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/common/fdlimit"
+	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/consensus"
 
 	"github.com/sero-cash/go-sero/consensus/ethash"
@@ -48,8 +50,10 @@ import (
 	"github.com/sero-cash/go-sero/core/vm"
 	"github.com/sero-cash/go-sero/crypto"
 	"github.com/sero-cash/go-sero/dashboard"
+	"github.com/sero-cash/go-sero/graphql"
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/metrics"
+	"github.com/sero-cash/go-sero/metrics/exp"
 	"github.com/sero-cash/go-sero/metrics/influxdb"
 	"github.com/sero-cash/go-sero/node"
 	"github.com/sero-cash/go-sero/p2p"
@@ -61,7 +65,9 @@ import (
 	"github.com/sero-cash/go-sero/sero"
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/gasprice"
+	"github.com/sero-cash/go-sero/sero/zlight"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -158,6 +164,10 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperGenesisFlag = cli.StringFlag{
+		Name:  "dev.genesis",
+		Usage: "Genesis file to use in developer mode instead of the built-in one",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -170,7 +180,7 @@ var (
 	defaultSyncMode = sero.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "snap", "full", or "light")`,
 		Value: &defaultSyncMode,
 	}
 	// Dashboard settings
@@ -198,6 +208,14 @@ var (
 		Usage: "Dashboard metrics collection refresh rate",
 		Value: dashboard.DefaultConfig.Refresh,
 	}
+	CheckpointFileFlag = cli.StringFlag{
+		Name:  "checkpoint.file",
+		Usage: "Signed checkpoint file to trust in addition to the built-in checkpoints",
+	}
+	CheckpointSignersFlag = cli.StringFlag{
+		Name:  "checkpoint.signers",
+		Usage: "Comma separated hex public keys trusted to sign checkpoint files",
+	}
 	// Ethash settings
 	EthashCacheDirFlag = DirectoryFlag{
 		Name:  "ethash.cachedir",
@@ -315,6 +333,16 @@ var (
 		Name:  "lightNode",
 		Usage: "start light node",
 	}
+	LightServFlag = cli.IntFlag{
+		Name:  "lightserv",
+		Usage: "Serve the zero wallets of the light clients over the zlight protocol (0 = disabled)",
+		Value: 0,
+	}
+	LightPeersFlag = cli.IntFlag{
+		Name:  "lightpeers",
+		Usage: "Maximum number of light clients to serve, or light servers to connect",
+		Value: sero.DefaultConfig.LightPeers,
+	}
 
 	ConfirmedBlockFlag = cli.Uint64Flag{
 		Name:  "confirmedBlock",
@@ -359,6 +387,15 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	StratumAddrFlag = cli.StringFlag{
+		Name:  "stratum.addr",
+		Usage: "Stratum mining server listening address (disabled if empty)",
+	}
+	StratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "stratum.difficulty",
+		Usage: "Initial share difficulty of the stratum workers",
+		Value: sero.DefaultConfig.Stratum.InitialDifficulty,
+	}
 	// AccountAddress settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -375,6 +412,10 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMReceiptErrorsFlag = cli.BoolFlag{
+		Name:  "vmerrors",
+		Usage: "Store the revert data and the VM errors of the failed transactions with the receipts (unreadable by older versions)",
+	}
 	// Logging and debug settings
 	SeroStatsURLFlag = cli.StringFlag{
 		Name:  "serostats",
@@ -477,6 +518,30 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL server",
+	}
+	GraphQLListenAddrFlag = cli.StringFlag{
+		Name:  "graphqladdr",
+		Usage: "GraphQL server listening interface",
+		Value: node.DefaultGraphQLHost,
+	}
+	GraphQLPortFlag = cli.IntFlag{
+		Name:  "graphqlport",
+		Usage: "GraphQL server listening port",
+		Value: node.DefaultGraphQLPort,
+	}
+	GraphQLCORSDomainFlag = cli.StringFlag{
+		Name:  "graphqlcorsdomain",
+		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Value: "",
+	}
+	GraphQLVirtualHostsFlag = cli.StringFlag{
+		Name:  "graphqlvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -538,6 +603,10 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of the DNS discovery trees to find peers in",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -566,6 +635,20 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	// MetricsHTTPFlag defines the endpoint for a stand-alone metrics HTTP endpoint.
+	// Since the pprof service enables sensitive/vulnerable behavior, this allows a user
+	// to enable a public-OK metrics endpoint without having to worry about ALSO exposing
+	// other profiling behavior or information.
+	MetricsHTTPFlag = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Enable stand-alone metrics HTTP server listening interface",
+		Value: "",
+	}
+	MetricsPortFlag = cli.IntFlag{
+		Name:  "metrics.port",
+		Usage: "Metrics HTTP server listening port",
+		Value: 6061,
+	}
 	MetricsEnableInfluxDBFlag = cli.BoolFlag{
 		Name:  "metrics.influxdb",
 		Usage: "Enable metrics export/push to an external InfluxDB database",
@@ -893,34 +976,23 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.MaxPeers = ctx.GlobalInt(MaxPeersFlag.Name)
 	}
 
-	log.Info("Maximum peer count", "SERO", cfg.MaxPeers)
-
-	//lightClient := ctx.GlobalBool(LightModeFlag.Name) || ctx.GlobalString(SyncModeFlag.Name) == "light"
-	////lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
-	//lightPeers := ctx.GlobalInt(LightPeersFlag.Name)
-	//
-	//if ctx.GlobalIsSet(MaxPeersFlag.Name) {
-	//	cfg.MaxPeers = ctx.GlobalInt(MaxPeersFlag.Name)
-	//	//if lightServer && !ctx.GlobalIsSet(LightPeersFlag.Name) {
-	//	//	cfg.MaxPeers += lightPeers
-	//	//}
-	//} else {
-	//	//if lightServer {
-	//	//	cfg.MaxPeers += lightPeers
-	//	//}
-	//	if lightClient && ctx.GlobalIsSet(LightPeersFlag.Name) && cfg.MaxPeers < lightPeers {
-	//		cfg.MaxPeers = lightPeers
-	//	}
-	//}
-	////if !(lightClient || lightServer) {
-	//if !(lightClient) {
-	//	lightPeers = 0
-	//}
-	//ethPeers := cfg.MaxPeers - lightPeers
-	//if lightClient {
-	//	ethPeers = 0
-	//}
-	//log.Info("Maximum peer count", "ETH", ethPeers, "LES", lightPeers, "total", cfg.MaxPeers)
+	lightClient := ctx.GlobalString(SyncModeFlag.Name) == "light"
+	lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
+	lightPeers := ctx.GlobalInt(LightPeersFlag.Name)
+
+	// The light clients are served on top of the full node peers, unless the
+	// peer count is set explicitly
+	if lightServer && !ctx.GlobalIsSet(MaxPeersFlag.Name) {
+		cfg.MaxPeers += lightPeers
+	}
+	if lightClient && ctx.GlobalIsSet(LightPeersFlag.Name) && cfg.MaxPeers < lightPeers {
+		cfg.MaxPeers = lightPeers
+	}
+	if lightServer {
+		log.Info("Maximum peer count", "SERO", cfg.MaxPeers-lightPeers, "ZLIGHT", lightPeers, "total", cfg.MaxPeers)
+	} else {
+		log.Info("Maximum peer count", "SERO", cfg.MaxPeers)
+	}
 
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
@@ -939,6 +1011,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if urls := ctx.GlobalString(DNSDiscoveryFlag.Name); urls != "" {
+		cfg.DNSDiscovery = strings.Split(urls, ",")
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -963,7 +1038,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
 		//cfg.MaxPeers = 0
-		cfg.ListenAddr = ":0"
+		if !ctx.GlobalIsSet(ListenPortFlag.Name) {
+			cfg.ListenAddr = ":0"
+		}
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
 		seroparam.Init_Dev(true)
@@ -1005,6 +1082,29 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	}
 }
 
+// setCheckpoints loads the signed checkpoint file given on the command line.
+func setCheckpoints(ctx *cli.Context) {
+	path := ctx.GlobalString(CheckpointFileFlag.Name)
+	if path == "" {
+		return
+	}
+	signers := [][]byte{}
+	pubs := append([]string{}, params.CheckpointSigners...)
+	if ctx.GlobalIsSet(CheckpointSignersFlag.Name) {
+		pubs = append(pubs, strings.Split(ctx.GlobalString(CheckpointSignersFlag.Name), ",")...)
+	}
+	for _, pub := range pubs {
+		signer, err := hexutil.Decode(strings.TrimSpace(pub))
+		if err != nil {
+			Fatalf("Invalid checkpoint signer %q: %v", pub, err)
+		}
+		signers = append(signers, signer)
+	}
+	if _, err := checkpoint.LoadFile(path, signers); err != nil {
+		Fatalf("Failed to load checkpoint file %s: %v", path, err)
+	}
+}
+
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
@@ -1099,6 +1199,7 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
+	setCheckpoints(ctx)
 
 	cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
@@ -1134,10 +1235,19 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(StratumAddrFlag.Name) {
+		cfg.Stratum.Addr = ctx.GlobalString(StratumAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumDifficultyFlag.Name) {
+		cfg.Stratum.InitialDifficulty = ctx.GlobalUint64(StratumDifficultyFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMReceiptErrorsFlag.Name) {
+		cfg.ReceiptErrors = ctx.GlobalBool(VMReceiptErrorsFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(MiningModeFlag.Name) {
 		cfg.MineMode = true
@@ -1158,6 +1268,12 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 	if ctx.GlobalIsSet(LightNodeFlag.Name) {
 		cfg.StartLight = true
 	}
+	if ctx.GlobalIsSet(LightServFlag.Name) {
+		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightPeersFlag.Name) {
+		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1171,7 +1287,7 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 			cfg.NetworkId = 1024
 		}
 
-		cfg.Genesis = core.DeveloperGenesisBlock()
+		cfg.Genesis = MakeDeveloperGenesis(ctx)
 	}
 	// TODO(fjl): move trie cache generations into config
 	if gen := ctx.GlobalInt(TrieCacheGenFlag.Name); gen > 0 {
@@ -1189,7 +1305,13 @@ func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
 // RegisterEthService adds an Sero client to the stack.
 func RegisterEthService(stack *node.Node, cfg *sero.Config) {
 	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		if cfg.SyncMode == downloader.LightSync {
+			return zlight.New(ctx, cfg)
+		}
 		fullNode, err := sero.New(ctx, cfg)
+		if fullNode != nil && cfg.LightServ > 0 {
+			fullNode.AddLesServer(zlight.NewServer(fullNode, cfg))
+		}
 		return fullNode, err
 	})
 	if err != nil {
@@ -1204,6 +1326,26 @@ func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config, commit st
 	})
 }
 
+// RegisterGraphQLService adds a GraphQL server over the Sero service to the
+// stack, configured by the command line flags.
+func RegisterGraphQLService(ctx *cli.Context, stack *node.Node, timeouts rpc.HTTPTimeouts) {
+	var (
+		endpoint = fmt.Sprintf("%s:%d", ctx.GlobalString(GraphQLListenAddrFlag.Name), ctx.GlobalInt(GraphQLPortFlag.Name))
+		cors     = splitAndTrim(ctx.GlobalString(GraphQLCORSDomainFlag.Name))
+		vhosts   = splitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
+	)
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var seroServ *sero.Sero
+		if err := ctx.Service(&seroServ); err != nil {
+			return nil, fmt.Errorf("GraphQL requires a full node: %v", err)
+		}
+		return graphql.New(seroServ.APIBackend, endpoint, cors, vhosts, timeouts, ctx.RPCAuthenticator(), ctx.RPCRateLimiter())
+	})
+	if err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -1228,6 +1370,12 @@ func SetupMetrics(ctx *cli.Context) {
 				"host": hosttag,
 			})
 		}
+
+		if ctx.GlobalString(MetricsHTTPFlag.Name) != "" {
+			address := fmt.Sprintf("%s:%d", ctx.GlobalString(MetricsHTTPFlag.Name), ctx.GlobalInt(MetricsPortFlag.Name))
+			log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
+			exp.Setup(address)
+		}
 	}
 }
 
@@ -1251,7 +1399,27 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 	case ctx.GlobalBool(AlphanetFlag.Name):
 		genesis = core.DefaultAlphanetGenesisBlock()
 	case ctx.GlobalBool(DeveloperFlag.Name):
-		genesis = core.DeveloperGenesisBlock()
+		genesis = MakeDeveloperGenesis(ctx)
+	}
+	return genesis
+}
+
+// MakeDeveloperGenesis returns the genesis of the developer network, loaded
+// from the file given with --dev.genesis if any.
+func MakeDeveloperGenesis(ctx *cli.Context) *core.Genesis {
+	path := ctx.GlobalString(DeveloperGenesisFlag.Name)
+	if path == "" {
+		return core.DeveloperGenesisBlock()
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		Fatalf("Failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		Fatalf("Invalid genesis file: %v", err)
 	}
 	return genesis
 }
@@ -1260,6 +1428,7 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb serodb.Database) {
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack)
+	setCheckpoints(ctx)
 
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
@@ -1289,7 +1458,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
+	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name), ReceiptErrors: ctx.GlobalBool(VMReceiptErrorsFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -112,6 +112,17 @@ func (h Hash) MarshalText() ([]byte, error) {
 	return hexutil.Bytes(h[:]).MarshalText()
 }
 
+// ImplementsGraphQLType returns true if Hash implements the specified GraphQL type.
+func (Hash) ImplementsGraphQLType(name string) bool { return name == "Bytes32" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (h *Hash) UnmarshalGraphQL(input interface{}) error {
+	if input, ok := input.(string); ok {
+		return h.UnmarshalText([]byte(input))
+	}
+	return fmt.Errorf("unexpected type for Bytes32: %v", input)
+}
+
 // SetBytes sets the hash to the value of b.
 // If b is larger than len(h), b will be cropped from the left.
 func (h *Hash) SetBytes(b []byte) {
@@ -335,6 +346,17 @@ func (a *Address) UnmarshalJSON(input []byte) error {
 	}
 }
 
+// ImplementsGraphQLType returns true if Address implements the specified GraphQL type.
+func (Address) ImplementsGraphQLType(name string) bool { return name == "Address" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (a *Address) UnmarshalGraphQL(input interface{}) error {
+	if input, ok := input.(string); ok {
+		return a.UnmarshalText([]byte(input))
+	}
+	return fmt.Errorf("unexpected type for Address: %v", input)
+}
+
 // Scan implements Scanner for database/sql.
 func (a *Address) Scan(src interface{}) error {
 	srcB, ok := src.([]byte)
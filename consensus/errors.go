@@ -34,4 +34,12 @@ var (
 	// ErrInvalidNumber is returned if a block's number doesn't equal it's parent's
 	// plus one.
 	ErrInvalidNumber = errors.New("invalid block number")
+
+	// ErrInvalidPoW is returned if the proof-of-work of a block doesn't meet its
+	// difficulty.
+	ErrInvalidPoW = errors.New("invalid proof-of-work")
+
+	// ErrInvalidMixDigest is returned if the mix digest of a block doesn't match
+	// its proof-of-work.
+	ErrInvalidMixDigest = errors.New("invalid mix digest")
 )
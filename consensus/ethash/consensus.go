@@ -54,8 +54,6 @@ var (
 var (
 	errZeroBlockTime     = errors.New("timestamp equals parent's")
 	errInvalidDifficulty = errors.New("non-positive difficulty")
-	errInvalidMixDigest  = errors.New("invalid mix digest")
-	errInvalidPoW        = errors.New("invalid proof-of-work")
 )
 
 // Author implements consensus.Engine, returning the header's coinbase as the
@@ -329,7 +327,7 @@ func (ethash *Ethash) VerifySeal(chain consensus.ChainReader, header *types.Head
 	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
 		time.Sleep(ethash.fakeDelay)
 		if ethash.fakeFail == header.Number.Uint64() {
-			return errInvalidPoW
+			return consensus.ErrInvalidPoW
 		}
 		return nil
 	}
@@ -342,38 +340,69 @@ func (ethash *Ethash) VerifySeal(chain consensus.ChainReader, header *types.Head
 		return errInvalidDifficulty
 	}
 	// Recompute the digest and PoW value and verify against the header
-	number := header.Number.Uint64()
+	digest, result := ethash.compute(header.Number.Uint64(), header.HashPow().Bytes(), header.Nonce.Uint64())
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return consensus.ErrInvalidMixDigest
+	}
+	target := new(big.Int).Div(maxUint256, header.ActualDifficulty())
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return consensus.ErrInvalidPoW
+	}
+	return nil
+}
 
+// compute returns the mix digest and the PoW value of a nonce.
+func (ethash *Ethash) compute(number uint64, hash []byte, nonce uint64) (digest []byte, result []byte) {
 	cache := ethash.cache(number)
 	size := datasetSize(number)
 	if ethash.config.PowMode == ModeTest {
 		size = 32 * 1024
 	}
 
-	var digest []byte
-	var result []byte
 	if number >= seroparam.SIP3() {
 		//dataset := ethash.dataset_async(number)
 		//if dataset.generated() {
-		//	digest, result = progpowFull(dataset.dataset, header.HashPow().Bytes(), header.Nonce.Uint64(), number)
+		//	digest, result = progpowFull(dataset.dataset, hash, nonce, number)
 		//} else {
-		digest, result = progpowLightWithoutCDag(size, cache.cache, cache.cdag, header.HashPow().Bytes(), header.Nonce.Uint64(), number)
+		digest, result = progpowLightWithoutCDag(size, cache.cache, cache.cdag, hash, nonce, number)
 		//}
 	} else {
-		digest, result = hashimotoLight(size, cache.cache, header.HashPow().Bytes(), header.Nonce.Uint64(), number)
+		digest, result = hashimotoLight(size, cache.cache, hash, nonce, number)
 	}
 	// Caches are unmapped in a finalizer. Ensure that the cache stays live
 	// until after the call to hashimotoLight so it's not unmapped while being used.
 	runtime.KeepAlive(cache)
+	return
+}
 
-	if !bytes.Equal(header.MixDigest[:], digest) {
-		return errInvalidMixDigest
+// Compute returns the mix digest and the PoW value of a nonce for the header
+// with the given pow hash, as computed by an external miner.
+func (ethash *Ethash) Compute(number uint64, powHash common.Hash, nonce uint64) (common.Hash, *big.Int) {
+	if ethash.shared != nil {
+		return ethash.shared.Compute(number, powHash, nonce)
 	}
-	target := new(big.Int).Div(maxUint256, header.ActualDifficulty())
-	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
-		return errInvalidPoW
+	digest, result := ethash.compute(number, powHash.Bytes(), nonce)
+	return common.BytesToHash(digest), new(big.Int).SetBytes(result)
+}
+
+// VerifyShare checks the seal of header against a share difficulty lower than
+// the block difficulty, used by pools. It reports whether the seal is also
+// valid for the block.
+func (ethash *Ethash) VerifyShare(header *types.Header, difficulty *big.Int) (bool, error) {
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
+		return ethash.VerifySeal(nil, header) == nil, nil
 	}
-	return nil
+	if difficulty.Sign() <= 0 {
+		return false, errInvalidDifficulty
+	}
+	digest, result := ethash.Compute(header.Number.Uint64(), header.HashPow(), header.Nonce.Uint64())
+	if header.MixDigest != digest {
+		return false, consensus.ErrInvalidMixDigest
+	}
+	if result.Cmp(new(big.Int).Div(maxUint256, difficulty)) > 0 {
+		return false, consensus.ErrInvalidPoW
+	}
+	return result.Cmp(new(big.Int).Div(maxUint256, header.ActualDifficulty())) <= 0, nil
 }
 
 // Prepare implements consensus.Engine, initializing the difficulty field of a
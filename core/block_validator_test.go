@@ -32,7 +32,7 @@ import (
 // Tests that simple header verification works, for both good and bad blocks.
 func TestHeaderVerification(t *testing.T) {
 	// Create a simple chain to verify
-	cpt.ZeroInit(cpt.NET_Alpha)
+	cpt.ZeroInit("", cpt.NET_Alpha)
 	var (
 		testdb    = serodb.NewMemDatabase()
 		gspec     = &Genesis{Config: params.TestChainConfig}
@@ -74,7 +74,9 @@ func TestHeaderVerification(t *testing.T) {
 			case <-time.After(25 * time.Millisecond):
 			}
 		}
-		chain.InsertChain(blocks[i : i+1])
+		if _, err := chain.InsertChain(blocks[i : i+1]); err != nil {
+			t.Fatalf("block %d: insert failed: %v", i, err)
+		}
 	}
 }
 
@@ -204,6 +204,7 @@ func NewBlockChain(db serodb.Database, cacheConfig *CacheConfig, chainConfig *pa
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	stake.CountStakePools(db)
 	// Take ownership of this particular state
 	go bc.update()
 
@@ -443,6 +444,15 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	if _, err := trie.NewSecure(block.Root(), bc.stateCache.TrieDB(), 0); err != nil {
 		return err
 	}
+	if checkpoints := bc.hc.Checkpoints(); checkpoints.Get(block.NumberU64()) != nil {
+		statedb, err := state.New(bc.stateCache, block.Header())
+		if err != nil {
+			return err
+		}
+		if err := checkpoints.VerifyStake(block.NumberU64(), stake.NewStakeState(statedb).StateHash()); err != nil {
+			return err
+		}
+	}
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock.Store(block)
@@ -756,6 +766,12 @@ func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
 	return bc.stateCache.TrieDB().Node(hash)
 }
 
+// StateCache returns the state database the blockchain imports with, caching
+// the trie nodes of the recent states.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // Stop stops the blockchain service. If any imports are currently in progress
 // it will abort them using the procInterrupt.
 func (bc *BlockChain) Stop() {
@@ -1009,9 +1025,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteBlock(batch, block)
 	blockhash := block.Hash()
 
+	var stakeState *stake.StakeState
 	if block.Header().Number.Uint64() >= seroparam.SIP4() {
 		state.GetStakeCons().Record(block.Header(), batch)
-		stakeState := stake.NewStakeState(state)
+		stakeState = stake.NewStakeState(state)
 		err = stakeState.RecordVotes(batch, block)
 		if err != nil {
 			log.Info("write block with pos", "err", err)
@@ -1086,7 +1103,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		}
 	}
 
-	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	if bc.vmConfig.ReceiptErrors {
+		rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	} else {
+		rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), types.Receipts(receipts).WithoutErrors())
+	}
+	rawdb.WriteIssuances(batch, block.Hash(), block.NumberU64(), state.Issuances())
+	rawdb.WriteSysCalls(batch, block.Hash(), block.NumberU64(), state.SysCalls())
+	rawdb.WriteContractTickets(batch, block.Hash(), block.NumberU64(), state.ContractTickets())
 
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
@@ -1127,6 +1151,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		rawdb.WriteTxLookupEntries(batch, block)
 		rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
 
+		if stakeState != nil {
+			stakeState.ReportMetrics(block.Header())
+		}
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
@@ -1144,6 +1171,24 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	return status, nil
 }
 
+// ContractTickets returns the tickets received by the contracts in the canonical
+// blocks up to the given number, as stored by WriteBlockWithState. It fails
+// naming the first block the tickets were not stored for, that is a block not
+// executed by the node such as a fast synced one, as the tickets received in
+// it are unknown.
+func ContractTickets(db rawdb.DatabaseReader, number uint64) ([]*types.ContractTicket, error) {
+	var tickets []*types.ContractTicket
+	// The genesis block gives no ticket
+	for n := uint64(1); n <= number; n++ {
+		hash := rawdb.ReadCanonicalHash(db, n)
+		if hash == (common.Hash{}) || !rawdb.HasContractTickets(db, hash, n) {
+			return nil, fmt.Errorf("contract tickets unavailable, the tickets received in block %d were not stored", n)
+		}
+		tickets = append(tickets, rawdb.ReadContractTickets(db, hash, n)...)
+	}
+	return tickets, nil
+}
+
 // InsertChain attempts to insert the given batch of blocks in to the canonical
 // chain or, otherwise, create a fork. If an error is returned it will return
 // the index number of the failing block as well an error describing what went
@@ -1210,6 +1255,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks, local bool) (int, []interf
 	tx_abort, tx_results := NewTxChecker(bc, chain)
 	defer close(tx_abort)
 
+	checkpoints := bc.hc.Checkpoints()
+
 	// Iterate over the blocks and insert when the verifier permits
 	for i, block := range chain {
 		// If the chain is terminating, stop processing blocks
@@ -1224,6 +1271,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, local bool) (int, []interf
 		if err == nil {
 			err = bc.Validator().ValidateBody(block)
 		}
+		if err == nil {
+			err = checkpoints.VerifyHeader(block.Header())
+		}
 		switch {
 		case err == ErrKnownBlock:
 			// Block and state both already known. However if the current block is below
@@ -1334,6 +1384,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, local bool) (int, []interf
 		}
 		// Validate the state using the default validator
 		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas)
+		if err == nil && checkpoints.Get(block.NumberU64()) != nil {
+			err = checkpoints.VerifyStake(block.NumberU64(), stake.NewStakeState(state).StateHash())
+		}
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -20,13 +20,30 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-czero-import/seroparam"
+
 	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/consensus"
 	"github.com/sero-cash/go-sero/core/state"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/core/vm"
 	"github.com/sero-cash/go-sero/params"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/flight"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	// Gas and gas price of the zero transactions built by BlockGen.TxParam.
+	chainMakerGas      = 25000
+	chainMakerGasPrice = 1000000000
 )
 
 // BlockGen creates blocks for testing.
@@ -38,10 +55,13 @@ type BlockGen struct {
 	chainReader consensus.ChainReader
 	header      *types.Header
 	statedb     *state.StateDB
+	db          serodb.Database
+	zero        bool
 
-	gasPool  *GasPool
-	txs      []*types.Transaction
-	receipts []*types.Receipt
+	gasPool   *GasPool
+	gasReward uint64
+	txs       []*types.Transaction
+	receipts  []*types.Receipt
 
 	config *params.ChainConfig
 	engine consensus.Engine
@@ -90,14 +110,180 @@ func (b *BlockGen) AddTxWithChain(bc *BlockChain, tx *types.Transaction) {
 		b.SetCoinbase(common.Address{})
 	}
 	b.statedb.Prepare(tx.Hash(), common.Hash{}, len(b.txs))
-	receipt, _, err := ApplyTransaction(b.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
+	receipt, gas, err := ApplyTransaction(b.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
 	if err != nil {
 		panic(err)
 	}
+	b.gasReward += new(big.Int).Mul(new(big.Int).SetUint64(gas), tx.GasPrice()).Uint64()
 	b.txs = append(b.txs, tx)
 	b.receipts = append(b.receipts, receipt)
 }
 
+// In returns the input spending the output of the given root. The output must
+// be in the parent given to GenerateZeroChain or in a block generated before,
+// its witness being anchored in the state of the parent of the generated block.
+func (b *BlockGen) In(root keys.Uint256) txtool.GIn {
+	b.requireZero("In")
+	out := localdb.GetRoot(b.db, &root)
+	if out == nil {
+		panic(fmt.Sprintf("output %s not found", hexutil.Encode(root[:])))
+	}
+	parentState, err := state.New(b.statedb.Database(), b.parent.Header())
+	if err != nil {
+		panic(err)
+	}
+	pos, paths, anchor := parentState.NextZState().State.MTree.GetPaths(*out.OS.RootCM)
+	return txtool.GIn{
+		Out:     txtool.Out{Root: root, State: *out},
+		Witness: txtool.Witness{Pos: hexutil.Uint64(pos), Paths: paths, Anchor: anchor},
+	}
+}
+
+// TxParam returns the parameters of a zero transaction of from spending the
+// outputs of the given roots, creating the given outputs and paying cost to
+// the commands added by the caller. The change of SERO is sent back to from.
+//
+// TxParam panics if an input is confidential or holds another asset than
+// SERO, or if the inputs do not cover the outputs, the cost and the fee.
+func (b *BlockGen) TxParam(from keys.PKr, roots []keys.Uint256, cost *big.Int, outs ...txtool.GOut) *txtool.GTxParam {
+	sero := utils.CurrencyToUint256("SERO")
+	param := &txtool.GTxParam{
+		Gas:      chainMakerGas,
+		GasPrice: big.NewInt(chainMakerGasPrice),
+		From:     txtool.Kr{PKr: from},
+		Outs:     outs,
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(param.Gas), param.GasPrice)
+	param.Fee = assets.Token{Currency: sero, Value: utils.U256(*fee)}
+
+	change := new(big.Int).Neg(new(big.Int).Add(fee, cost))
+	for _, root := range roots {
+		in := b.In(root)
+		out := in.Out.State.OS.Out_O
+		if out == nil || out.Asset.Tkt != nil || out.Asset.Tkn == nil || out.Asset.Tkn.Currency != sero {
+			panic(fmt.Sprintf("output %s is not a plain SERO one", hexutil.Encode(root[:])))
+		}
+		change.Add(change, out.Asset.Tkn.Value.ToInt())
+		param.Ins = append(param.Ins, in)
+	}
+	for _, out := range outs {
+		if out.Asset.Tkn != nil && out.Asset.Tkn.Currency == sero {
+			change.Sub(change, out.Asset.Tkn.Value.ToInt())
+		}
+	}
+	if change.Sign() < 0 {
+		panic(fmt.Sprintf("insufficient funds: missing %v", new(big.Int).Neg(change)))
+	}
+	if change.Sign() > 0 {
+		param.Outs = append(param.Outs, txtool.GOut{
+			PKr:   from,
+			Asset: assets.Asset{Tkn: &assets.Token{Currency: sero, Value: utils.U256(*change)}},
+		})
+	}
+	return param
+}
+
+// AddZeroTx signs the parameters of a zero transaction with the spending key
+// and adds the transaction to the generated block.
+//
+// AddZeroTx panics if the transaction cannot be signed or executed.
+func (b *BlockGen) AddZeroTx(sk *keys.Uint512, param *txtool.GTxParam) *types.Transaction {
+	gtx, err := flight.SignTx(sk, param)
+	if err != nil {
+		panic(err)
+	}
+	tx := types.NewTxWithGTx(param.Gas, param.GasPrice, &gtx.Tx)
+	b.AddTx(tx)
+	return tx
+}
+
+// BuyShare adds a transaction of from buying shares for value, voted by vote
+// or by the stake pool if not nil, and spending the outputs of the given roots.
+func (b *BlockGen) BuyShare(sk *keys.Uint512, from keys.PKr, roots []keys.Uint256, vote keys.PKr, pool *keys.Uint256, value *big.Int) *types.Transaction {
+	param := b.TxParam(from, roots, value)
+	param.Cmds.BuyShare = &stx.BuyShareCmd{Value: utils.U256(*value), Vote: vote, Pool: pool}
+	return b.AddZeroTx(sk, param)
+}
+
+// RegistPool adds a transaction of from registering a stake pool voted by vote
+// at the given fee rate, and spending the outputs of the given roots. The
+// value must be stake.GetPoolValueThreshold.
+func (b *BlockGen) RegistPool(sk *keys.Uint512, from keys.PKr, roots []keys.Uint256, vote keys.PKr, feeRate uint32, value *big.Int) *types.Transaction {
+	param := b.TxParam(from, roots, value)
+	param.Cmds.RegistPool = &stx.RegistPoolCmd{Value: utils.U256(*value), Vote: vote, FeeRate: feeRate}
+	return b.AddZeroTx(sk, param)
+}
+
+// Lottery returns the shares selected to vote for the generated block.
+func (b *BlockGen) Lottery() []*stake.Share {
+	b.requireZero("Lottery")
+	_, shares, err := stake.NewStakeState(b.statedb).SeleteShare(b.header.HashPos())
+	if err != nil {
+		panic(err)
+	}
+	return shares
+}
+
+// AddVote signs with the seed the vote for the generated block of a share it
+// selected, as its stake pool if isPool is set, and adds it into the current
+// votes of the header.
+func (b *BlockGen) AddVote(seed *keys.Uint256, share common.Hash, isPool bool) {
+	hashPos, parentPos := b.header.HashPos(), b.parent.HashPos()
+	b.header.CurrentVotes = append(b.header.CurrentVotes, b.signVote(seed, share, isPool, &hashPos, &parentPos))
+}
+
+// AddParentVote signs with the seed the vote for the parent block of a share
+// it selected, as its stake pool if isPool is set, and adds it into the parent
+// votes of the header.
+func (b *BlockGen) AddParentVote(seed *keys.Uint256, share common.Hash, isPool bool) {
+	grandparent := b.chainReader.GetHeader(b.parent.ParentHash(), b.parent.NumberU64()-1)
+	if grandparent == nil {
+		panic("parent vote of the genesis block")
+	}
+	hashPos, parentPos := b.parent.HashPos(), grandparent.HashPos()
+	b.header.ParentVotes = append(b.header.ParentVotes, b.signVote(seed, share, isPool, &hashPos, &parentPos))
+}
+
+// SetVotes replaces the votes of the header, without checking them.
+func (b *BlockGen) SetVotes(current []types.HeaderVote, parent []types.HeaderVote) {
+	b.header.CurrentVotes = append([]types.HeaderVote{}, current...)
+	b.header.ParentVotes = append([]types.HeaderVote{}, parent...)
+}
+
+func (b *BlockGen) signVote(seed *keys.Uint256, id common.Hash, isPool bool, hashPos *common.Hash, parentPos *common.Hash) types.HeaderVote {
+	b.requireZero("a vote")
+	stakeState := stake.NewStakeState(b.statedb)
+	share := stakeState.GetShare(id)
+	if share == nil {
+		panic(fmt.Sprintf("share %s not found", id.Hex()))
+	}
+	votePKr := share.VotePKr
+	if isPool {
+		if share.PoolId == nil {
+			panic(fmt.Sprintf("share %s has no stake pool", id.Hex()))
+		}
+		pool := stakeState.GetStakePool(*share.PoolId)
+		if pool == nil {
+			panic(fmt.Sprintf("stake pool %s not found", share.PoolId.Hex()))
+		}
+		votePKr = pool.VotePKr
+	}
+	stakeHash := types.StakeHash(hashPos, parentPos, isPool)
+	sign, err := keys.SignPKr(seed, stakeHash.HashToUint256(), &votePKr)
+	if err != nil {
+		panic(err)
+	}
+	return types.HeaderVote{Id: id, IsPool: isPool, Sign: sign}
+}
+
+// requireZero panics if the block is not generated by GenerateZeroChain, the
+// outputs and the stake state of the previous blocks being missing otherwise.
+func (b *BlockGen) requireZero(what string) {
+	if !b.zero {
+		panic(fmt.Sprintf("%s requires a chain generated by GenerateZeroChain", what))
+	}
+}
+
 // Number returns the block number of the block being generated.
 func (b *BlockGen) Number() *big.Int {
 	return new(big.Int).Set(b.header.Number)
@@ -147,8 +333,26 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 //
 // Blocks created by GenerateChain do not contain valid proof of work
 // values. Inserting them into BlockChain requires use of FakePow or
-// a similar non-validating proof of work implementation.
+// a similar non-validating proof of work implementation. As BlockChain
+// does, the share pool is updated before the transactions of the blocks
+// from SIP4 on.
 func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db serodb.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return generateChain(config, parent, engine, db, n, gen, false)
+}
+
+// GenerateZeroChain creates a chain of n blocks as GenerateChain does, the
+// zero transactions, the stake and the votes of the blocks being handled as
+// BlockChain does: the outputs, the stake state and the selected shares of
+// the blocks are recorded into db. The nonce of the blocks is their
+// number, so that each block selects its own shares to vote.
+//
+// The In, TxParam, AddZeroTx, BuyShare, RegistPool, Lottery, AddVote and
+// AddParentVote helpers of BlockGen are only available on such chains.
+func GenerateZeroChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db serodb.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return generateChain(config, parent, engine, db, n, gen, true)
+}
+
+func generateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db serodb.Database, n int, gen func(int, *BlockGen), zero bool) ([]*types.Block, []types.Receipts) {
 	if config == nil {
 		config = params.AlphanetChainConfig
 	}
@@ -159,10 +363,22 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		blockchain, _ := NewBlockChain(db, nil, config, engine, vm.Config{}, nil)
 		defer blockchain.Stop()
 
-		b := &BlockGen{i: i, parent: parent, chain: blocks, chainReader: blockchain, statedb: statedb, config: config, engine: engine}
+		b := &BlockGen{i: i, parent: parent, chain: blocks, chainReader: blockchain, statedb: statedb, db: db, zero: zero, config: config, engine: engine}
+		chainReader := &chainMakerReader{BlockChain: blockchain, blocks: blocks[:i]}
+		if zero {
+			b.chainReader = chainReader
+		}
 		b.header = makeHeader(b.chainReader, parent, statedb, b.engine)
 
 		// Mutate the state and block according to any hard-fork specs
+		if zero {
+			b.header.Nonce = types.EncodeNonce(b.header.Number.Uint64())
+		}
+		if b.header.Number.Uint64() >= seroparam.SIP4() {
+			if err := stake.NewStakeState(statedb).ProcessBeforeApply(chainReader, b.header); err != nil {
+				panic(fmt.Sprintf("stake process error: %v", err))
+			}
+		}
 
 		// Execute any user modifications to the block and finalize it
 		if gen != nil {
@@ -170,8 +386,18 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		}
 
 		if b.engine != nil {
-			block, _ := b.engine.Finalize(b.chainReader, b.header, statedb, b.txs, b.receipts, 0)
+			block, _ := b.engine.Finalize(b.chainReader, b.header, statedb, b.txs, b.receipts, b.gasReward)
 			// Write state changes to db
+			batch := db.NewBatch()
+			if zero {
+				if block.NumberU64() >= seroparam.SIP4() {
+					statedb.GetStakeCons().Record(block.Header(), batch)
+					if err := stake.NewStakeState(statedb).RecordVotes(batch, block); err != nil {
+						panic(fmt.Sprintf("stake write error: %v", err))
+					}
+				}
+				statedb.NextZState().RecordBlock(batch, block.Hash().HashToUint256())
+			}
 			root, err := statedb.Commit(true)
 			if err != nil {
 				panic(fmt.Sprintf("state write error: %v", err))
@@ -179,6 +405,9 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 			if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
 				panic(fmt.Sprintf("trie write error: %v", err))
 			}
+			if err := batch.Write(); err != nil {
+				panic(fmt.Sprintf("block data write error: %v", err))
+			}
 
 			return block, b.receipts
 		}
@@ -219,3 +448,44 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 		Time:     time,
 	}
 }
+
+// chainMakerReader resolves the blocks generated so far besides the ones of
+// the database.
+type chainMakerReader struct {
+	*BlockChain
+	blocks []*types.Block
+}
+
+func (cr *chainMakerReader) GetBlock(hash common.Hash, number uint64) *types.Block {
+	for _, block := range cr.blocks {
+		if block.NumberU64() == number && block.Hash() == hash {
+			return block
+		}
+	}
+	return cr.BlockChain.GetBlock(hash, number)
+}
+
+func (cr *chainMakerReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if block := cr.GetBlock(hash, number); block != nil {
+		return block.Header()
+	}
+	return cr.BlockChain.GetHeader(hash, number)
+}
+
+func (cr *chainMakerReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, block := range cr.blocks {
+		if block.Hash() == hash {
+			return block.Header()
+		}
+	}
+	return cr.BlockChain.GetHeaderByHash(hash)
+}
+
+func (cr *chainMakerReader) GetHeaderByNumber(number uint64) *types.Header {
+	for _, block := range cr.blocks {
+		if block.NumberU64() == number {
+			return block.Header()
+		}
+	}
+	return cr.BlockChain.GetHeaderByNumber(number)
+}
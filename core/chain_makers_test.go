@@ -0,0 +1,178 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-czero-import/seroparam"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+// Tests that GenerateChain keeps the blocks free of any zero bookkeeping.
+func TestGenerateChain(t *testing.T) {
+	cpt.ZeroInit("", cpt.NET_Dev)
+	var (
+		db      = serodb.NewMemDatabase()
+		genesis = (&Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 4, nil)
+	for _, block := range blocks {
+		if block.Nonce() != 0 {
+			t.Errorf("block %d: nonce mismatch: have %d, want 0", block.NumberU64(), block.Nonce())
+		}
+		if localdb.GetBlock(db, block.NumberU64(), block.Hash().HashToUint256()) != nil {
+			t.Errorf("block %d: outputs recorded", block.NumberU64())
+		}
+	}
+}
+
+// Tests that a chain staking and voting with zero transactions can be built
+// by GenerateZeroChain and inserted into a BlockChain.
+func TestGenerateZeroChain(t *testing.T) {
+	cpt.ZeroInit("", cpt.NET_Dev)
+	seroparam.Init_Dev(true)
+	defer seroparam.Init_Dev(false)
+	var (
+		seed   = keys.Uint256{1}
+		sk     = keys.Seed2Sk(&seed)
+		addr   = keys.Seed2Addr(&seed)
+		owner  = keys.Addr2PKr(&addr, &keys.Uint256{1})
+		holder = keys.Addr2PKr(&addr, &keys.Uint256{2})
+		funds  = new(big.Int).Mul(big.NewInt(10000), big.NewInt(1e18))
+		db     = serodb.NewMemDatabase()
+		gspec  = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				common.BytesToAddress(owner[:]):  {Balance: funds},
+				common.BytesToAddress(holder[:]): {Balance: funds},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	roots := localdb.GetBlock(db, 0, genesis.Hash().HashToUint256()).Roots
+	if len(roots) != 2 {
+		t.Fatalf("genesis outputs mismatch: have %d, want 2", len(roots))
+	}
+	rootOf := func(pkr keys.PKr) keys.Uint256 {
+		for _, root := range roots {
+			if out := localdb.GetRoot(db, &root); out != nil && out.OS.Out_O != nil && out.OS.Out_O.Addr == pkr {
+				return root
+			}
+		}
+		t.Fatalf("no genesis output of %s", common.BytesToAddress(pkr[:]).String())
+		return keys.Uint256{}
+	}
+	poolId := common.BytesToHash(crypto.Keccak256(owner[:]))
+
+	votes := 0
+	blocks, receipts := GenerateZeroChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 8, func(i int, b *BlockGen) {
+		switch i {
+		case 0:
+			b.RegistPool(&sk, owner, []keys.Uint256{rootOf(owner)}, owner, seroparam.LOWEST_STAKING_NODE_FEE_RATE, stake.GetPoolValueThreshold())
+			pool := poolId.HashToUint256()
+			b.BuyShare(&sk, holder, []keys.Uint256{rootOf(holder)}, holder, pool, new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18)))
+		default:
+			for j, share := range b.Lottery() {
+				id := common.BytesToHash(share.Id())
+				if j == 0 {
+					b.AddVote(&seed, id, true)
+				} else {
+					b.AddVote(&seed, id, false)
+				}
+				votes++
+			}
+		}
+	})
+	if len(receipts[0]) != 2 {
+		t.Fatalf("receipts mismatch: have %d, want 2", len(receipts[0]))
+	}
+	if id := receipts[0][0].PoolId; id == nil || *id != poolId {
+		t.Fatalf("pool id mismatch: have %v, want %x", id, poolId)
+	}
+	if receipts[0][1].ShareId == nil {
+		t.Fatalf("no share bought")
+	}
+	for i, block := range blocks {
+		if block.Nonce() != block.NumberU64() {
+			t.Errorf("block %d: nonce mismatch: have %d, want %d", i, block.Nonce(), block.NumberU64())
+		}
+	}
+	if votes == 0 {
+		t.Fatalf("no share selected")
+	}
+
+	// Insert the chain into a fresh database
+	chaindb := serodb.NewMemDatabase()
+	gspec.MustCommit(chaindb)
+	chain, _ := NewBlockChain(chaindb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to get head state: %v", err)
+	}
+	stakeState := stake.NewStakeState(statedb)
+	if stakeState.GetStakePool(poolId) == nil {
+		t.Errorf("stake pool %x missing", poolId)
+	}
+	if share := stakeState.GetShare(*receipts[0][1].ShareId); share == nil || share.PoolId == nil || *share.PoolId != poolId {
+		t.Errorf("share %x mismatch: have %v", *receipts[0][1].ShareId, share)
+	}
+}
+
+// Tests that a block given more votes than allowed by SetVotes is rejected.
+func TestGenerateZeroChainBadVotes(t *testing.T) {
+	cpt.ZeroInit("", cpt.NET_Dev)
+	var (
+		db      = serodb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateZeroChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		if i == 1 {
+			votes := make([]types.HeaderVote, stake.MaxVoteCount+1)
+			for j := range votes {
+				votes[j] = types.HeaderVote{Id: common.Hash{byte(j)}}
+			}
+			b.SetVotes(votes, nil)
+		}
+	})
+	chaindb := serodb.NewMemDatabase()
+	gspec.MustCommit(chaindb)
+	chain, _ := NewBlockChain(chaindb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err == nil || n != 1 {
+		t.Fatalf("insert result mismatch: have %d/%v, want 1/error", n, err)
+	}
+}
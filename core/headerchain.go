@@ -35,6 +35,7 @@ import (
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/params"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
 )
 
 const (
@@ -218,6 +219,15 @@ func (hc *HeaderChain) ValidateHeaderChain(chain []*types.Header, checkFreq int)
 		}
 	}
 
+	// Reject chains conflicting with a checkpoint before checking any seal
+	checkpoints := hc.Checkpoints()
+	for i, header := range chain {
+		if err := checkpoints.VerifyHeader(header); err != nil {
+			log.Warn("Header chain conflicts with checkpoint", "number", header.Number, "hash", header.Hash(), "err", err)
+			return i, err
+		}
+	}
+
 	// Generate the list of seal verification requests, and start the parallel verifier
 	seals := make([]bool, len(chain))
 	for i := 0; i < len(seals)/checkFreq; i++ {
@@ -284,6 +294,11 @@ func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, writeHeader WhCa
 	return 0, nil
 }
 
+// Checkpoints returns the checkpoints of the network this chain belongs to.
+func (hc *HeaderChain) Checkpoints() *checkpoint.Set {
+	return checkpoint.ForGenesis(hc.genesisHeader.Hash())
+}
+
 // GetBlockHashesFromHash retrieves a number of block hashes starting at a given
 // hash, fetching towards the genesis block.
 func (hc *HeaderChain) GetBlockHashesFromHash(hash common.Hash, max uint64) []common.Hash {
@@ -0,0 +1,64 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
+)
+
+// Tests that a header chain conflicting with a checkpoint is rejected at the
+// conflicting header, and that the checkpointed one is accepted.
+func TestHeaderChainCheckpoint(t *testing.T) {
+	cpt.ZeroInit("", cpt.NET_Alpha)
+	var (
+		testdb  = serodb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, ExtraData: []byte("checkpoint test")}
+		genesis = gspec.MustCommit(testdb)
+	)
+	canonical, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), testdb, 8, nil)
+	forked, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), testdb, 8, func(i int, b *BlockGen) {
+		b.SetExtra([]byte("fork"))
+	})
+	checkpoint.Register(genesis.Hash(), []params.Checkpoint{{Number: 4, Hash: canonical[3].Hash()}})
+
+	hc, err := NewHeaderChain(testdb, params.TestChainConfig, ethash.NewFaker(), func() bool { return false })
+	if err != nil {
+		t.Fatalf("failed to create header chain: %v", err)
+	}
+	headers := func(blocks []*types.Block) []*types.Header {
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		return headers
+	}
+	if i, err := hc.ValidateHeaderChain(headers(forked), 1); err == nil || !strings.Contains(err.Error(), checkpoint.ErrHashMismatch.Error()) || i != 3 {
+		t.Fatalf("conflicting chain: have %d, %v, want 3, %v", i, err, checkpoint.ErrHashMismatch)
+	}
+	if _, err := hc.ValidateHeaderChain(headers(canonical), 1); err != nil {
+		t.Fatalf("checkpointed chain rejected: %v", err)
+	}
+}
@@ -324,6 +324,133 @@ func DeleteReceipts(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadIssuances retrieves the token issuances and ticket category registrations
+// made by the transactions of a block.
+func ReadIssuances(db DatabaseReader, hash common.Hash, number uint64) []*types.Issuance {
+	data, _ := db.Get(blockIssuancesKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	issuances := []*types.Issuance{}
+	if err := rlp.DecodeBytes(data, &issuances); err != nil {
+		log.Error("Invalid issuance array RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return issuances
+}
+
+// HasIssuances verifies the issuances of a block were stored, that is whether
+// the block was executed by the node.
+func HasIssuances(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if has, err := db.Has(blockIssuancesKey(number, hash)); !has || err != nil {
+		return false
+	}
+	return true
+}
+
+// WriteIssuances stores the issuances of a block. An empty list is stored too,
+// telling the blocks executed by the node from the fast synced ones.
+func WriteIssuances(db DatabaseWriter, hash common.Hash, number uint64, issuances []*types.Issuance) {
+	if issuances == nil {
+		issuances = []*types.Issuance{}
+	}
+	bytes, err := rlp.EncodeToBytes(issuances)
+	if err != nil {
+		log.Crit("Failed to encode block issuances", "err", err)
+	}
+	if err := db.Put(blockIssuancesKey(number, hash), bytes); err != nil {
+		log.Crit("Failed to store block issuances", "err", err)
+	}
+}
+
+// DeleteIssuances removes the issuances associated with a block hash.
+func DeleteIssuances(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockIssuancesKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block issuances", "err", err)
+	}
+}
+
+// ReadSysCalls retrieves the system calls made by the transactions of a block.
+func ReadSysCalls(db DatabaseReader, hash common.Hash, number uint64) []*types.SysCall {
+	data, _ := db.Get(blockSysCallsKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	calls := []*types.SysCall{}
+	if err := rlp.DecodeBytes(data, &calls); err != nil {
+		log.Error("Invalid system call array RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return calls
+}
+
+// WriteSysCalls stores the system calls of a block.
+func WriteSysCalls(db DatabaseWriter, hash common.Hash, number uint64, calls []*types.SysCall) {
+	if len(calls) == 0 {
+		return
+	}
+	bytes, err := rlp.EncodeToBytes(calls)
+	if err != nil {
+		log.Crit("Failed to encode block system calls", "err", err)
+	}
+	if err := db.Put(blockSysCallsKey(number, hash), bytes); err != nil {
+		log.Crit("Failed to store block system calls", "err", err)
+	}
+}
+
+// DeleteSysCalls removes the system calls associated with a block hash.
+func DeleteSysCalls(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockSysCallsKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block system calls", "err", err)
+	}
+}
+
+// ReadContractTickets retrieves the tickets received by the contracts in a block,
+// nil if they were not stored.
+func ReadContractTickets(db DatabaseReader, hash common.Hash, number uint64) []*types.ContractTicket {
+	data, _ := db.Get(blockTicketsKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	tickets := []*types.ContractTicket{}
+	if err := rlp.DecodeBytes(data, &tickets); err != nil {
+		log.Error("Invalid contract ticket array RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return tickets
+}
+
+// HasContractTickets checks if the tickets received by the contracts in a block
+// were stored, which they are for every block executed by the node.
+func HasContractTickets(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if has, err := db.Has(blockTicketsKey(number, hash)); !has || err != nil {
+		return false
+	}
+	return true
+}
+
+// WriteContractTickets stores the tickets received by the contracts in a block,
+// an empty list if none.
+func WriteContractTickets(db DatabaseWriter, hash common.Hash, number uint64, tickets []*types.ContractTicket) {
+	if tickets == nil {
+		tickets = []*types.ContractTicket{}
+	}
+	bytes, err := rlp.EncodeToBytes(tickets)
+	if err != nil {
+		log.Crit("Failed to encode block contract tickets", "err", err)
+	}
+	if err := db.Put(blockTicketsKey(number, hash), bytes); err != nil {
+		log.Crit("Failed to store block contract tickets", "err", err)
+	}
+}
+
+// DeleteContractTickets removes the contract tickets associated with a block hash.
+func DeleteContractTickets(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockTicketsKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block contract tickets", "err", err)
+	}
+}
+
 // ReadBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -352,6 +479,9 @@ func WriteBlock(db DatabaseWriter, block *types.Block) {
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
+	DeleteIssuances(db, hash, number)
+	DeleteSysCalls(db, hash, number)
+	DeleteContractTickets(db, hash, number)
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -315,3 +315,116 @@ func TestBlockReceiptStorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that the errors of the receipts are stored along with the staking
+// fields, and dropped if not wanted.
+func TestBlockReceiptErrorStorage(t *testing.T) {
+	db := serodb.NewMemDatabase()
+
+	poolId := common.BytesToHash([]byte{0x01})
+	receipt1 := &types.Receipt{
+		Status:     types.ReceiptStatusFailed,
+		TxHash:     common.BytesToHash([]byte{0x11, 0x11}),
+		ErrCode:    1,
+		ErrMsg:     "evm: execution reverted",
+		RevertData: []byte{0x08, 0xc3, 0x79, 0xa0},
+	}
+	receipt2 := &types.Receipt{
+		Status:  types.ReceiptStatusSuccessful,
+		TxHash:  common.BytesToHash([]byte{0x22, 0x22}),
+		PoolId:  &poolId,
+		ErrCode: 17,
+		ErrMsg:  "handleSend error",
+	}
+	receipts := types.Receipts{receipt1, receipt2}
+
+	hash := common.BytesToHash([]byte{0x03, 0x14})
+	WriteReceipts(db, hash, 0, receipts)
+	rs := ReadReceipts(db, hash, 0)
+	if len(rs) != len(receipts) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(rs), len(receipts))
+	}
+	for i, want := range receipts {
+		have := rs[i]
+		if have.ErrCode != want.ErrCode || have.ErrMsg != want.ErrMsg || !bytes.Equal(have.RevertData, want.RevertData) {
+			t.Errorf("receipt #%d: error mismatch: have %d %q %x, want %d %q %x", i, have.ErrCode, have.ErrMsg, have.RevertData, want.ErrCode, want.ErrMsg, want.RevertData)
+		}
+	}
+	if rs[1].PoolId == nil || *rs[1].PoolId != poolId {
+		t.Errorf("pool id mismatch: have %v, want %v", rs[1].PoolId, poolId)
+	}
+
+	WriteReceipts(db, hash, 0, receipts.WithoutErrors())
+	for i, receipt := range ReadReceipts(db, hash, 0) {
+		if receipt.HasError() {
+			t.Errorf("receipt #%d: error not dropped: %d %q", i, receipt.ErrCode, receipt.ErrMsg)
+		}
+	}
+	if !receipt1.HasError() {
+		t.Errorf("original receipt modified")
+	}
+}
+
+// Tests that the system calls of a block are stored aside of its receipts and
+// removed with it.
+func TestBlockSysCallStorage(t *testing.T) {
+	db := serodb.NewMemDatabase()
+
+	calls := []*types.SysCall{
+		{Contract: common.BytesToAddress([]byte{0x01}), Topic: common.HexToHash("0x0a"), Args: []byte{0xc0}, TxHash: common.BytesToHash([]byte{0x11})},
+		{Contract: common.BytesToAddress([]byte{0x02}), Topic: common.HexToHash("0x0b"), Args: []byte{0xc1, 0x80}, TxHash: common.BytesToHash([]byte{0x22})},
+	}
+	hash := common.BytesToHash([]byte{0x03, 0x14})
+	if stored := ReadSysCalls(db, hash, 1); stored != nil {
+		t.Fatalf("non existent system calls returned: %v", stored)
+	}
+	WriteSysCalls(db, hash, 1, calls)
+	stored := ReadSysCalls(db, hash, 1)
+	if len(stored) != len(calls) {
+		t.Fatalf("system call count mismatch: have %d, want %d", len(stored), len(calls))
+	}
+	for i, want := range calls {
+		have := stored[i]
+		if have.Contract != want.Contract || have.Topic != want.Topic || !bytes.Equal(have.Args, want.Args) || have.TxHash != want.TxHash {
+			t.Errorf("system call #%d mismatch: have %+v, want %+v", i, have, want)
+		}
+	}
+	DeleteBlock(db, hash, 1)
+	if stored := ReadSysCalls(db, hash, 1); stored != nil {
+		t.Errorf("deleted system calls returned: %v", stored)
+	}
+}
+
+// Tests block contract ticket storage and retrieval operations.
+func TestBlockContractTicketStorage(t *testing.T) {
+	db := serodb.NewMemDatabase()
+
+	tickets := []*types.ContractTicket{
+		{Contract: common.BytesToAddress([]byte{0x01}), Category: "CAT", Value: common.BytesToHash([]byte{0x0a}), TxHash: common.BytesToHash([]byte{0x11})},
+		{Contract: common.BytesToAddress([]byte{0x02}), Category: "DOG", Value: common.BytesToHash([]byte{0x0b}), TxHash: common.BytesToHash([]byte{0x22})},
+	}
+	hash := common.BytesToHash([]byte{0x03, 0x14})
+	if HasContractTickets(db, hash, 1) {
+		t.Fatalf("non existent contract tickets found")
+	}
+	WriteContractTickets(db, hash, 1, tickets)
+	stored := ReadContractTickets(db, hash, 1)
+	if len(stored) != len(tickets) {
+		t.Fatalf("contract ticket count mismatch: have %d, want %d", len(stored), len(tickets))
+	}
+	for i, want := range tickets {
+		if have := stored[i]; *have != *want {
+			t.Errorf("contract ticket #%d mismatch: have %+v, want %+v", i, have, want)
+		}
+	}
+	// A block receiving no ticket is told apart from a block not stored
+	empty := common.BytesToHash([]byte{0x04})
+	WriteContractTickets(db, empty, 2, nil)
+	if !HasContractTickets(db, empty, 2) || len(ReadContractTickets(db, empty, 2)) != 0 {
+		t.Errorf("empty contract tickets not stored")
+	}
+	DeleteBlock(db, hash, 1)
+	if HasContractTickets(db, hash, 1) {
+		t.Errorf("deleted contract tickets found")
+	}
+}
@@ -51,6 +51,10 @@ var (
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 
+	blockIssuancesPrefix = []byte("u") // blockIssuancesPrefix + num (uint64 big endian) + hash -> block issuances
+	blockSysCallsPrefix  = []byte("y") // blockSysCallsPrefix + num (uint64 big endian) + hash -> block system calls
+	blockTicketsPrefix   = []byte("k") // blockTicketsPrefix + num (uint64 big endian) + hash -> tickets received by contracts
+
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 
@@ -59,6 +63,7 @@ var (
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	RegistryIndexPrefix  = []byte("iR") // RegistryIndexPrefix is the data table of the token registry indexer
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -113,6 +118,21 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// blockIssuancesKey = blockIssuancesPrefix + num (uint64 big endian) + hash
+func blockIssuancesKey(number uint64, hash common.Hash) []byte {
+	return append(append(blockIssuancesPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// blockSysCallsKey = blockSysCallsPrefix + num (uint64 big endian) + hash
+func blockSysCallsKey(number uint64, hash common.Hash) []byte {
+	return append(append(blockSysCallsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// blockTicketsKey = blockTicketsPrefix + num (uint64 big endian) + hash
+func blockTicketsKey(number uint64, hash common.Hash) []byte {
+	return append(append(blockTicketsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/trie"
 )
@@ -32,6 +33,11 @@ type DumpAccount struct {
 	CodeHash string            `json:"codeHash"`
 	Code     string            `json:"code"`
 	Storage  map[string]string `json:"storage"`
+
+	// Assets, dumped only if asked for
+	Balances    map[string]string   `json:"balances,omitempty"`
+	Tickets     map[string][]string `json:"tickets,omitempty"`
+	TicketNonce uint64              `json:"ticketNonce,omitempty"`
 }
 
 type Dump struct {
@@ -40,6 +46,17 @@ type Dump struct {
 }
 
 func (self *StateDB) RawDump() Dump {
+	return self.rawDump(false, nil)
+}
+
+// RawDumpWithAssets is RawDump also listing the balances of every currency,
+// the tickets and the ticket nonce of the accounts, received being the tickets
+// received by the contracts in the previous blocks, see Tickets.
+func (self *StateDB) RawDumpWithAssets(received []*types.ContractTicket) Dump {
+	return self.rawDump(true, received)
+}
+
+func (self *StateDB) rawDump(withAssets bool, received []*types.ContractTicket) Dump {
 	dump := Dump{
 		Root:     fmt.Sprintf("%x", self.trie.Hash()),
 		Accounts: make(map[string]DumpAccount),
@@ -66,6 +83,21 @@ func (self *StateDB) RawDump() Dump {
 		for storageIt.Next() {
 			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
 		}
+		if withAssets {
+			account.TicketNonce = data.TicketNonce
+			account.Balances = make(map[string]string)
+			for currency, balance := range obj.Balances() {
+				if balance.Sign() > 0 {
+					account.Balances[currency] = balance.String()
+				}
+			}
+			account.Tickets = make(map[string][]string)
+			for category, values := range self.Tickets(obj.Address(), received) {
+				for _, value := range values {
+					account.Tickets[category] = append(account.Tickets[category], value.Hex())
+				}
+			}
+		}
 		dump.Accounts[common.Bytes2Hex(addr)] = account
 	}
 	return dump
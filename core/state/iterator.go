@@ -21,7 +21,6 @@ import (
 	"fmt"
 
 	"github.com/sero-cash/go-sero/common"
-	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/trie"
 )
 
@@ -104,10 +103,13 @@ func (it *NodeIterator) step() error {
 		return nil
 	}
 	// Otherwise we've reached an account node, initiate data iteration
-	var account Account
-	if err := rlp.Decode(bytes.NewReader(it.stateIt.LeafBlob()), &account); err != nil {
+	account, err := DecodeAccountLeaf(it.stateIt.LeafBlob())
+	if err != nil {
 		return err
 	}
+	if account == nil {
+		return nil
+	}
 	dataTrie, err := it.state.db.OpenStorageTrie(common.BytesToHash(it.stateIt.LeafKey()), account.Root)
 	if err != nil {
 		return err
@@ -126,6 +126,9 @@ type (
 	addLogChange struct {
 		txhash common.Hash
 	}
+	addIssuanceChange struct{}
+	addSysCallChange  struct{}
+	addTicketChange   struct{}
 	addPreimageChange struct {
 		hash common.Hash
 	}
@@ -230,6 +233,30 @@ func (ch addLogChange) dirtied() *common.Address {
 	return nil
 }
 
+func (ch addIssuanceChange) revert(s *StateDB) {
+	s.issuances = s.issuances[:len(s.issuances)-1]
+}
+
+func (ch addIssuanceChange) dirtied() *common.Address {
+	return nil
+}
+
+func (ch addSysCallChange) revert(s *StateDB) {
+	s.syscalls = s.syscalls[:len(s.syscalls)-1]
+}
+
+func (ch addSysCallChange) dirtied() *common.Address {
+	return nil
+}
+
+func (ch addTicketChange) revert(s *StateDB) {
+	s.tickets = s.tickets[:len(s.tickets)-1]
+}
+
+func (ch addTicketChange) dirtied() *common.Address {
+	return nil
+}
+
 func (ch addPreimageChange) revert(s *StateDB) {
 	delete(s.preimages, ch.hash)
 }
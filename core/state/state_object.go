@@ -111,6 +111,30 @@ type Account struct {
 	bookMap  map[string]*Book
 }
 
+// DecodeAccountLeaf decodes a leaf of the account trie, returning nil for the
+// leaves of the zero state. The zero state is stored in the account trie too,
+// under hashed keys telling nothing about their owner: its leaves are told
+// apart as not starting like an account, with a ticket nonce and a storage
+// root. A leaf starting like an account but failing to decode is corrupt.
+func DecodeAccountLeaf(blob []byte) (*Account, error) {
+	content, rest, err := rlp.SplitList(blob)
+	if err != nil || len(rest) > 0 {
+		return nil, nil
+	}
+	kind, nonce, rest, err := rlp.Split(content)
+	if err != nil || kind == rlp.List || (kind == rlp.String && len(nonce) > 8) {
+		return nil, nil
+	}
+	if kind, root, _, err := rlp.Split(rest); err != nil || kind != rlp.String || len(root) != common.HashLength {
+		return nil, nil
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
 // newObject creates a state object.
 func newObject(db *StateDB, address common.Address, data Account) *stateObject {
 	if data.CodeHash == nil {
@@ -23,6 +23,7 @@ import (
 
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/serodb"
 	checker "gopkg.in/check.v1"
 )
@@ -225,3 +226,32 @@ func compareStateObjects(so0, so1 *stateObject, t *testing.T) {
 		t.Fatalf("Deleted mismatch: have %v, want %v", so0.deleted, so1.deleted)
 	}
 }
+
+// Tests that the leaves of the account trie are decoded as accounts, that the
+// ones of the zero state are recognised and that corrupt accounts are not
+// mistaken for them.
+func TestDecodeAccountLeaf(t *testing.T) {
+	account := Account{
+		TicketNonce: 3,
+		Root:        common.HexToHash("0x01"),
+		CodeHash:    emptyCodeHash,
+		Books:       []*Book{{Balance: big.NewInt(5), Currency: "SERO"}},
+	}
+	enc, _ := rlp.EncodeToBytes(&account)
+	if have, err := DecodeAccountLeaf(enc); err != nil || have == nil || have.TicketNonce != 3 || len(have.Books) != 1 {
+		t.Fatalf("account decoding failed: %+v, %v", have, err)
+	}
+	// The zero state leaves are raw bytes, hashes or encoded objects
+	hashes := append(common.HexToHash("0x03").Bytes(), common.HexToHash("0x04").Bytes()...)
+	object, _ := rlp.EncodeToBytes([]interface{}{[]byte("pkr"), uint64(1)})
+	for i, leaf := range [][]byte{{1}, hashes, object} {
+		if have, err := DecodeAccountLeaf(leaf); err != nil || have != nil {
+			t.Errorf("zero state leaf %d decoded as an account: %+v, %v", i, have, err)
+		}
+	}
+	// An account with an invalid balance list is corrupt
+	corrupt, _ := rlp.EncodeToBytes([]interface{}{account.TicketNonce, account.Root, account.CodeHash, []byte("books")})
+	if _, err := DecodeAccountLeaf(corrupt); err == nil {
+		t.Errorf("corrupt account decoded")
+	}
+}
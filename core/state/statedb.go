@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"sort"
@@ -93,6 +94,9 @@ type StateDB struct {
 	txIndex      int
 	logs         map[common.Hash][]*types.Log
 	logSize      uint
+	issuances    []*types.Issuance
+	syscalls     []*types.SysCall
+	tickets      []*types.ContractTicket
 
 	preimages map[common.Hash][]byte
 
@@ -191,6 +195,24 @@ func (self *StateDB) AddTicket(contractAddr common.Address, categoryName string,
 	if stateObject != nil {
 		bytes, _ := rlp.EncodeToBytes([]interface{}{contractAddr, strings.ToUpper(categoryName), value})
 		stateObject.SetState(self.db, crypto.Keccak256Hash(bytes), TrueHash)
+
+		self.journal.append(addTicketChange{})
+		self.tickets = append(self.tickets, &types.ContractTicket{Contract: contractAddr, Category: strings.ToUpper(categoryName), Value: value, TxHash: self.thash})
+		self.addCategoryIssuance(strings.ToUpper(categoryName))
+	}
+}
+
+// addCategoryIssuance records the registration of a ticket category, once per
+// block. Since the tickets can no longer be allotted, the categories registered
+// before also show up when their tickets move between contracts.
+func (self *StateDB) addCategoryIssuance(category string) {
+	for _, issuance := range self.issuances {
+		if issuance.Category == category {
+			return
+		}
+	}
+	if owner := self.GetContrctAddressByTicket(category); owner != (common.Address{}) {
+		self.AddIssuance(&types.Issuance{Contract: owner, Category: category})
 	}
 }
 
@@ -207,8 +229,49 @@ func (self *StateDB) RemoveTicket(contractAddr common.Address, categoryName stri
 	return false
 }
 
+// Tickets returns the tickets owned by the contract at addr by category. They
+// are looked up among the given tickets received by the contracts in the
+// previous blocks, see core.ContractTickets, and the ones received in this
+// state, see ContractTickets.
+func (self *StateDB) Tickets(addr common.Address, received []*types.ContractTicket) map[string][]common.Hash {
+	result := make(map[string][]common.Hash)
+	seen := make(map[string]bool)
+	for _, ticket := range append(append([]*types.ContractTicket(nil), received...), self.tickets...) {
+		if ticket.Contract != addr {
+			continue
+		}
+		key := ticket.Category + ticket.Value.Hex()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if self.OwnTicket(addr, ticket.Category, ticket.Value) {
+			result[ticket.Category] = append(result[ticket.Category], ticket.Value)
+		}
+	}
+	for _, values := range result {
+		sort.Slice(values, func(i, j int) bool {
+			return bytes.Compare(values[i][:], values[j][:]) < 0
+		})
+	}
+	return result
+}
+
+// ContractTickets returns the tickets received by the contracts so far.
+func (self *StateDB) ContractTickets() []*types.ContractTicket {
+	return self.tickets
+}
+
 func (self *StateDB) RegisterTicket(contractAddr common.Address, categoryName string) bool {
-	return self.registerAddressByState("Ticket", contractAddr, strings.ToUpper(categoryName))
+	category := strings.ToUpper(categoryName)
+	registered := self.GetContrctAddressByTicket(category) != (common.Address{})
+	if !self.registerAddressByState("Ticket", contractAddr, category) {
+		return false
+	}
+	if !registered {
+		self.addCategoryIssuance(category)
+	}
+	return true
 }
 
 func (self *StateDB) GetContrctAddressByTicket(categoryName string) common.Address {
@@ -369,6 +432,9 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.txIndex = 0
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
+	self.issuances = nil
+	self.syscalls = nil
+	self.tickets = nil
 	self.preimages = make(map[common.Hash][]byte)
 	self.clearJournalAndRefund()
 	return nil
@@ -397,6 +463,34 @@ func (self *StateDB) Logs() []*types.Log {
 	return logs
 }
 
+// AddIssuance records a token issuance or a ticket category registration of
+// the current transaction.
+func (self *StateDB) AddIssuance(issuance *types.Issuance) {
+	self.journal.append(addIssuanceChange{})
+
+	issuance.TxHash = self.thash
+	self.issuances = append(self.issuances, issuance)
+}
+
+// Issuances returns the token issuances and ticket category registrations
+// made so far.
+func (self *StateDB) Issuances() []*types.Issuance {
+	return self.issuances
+}
+
+// AddSysCall records a system call of the current transaction.
+func (self *StateDB) AddSysCall(call *types.SysCall) {
+	self.journal.append(addSysCallChange{})
+
+	call.TxHash = self.thash
+	self.syscalls = append(self.syscalls, call)
+}
+
+// SysCalls returns the system calls made so far.
+func (self *StateDB) SysCalls() []*types.SysCall {
+	return self.syscalls
+}
+
 // AddPreimage records a SHA3 preimage seen by the VM.
 func (self *StateDB) AddPreimage(hash common.Hash, preimage []byte) {
 	if _, ok := self.preimages[hash]; !ok {
@@ -760,6 +854,9 @@ func (self *StateDB) Copy() *StateDB {
 		refund:            self.refund,
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
+		issuances:         append([]*types.Issuance(nil), self.issuances...),
+		syscalls:          append([]*types.SysCall(nil), self.syscalls...),
+		tickets:           append([]*types.ContractTicket(nil), self.tickets...),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
 		number:            self.number,
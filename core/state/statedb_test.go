@@ -428,3 +428,56 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that the tickets owned by the contracts are listed, both before and
+// after being committed, and that the ones received in previous blocks are
+// only listed if given to the state.
+func TestTickets(t *testing.T) {
+	db := serodb.NewMemDatabase()
+	state, _ := New(NewDatabase(db), nil)
+	contract1, contract2 := toAddr([]byte{0x01}), toAddr([]byte{0x02})
+	ticket1, ticket2, ticket3, ticket4 := common.BytesToHash([]byte{1}), common.BytesToHash([]byte{2}), common.BytesToHash([]byte{3}), common.BytesToHash([]byte{4})
+
+	state.AddTicket(contract1, "cat", ticket2)
+	state.AddTicket(contract1, "CAT", ticket1)
+	state.AddTicket(contract2, "DOG", ticket3)
+	state.AddTicket(contract1, "DOG", ticket3)
+	state.RemoveTicket(contract1, "DOG", ticket3)
+
+	snapshot := state.Snapshot()
+	state.AddTicket(contract2, "DOG", ticket4)
+	state.RemoveTicket(contract1, "CAT", ticket1)
+	state.RevertToSnapshot(snapshot)
+
+	check := func(stage string, state *StateDB, received []*types.ContractTicket) {
+		if tickets := state.Tickets(contract1, received); len(tickets) != 1 || !reflect.DeepEqual(tickets["CAT"], []common.Hash{ticket1, ticket2}) {
+			t.Errorf("%s: contract1 tickets mismatch: %v", stage, tickets)
+		}
+		if tickets := state.Tickets(contract2, received); len(tickets) != 1 || !reflect.DeepEqual(tickets["DOG"], []common.Hash{ticket3}) {
+			t.Errorf("%s: contract2 tickets mismatch: %v", stage, tickets)
+		}
+		if tickets := state.Tickets(toAddr([]byte{0x03}), received); len(tickets) != 0 {
+			t.Errorf("%s: unexpected tickets: %v", stage, tickets)
+		}
+	}
+	check("dirty", state, nil)
+	state.IntermediateRoot(false)
+	check("hashed", state, nil)
+
+	if tickets := state.ContractTickets(); len(tickets) != 4 {
+		t.Fatalf("received tickets mismatch: have %d, want 4", len(tickets))
+	}
+	// The tickets are not part of the accounts of the contracts
+	if state.Exist(contract1) || state.Exist(contract2) {
+		t.Errorf("contract accounts created by the tickets")
+	}
+	root, _ := state.Commit(false)
+	state.Database().TrieDB().Commit(root, false)
+
+	// The tickets are found back by another state only once given
+	fresh, _ := New(state.Database(), &types.Header{Root: root, Number: big.NewInt(1)})
+	if tickets := fresh.Tickets(contract1, nil); len(tickets) != 0 {
+		t.Errorf("not given: contract1 tickets mismatch: %v", tickets)
+	}
+	check("given", fresh, state.ContractTickets())
+}
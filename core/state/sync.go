@@ -17,10 +17,7 @@
 package state
 
 import (
-	"bytes"
-
 	"github.com/sero-cash/go-sero/common"
-	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/trie"
 )
 
@@ -28,8 +25,8 @@ import (
 func NewStateSync(root common.Hash, database trie.DatabaseReader) *trie.Sync {
 	var syncer *trie.Sync
 	callback := func(leaf []byte, parent common.Hash) error {
-		var obj Account
-		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
+		obj, err := DecodeAccountLeaf(leaf)
+		if err != nil || obj == nil {
 			return err
 		}
 		syncer.AddSubTrie(obj.Root, 64, parent, nil)
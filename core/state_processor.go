@@ -97,21 +97,28 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+	receipt, gas, _, err := ApplyTransactionWithOutput(config, bc, author, gp, statedb, header, tx, usedGas, cfg)
+	return receipt, gas, err
+}
+
+// ApplyTransactionWithOutput is ApplyTransaction also returning the output of
+// the EVM, which holds the revert reason of a failed contract call.
+func ApplyTransactionWithOutput(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, []byte, error) {
 	msg, err := tx.AsMessage()
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	err = statedb.NextZState().AddStx(tx.GetZZSTX())
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	var poolId, shareId *common.Hash
 	if header.Number.Uint64() >= seroparam.SIP4() {
 		if poolId, shareId, err = applyStake(msg.From(), tx.GetZZSTX().Desc_Cmd, statedb, tx.Hash(), header.Number.Uint64()); err != nil {
 			log.Info("applyStake", "error", err)
-			return nil, 0, err
+			return nil, 0, nil, err
 		}
 	}
 
@@ -126,11 +133,12 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	st := NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb()
 
 	if err != nil {
 		gp.AddGas(gas)
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	root := statedb.IntermediateRoot(true).Bytes()
@@ -150,8 +158,27 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 	receipt.PoolId = poolId
 	receipt.ShareId = shareId
+	setReceiptError(receipt, ret, st.VMError(), vmenv.SysCallError())
 
-	return receipt, gas, err
+	return receipt, gas, ret, err
+}
+
+// setReceiptError records into the receipt the error the execution failed
+// with, or else the one of the last system call which failed.
+func setReceiptError(receipt *types.Receipt, ret []byte, vmerr error, sysCallErr error) {
+	switch {
+	case vmerr != nil:
+		code := vm.ToErrorCode(vmerr)
+		receipt.ErrCode, receipt.ErrMsg = uint8(code), vmerr.Error()
+		if sysCallErr != nil {
+			receipt.ErrMsg += ": " + sysCallErr.Error()
+		}
+		if code == vm.ErrorReverted {
+			receipt.RevertData = common.CopyBytes(ret)
+		}
+	case sysCallErr != nil:
+		receipt.ErrCode, receipt.ErrMsg = uint8(vm.ErrorSysCall), sysCallErr.Error()
+	}
 }
 
 func applyStake(from common.Address, stakeDesc stx.DescCmd, statedb *state.StateDB, txHash common.Hash, number uint64) (poolId *common.Hash, shareId *common.Hash, err error) {
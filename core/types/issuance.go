@@ -0,0 +1,44 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/sero-cash/go-sero/common"
+)
+
+// Issuance is a token issued or a ticket category registered by a contract
+// through a system call. Since system calls never reach the receipts, the
+// issuances of a block are stored aside of them, outside of the consensus.
+type Issuance struct {
+	Contract common.Address
+	Currency string   // Issued token, empty for a ticket category registration
+	Total    *big.Int // Amount of tokens issued
+	Category string   // Registered ticket category, empty for a token issuance
+	TxHash   common.Hash
+}
+
+// ContractTicket is a ticket received by a contract. The tickets received in a
+// block are stored aside of its receipts, outside of the consensus, for the
+// tickets owned by a contract to be listed.
+type ContractTicket struct {
+	Contract common.Address
+	Category string
+	Value    common.Hash
+	TxHash   common.Hash
+}
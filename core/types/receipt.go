@@ -59,6 +59,11 @@ type Receipt struct {
 	//Staking
 	PoolId  *common.Hash `rlp:"nil"`
 	ShareId *common.Hash `rlp:"nil"`
+
+	//Errors, stored only if enabled
+	ErrCode    uint8  // vm.ErrorCode of the failed execution, or of the last failed system call
+	ErrMsg     string // Message of the error
+	RevertData []byte // Output of the reverted execution
 }
 
 type receiptMarshaling struct {
@@ -131,10 +136,16 @@ func (r *Receipt) statusEncoding() []byte {
 	return r.PostState
 }
 
+// HasError reports whether the receipt holds an error.
+func (r *Receipt) HasError() bool {
+	return r.ErrCode != 0 || len(r.ErrMsg) > 0 || len(r.RevertData) > 0
+}
+
 // Size returns the approximate memory used by all internal contents. It is used
 // to approximate and limit the memory consumption of various caches.
 func (r *Receipt) Size() common.StorageSize {
 	size := common.StorageSize(unsafe.Sizeof(*r)) + common.StorageSize(len(r.PostState))
+	size += common.StorageSize(len(r.ErrMsg) + len(r.RevertData))
 
 	size += common.StorageSize(len(r.Logs)) * common.StorageSize(unsafe.Sizeof(Log{}))
 	for _, log := range r.Logs {
@@ -149,6 +160,21 @@ type Receipts []*Receipt
 // Len returns the number of receipts in this list.
 func (r Receipts) Len() int { return len(r) }
 
+// WithoutErrors returns the receipts with their errors dropped, so that they
+// are stored readable by older nodes.
+func (r Receipts) WithoutErrors() Receipts {
+	ret := make(Receipts, len(r))
+	for i, receipt := range r {
+		ret[i] = receipt
+		if receipt.HasError() {
+			cpy := *receipt
+			cpy.ErrCode, cpy.ErrMsg, cpy.RevertData = 0, "", nil
+			ret[i] = &cpy
+		}
+	}
+	return ret
+}
+
 // GetRlp returns the RLP encoding of one receipt from the list.
 func (r Receipts) GetRlp(i int) []byte {
 	bytes, err := rlp.EncodeToBytes(r[i])
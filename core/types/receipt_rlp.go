@@ -24,6 +24,12 @@ type receiptForStorage_Version_1 struct {
 	ShareId *common.Hash  `rlp:"nil"`
 }
 
+type receiptForStorage_Version_2 struct {
+	ErrCode    uint8
+	ErrMsg     string
+	RevertData []byte
+}
+
 // ReceiptForStorage is a wrapper around a Receipt that flattens and parses the
 // entire content of a receipt, as opposed to only the consensus fields originally.
 type ReceiptForStorage Receipt
@@ -47,12 +53,19 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 		}
 		vs.Versions = append(vs.Versions, &v0)
 	}
-	if r.PoolId != nil || r.ShareId != nil {
+	if r.PoolId != nil || r.ShareId != nil || (*Receipt)(r).HasError() {
 		v1 := &receiptForStorage_Version_1{}
 		v1.ShareId = r.ShareId
 		v1.PoolId = r.PoolId
 		vs.Versions = append(vs.Versions, &v1)
 	}
+	if (*Receipt)(r).HasError() {
+		v2 := &receiptForStorage_Version_2{}
+		v2.ErrCode = r.ErrCode
+		v2.ErrMsg = r.ErrMsg
+		v2.RevertData = r.RevertData
+		vs.Versions = append(vs.Versions, &v2)
+	}
 	return rlp.Encode(w, &vs)
 }
 
@@ -61,9 +74,11 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	var v0 receiptForStorage_Version_0
 	var v1 receiptForStorage_Version_1
+	var v2 receiptForStorage_Version_2
 	vs := vserial.VSerial{}
 	vs.Versions = append(vs.Versions, &v0)
 	vs.Versions = append(vs.Versions, &v1)
+	vs.Versions = append(vs.Versions, &v2)
 	if err := s.Decode(&vs); err != nil {
 		return err
 	}
@@ -88,6 +103,11 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 		r.PoolId = v1.PoolId
 		r.ShareId = v1.ShareId
 	}
+	{
+		r.ErrCode = v2.ErrCode
+		r.ErrMsg = v2.ErrMsg
+		r.RevertData = v2.RevertData
+	}
 
 	return nil
 }
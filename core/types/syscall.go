@@ -0,0 +1,32 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/rlp"
+)
+
+// SysCall is a system call made by a contract, its arguments being the RLP
+// encoding of the call decoded by the VM. Like the issuances, the system calls
+// of a block are stored aside of the receipts, outside of the consensus.
+type SysCall struct {
+	Contract common.Address
+	Topic    common.Hash
+	Args     rlp.RawValue
+	TxHash   common.Hash
+}
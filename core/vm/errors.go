@@ -16,7 +16,10 @@
 
 package vm
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 // List execution errors
 var (
@@ -29,3 +32,105 @@ var (
 	ErrNoCompatibleInterpreter  = errors.New("no compatible interpreter")
 	ErrCodeInvalid              = errors.New("imput invalid")
 )
+
+// ErrorCode identifies the error an execution failed with. The codes are
+// stored with the receipts, new ones must be appended.
+type ErrorCode uint8
+
+const (
+	ErrorNone ErrorCode = iota
+	ErrorReverted
+	ErrorOutOfGas
+	ErrorCodeStoreOutOfGas
+	ErrorDepth
+	ErrorInsufficientBalance
+	ErrorContractAddressCollision
+	ErrorInputInvalid
+	ErrorWriteProtection
+	ErrorReturnDataOutOfBounds
+	ErrorMaxCodeSizeExceeded
+	ErrorToAddress
+	ErrorGasUintOverflow
+	ErrorInvalidJump
+	ErrorInvalidOpcode
+	ErrorStackUnderflow
+	ErrorStackOverflow
+	ErrorSysCall // A system call failed, aborting the execution or not
+	ErrorUnknown
+)
+
+var errorCodeNames = [...]string{
+	ErrorNone:                     "",
+	ErrorReverted:                 "reverted",
+	ErrorOutOfGas:                 "out of gas",
+	ErrorCodeStoreOutOfGas:        "code store out of gas",
+	ErrorDepth:                    "max call depth exceeded",
+	ErrorInsufficientBalance:      "insufficient balance",
+	ErrorContractAddressCollision: "contract address collision",
+	ErrorInputInvalid:             "input invalid",
+	ErrorWriteProtection:          "write protection",
+	ErrorReturnDataOutOfBounds:    "return data out of bounds",
+	ErrorMaxCodeSizeExceeded:      "max code size exceeded",
+	ErrorToAddress:                "invalid to address",
+	ErrorGasUintOverflow:          "gas uint64 overflow",
+	ErrorInvalidJump:              "invalid jump destination",
+	ErrorInvalidOpcode:            "invalid opcode",
+	ErrorStackUnderflow:           "stack underflow",
+	ErrorStackOverflow:            "stack limit reached",
+	ErrorSysCall:                  "system call failed",
+	ErrorUnknown:                  "unknown",
+}
+
+func (self ErrorCode) String() string {
+	if int(self) < len(errorCodeNames) {
+		return errorCodeNames[self]
+	}
+	return errorCodeNames[ErrorUnknown]
+}
+
+// ToErrorCode returns the code of an error returned by the EVM.
+func ToErrorCode(err error) ErrorCode {
+	switch err {
+	case nil:
+		return ErrorNone
+	case errExecutionReverted:
+		return ErrorReverted
+	case ErrOutOfGas:
+		return ErrorOutOfGas
+	case ErrCodeStoreOutOfGas:
+		return ErrorCodeStoreOutOfGas
+	case ErrDepth:
+		return ErrorDepth
+	case ErrInsufficientBalance:
+		return ErrorInsufficientBalance
+	case ErrContractAddressCollision:
+		return ErrorContractAddressCollision
+	case ErrCodeInvalid:
+		return ErrorInputInvalid
+	case errWriteProtection:
+		return ErrorWriteProtection
+	case errReturnDataOutOfBounds:
+		return ErrorReturnDataOutOfBounds
+	case errMaxCodeSizeExceeded:
+		return ErrorMaxCodeSizeExceeded
+	case ErrToAddressError:
+		return ErrorToAddress
+	case errGasUintOverflow:
+		return ErrorGasUintOverflow
+	}
+	// The errors carrying their context are built on the fly
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "invalid jump destination"):
+		return ErrorInvalidJump
+	case strings.HasPrefix(msg, "invalid opcode"):
+		return ErrorInvalidOpcode
+	case strings.HasPrefix(msg, "stack underflow"):
+		return ErrorStackUnderflow
+	case strings.HasPrefix(msg, "stack limit reached"):
+		return ErrorStackOverflow
+	case strings.HasPrefix(msg, "setTokenRate error"), strings.HasPrefix(msg, "issueToken error"):
+		return ErrorSysCall
+	}
+	return ErrorUnknown
+}
@@ -118,6 +118,9 @@ type EVM struct {
 	// available gas is calculated in gasCall* according to the 63/64 rule and later
 	// applied in opCall*.
 	callGasTemp uint64
+	// sysCallErr holds the error of the last system call which failed
+	// without aborting the execution, the contract only being told so.
+	sysCallErr error
 }
 
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
@@ -149,6 +152,12 @@ func (evm *EVM) Interpreter() Interpreter {
 	return evm.interpreter
 }
 
+// SysCallError returns the error of the last system call which failed
+// without aborting the execution, such as a send of a balance not enough.
+func (evm *EVM) SysCallError() error {
+	return evm.sysCallErr
+}
+
 // Call executes the contract associated with the addr with the given input as
 // parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -194,6 +203,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			evm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
 		}()
 	}
+	if tracer := evm.frameTracer(); tracer != nil {
+		tracer.CaptureEnter(CALL, caller.Address(), addr, input, gas, asset)
+		defer func() { tracer.CaptureExit(ret, gas-contract.Gas, err) }()
+	}
 	ret, err = run(evm, contract, input)
 
 	// When an error was returned by the EVM or when setting the creation code
@@ -244,6 +257,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 		return ret, leftOverGas, err
 	}
 
+	if tracer := evm.frameTracer(); tracer != nil {
+		tracer.CaptureEnter(CALLCODE, caller.Address(), addr, input, gas, asset)
+		defer func() { tracer.CaptureExit(ret, gas-contract.Gas, err) }()
+	}
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -282,6 +299,10 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 		return ret, leftOverGas, err
 	}
 
+	if tracer := evm.frameTracer(); tracer != nil {
+		tracer.CaptureEnter(DELEGATECALL, caller.Address(), addr, input, gas, nil)
+		defer func() { tracer.CaptureExit(ret, gas-contract.Gas, err) }()
+	}
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -330,6 +351,10 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// When an error was returned by the EVM or when setting the creation code
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in Homestead this also counts for code storage gas errors.
+	if tracer := evm.frameTracer(); tracer != nil {
+		tracer.CaptureEnter(STATICCALL, caller.Address(), addr, input, gas, nil)
+		defer func() { tracer.CaptureExit(ret, gas-contract.Gas, err) }()
+	}
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -398,6 +423,10 @@ func (evm *EVM) create(caller ContractRef, code []byte, gas uint64, asset *asset
 	if evm.vmConfig.Debug && evm.depth == 0 {
 		evm.vmConfig.Tracer.CaptureStart(caller.Address(), address, true, code, gas, asset)
 	}
+	tracer := evm.frameTracer()
+	if tracer != nil {
+		tracer.CaptureEnter(CREATE, caller.Address(), address, code, gas, asset)
+	}
 	start := time.Now()
 
 	ret, err := run(evm, contract, nil)
@@ -433,6 +462,9 @@ func (evm *EVM) create(caller ContractRef, code []byte, gas uint64, asset *asset
 	if evm.vmConfig.Debug && evm.depth == 0 {
 		evm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
 	}
+	if tracer != nil {
+		tracer.CaptureExit(ret, gas-contract.Gas, err)
+	}
 	return ret, address, contract.Gas, err
 
 }
@@ -446,5 +478,14 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, asset *asset
 	return evm.create(caller, code, gas, asset, contractAddr)
 }
 
+// frameTracer returns the tracer to notify of the nested frames, if any.
+func (evm *EVM) frameTracer() FrameTracer {
+	if !evm.vmConfig.Debug || evm.depth == 0 {
+		return nil
+	}
+	tracer, _ := evm.vmConfig.Tracer.(FrameTracer)
+	return tracer
+}
+
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
@@ -1007,6 +1007,7 @@ func handleIssueToken(d []byte, evm *EVM, contract *Contract, mem []byte) (bool,
 
 	total := new(big.Int).SetBytes(d[32:64])
 	evm.StateDB.AddBalance(contract.Address(), coinName, total)
+	evm.StateDB.AddIssuance(&types.Issuance{Contract: contract.Address(), Currency: coinName, Total: total})
 	return true, nil
 }
 
@@ -1095,6 +1096,9 @@ func makeLog(size int) executionFunc {
 			if l != len(d) {
 				return d, ErrCodeInvalid
 			}
+			if call, err := DecodeSysCall(topics[0], d, data); err == nil {
+				interpreter.evm.StateDB.AddSysCall(NewSysCall(contract.Address(), call))
+			}
 		}
 		if topics[0] == topic_allotTicket {
 			//hash, returnGas, err, alarm := handleAllotTicket(d, interpreter.evm, contract, data)
@@ -1113,6 +1117,7 @@ func makeLog(size int) executionFunc {
 				memory.Set(mStart.Uint64()+length-32, 32, hashTrue)
 			} else {
 				log.Trace(err.Error())
+				interpreter.evm.sysCallErr = err
 				memory.Set(mStart.Uint64()+length-32, 32, hashFalse)
 			}
 			contract.Gas += interpreter.evm.callGasTemp
@@ -1134,6 +1139,7 @@ func makeLog(size int) executionFunc {
 			}
 			if err != nil {
 				log.Trace("send error ", "contract", contract.Address(), "error", err)
+				interpreter.evm.sysCallErr = err
 				memory.Set(mStart.Uint64()+length-32, 32, hashFalse)
 			} else {
 				memory.Set(mStart.Uint64()+length-32, 32, hashTrue)
@@ -1191,6 +1197,7 @@ func makeLog(size int) executionFunc {
 			copy(key[:], d[32:64])
 			pkg, err := interpreter.evm.StateDB.NextZState().Pkgs.Close(&id, contract.Address().ToPKr(), &key)
 			if err != nil {
+				interpreter.evm.sysCallErr = fmt.Errorf("closePkg error , contract : %s, error : %s", contract.Address(), err)
 				memory.Set(mStart.Uint64(), 256, make([]byte, 256))
 			} else {
 				if pkg.O.Asset.Tkn != nil {
@@ -1231,6 +1238,7 @@ func makeLog(size int) executionFunc {
 				return nil, ErrToAddressError
 			}
 			if err := interpreter.evm.StateDB.NextZState().Pkgs.Transfer(&id, contract.Address().ToPKr(), toAddr.ToPKr()); err != nil {
+				interpreter.evm.sysCallErr = fmt.Errorf("transferPkg error , contract : %s, error : %s", contract.Address(), err)
 				memory.Set(mStart.Uint64()+length-32, 32, hashFalse)
 			} else {
 				memory.Set(mStart.Uint64()+length-32, 32, hashTrue)
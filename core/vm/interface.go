@@ -79,6 +79,8 @@ type StateDB interface {
 	Snapshot() int
 
 	AddLog(*types.Log)
+	AddIssuance(*types.Issuance)
+	AddSysCall(*types.SysCall)
 	AddPreimage(common.Hash, []byte)
 
 	ForEachStorage(common.Address, func(common.Hash, common.Hash) bool)
@@ -35,6 +35,9 @@ type Config struct {
 	NoRecursion bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// Store the errors of the failed executions with the receipts,
+	// which older nodes can not read
+	ReceiptErrors bool
 	// JumpTable contains the EVM instruction table. This
 	// may be left uninitialised and will be set to the default
 	// table.
@@ -105,6 +105,15 @@ type Tracer interface {
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
 
+// FrameTracer is a Tracer also notified of the nested calls and creations of
+// the transaction, along with the asset each of them carries. CaptureEnter is
+// called once the asset has been transferred to the callee.
+type FrameTracer interface {
+	Tracer
+	CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, asset *assets.Asset)
+	CaptureExit(output []byte, gasUsed uint64, err error)
+}
+
 // StructLogger is an EVM state logger and implements Tracer.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
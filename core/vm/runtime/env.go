@@ -23,10 +23,14 @@ import (
 )
 
 func NewEnv(cfg *Config) *vm.EVM {
+	transfer := cfg.Transfer
+	if transfer == nil {
+		transfer = core.Transfer
+	}
 	context := vm.Context{
-		//CanTransfer: core.CanTransfer,
-		Transfer: core.Transfer,
-		GetHash:  func(uint64) common.Hash { return common.Hash{} },
+		CanTransfer: core.CanTransfer,
+		Transfer:    transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
 
 		Origin:      cfg.Origin,
 		Coinbase:    cfg.Coinbase,
@@ -43,12 +43,25 @@ type Config struct {
 	Time        *big.Int
 	GasLimit    uint64
 	GasPrice    *big.Int
-	Value       *big.Int
+	Value       *big.Int      // SERO sent along the call, unless Asset is set
+	Asset       *assets.Asset // Token and ticket sent along the call
 	Debug       bool
 	EVMConfig   vm.Config
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
+	Transfer  vm.TransferFunc // Optional, defaults to core.Transfer
+}
+
+func (cfg *Config) asset() assets.Asset {
+	if cfg.Asset != nil {
+		return *cfg.Asset
+	}
+	return assets.Asset{Tkn: &assets.Token{
+		Currency: *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256(),
+		Value:    utils.U256(*cfg.Value),
+	},
+	}
 }
 
 // sets defaults on the config
@@ -108,11 +121,7 @@ func Execute(code, input []byte, cfg *Config) ([]byte, *state.StateDB, error) {
 	// set the receiver's (the executing contract) code for execution.
 	cfg.State.SetCode(address, code)
 	// Call the code with the given configuration.
-	asset := assets.Asset{Tkn: &assets.Token{
-		Currency: *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256(),
-		Value:    utils.U256(*cfg.Value),
-	},
-	}
+	asset := cfg.asset()
 	ret, _, err, _ := vmenv.Call(
 		sender,
 		common.BytesToAddress([]byte("contract")),
@@ -138,12 +147,7 @@ func Create(input []byte, cfg *Config) ([]byte, common.Address, uint64, error) {
 		vmenv  = NewEnv(cfg)
 		sender = vm.AccountRef(cfg.Origin)
 	)
-
-	asset := assets.Asset{Tkn: &assets.Token{
-		Currency: *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256(),
-		Value:    utils.U256(*cfg.Value),
-	},
-	}
+	asset := cfg.asset()
 	code, address, leftOverGas, err := vmenv.Create(
 		sender,
 		input,
@@ -165,11 +169,7 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, er
 
 	sender := cfg.State.GetOrNewStateObject(cfg.Origin)
 	// Call the code with the given configuration.
-	asset := assets.Asset{Tkn: &assets.Token{
-		Currency: *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256(),
-		Value:    utils.U256(*cfg.Value),
-	},
-	}
+	asset := cfg.asset()
 	ret, leftOverGas, err, _ := vmenv.Call(
 		sender,
 		address,
@@ -21,11 +21,17 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sero-cash/go-czero-import/keys"
+
 	"github.com/sero-cash/go-sero/accounts/abi"
 	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
 	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/core/vm"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/utils"
 )
 
 func TestDefaults(t *testing.T) {
@@ -148,3 +154,86 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestCallAsset(t *testing.T) {
+	state, _ := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	address := common.BytesToAddress([]byte("contract"))
+	state.SetCode(address, []byte{byte(vm.STOP)})
+	origin := common.BytesToAddress([]byte("origin"))
+	state.AddBalance(origin, "ABC", big.NewInt(100))
+
+	var transferred *assets.Asset
+	asset := assets.NewAssetByName("ABC", big.NewInt(100), "", keys.Uint256{})
+	cfg := &Config{
+		Origin: origin,
+		State:  state,
+		Asset:  &asset,
+		Transfer: func(db vm.StateDB, from, to common.Address, asset *assets.Asset, txHash common.Hash) bool {
+			transferred = asset
+			return core.Transfer(db, from, to, asset, txHash)
+		},
+	}
+	if _, _, err := Call(address, nil, cfg); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if transferred == nil || transferred.Tkn == nil || utils.Uint256ToCurrency(&transferred.Tkn.Currency) != "ABC" {
+		t.Fatalf("asset not transferred: %+v", transferred)
+	}
+	if balance := state.GetBalance(address, "ABC"); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("Expected balance 100, got %v", balance)
+	}
+}
+
+func TestCallTicketIssuance(t *testing.T) {
+	db := state.NewDatabase(serodb.NewMemDatabase())
+	statedb, _ := state.New(db, nil)
+	owner := common.BytesToAddress([]byte("owner"))
+	statedb.SetCode(owner, []byte{byte(vm.STOP)})
+	statedb.RegisterTicket(owner, "CAT")
+	if issuances := statedb.Issuances(); len(issuances) != 1 || issuances[0].Category != "CAT" || issuances[0].Contract != owner {
+		t.Fatalf("registration not recorded: %v", issuances)
+	}
+	origin := common.BytesToAddress([]byte("origin"))
+	statedb.SetCode(origin, []byte{byte(vm.STOP)})
+	statedb.AddTicket(origin, "CAT", common.BytesToHash([]byte{1}))
+	statedb.AddTicket(origin, "CAT", common.BytesToHash([]byte{2}))
+	statedb.AddTicket(origin, "DOG", common.BytesToHash([]byte{1}))
+	address := common.BytesToAddress([]byte("contract"))
+	statedb.SetCode(address, []byte{byte(vm.STOP)})
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	// The tickets move between contracts in the next block
+	state, _ := state.New(db, &types.Header{Root: root, Number: big.NewInt(1)})
+
+	call := func(category string, value byte) {
+		var ticket keys.Uint256
+		copy(ticket[:], common.BytesToHash([]byte{value}).Bytes())
+		asset := assets.NewAssetByName("", nil, category, ticket)
+		cfg := &Config{
+			Origin:   origin,
+			State:    state,
+			Asset:    &asset,
+			Transfer: core.Transfer,
+		}
+		if _, _, err := Call(address, nil, cfg); err != nil {
+			t.Fatal("didn't expect error", err)
+		}
+	}
+	// The first ticket of a registered category received in the block records it
+	call("CAT", 1)
+	if !state.OwnTicket(address, "CAT", common.BytesToHash([]byte{1})) {
+		t.Fatalf("ticket not received")
+	}
+	issuances := state.Issuances()
+	if len(issuances) != 1 || issuances[0].Category != "CAT" || issuances[0].Contract != owner {
+		t.Fatalf("issuance mismatch: %v", issuances)
+	}
+	// The next ones, and the ones of unregistered categories, don't
+	call("CAT", 2)
+	call("DOG", 1)
+	if issuances := state.Issuances(); len(issuances) != 1 {
+		t.Fatalf("issuance count mismatch: have %d, want 1", len(issuances))
+	}
+}
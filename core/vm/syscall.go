@@ -0,0 +1,284 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+)
+
+// The topics of the LOGs contracts emit to call the SERO system functions.
+// These LOGs are executed by the interpreter and never reach the receipts, the
+// decoded calls are stored aside of them.
+var (
+	TopicIssueToken    = topic_issueToken
+	TopicSend          = topic_send
+	TopicBalanceOf     = topic_balanceOf
+	TopicAllotTicket   = topic_allotTicket
+	TopicCurrency      = topic_currency
+	TopicCategory      = topic_category
+	TopicTicket        = topic_ticket
+	TopicSetCallValues = topic_setCallValues
+	TopicSetTokenRate  = topic_setTokenRate
+	TopicClosePkg      = topic_closePkg
+	TopicTransferPkg   = topic_transferPkg
+)
+
+var errSysCallMemory = errors.New("system call refers outside of the memory")
+
+// SysCall is a decoded system call LOG.
+type SysCall interface {
+	Topic() common.Hash
+}
+
+type IssueToken struct {
+	Currency string
+	Total    *big.Int
+}
+
+type Send struct {
+	To       common.ContractAddress
+	Currency string
+	Amount   *big.Int
+	Category string
+	Ticket   common.Hash
+}
+
+type BalanceOf struct {
+	Currency string
+}
+
+type AllotTicket struct {
+	To       common.ContractAddress
+	Category string
+	Ticket   common.Hash // empty for a new ticket
+}
+
+// CallCurrency, CallCategory and CallTicket query the asset sent to the contract.
+type CallCurrency struct{}
+type CallCategory struct{}
+type CallTicket struct{}
+
+type SetCallValues struct {
+	Currency string
+	Amount   *big.Int
+	Category string
+	Ticket   common.Hash
+}
+
+type SetTokenRate struct {
+	Currency string
+	Tokens   *big.Int
+	Ta       *big.Int
+}
+
+type ClosePkg struct {
+	Id  common.Hash
+	Key common.Hash
+}
+
+type TransferPkg struct {
+	Id common.Hash
+	To common.ContractAddress
+}
+
+func (IssueToken) Topic() common.Hash    { return TopicIssueToken }
+func (Send) Topic() common.Hash          { return TopicSend }
+func (BalanceOf) Topic() common.Hash     { return TopicBalanceOf }
+func (AllotTicket) Topic() common.Hash   { return TopicAllotTicket }
+func (CallCurrency) Topic() common.Hash  { return TopicCurrency }
+func (CallCategory) Topic() common.Hash  { return TopicCategory }
+func (CallTicket) Topic() common.Hash    { return TopicTicket }
+func (SetCallValues) Topic() common.Hash { return TopicSetCallValues }
+func (SetTokenRate) Topic() common.Hash  { return TopicSetTokenRate }
+func (ClosePkg) Topic() common.Hash      { return TopicClosePkg }
+func (TransferPkg) Topic() common.Hash   { return TopicTransferPkg }
+
+// Asset returns the asset sent by the system call.
+func (self Send) Asset() assets.Asset {
+	return assets.NewAssetByName(self.Currency, self.Amount, self.Category, *self.Ticket.HashToUint256())
+}
+
+// Asset returns the asset attached to the following calls of the contract.
+func (self SetCallValues) Asset() assets.Asset {
+	return assets.NewAssetByName(self.Currency, self.Amount, self.Category, *self.Ticket.HashToUint256())
+}
+
+// sysCallTypes maps the topics of the system calls to their decoded types.
+var sysCallTypes = map[common.Hash]reflect.Type{
+	TopicIssueToken:    reflect.TypeOf(IssueToken{}),
+	TopicSend:          reflect.TypeOf(Send{}),
+	TopicBalanceOf:     reflect.TypeOf(BalanceOf{}),
+	TopicAllotTicket:   reflect.TypeOf(AllotTicket{}),
+	TopicCurrency:      reflect.TypeOf(CallCurrency{}),
+	TopicCategory:      reflect.TypeOf(CallCategory{}),
+	TopicTicket:        reflect.TypeOf(CallTicket{}),
+	TopicSetCallValues: reflect.TypeOf(SetCallValues{}),
+	TopicSetTokenRate:  reflect.TypeOf(SetTokenRate{}),
+	TopicClosePkg:      reflect.TypeOf(ClosePkg{}),
+	TopicTransferPkg:   reflect.TypeOf(TransferPkg{}),
+}
+
+// SysCallName returns the name of a system call, the name of its type.
+func SysCallName(call SysCall) string {
+	return reflect.TypeOf(call).Name()
+}
+
+// NewSysCall encodes the system call made by contract to be stored.
+func NewSysCall(contract common.Address, call SysCall) *types.SysCall {
+	args, _ := rlp.EncodeToBytes(call)
+	return &types.SysCall{Contract: contract, Topic: call.Topic(), Args: args}
+}
+
+// UnpackSysCall decodes the arguments of a stored system call.
+func UnpackSysCall(call *types.SysCall) (SysCall, error) {
+	typ, ok := sysCallTypes[call.Topic]
+	if !ok {
+		return nil, fmt.Errorf("unknown system call topic %x", call.Topic)
+	}
+	ret := reflect.New(typ)
+	if err := rlp.DecodeBytes(call.Args, ret.Interface()); err != nil {
+		return nil, err
+	}
+	return ret.Elem().Interface().(SysCall), nil
+}
+
+// IsSysCall reports whether topic is the topic of a system call.
+func IsSysCall(topic common.Hash) bool {
+	_, ok := memLens[topic]
+	return ok
+}
+
+// DecodeSysCall decodes the data of a system call LOG, mem being the memory
+// of the contract the strings are referenced from.
+func DecodeSysCall(topic common.Hash, d []byte, mem []byte) (SysCall, error) {
+	l, ok := memLens[topic]
+	if !ok {
+		return nil, fmt.Errorf("unknown system call topic %x", topic)
+	}
+	if len(d) != l {
+		return nil, fmt.Errorf("system call %x data length mismatch: have %d, want %d", topic, len(d), l)
+	}
+	var (
+		err  error
+		ret  SysCall
+		strs = func(offsets ...int) (ret []string) {
+			for _, off := range offsets {
+				var s string
+				if err == nil {
+					s, err = memString(d[off:off+32], mem)
+				}
+				ret = append(ret, strings.ToUpper(s))
+			}
+			return
+		}
+	)
+	switch topic {
+	case TopicIssueToken:
+		ret = IssueToken{strs(0)[0], new(big.Int).SetBytes(d[32:64])}
+	case TopicSend:
+		s := strs(32, 96)
+		ret = Send{common.BytesToContractAddress(d[12:32]), s[0], new(big.Int).SetBytes(d[64:96]), s[1], common.BytesToHash(d[128:160])}
+	case TopicBalanceOf:
+		ret = BalanceOf{strs(0)[0]}
+	case TopicAllotTicket:
+		ret = AllotTicket{common.BytesToContractAddress(d[44:64]), strs(64)[0], common.BytesToHash(d[0:32])}
+	case TopicCurrency:
+		ret = CallCurrency{}
+	case TopicCategory:
+		ret = CallCategory{}
+	case TopicTicket:
+		ret = CallTicket{}
+	case TopicSetCallValues:
+		s := strs(0, 64)
+		ret = SetCallValues{s[0], new(big.Int).SetBytes(d[32:64]), s[1], common.BytesToHash(d[96:128])}
+	case TopicSetTokenRate:
+		ret = SetTokenRate{strs(0)[0], new(big.Int).SetBytes(d[32:64]), new(big.Int).SetBytes(d[64:96])}
+	case TopicClosePkg:
+		ret = ClosePkg{common.BytesToHash(d[0:32]), common.BytesToHash(d[32:64])}
+	case TopicTransferPkg:
+		ret = TransferPkg{common.BytesToHash(d[0:32]), common.BytesToContractAddress(d[32:64])}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// memString reads the solidity string at the memory offset stored in word.
+func memString(word []byte, mem []byte) (string, error) {
+	offset := new(big.Int).SetBytes(word)
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(mem)) || uint64(len(mem))-offset.Uint64() < 32 {
+		return "", errSysCallMemory
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(mem[start-32 : start])
+	if !length.IsUint64() || length.Uint64() > uint64(len(mem))-start {
+		return "", errSysCallMemory
+	}
+	return string(mem[start : start+length.Uint64()]), nil
+}
+
+// SysCallRecord is a system call made during a transaction.
+type SysCallRecord struct {
+	Contract common.Address
+	Depth    int
+	Call     SysCall
+}
+
+// SysCallTracer is a Tracer collecting the system calls of the executed
+// contracts with their call depth.
+type SysCallTracer struct {
+	Calls []SysCallRecord
+}
+
+func (self *SysCallTracer) CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, asset *assets.Asset) error {
+	return nil
+}
+
+func (self *SysCallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if err != nil || op < LOG1 || op > LOG4 {
+		return nil
+	}
+	mStart, mSize, topic := stack.Back(0), stack.Back(1), common.BigToHash(stack.Back(2))
+	if !IsSysCall(topic) {
+		return nil
+	}
+	call, e := DecodeSysCall(topic, memory.GetPtr(mStart.Int64(), mSize.Int64()), memory.Data())
+	if e != nil {
+		return nil
+	}
+	self.Calls = append(self.Calls, SysCallRecord{contract.Address(), depth, call})
+	return nil
+}
+
+func (self *SysCallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (self *SysCallTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
@@ -0,0 +1,129 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// word returns v as a 32 bytes memory word.
+func word(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return common.LeftPadBytes(big.NewInt(int64(v)).Bytes(), 32)
+	case []byte:
+		return common.LeftPadBytes(v, 32)
+	}
+	panic("unsupported word")
+}
+
+func concat(words ...[]byte) (ret []byte) {
+	for _, w := range words {
+		ret = append(ret, w...)
+	}
+	return
+}
+
+func TestDecodeSysCall(t *testing.T) {
+	// Two solidity strings at offsets 0 and 64 of the memory
+	mem := concat(word(4), common.RightPadBytes([]byte("abcd"), 32), word(6), common.RightPadBytes([]byte("TICKET"), 32))
+	to := common.BytesToContractAddress([]byte{1, 2, 3})
+	ticket := common.HexToHash("0x1234")
+
+	d := concat(word(to[:]), word(0), word(1000), word(64), ticket[:])
+	call, err := DecodeSysCall(TopicSend, d, mem)
+	if err != nil {
+		t.Fatalf("send decoding failed: %v", err)
+	}
+	send, ok := call.(Send)
+	if !ok {
+		t.Fatalf("send decoded as %T", call)
+	}
+	if send.To != to || send.Currency != "ABCD" || send.Amount.Int64() != 1000 || send.Category != "TICKET" || send.Ticket != ticket {
+		t.Errorf("send mismatch: %+v", send)
+	}
+	asset := send.Asset()
+	if asset.Tkn == nil || utils.Uint256ToCurrency(&asset.Tkn.Currency) != "ABCD" || asset.Tkn.Value.ToInt().Int64() != 1000 {
+		t.Errorf("send token mismatch: %+v", asset.Tkn)
+	}
+	if asset.Tkt == nil || common.BytesToHash(asset.Tkt.Value[:]) != ticket {
+		t.Errorf("send ticket mismatch: %+v", asset.Tkt)
+	}
+
+	call, err = DecodeSysCall(TopicIssueToken, concat(word(0), word(5000)), mem)
+	if err != nil {
+		t.Fatalf("issueToken decoding failed: %v", err)
+	}
+	if issue := call.(IssueToken); issue.Currency != "ABCD" || issue.Total.Int64() != 5000 {
+		t.Errorf("issueToken mismatch: %+v", issue)
+	}
+
+	id, key := common.HexToHash("0x01"), common.HexToHash("0x02")
+	call, err = DecodeSysCall(TopicClosePkg, concat(id[:], key[:], make([]byte, 192)), mem)
+	if err != nil {
+		t.Fatalf("closePkg decoding failed: %v", err)
+	}
+	if pkg := call.(ClosePkg); pkg.Id != id || pkg.Key != key {
+		t.Errorf("closePkg mismatch: %+v", pkg)
+	}
+
+	// Malformed calls
+	if _, err := DecodeSysCall(TopicBalanceOf, word(1000), mem); err == nil {
+		t.Errorf("string outside of the memory decoded")
+	}
+	if _, err := DecodeSysCall(TopicBalanceOf, word(32), mem[:40]); err == nil {
+		t.Errorf("truncated string decoded")
+	}
+	if _, err := DecodeSysCall(TopicIssueToken, word(0), mem); err == nil {
+		t.Errorf("short data decoded")
+	}
+	if _, err := DecodeSysCall(common.Hash{}, nil, mem); err == nil {
+		t.Errorf("unknown topic decoded")
+	}
+}
+
+func TestUnpackSysCall(t *testing.T) {
+	contract := common.BytesToAddress([]byte{9})
+	calls := []SysCall{
+		Send{common.BytesToContractAddress([]byte{1}), "ABCD", big.NewInt(1000), "TICKET", common.HexToHash("0x1234")},
+		IssueToken{"ABCD", big.NewInt(5000)},
+		CallCurrency{},
+		TransferPkg{common.HexToHash("0x01"), common.BytesToContractAddress([]byte{2})},
+	}
+	for _, call := range calls {
+		stored := NewSysCall(contract, call)
+		if stored.Contract != contract || stored.Topic != call.Topic() {
+			t.Errorf("%s: stored call mismatch: %+v", SysCallName(call), stored)
+		}
+		unpacked, err := UnpackSysCall(stored)
+		if err != nil {
+			t.Fatalf("%s: unpacking failed: %v", SysCallName(call), err)
+		}
+		if !reflect.DeepEqual(unpacked, call) {
+			t.Errorf("%s: unpacked call mismatch: have %+v, want %+v", SysCallName(call), unpacked, call)
+		}
+	}
+	if _, err := UnpackSysCall(&types.SysCall{Topic: common.Hash{}}); err == nil {
+		t.Errorf("unknown topic unpacked")
+	}
+}
@@ -0,0 +1,1075 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package graphql provides a GraphQL interface to the chain, the zero outputs
+// and the stake data of the node.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/internal/ethapi"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+const (
+	maxPageSize   = 100 // Maximum number of items of a page of a list
+	maxBlockRange = 100 // Maximum number of blocks of a range query
+
+	// maxQueryComplexity bounds the number of objects a query may load, as
+	// every nested list may return maxPageSize of them.
+	maxQueryComplexity = 1000
+)
+
+var (
+	errBlockInvariant  = errors.New("block objects must be instantiated with at least one of num or hash")
+	errQueryTooComplex = fmt.Errorf("query too complex: it loads more than %d objects", maxQueryComplexity)
+)
+
+type complexityKey struct{}
+
+// withComplexity returns a context counting the objects loaded by a query.
+func withComplexity(ctx context.Context) context.Context {
+	return context.WithValue(ctx, complexityKey{}, new(int32))
+}
+
+// charge adds n loaded objects to the complexity of the query, failing once it
+// exceeds maxQueryComplexity.
+func charge(ctx context.Context, n int) error {
+	if count, ok := ctx.Value(complexityKey{}).(*int32); ok && atomic.AddInt32(count, int32(n)) > maxQueryComplexity {
+		return errQueryTooComplex
+	}
+	return nil
+}
+
+// Log represents an individual log message. All arguments are mandatory.
+type Log struct {
+	backend     ethapi.Backend
+	transaction *Transaction
+	log         *types.Log
+}
+
+func (l *Log) Transaction(ctx context.Context) *Transaction {
+	return l.transaction
+}
+
+func (l *Log) Account(ctx context.Context) common.Address {
+	return l.log.Address
+}
+
+func (l *Log) Index(ctx context.Context) int32 {
+	return int32(l.log.Index)
+}
+
+func (l *Log) Topics(ctx context.Context) []common.Hash {
+	return l.log.Topics
+}
+
+func (l *Log) Data(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(l.log.Data)
+}
+
+// Transaction represents a transaction, lazily loaded from its hash.
+type Transaction struct {
+	backend ethapi.Backend
+	hash    common.Hash
+	tx      *types.Transaction
+	block   *Block
+	index   uint64
+
+	lock sync.Mutex
+}
+
+// resolve returns the transaction, mined or pending, nil if unknown.
+func (t *Transaction) resolve(ctx context.Context) *types.Transaction {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.tx == nil {
+		tx, blockHash, _, index := rawdb.ReadTransaction(t.backend.ChainDb(), t.hash)
+		if tx != nil {
+			t.tx = tx
+			t.block = &Block{backend: t.backend, hash: blockHash}
+			t.index = index
+		} else {
+			t.tx = t.backend.GetPoolTransaction(t.hash)
+		}
+	}
+	return t.tx
+}
+
+// getReceipt returns the receipt of the transaction, nil if pending.
+func (t *Transaction) getReceipt(ctx context.Context) (*types.Receipt, error) {
+	if t.resolve(ctx) == nil || t.block == nil {
+		return nil, nil
+	}
+	receipts, err := t.block.resolveReceipts(ctx)
+	if err != nil || uint64(len(receipts)) <= t.index {
+		return nil, err
+	}
+	return receipts[t.index], nil
+}
+
+func (t *Transaction) Hash(ctx context.Context) common.Hash {
+	return t.hash
+}
+
+func (t *Transaction) From(ctx context.Context) common.Address {
+	if tx := t.resolve(ctx); tx != nil {
+		return tx.From()
+	}
+	return common.Address{}
+}
+
+func (t *Transaction) To(ctx context.Context) *common.Address {
+	tx := t.resolve(ctx)
+	if tx == nil {
+		return nil
+	}
+	if to := tx.To(); to != nil && *to != (common.Address{}) {
+		return to
+	}
+	return nil
+}
+
+func (t *Transaction) Gas(ctx context.Context) hexutil.Uint64 {
+	if tx := t.resolve(ctx); tx != nil {
+		return hexutil.Uint64(tx.Gas())
+	}
+	return 0
+}
+
+func (t *Transaction) GasPrice(ctx context.Context) hexutil.Big {
+	if tx := t.resolve(ctx); tx != nil {
+		return hexutil.Big(*tx.GasPrice())
+	}
+	return hexutil.Big{}
+}
+
+func (t *Transaction) InputData(ctx context.Context) hexutil.Bytes {
+	if tx := t.resolve(ctx); tx != nil {
+		return hexutil.Bytes(tx.Data())
+	}
+	return hexutil.Bytes{}
+}
+
+func (t *Transaction) Block(ctx context.Context) *Block {
+	t.resolve(ctx)
+	return t.block
+}
+
+func (t *Transaction) Index(ctx context.Context) *int32 {
+	if t.resolve(ctx) == nil || t.block == nil {
+		return nil
+	}
+	index := int32(t.index)
+	return &index
+}
+
+func (t *Transaction) Status(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	status := hexutil.Uint64(receipt.Status)
+	return &status, nil
+}
+
+func (t *Transaction) GasUsed(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	used := hexutil.Uint64(receipt.GasUsed)
+	return &used, nil
+}
+
+func (t *Transaction) CumulativeGasUsed(ctx context.Context) (*hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	used := hexutil.Uint64(receipt.CumulativeGasUsed)
+	return &used, nil
+}
+
+func (t *Transaction) ShareId(ctx context.Context) (*common.Hash, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	return receipt.ShareId, nil
+}
+
+func (t *Transaction) PoolId(ctx context.Context) (*common.Hash, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	return receipt.PoolId, nil
+}
+
+func (t *Transaction) Logs(ctx context.Context) (*[]*Log, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	if err := charge(ctx, len(receipt.Logs)); err != nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(receipt.Logs))
+	for _, log := range receipt.Logs {
+		ret = append(ret, &Log{backend: t.backend, transaction: t, log: log})
+	}
+	return &ret, nil
+}
+
+// Block represents a block, lazily loaded from its number or its hash.
+type Block struct {
+	backend  ethapi.Backend
+	num      *rpc.BlockNumber
+	hash     common.Hash
+	block    *types.Block
+	header   *types.Header
+	receipts types.Receipts
+
+	zero     *txtool.Block // Outputs and nullifiers indexed by the local database
+	zeroDone bool
+
+	lock sync.Mutex
+}
+
+// resolve returns the block, nil if unknown.
+func (b *Block) resolve(ctx context.Context) (*types.Block, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.block != nil {
+		return b.block, nil
+	}
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	var err error
+	switch {
+	case b.hash != (common.Hash{}):
+		b.block, err = b.backend.GetBlock(ctx, b.hash)
+	case b.num != nil:
+		b.block, err = b.backend.BlockByNumber(ctx, *b.num)
+	default:
+		return nil, errBlockInvariant
+	}
+	if b.block != nil {
+		b.hash = b.block.Hash()
+		b.header = b.block.Header()
+	}
+	return b.block, err
+}
+
+// resolveHeader returns the header of the block, failing if the block is
+// unknown.
+func (b *Block) resolveHeader(ctx context.Context) (*types.Header, error) {
+	block, err := b.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", b.hash)
+	}
+	return b.header, nil
+}
+
+// resolveReceipts returns the receipts of the transactions of the block.
+func (b *Block) resolveReceipts(ctx context.Context) (types.Receipts, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.receipts == nil {
+		if err := charge(ctx, 1); err != nil {
+			return nil, err
+		}
+		receipts, err := b.backend.GetReceipts(ctx, b.hash)
+		if err != nil {
+			return nil, err
+		}
+		b.receipts = receipts
+	}
+	return b.receipts, nil
+}
+
+// resolveZero returns the outputs and nullifiers of the block from the local
+// database, nil if not indexed yet.
+func (b *Block) resolveZero(ctx context.Context) (*txtool.Block, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.zeroDone {
+		if err := charge(ctx, 1); err != nil {
+			return nil, err
+		}
+		infos, err := b.backend.GetBlocksInfo(header.Number.Uint64(), 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(infos) > 0 && infos[0].Hash == *b.hash.HashToUint256() {
+			b.zero = &infos[0]
+		}
+		b.zeroDone = true
+	}
+	return b.zero, nil
+}
+
+func (b *Block) Number(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.Number.Uint64()), nil
+}
+
+func (b *Block) Hash(ctx context.Context) (common.Hash, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return common.Hash{}, err
+	}
+	return b.hash, nil
+}
+
+func (b *Block) Parent(ctx context.Context) (*Block, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header.Number.Sign() == 0 {
+		return nil, err
+	}
+	return &Block{backend: b.backend, hash: header.ParentHash}, nil
+}
+
+func (b *Block) Miner(ctx context.Context) (common.Address, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return header.Coinbase, nil
+}
+
+func (b *Block) StateRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.Root, nil
+}
+
+func (b *Block) TransactionsRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.TxHash, nil
+}
+
+func (b *Block) ReceiptsRoot(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.ReceiptHash, nil
+}
+
+func (b *Block) LogsBloom(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hexutil.Bytes(header.Bloom.Bytes()), nil
+}
+
+func (b *Block) Difficulty(ctx context.Context) (hexutil.Big, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*header.Difficulty), nil
+}
+
+func (b *Block) TotalDifficulty(ctx context.Context) (hexutil.Big, error) {
+	if _, err := b.resolveHeader(ctx); err != nil {
+		return hexutil.Big{}, err
+	}
+	td := b.backend.GetTd(b.hash)
+	if td == nil {
+		return hexutil.Big{}, fmt.Errorf("total difficulty of block %x not found", b.hash)
+	}
+	return hexutil.Big(*td), nil
+}
+
+func (b *Block) GasLimit(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.GasLimit), nil
+}
+
+func (b *Block) GasUsed(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.GasUsed), nil
+}
+
+func (b *Block) Timestamp(ctx context.Context) (hexutil.Uint64, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(header.Time.Uint64()), nil
+}
+
+func (b *Block) ExtraData(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hexutil.Bytes(header.Extra), nil
+}
+
+func (b *Block) MixHash(ctx context.Context) (common.Hash, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.MixDigest, nil
+}
+
+func (b *Block) Nonce(ctx context.Context) (hexutil.Bytes, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hexutil.Bytes(header.Nonce[:]), nil
+}
+
+func (b *Block) TransactionCount(ctx context.Context) (int32, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return 0, err
+	}
+	return int32(len(block.Transactions())), nil
+}
+
+// transaction returns the resolver of the transaction at the index of the
+// block.
+func (b *Block) transaction(block *types.Block, index int) *Transaction {
+	return &Transaction{
+		backend: b.backend,
+		hash:    block.Transactions()[index].Hash(),
+		tx:      block.Transactions()[index],
+		block:   b,
+		index:   uint64(index),
+	}
+}
+
+func (b *Block) Transactions(ctx context.Context, args struct {
+	First *int32
+	Skip  *int32
+}) ([]*Transaction, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	start, end := page(len(block.Transactions()), args.First, args.Skip)
+	if err := charge(ctx, end-start); err != nil {
+		return nil, err
+	}
+	ret := make([]*Transaction, 0, end-start)
+	for i := start; i < end; i++ {
+		ret = append(ret, b.transaction(block, i))
+	}
+	return ret, nil
+}
+
+func (b *Block) TransactionAt(ctx context.Context, args struct{ Index int32 }) (*Transaction, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	if args.Index < 0 || int(args.Index) >= len(block.Transactions()) {
+		return nil, nil
+	}
+	return b.transaction(block, int(args.Index)), nil
+}
+
+func (b *Block) Logs(ctx context.Context) ([]*Log, error) {
+	block, err := b.resolve(ctx)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	receipts, err := b.resolveReceipts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*Log
+	for i, receipt := range receipts {
+		if i >= len(block.Transactions()) {
+			break
+		}
+		if err := charge(ctx, len(receipt.Logs)); err != nil {
+			return nil, err
+		}
+		tx := b.transaction(block, i)
+		for _, log := range receipt.Logs {
+			ret = append(ret, &Log{backend: b.backend, transaction: tx, log: log})
+		}
+	}
+	return ret, nil
+}
+
+func (b *Block) CurrentVotes(ctx context.Context) ([]*Vote, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, len(header.CurrentVotes)); err != nil {
+		return nil, err
+	}
+	return newVotes(b.backend, header.CurrentVotes), nil
+}
+
+func (b *Block) ParentVotes(ctx context.Context) ([]*Vote, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, len(header.ParentVotes)); err != nil {
+		return nil, err
+	}
+	return newVotes(b.backend, header.ParentVotes), nil
+}
+
+func (b *Block) Outs(ctx context.Context, args struct {
+	First *int32
+	Skip  *int32
+}) (*[]*Out, error) {
+	zero, err := b.resolveZero(ctx)
+	if err != nil || zero == nil {
+		return nil, err
+	}
+	start, end := page(len(zero.Outs), args.First, args.Skip)
+	if err := charge(ctx, end-start); err != nil {
+		return nil, err
+	}
+	ret := make([]*Out, 0, end-start)
+	for i := start; i < end; i++ {
+		ret = append(ret, &Out{backend: b.backend, out: zero.Outs[i]})
+	}
+	return &ret, nil
+}
+
+func (b *Block) Nils(ctx context.Context) (*[]common.Hash, error) {
+	zero, err := b.resolveZero(ctx)
+	if err != nil || zero == nil {
+		return nil, err
+	}
+	if err := charge(ctx, len(zero.Nils)); err != nil {
+		return nil, err
+	}
+	ret := make([]common.Hash, 0, len(zero.Nils))
+	for _, n := range zero.Nils {
+		ret = append(ret, uint256ToHash(n))
+	}
+	return &ret, nil
+}
+
+func (b *Block) Shares(ctx context.Context) ([]*Share, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shares, _ := stake.GetBlockRecords(b.backend.ChainDb(), b.hash, header.Number.Uint64())
+	if err := charge(ctx, len(shares)); err != nil {
+		return nil, err
+	}
+	ret := make([]*Share, 0, len(shares))
+	for _, share := range shares {
+		ret = append(ret, &Share{backend: b.backend, share: share})
+	}
+	return ret, nil
+}
+
+func (b *Block) StakePools(ctx context.Context) ([]*StakePool, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, pools := stake.GetBlockRecords(b.backend.ChainDb(), b.hash, header.Number.Uint64())
+	if err := charge(ctx, len(pools)); err != nil {
+		return nil, err
+	}
+	ret := make([]*StakePool, 0, len(pools))
+	for _, pool := range pools {
+		ret = append(ret, &StakePool{backend: b.backend, pool: pool})
+	}
+	return ret, nil
+}
+
+// Vote represents a vote of a share, or of its stake pool, in a block header.
+type Vote struct {
+	backend ethapi.Backend
+	vote    types.HeaderVote
+}
+
+func newVotes(backend ethapi.Backend, votes []types.HeaderVote) []*Vote {
+	ret := make([]*Vote, 0, len(votes))
+	for _, vote := range votes {
+		ret = append(ret, &Vote{backend: backend, vote: vote})
+	}
+	return ret
+}
+
+func (v *Vote) Id(ctx context.Context) common.Hash {
+	return v.vote.Id
+}
+
+func (v *Vote) IsPool(ctx context.Context) bool {
+	return v.vote.IsPool
+}
+
+func (v *Vote) Signature(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(v.vote.Sign[:])
+}
+
+func (v *Vote) Share(ctx context.Context) (*Share, error) {
+	return getShare(ctx, v.backend, v.vote.Id)
+}
+
+// Pool returns the stake pool voting on behalf of the share, the id of a vote
+// being the one of its share even for the votes of stake pools.
+func (v *Vote) Pool(ctx context.Context) (*StakePool, error) {
+	if !v.vote.IsPool {
+		return nil, nil
+	}
+	share, err := getShare(ctx, v.backend, v.vote.Id)
+	if share == nil || err != nil {
+		return nil, err
+	}
+	if share.share.PoolId == nil {
+		return nil, nil
+	}
+	return getStakePool(ctx, v.backend, *share.share.PoolId)
+}
+
+// Share represents a share of the proof of stake.
+type Share struct {
+	backend ethapi.Backend
+	share   *stake.Share
+}
+
+// stakeState returns the stake state of the latest block.
+func stakeState(ctx context.Context, backend ethapi.Backend) (*stake.StakeState, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	state, _, err := backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return stake.NewStakeState(state), nil
+}
+
+// getShare returns the current state of a share, nil if unknown.
+func getShare(ctx context.Context, backend ethapi.Backend, id common.Hash) (*Share, error) {
+	state, err := stakeState(ctx, backend)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	share := state.GetShare(id)
+	if share == nil {
+		return nil, nil
+	}
+	return &Share{backend: backend, share: share}, nil
+}
+
+func (s *Share) Id(ctx context.Context) common.Hash {
+	return common.BytesToHash(s.share.Id())
+}
+
+func (s *Share) Owner(ctx context.Context) common.Address {
+	return common.BytesToAddress(s.share.PKr[:])
+}
+
+func (s *Share) Voter(ctx context.Context) common.Address {
+	return common.BytesToAddress(s.share.VotePKr[:])
+}
+
+func (s *Share) Transaction(ctx context.Context) *Transaction {
+	return &Transaction{backend: s.backend, hash: s.share.TransactionHash}
+}
+
+func (s *Share) Pool(ctx context.Context) (*StakePool, error) {
+	if s.share.PoolId == nil {
+		return nil, nil
+	}
+	return getStakePool(ctx, s.backend, *s.share.PoolId)
+}
+
+func (s *Share) Price(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(s.share.Value)
+}
+
+func (s *Share) BlockNumber(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(s.share.BlockNumber)
+}
+
+func (s *Share) InitialCount(ctx context.Context) int32 {
+	return int32(s.share.InitNum)
+}
+
+func (s *Share) Remaining(ctx context.Context) int32 {
+	return int32(s.share.Num)
+}
+
+func (s *Share) Missed(ctx context.Context) int32 {
+	return int32(s.share.WillVoteNum)
+}
+
+func (s *Share) Fee(ctx context.Context) int32 {
+	return int32(s.share.Fee)
+}
+
+func (s *Share) Status(ctx context.Context) int32 {
+	return int32(s.share.Status)
+}
+
+func (s *Share) Income(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(s.share.Income)
+}
+
+func (s *Share) Profit(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(s.share.Profit)
+}
+
+func (s *Share) LastPayTime(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(s.share.LastPayTime)
+}
+
+// StakePool represents a stake pool.
+type StakePool struct {
+	backend ethapi.Backend
+	pool    *stake.StakePool
+}
+
+// getStakePool returns the current state of a stake pool, nil if unknown.
+func getStakePool(ctx context.Context, backend ethapi.Backend, id common.Hash) (*StakePool, error) {
+	state, err := stakeState(ctx, backend)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	pool := state.GetStakePool(id)
+	if pool == nil {
+		return nil, nil
+	}
+	return &StakePool{backend: backend, pool: pool}, nil
+}
+
+func (p *StakePool) Id(ctx context.Context) common.Hash {
+	return common.BytesToHash(p.pool.Id())
+}
+
+func (p *StakePool) Owner(ctx context.Context) common.Address {
+	return common.BytesToAddress(p.pool.PKr[:])
+}
+
+func (p *StakePool) Voter(ctx context.Context) common.Address {
+	return common.BytesToAddress(p.pool.VotePKr[:])
+}
+
+func (p *StakePool) Transaction(ctx context.Context) *Transaction {
+	return &Transaction{backend: p.backend, hash: p.pool.TransactionHash}
+}
+
+func (p *StakePool) BlockNumber(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(p.pool.BlockNumber)
+}
+
+func (p *StakePool) Amount(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(p.pool.Amount)
+}
+
+func (p *StakePool) Fee(ctx context.Context) int32 {
+	return int32(p.pool.Fee)
+}
+
+func (p *StakePool) ShareCount(ctx context.Context) int32 {
+	return int32(p.pool.CurrentShareNum)
+}
+
+func (p *StakePool) WishVoteCount(ctx context.Context) int32 {
+	return int32(p.pool.WishVoteNum)
+}
+
+func (p *StakePool) ChoicedCount(ctx context.Context) int32 {
+	return int32(p.pool.ChoicedShareNum)
+}
+
+func (p *StakePool) MissedCount(ctx context.Context) int32 {
+	return int32(p.pool.MissedVoteNum)
+}
+
+func (p *StakePool) ExpiredCount(ctx context.Context) int32 {
+	return int32(p.pool.ExpireNum)
+}
+
+func (p *StakePool) Income(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(p.pool.Income)
+}
+
+func (p *StakePool) Profit(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(p.pool.Profit)
+}
+
+func (p *StakePool) LastPayTime(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(p.pool.LastPayTime)
+}
+
+func (p *StakePool) Closed(ctx context.Context) bool {
+	return p.pool.Closed
+}
+
+// Out represents an output of the zero-knowledge UTXO set.
+type Out struct {
+	backend ethapi.Backend
+	out     txtool.Out
+}
+
+func (o *Out) Root(ctx context.Context) common.Hash {
+	return uint256ToHash(o.out.Root)
+}
+
+func (o *Out) Index(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(o.out.State.OS.Index)
+}
+
+func (o *Out) Transaction(ctx context.Context) *Transaction {
+	return &Transaction{backend: o.backend, hash: uint256ToHash(o.out.State.TxHash)}
+}
+
+func (o *Out) IsZ(ctx context.Context) bool {
+	return o.out.State.OS.Out_Z != nil
+}
+
+func (o *Out) Owner(ctx context.Context) *common.Address {
+	var owner common.Address
+	switch os := o.out.State.OS; {
+	case os.Out_O != nil:
+		owner = common.BytesToAddress(os.Out_O.Addr[:])
+	case os.Out_Z != nil:
+		owner = common.BytesToAddress(os.Out_Z.PKr[:])
+	default:
+		return nil
+	}
+	return &owner
+}
+
+func (o *Out) Currency(ctx context.Context) *string {
+	if os := o.out.State.OS; os.Out_O != nil && os.Out_O.Asset.Tkn != nil {
+		currency := common.BytesToString(os.Out_O.Asset.Tkn.Currency[:])
+		return &currency
+	}
+	return nil
+}
+
+func (o *Out) Value(ctx context.Context) *hexutil.Big {
+	if os := o.out.State.OS; os.Out_O != nil && os.Out_O.Asset.Tkn != nil {
+		return (*hexutil.Big)(os.Out_O.Asset.Tkn.Value.ToIntRef())
+	}
+	return nil
+}
+
+func (o *Out) Category(ctx context.Context) *string {
+	if os := o.out.State.OS; os.Out_O != nil && os.Out_O.Asset.Tkt != nil {
+		category := common.BytesToString(os.Out_O.Asset.Tkt.Category[:])
+		return &category
+	}
+	return nil
+}
+
+func (o *Out) Ticket(ctx context.Context) *common.Hash {
+	if os := o.out.State.OS; os.Out_O != nil && os.Out_O.Asset.Tkt != nil {
+		ticket := uint256ToHash(os.Out_O.Asset.Tkt.Value)
+		return &ticket
+	}
+	return nil
+}
+
+func (o *Out) OutCM(ctx context.Context) *common.Hash {
+	if o.out.State.OS.OutCM == nil {
+		return nil
+	}
+	cm := uint256ToHash(*o.out.State.OS.OutCM)
+	return &cm
+}
+
+func (o *Out) RootCM(ctx context.Context) *common.Hash {
+	if o.out.State.OS.RootCM == nil {
+		return nil
+	}
+	cm := uint256ToHash(*o.out.State.OS.RootCM)
+	return &cm
+}
+
+// Resolver is the root resolver of the queries.
+type Resolver struct {
+	backend ethapi.Backend
+}
+
+func (r *Resolver) Block(ctx context.Context, args struct {
+	Number *hexutil.Uint64
+	Hash   *common.Hash
+}) (*Block, error) {
+	var block *Block
+	switch {
+	case args.Hash != nil:
+		block = &Block{backend: r.backend, hash: *args.Hash}
+	case args.Number != nil:
+		num := rpc.BlockNumber(*args.Number)
+		block = &Block{backend: r.backend, num: &num}
+	default:
+		num := rpc.LatestBlockNumber
+		block = &Block{backend: r.backend, num: &num}
+	}
+	// Resolve the block, returning null if unknown
+	if b, err := block.resolve(ctx); err != nil || b == nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (r *Resolver) Blocks(ctx context.Context, args struct {
+	From hexutil.Uint64
+	To   *hexutil.Uint64
+}) ([]*Block, error) {
+	from := uint64(args.From)
+
+	var to uint64
+	if args.To != nil {
+		to = uint64(*args.To)
+	} else {
+		to = r.backend.CurrentBlock().NumberU64()
+	}
+	if to < from {
+		return []*Block{}, nil
+	}
+	if to-from >= maxBlockRange {
+		return nil, fmt.Errorf("block range too large: %d blocks, want at most %d", to-from+1, maxBlockRange)
+	}
+	ret := make([]*Block, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		num := rpc.BlockNumber(i)
+		block := &Block{backend: r.backend, num: &num}
+		// Stop at the head of the chain
+		if b, err := block.resolve(ctx); err != nil {
+			return nil, err
+		} else if b == nil {
+			break
+		}
+		ret = append(ret, block)
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Transaction(ctx context.Context, args struct{ Hash common.Hash }) (*Transaction, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	tx := &Transaction{backend: r.backend, hash: args.Hash}
+	// Resolve the transaction, returning null if unknown
+	if tx.resolve(ctx) == nil {
+		return nil, nil
+	}
+	return tx, nil
+}
+
+func (r *Resolver) Share(ctx context.Context, args struct{ Id common.Hash }) (*Share, error) {
+	return getShare(ctx, r.backend, args.Id)
+}
+
+func (r *Resolver) StakePool(ctx context.Context, args struct{ Id common.Hash }) (*StakePool, error) {
+	return getStakePool(ctx, r.backend, args.Id)
+}
+
+func (r *Resolver) SharePrice(ctx context.Context) (hexutil.Big, error) {
+	state, err := stakeState(ctx, r.backend)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	if state == nil {
+		return hexutil.Big{}, errors.New("latest state not found")
+	}
+	return hexutil.Big(*state.CurrentPrice()), nil
+}
+
+func (r *Resolver) SharePoolSize(ctx context.Context) (int32, error) {
+	state, err := stakeState(ctx, r.backend)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return 0, errors.New("latest state not found")
+	}
+	return int32(state.ShareSize()), nil
+}
+
+// page returns the bounds of a page of a list of the given length, of at most
+// maxPageSize items.
+func page(length int, first, skip *int32) (int, int) {
+	start, size := 0, maxPageSize
+	if skip != nil && *skip > 0 {
+		start = int(*skip)
+	}
+	if first != nil && *first >= 0 && int(*first) < size {
+		size = int(*first)
+	}
+	if start > length {
+		start = length
+	}
+	end := start + size
+	if end > length {
+		end = length
+	}
+	return start, end
+}
+
+func uint256ToHash(u keys.Uint256) common.Hash {
+	return common.BytesToHash(u[:])
+}
@@ -0,0 +1,378 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/internal/ethapi"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+// testBackend is a chain of blocks held in memory, each with its own number
+// of transactions, every transaction emitting one log.
+type testBackend struct {
+	ethapi.Backend
+
+	db       serodb.Database
+	state    *state.StateDB // Latest state, nil if unknown
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	zero     map[uint64]txtool.Block
+}
+
+func newTestBackend(blocks int, txs int) *testBackend {
+	b := &testBackend{
+		db:       serodb.NewMemDatabase(),
+		receipts: make(map[common.Hash]types.Receipts),
+		zero:     make(map[uint64]txtool.Block),
+	}
+	parent := common.Hash{}
+	for i := 0; i < blocks; i++ {
+		var (
+			transactions []*types.Transaction
+			receipts     types.Receipts
+		)
+		for j := 0; j < txs; j++ {
+			t := &stx.T{}
+			t.Ehash[0], t.Ehash[1] = byte(i), byte(j)
+			tx := types.NewTxWithGTx(21000, big.NewInt(1), t)
+			transactions = append(transactions, tx)
+			receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: uint64(j+1) * 21000}
+			receipt.Logs = []*types.Log{{Index: uint(j), Data: []byte{byte(j)}}}
+			receipts = append(receipts, receipt)
+		}
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Difficulty: big.NewInt(1)}
+		block := types.NewBlock(header, transactions, receipts)
+		b.blocks = append(b.blocks, block)
+		b.receipts[block.Hash()] = receipts
+		parent = block.Hash()
+	}
+	return b
+}
+
+func (b *testBackend) ChainDb() serodb.Database { return b.db }
+
+func (b *testBackend) CurrentBlock() *types.Block { return b.blocks[len(b.blocks)-1] }
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.CurrentBlock(), nil
+	}
+	if number < 0 || int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	for _, block := range b.blocks {
+		if block.Hash() == hash {
+			return block, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts[hash], nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	return b.state, b.CurrentBlock().Header(), nil
+}
+
+func (b *testBackend) GetTd(hash common.Hash) *big.Int { return big.NewInt(1) }
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) GetBlocksInfo(start uint64, count uint64) ([]txtool.Block, error) {
+	if info, ok := b.zero[start]; ok {
+		return []txtool.Block{info}, nil
+	}
+	return nil, nil
+}
+
+type testResult struct {
+	Data   json.RawMessage
+	Errors []struct{ Message string }
+}
+
+// query runs a query through the handler of the service.
+func query(t *testing.T, backend ethapi.Backend, q string) *testResult {
+	h, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"query": q})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("query failed: %d %s", w.Code, w.Body)
+	}
+	result := new(testResult)
+	if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body, err)
+	}
+	return result
+}
+
+// Tests that every field of the schema has a resolver.
+func TestBuildSchema(t *testing.T) {
+	if _, err := newHandler(nil); err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+}
+
+func TestQueryBlock(t *testing.T) {
+	backend := newTestBackend(4, 3)
+
+	result := query(t, backend, `{ block(number: 2) { number parent { number hash } transactionCount
+		transactions(first: 2, skip: 1) { hash index status gasUsed block { number } logs { index data } } } }`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	var data struct {
+		Block struct {
+			Number           string
+			Parent           struct{ Number, Hash string }
+			TransactionCount int
+			Transactions     []struct {
+				Hash    string
+				Index   int
+				Status  string
+				GasUsed string
+				Block   struct{ Number string }
+				Logs    []struct {
+					Index int
+					Data  string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	block := data.Block
+	if block.Number != "0x2" || block.Parent.Number != "0x1" || block.Parent.Hash != backend.blocks[1].Hash().Hex() {
+		t.Errorf("block mismatch: %+v", block)
+	}
+	if block.TransactionCount != 3 || len(block.Transactions) != 2 {
+		t.Fatalf("transactions mismatch: count %d, page %d", block.TransactionCount, len(block.Transactions))
+	}
+	for i, tx := range block.Transactions {
+		want := backend.blocks[2].Transactions()[i+1]
+		if tx.Hash != want.Hash().Hex() || tx.Index != i+1 || tx.Block.Number != "0x2" {
+			t.Errorf("transaction %d mismatch: %+v", i, tx)
+		}
+		if tx.Status != "0x1" || tx.GasUsed != "0x5208" {
+			t.Errorf("transaction %d receipt mismatch: %+v", i, tx)
+		}
+		if len(tx.Logs) != 1 || tx.Logs[0].Index != i+1 {
+			t.Errorf("transaction %d logs mismatch: %+v", i, tx.Logs)
+		}
+	}
+
+	// Unknown blocks resolve to null
+	result = query(t, backend, `{ block(number: 10) { number } }`)
+	if len(result.Errors) > 0 || string(result.Data) != `{"block":null}` {
+		t.Errorf("unknown block: %s %v", result.Data, result.Errors)
+	}
+}
+
+func TestQueryBlocks(t *testing.T) {
+	backend := newTestBackend(8, 0)
+
+	result := query(t, backend, `{ blocks(from: 5) { number } }`)
+	if len(result.Errors) > 0 || string(result.Data) != `{"blocks":[{"number":"0x5"},{"number":"0x6"},{"number":"0x7"}]}` {
+		t.Errorf("blocks to the head: %s %v", result.Data, result.Errors)
+	}
+	result = query(t, backend, `{ blocks(from: 6, to: 20) { number } }`)
+	if len(result.Errors) > 0 || string(result.Data) != `{"blocks":[{"number":"0x6"},{"number":"0x7"}]}` {
+		t.Errorf("blocks past the head: %s %v", result.Data, result.Errors)
+	}
+	result = query(t, backend, `{ blocks(from: 0, to: 100) { number } }`)
+	if len(result.Errors) == 0 {
+		t.Errorf("range of %d blocks accepted", 101)
+	}
+}
+
+func TestQueryOuts(t *testing.T) {
+	backend := newTestBackend(2, 0)
+	info := txtool.Block{Num: 1, Hash: *backend.blocks[1].Hash().HashToUint256()}
+	for i := 0; i < 5; i++ {
+		root := keys.Uint256{byte(i + 1)}
+		info.Outs = append(info.Outs, txtool.Out{Root: root, State: localdb.RootState{OS: localdb.OutState{Index: uint64(i)}}})
+	}
+	info.Nils = []keys.Uint256{{9}}
+	backend.zero[1] = info
+
+	result := query(t, backend, `{ block(number: 1) { outs(first: 2, skip: 3) { index isZ } nils } }`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	want := `{"block":{"outs":[{"index":"0x3","isZ":false},{"index":"0x4","isZ":false}],"nils":["` + uint256ToHash(info.Nils[0]).Hex() + `"]}}`
+	if string(result.Data) != want {
+		t.Errorf("outs mismatch: have %s, want %s", result.Data, want)
+	}
+	// Blocks not indexed by the local database have no outputs
+	result = query(t, backend, `{ block(number: 0) { outs { index } } }`)
+	if len(result.Errors) > 0 || string(result.Data) != `{"block":{"outs":null}}` {
+		t.Errorf("unindexed block: %s %v", result.Data, result.Errors)
+	}
+}
+
+// Tests that the votes resolve to the share voted for, and the votes of stake
+// pools also to the pool of the share.
+func TestQueryVotes(t *testing.T) {
+	backend := newTestBackend(1, 0)
+	backend.state, _ = state.New(state.NewDatabase(backend.db), nil)
+	stakeState := stake.NewStakeState(backend.state)
+
+	var poolPKr, sharePKr keys.PKr
+	copy(poolPKr[:], crypto.Keccak512([]byte("pool")))
+	copy(sharePKr[:], crypto.Keccak512([]byte("share")))
+	pool := &stake.StakePool{PKr: poolPKr, Amount: big.NewInt(1)}
+	stakeState.AddStakePool(pool)
+	poolId := common.BytesToHash(pool.Id())
+	pooled := &stake.Share{PKr: sharePKr, Value: big.NewInt(1), InitNum: 1, PoolId: &poolId}
+	solo := &stake.Share{PKr: sharePKr, Value: big.NewInt(2), InitNum: 1}
+	stakeState.AddPendingShare(pooled)
+	stakeState.AddPendingShare(solo)
+	pooledId, soloId := common.BytesToHash(pooled.Id()), common.BytesToHash(solo.Id())
+
+	header := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1), CurrentVotes: []types.HeaderVote{
+		{Id: pooledId, IsPool: true},
+		{Id: soloId, IsPool: false},
+	}}
+	backend.blocks[0] = types.NewBlockWithHeader(header)
+
+	result := query(t, backend, `{ block { currentVotes { id isPool share { id } pool { id } } } }`)
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	var data struct {
+		Block struct {
+			CurrentVotes []struct {
+				Id     string
+				IsPool bool
+				Share  *struct{ Id string }
+				Pool   *struct{ Id string }
+			}
+		}
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	votes := data.Block.CurrentVotes
+	if len(votes) != 2 {
+		t.Fatalf("vote count mismatch: have %d, want 2", len(votes))
+	}
+	if vote := votes[0]; vote.Id != pooledId.Hex() || !vote.IsPool || vote.Share == nil || vote.Share.Id != pooledId.Hex() || vote.Pool == nil || vote.Pool.Id != poolId.Hex() {
+		t.Errorf("pool vote mismatch: %+v", vote)
+	}
+	if vote := votes[1]; vote.Id != soloId.Hex() || vote.IsPool || vote.Share == nil || vote.Share.Id != soloId.Hex() || vote.Pool != nil {
+		t.Errorf("share vote mismatch: %+v", vote)
+	}
+}
+
+func TestQueryComplexity(t *testing.T) {
+	backend := newTestBackend(100, 10)
+
+	result := query(t, backend, `{ blocks(from: 0, to: 9) { transactions { hash } } }`)
+	if len(result.Errors) > 0 {
+		t.Errorf("small query failed: %v", result.Errors)
+	}
+	result = query(t, backend, `{ blocks(from: 0, to: 99) { transactions { hash } } }`)
+	if len(result.Errors) == 0 || result.Errors[0].Message != errQueryTooComplex.Error() {
+		t.Errorf("complex query answered: %v", result.Errors)
+	}
+}
+
+func TestServiceGuards(t *testing.T) {
+	auth, err := rpc.NewAuthenticator(&rpc.AuthConfig{
+		Credentials: []rpc.Credential{
+			{Name: "reader", Key: "reader-key", Allow: []string{"graphql"}},
+			{Name: "other", Key: "other-key", Allow: []string{"sero"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := rpc.NewRateLimiter(&rpc.RateLimitConfig{Rate: 0.001, Burst: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(newTestBackend(1, 0), "", nil, nil, rpc.DefaultHTTPTimeouts, auth, limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(key string, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("content-type", "application/json")
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		s.handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	q := `{"query": "{ block { number } }"}`
+
+	if code := send("", q); code != http.StatusUnauthorized {
+		t.Errorf("query without credential: have %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := send("other-key", q); code != http.StatusForbidden {
+		t.Errorf("query of another namespace: have %d, want %d", code, http.StatusForbidden)
+	}
+	if code := send("reader-key", q); code != http.StatusOK {
+		t.Errorf("allowed query: have %d, want %d", code, http.StatusOK)
+	}
+	if code := send("reader-key", `{"query": "`+strings.Repeat(" ", 1024*1024)+`"}`); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large query: have %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+	// A query costs 10 of the burst of 15
+	if code := send("reader-key", q); code != http.StatusTooManyRequests {
+		t.Errorf("rate limited query: have %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+// Tests that the pages of the lists are bounded.
+func TestPage(t *testing.T) {
+	num := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		length      int
+		first, skip *int32
+		start, end  int
+	}{
+		{10, nil, nil, 0, 10},
+		{10, num(3), nil, 0, 3},
+		{10, num(3), num(8), 8, 10},
+		{10, nil, num(20), 10, 10},
+		{10, num(-1), num(-1), 0, 10},
+		{1000, nil, nil, 0, maxPageSize},
+		{1000, num(500), num(10), 10, 10 + maxPageSize},
+	}
+	for i, tt := range tests {
+		start, end := page(tt.length, tt.first, tt.skip)
+		if start != tt.start || end != tt.end {
+			t.Errorf("test %d: have [%d, %d), want [%d, %d)", i, start, end, tt.start, tt.end)
+		}
+	}
+}
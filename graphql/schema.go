@@ -0,0 +1,288 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+const schema string = `
+    # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes32
+    # Address is a 96 byte account address or one-time address (PKr),
+    # represented as base58.
+    scalar Address
+    # Bytes is an arbitrary length binary string, represented as 0x-prefixed
+    # hexadecimal. An empty byte string is represented as '0x'.
+    scalar Bytes
+    # BigInt is a large integer, represented as 0x-prefixed hexadecimal. Input
+    # is also accepted as an integer.
+    scalar BigInt
+    # Long is a 64 bit unsigned integer, represented as 0x-prefixed hexadecimal.
+    # Input is also accepted as an integer.
+    scalar Long
+
+    schema {
+        query: Query
+    }
+
+    # Log is a log entry emitted by a contract.
+    type Log {
+        # Index is the index of this log in the block.
+        index: Int!
+        # Account is the contract that emitted this log.
+        account: Address!
+        # Topics is the list of 0-4 indexed topics of the log.
+        topics: [Bytes32!]!
+        # Data is the unindexed data of this log.
+        data: Bytes!
+        # Transaction is the transaction that generated this log entry.
+        transaction: Transaction!
+    }
+
+    # Transaction is a transaction, mined or pending.
+    type Transaction {
+        # Hash is the hash of this transaction.
+        hash: Bytes32!
+        # From is the one-time address paying for this transaction.
+        from: Address!
+        # To is the contract called by this transaction, or the receiver of its
+        # first open output. Null for contract creations and fully private
+        # transactions.
+        to: Address
+        # Gas is the maximum gas this transaction may spend.
+        gas: Long!
+        # GasPrice is the price of a unit of gas of this transaction.
+        gasPrice: BigInt!
+        # InputData is the data supplied to the contract called.
+        inputData: Bytes!
+        # Block is the block this transaction was mined in, null if pending.
+        block: Block
+        # Index is the index of this transaction in its block, null if pending.
+        index: Int
+        # Status is the result of the execution of this transaction, 1 on
+        # success and 0 on failure. Null if pending.
+        status: Long
+        # GasUsed is the gas spent by this transaction, null if pending.
+        gasUsed: Long
+        # CumulativeGasUsed is the gas spent by the block up to and including
+        # this transaction, null if pending.
+        cumulativeGasUsed: Long
+        # ShareId is the share bought by this transaction, if any.
+        shareId: Bytes32
+        # PoolId is the stake pool registered or modified by this transaction,
+        # if any.
+        poolId: Bytes32
+        # Logs is the list of logs emitted by this transaction, null if
+        # pending.
+        logs: [Log!]
+    }
+
+    # Vote is a vote of a share, or of its stake pool, included in a block
+    # header.
+    type Vote {
+        # Id is the id of the share voted for, also for the votes of stake
+        # pools.
+        id: Bytes32!
+        # IsPool is true if the vote is cast by the stake pool of the share on
+        # its behalf.
+        isPool: Boolean!
+        # Signature is the signature of the vote.
+        signature: Bytes!
+        # Share is the current state of the share voted for.
+        share: Share
+        # Pool is the current state of the stake pool of the share, null for
+        # the votes cast by the share itself.
+        pool: StakePool
+    }
+
+    # Share is a share of the proof of stake, voting for the blocks.
+    type Share {
+        # Id is the id of this share.
+        id: Bytes32!
+        # Owner is the one-time address owning this share.
+        owner: Address!
+        # Voter is the one-time address voting with this share.
+        voter: Address!
+        # Transaction is the transaction buying this share.
+        transaction: Transaction!
+        # Pool is the stake pool voting with this share, if any.
+        pool: StakePool
+        # Price is the price paid per vote of this share.
+        price: BigInt
+        # BlockNumber is the number of the block this share was bought in.
+        blockNumber: Long!
+        # InitialCount is the number of votes bought.
+        initialCount: Int!
+        # Remaining is the number of votes left, or the number of expired votes
+        # if this share is no longer valid.
+        remaining: Int!
+        # Missed is the number of votes selected but not cast.
+        missed: Int!
+        # Fee is the fee rate of the stake pool, in ten thousandths.
+        fee: Int!
+        # Status is 0 if this share is valid, 1 if it expired and 2 if it is
+        # finished.
+        status: Int!
+        # Income is the reward earned by this share and not paid yet.
+        income: BigInt
+        # Profit is the reward earned by this share.
+        profit: BigInt
+        # LastPayTime is the number of the block paying this share last.
+        lastPayTime: Long!
+    }
+
+    # StakePool is a stake pool, voting on behalf of the shares it holds.
+    type StakePool {
+        # Id is the id of this stake pool.
+        id: Bytes32!
+        # Owner is the one-time address owning this stake pool.
+        owner: Address!
+        # Voter is the one-time address voting for this stake pool.
+        voter: Address!
+        # Transaction is the transaction registering this stake pool.
+        transaction: Transaction!
+        # BlockNumber is the number of the block this stake pool was
+        # registered in.
+        blockNumber: Long!
+        # Amount is the amount locked by the registration of this stake pool.
+        amount: BigInt
+        # Fee is the fee rate of this stake pool, in ten thousandths.
+        fee: Int!
+        # ShareCount is the number of votes of the shares of this stake pool.
+        shareCount: Int!
+        # WishVoteCount is the number of votes selected but not cast yet.
+        wishVoteCount: Int!
+        # ChoicedCount is the number of votes selected.
+        choicedCount: Int!
+        # MissedCount is the number of votes selected but not cast.
+        missedCount: Int!
+        # ExpiredCount is the number of expired votes.
+        expiredCount: Int!
+        # Income is the reward earned by this stake pool and not paid yet.
+        income: BigInt
+        # Profit is the reward earned by this stake pool.
+        profit: BigInt
+        # LastPayTime is the number of the block paying this stake pool last.
+        lastPayTime: Long!
+        # Closed is true if this stake pool was closed.
+        closed: Boolean!
+    }
+
+    # Out is an output of the zero-knowledge UTXO set.
+    type Out {
+        # Root is the root of this output.
+        root: Bytes32!
+        # Index is the position of this output in the commitment tree.
+        index: Long!
+        # Transaction is the transaction creating this output.
+        transaction: Transaction!
+        # IsZ is true if the content of this output is private.
+        isZ: Boolean!
+        # Owner is the one-time address receiving this output.
+        owner: Address
+        # Currency is the token of an open output, if any.
+        currency: String
+        # Value is the amount of tokens of an open output.
+        value: BigInt
+        # Category is the category of the ticket of an open output, if any.
+        category: String
+        # Ticket is the ticket of an open output.
+        ticket: Bytes32
+        # OutCM is the commitment of a private output.
+        outCM: Bytes32
+        # RootCM is the commitment of this output in the commitment tree.
+        rootCM: Bytes32
+    }
+
+    # Block is a block of the chain.
+    type Block {
+        # Number is the number of this block, starting at 0 for the genesis
+        # block.
+        number: Long!
+        # Hash is the block hash of this block.
+        hash: Bytes32!
+        # Parent is the parent block of this block, null for the genesis block.
+        parent: Block
+        # Miner is the one-time address receiving the mining reward.
+        miner: Address!
+        # StateRoot is the root of the state trie after this block.
+        stateRoot: Bytes32!
+        # TransactionsRoot is the root of the trie of the transactions.
+        transactionsRoot: Bytes32!
+        # ReceiptsRoot is the root of the trie of the transaction receipts.
+        receiptsRoot: Bytes32!
+        # LogsBloom is the bloom filter of the logs of this block.
+        logsBloom: Bytes!
+        # Difficulty is the proof of work difficulty of this block.
+        difficulty: BigInt!
+        # TotalDifficulty is the difficulty of the chain up to this block.
+        totalDifficulty: BigInt!
+        # GasLimit is the maximum gas the transactions of this block may spend.
+        gasLimit: Long!
+        # GasUsed is the gas spent by the transactions of this block.
+        gasUsed: Long!
+        # Timestamp is the unix timestamp of this block.
+        timestamp: Long!
+        # ExtraData is the data set by the miner of this block.
+        extraData: Bytes!
+        # MixHash is the proof of work mix hash of this block.
+        mixHash: Bytes32!
+        # Nonce is the proof of work nonce of this block.
+        nonce: Bytes!
+        # TransactionCount is the number of transactions in this block.
+        transactionCount: Int!
+        # Transactions is a page of the transactions of this block, of at most
+        # 100 transactions.
+        transactions(first: Int, skip: Int): [Transaction!]!
+        # TransactionAt returns the transaction at the index, null if out of
+        # range.
+        transactionAt(index: Int!): Transaction
+        # Logs is the list of logs emitted by the transactions of this block.
+        logs: [Log!]!
+        # CurrentVotes are the votes for this block.
+        currentVotes: [Vote!]!
+        # ParentVotes are the late votes for the parent block.
+        parentVotes: [Vote!]!
+        # Outs is a page of the outputs created by this block, of at most 100
+        # outputs. Null until the block is confirmed, or if the node doesn't
+        # index the outputs.
+        outs(first: Int, skip: Int): [Out!]
+        # Nils are the nullifiers of the outputs spent by this block. Null until
+        # the block is confirmed, or if the node doesn't index the outputs.
+        nils: [Bytes32!]
+        # Shares are the shares bought or updated by this block.
+        shares: [Share!]!
+        # StakePools are the stake pools registered or updated by this block.
+        stakePools: [StakePool!]!
+    }
+
+    type Query {
+        # Block fetches a block by number or by hash, the latest block if
+        # neither is given.
+        block(number: Long, hash: Bytes32): Block
+        # Blocks returns the blocks in the range, of at most 100 blocks, ending
+        # at the latest block if 'to' is not given.
+        blocks(from: Long!, to: Long): [Block!]!
+        # Transaction returns a transaction by its hash.
+        transaction(hash: Bytes32!): Transaction
+        # Share returns the current state of a share.
+        share(id: Bytes32!): Share
+        # StakePool returns the current state of a stake pool.
+        stakePool(id: Bytes32!): StakePool
+        # SharePrice is the current price of a vote of a share.
+        sharePrice: BigInt!
+        # SharePoolSize is the number of votes of the valid shares.
+        sharePoolSize: Int!
+    }
+`
@@ -24,6 +24,7 @@ import (
 
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
 )
 
 // NotFound is returned by API methods if the requested item does not exist.
@@ -119,6 +120,7 @@ type CallMsg struct {
 	GasPrice *big.Int        // wei <-> gas exchange ratio
 	Value    *big.Int        // amount of wei sent along with the call
 	Data     []byte          // input data, usually an ABI-encoded contract method invocation
+	Asset    *assets.Asset   // token and ticket sent along with the call
 }
 
 // A ContractCaller provides contract calls, essentially transactions that are executed by
@@ -48,6 +48,7 @@ import (
 	"github.com/sero-cash/go-czero-import/keys"
 	"github.com/sero-cash/go-czero-import/seroparam"
 	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/accounts/abi"
 	"github.com/sero-cash/go-sero/accounts/keystore"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/common/hexutil"
@@ -95,6 +96,22 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*hexutil.Big, error)
 	return (*hexutil.Big)(price), err
 }
 
+// SuggestFee returns a suggestion for the gas price of a transaction paying its
+// fee in the given currency, in that currency. It uses the token rate of the
+// contract to, or of the contract which issued the token if to is not given.
+func (s *PublicEthereumAPI) SuggestFee(ctx context.Context, currency Smbol, to *ContractAddress) (*hexutil.Big, error) {
+	if currency.IsEmpty() {
+		currency = Smbol(params.DefaultCurrency)
+	}
+	var contract *common.Address
+	if to != nil {
+		addr := common.BytesToAddress(to[:])
+		contract = &addr
+	}
+	price, err := s.b.SuggestFee(ctx, strings.TrimSpace(string(currency)), contract)
+	return (*hexutil.Big)(price), err
+}
+
 // ProtocolVersion returns the current Ethereum protocol version this node supports
 func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -1065,6 +1082,47 @@ func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Addres
 	return code, state.Error()
 }
 
+// ContractAssets is everything a contract owns.
+type ContractAssets struct {
+	Tkn         map[string]*hexutil.Big  `json:"tkn"`
+	Tkt         map[string][]common.Hash `json:"tkt"`
+	TicketNonce hexutil.Uint64           `json:"ticketNonce"`
+}
+
+// GetContractAssets returns the balances of every currency and the tickets of
+// the contract at the given address and block number. It fails if the node did
+// not execute all the blocks, such as fast synced ones, as the tickets received
+// in them are unknown.
+func (s *PublicBlockChainAPI) GetContractAssets(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*ContractAssets, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if !state.IsContract(address) {
+		return nil, errors.New("not a contract address")
+	}
+	// The tickets received in the pending block are in its state
+	number := header.Number.Uint64()
+	if blockNr == rpc.PendingBlockNumber {
+		number--
+	}
+	received, err := core.ContractTickets(s.b.ChainDb(), number)
+	if err != nil {
+		return nil, err
+	}
+	result := &ContractAssets{
+		Tkn:         map[string]*hexutil.Big{},
+		Tkt:         state.Tickets(address, received),
+		TicketNonce: hexutil.Uint64(state.GetTicketNonce(address)),
+	}
+	for currency, balance := range state.Balances(address) {
+		if balance.Sign() > 0 {
+			result.Tkn[currency] = (*hexutil.Big)(new(big.Int).Set(balance))
+		}
+	}
+	return result, state.Error()
+}
+
 // GetStorageAt returns the storage from the state at the given address, key and
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
@@ -1733,9 +1791,70 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = address.BytesToAccount(receipt.ContractAddress[:64])
 	}
+	if vmErr := newReceiptError(receipt); vmErr != nil {
+		fields["vmError"] = vmErr
+	}
+	fields["sysCalls"] = newRPCSysCalls(rawdb.ReadSysCalls(s.b.ChainDb(), blockHash, blockNumber), hash)
 	return fields, nil
 }
 
+// RPCSysCall is a system call made by a contract during a transaction.
+type RPCSysCall struct {
+	Contract address.AccountAddress `json:"contract"`
+	Name     string                 `json:"name"`
+	Topic    common.Hash            `json:"topic"`
+	Args     vm.SysCall             `json:"args"`
+}
+
+// newRPCSysCalls returns the system calls of the transaction txHash among
+// the ones of its block.
+func newRPCSysCalls(calls []*types.SysCall, txHash common.Hash) []*RPCSysCall {
+	ret := []*RPCSysCall{}
+	for _, call := range calls {
+		if call.TxHash != txHash {
+			continue
+		}
+		args, err := vm.UnpackSysCall(call)
+		if err != nil {
+			log.Error("Invalid stored system call", "tx", txHash, "err", err)
+			continue
+		}
+		ret = append(ret, &RPCSysCall{
+			Contract: address.BytesToAccount(call.Contract[:64]),
+			Name:     vm.SysCallName(args),
+			Topic:    call.Topic,
+			Args:     args,
+		})
+	}
+	return ret
+}
+
+// ReceiptError is the error of a transaction stored with its receipt, if the
+// node stores them.
+type ReceiptError struct {
+	Code         hexutil.Uint  `json:"code"`
+	Name         string        `json:"name"`
+	Message      string        `json:"message"`
+	RevertData   hexutil.Bytes `json:"revertData,omitempty"`
+	RevertReason string        `json:"revertReason,omitempty"` // Error(string) of the revert data
+}
+
+func newReceiptError(receipt *types.Receipt) *ReceiptError {
+	if !receipt.HasError() {
+		return nil
+	}
+	ret := &ReceiptError{
+		Code:       hexutil.Uint(receipt.ErrCode),
+		Name:       vm.ErrorCode(receipt.ErrCode).String(),
+		Message:    receipt.ErrMsg,
+		RevertData: receipt.RevertData,
+	}
+	if len(receipt.RevertData) > 0 {
+		ret.RevertReason, _ = abi.UnpackRevert(receipt.RevertData)
+	}
+	return ret
+}
+
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
 	From        address.AccountAddress `json:"from"`
@@ -121,14 +121,22 @@ func (s *PublicExchangeAPI) GenTx(ctx context.Context, param GenTxArgs) (*txtool
 		return nil, err
 	}
 
-	return s.b.GenTx(param.toTxParam())
+	txParam, err := param.toTxParam(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	return s.b.GenTx(txParam)
 }
 
 func (s *PublicExchangeAPI) GenTxWithSign(ctx context.Context, param GenTxArgs) (*txtool.GTx, error) {
 	if err := param.check(); err != nil {
 		return nil, err
 	}
-	txParam, tx, e := exchange.CurrentExchange().GenTxWithSign(param.toTxParam())
+	preTx, err := param.toTxParam(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	txParam, tx, e := exchange.CurrentExchange().GenTxWithSign(preTx)
 	if tx != nil {
 		for _, in := range txParam.Ins {
 			tx.Roots = append(tx.Roots, in.Out.Root)
@@ -181,6 +189,10 @@ func (s *PublicExchangeAPI) GetTx(ctx context.Context, txHash keys.Uint256) (map
 		"Fee":         (*utils.U256)(fee),
 		"GasPrice":    (*utils.U256)(tx.GasPrice()),
 		"GasUsed":     gasUsed,
+		"Status":      receipt.Status,
+	}
+	if vmErr := newReceiptError(receipt); vmErr != nil {
+		fields["VmError"] = vmErr
 	}
 
 	block, _ := s.b.BlockByNumber(ctx, rpc.BlockNumber(blockNumber))
@@ -0,0 +1,373 @@
+package ethapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/accounts/abi"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/consensus"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/flight"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// SimulateTxArgs holds either a signed transaction or the parameters of an
+// unsigned one, as returned by genTx.
+type SimulateTxArgs struct {
+	Tx    *txtool.GTx
+	Param *txtool.GTxParam
+}
+
+func (args *SimulateTxArgs) UnmarshalJSON(input []byte) error {
+	var probe struct {
+		Tx json.RawMessage
+	}
+	if err := json.Unmarshal(input, &probe); err != nil {
+		return err
+	}
+	if len(probe.Tx) > 0 {
+		args.Tx = new(txtool.GTx)
+		return json.Unmarshal(input, args.Tx)
+	}
+	args.Param = new(txtool.GTxParam)
+	return json.Unmarshal(input, args.Param)
+}
+
+// SimulatedOut is an output created by a simulated transaction.
+type SimulatedOut struct {
+	Root  keys.Uint256  `json:"root"`
+	PKr   PKrAddress    `json:"pkr"`
+	Asset *assets.Asset `json:"asset,omitempty"` // nil for the confidential outputs
+}
+
+// SimulatedPkg is the state of a package after a simulated transaction.
+type SimulatedPkg struct {
+	Id     keys.Uint256 `json:"id"`
+	Owner  PKrAddress   `json:"owner"`
+	Closed bool         `json:"closed"`
+}
+
+// SimulateTxResult is the outcome of a simulated transaction.
+type SimulateTxResult struct {
+	Status          hexutil.Uint            `json:"status"`
+	GasUsed         hexutil.Uint64          `json:"gasUsed"`
+	ContractAddress *address.AccountAddress `json:"contractAddress"`
+	Logs            []*types.Log            `json:"logs"`
+	Outs            []SimulatedOut          `json:"outs"`
+	Spent           []keys.Uint256          `json:"spent"`
+	Pkgs            []SimulatedPkg          `json:"pkgs"`
+	Pool            map[string]interface{}  `json:"pool,omitempty"`
+	Share           map[string]interface{}  `json:"share,omitempty"`
+	Return          hexutil.Bytes           `json:"return,omitempty"`
+	RevertReason    string                  `json:"revertReason,omitempty"`
+	VmError         *ReceiptError           `json:"vmError,omitempty"`
+	Error           string                  `json:"error,omitempty"` // why the transaction would be rejected
+}
+
+// chainContext is a core.ChainContext resolving the headers and the consensus
+// engine through the backend.
+type chainContext struct {
+	ctx context.Context
+	b   Backend
+}
+
+func (c chainContext) Engine() consensus.Engine {
+	return c.b.GetEngin()
+}
+
+func (c chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header, _ := c.b.HeaderByNumber(c.ctx, rpc.BlockNumber(number))
+	if header == nil || header.Hash() != hash {
+		return nil
+	}
+	return header
+}
+
+// SimulateTx applies a transaction on a copy of the pending state without
+// broadcasting it, and returns its outcome. The transaction is either signed,
+// or only the parameters of one. The latter have no signatures, so that the
+// signatures of their package commands are not checked against the state.
+func (s *PublicBlockChainAPI) SimulateTx(ctx context.Context, args SimulateTxArgs) (*SimulateTxResult, error) {
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	result, receipt, err := simulateTx(s.b.ChainConfig(), chainContext{ctx, s.b}, statedb, header, args)
+	if receipt == nil || err != nil {
+		return result, err
+	}
+
+	wallets := s.b.AccountManager().Wallets()
+	stakeState := stake.NewStakeState(statedb)
+	if receipt.PoolId != nil {
+		if pool := stakeState.GetStakePool(*receipt.PoolId); pool != nil {
+			result.Pool = newRPCStakePool(wallets, *pool, header.Time.Uint64())
+		}
+	}
+	if receipt.ShareId != nil {
+		if share := stakeState.GetShare(*receipt.ShareId); share != nil {
+			result.Share = newRPCShare(wallets, *share, header.Time.Uint64())
+		}
+	}
+	return result, nil
+}
+
+// simulateTx applies a transaction on the state of the given header, and
+// returns its outcome with its receipt, nil if the transaction is rejected.
+func simulateTx(config *params.ChainConfig, chain core.ChainContext, statedb *state.StateDB, header *types.Header, args SimulateTxArgs) (*SimulateTxResult, *types.Receipt, error) {
+	zstate := statedb.NextZState()
+	result := &SimulateTxResult{Logs: []*types.Log{}, Outs: []SimulatedOut{}, Spent: []keys.Uint256{}, Pkgs: []SimulatedPkg{}}
+
+	var (
+		tx   *types.Transaction
+		pkgs []SimulatedPkg
+		err  error
+	)
+	roots, dels := len(zstate.State.GetBlockRoots()), len(zstate.State.GetBlockDels())
+	switch {
+	case args.Tx != nil:
+		gasPrice := args.Tx.GasPrice.ToInt()
+		tx = types.NewTxWithGTx(uint64(args.Tx.Gas), gasPrice, &args.Tx.Tx)
+	case args.Param != nil:
+		if tx, pkgs, err = applyTxParam(statedb, args.Param); err != nil {
+			result.Error = err.Error()
+			return result, nil, nil
+		}
+	default:
+		return nil, nil, errors.New("no transaction to simulate")
+	}
+
+	statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	receipt, _, ret, err := core.ApplyTransactionWithOutput(config, chain, nil, gp, statedb, header, tx, new(uint64), vm.Config{})
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil, nil
+	}
+
+	result.Status = hexutil.Uint(receipt.Status)
+	result.GasUsed = hexutil.Uint64(receipt.GasUsed)
+	if receipt.ContractAddress != (common.Address{}) {
+		contract := address.BytesToAccount(receipt.ContractAddress[:64])
+		result.ContractAddress = &contract
+	}
+	if receipt.Logs != nil {
+		result.Logs = receipt.Logs
+	}
+	result.Return = ret
+	if receipt.Status == types.ReceiptStatusFailed {
+		result.RevertReason, _ = abi.UnpackRevert(ret)
+	}
+	result.VmError = newReceiptError(receipt)
+
+	for _, root := range zstate.State.GetBlockRoots()[roots:] {
+		out := zstate.State.GetOut(&root)
+		if out == nil {
+			continue
+		}
+		simulated := SimulatedOut{Root: root}
+		if out.Out_O != nil {
+			simulated.PKr = pkrToPKrAddress(out.Out_O.Addr)
+			asset := out.Out_O.Asset.Clone()
+			simulated.Asset = &asset
+		} else if out.Out_Z != nil {
+			simulated.PKr = pkrToPKrAddress(out.Out_Z.PKr)
+		}
+		result.Outs = append(result.Outs, simulated)
+	}
+	if args.Param != nil {
+		for _, in := range args.Param.Ins {
+			result.Spent = append(result.Spent, in.Out.Root)
+		}
+	} else {
+		result.Spent = append(result.Spent, zstate.State.GetBlockDels()[dels:]...)
+	}
+
+	var ids []keys.Uint256
+	if desc := tx.GetZZSTX().Desc_Pkg; desc.Create != nil {
+		ids = append(ids, desc.Create.Id)
+	} else if desc.Transfer != nil {
+		ids = append(ids, desc.Transfer.Id)
+	} else if desc.Close != nil {
+		ids = append(ids, desc.Close.Id)
+	}
+	for _, id := range ids {
+		if pkg := zstate.Pkgs.GetPkgById(&id); pkg != nil {
+			result.Pkgs = append(result.Pkgs, SimulatedPkg{id, pkrToPKrAddress(pkg.Pack.PKr), pkg.Closed})
+		}
+	}
+	result.Pkgs = append(result.Pkgs, pkgs...)
+
+	return result, receipt, nil
+}
+
+// applyTxParam checks the inputs and the package commands of the parameters
+// of a transaction against the state, spends its inputs and adds its outputs
+// into it. It returns the unsigned transaction running the commands left, and
+// the packages the transaction would transfer or close.
+func applyTxParam(statedb *state.StateDB, param *txtool.GTxParam) (*types.Transaction, []SimulatedPkg, error) {
+	if param.GasPrice == nil || param.GasPrice.Sign() == 0 {
+		return nil, nil, errors.New("gasPrice can not be zero")
+	}
+	zstate := statedb.NextZState()
+	var (
+		ins   []assets.Asset
+		nils  []keys.Uint256
+		dels  []keys.Uint256
+		spent = make(map[keys.Uint256]bool)
+	)
+	for _, in := range param.Ins {
+		os := zstate.State.GetOut(&in.Out.Root)
+		if os == nil {
+			return nil, nil, fmt.Errorf("can not find the utxo for root : %v", hexutil.Encode(in.Out.Root[:]))
+		}
+		dout := flight.DecTraceOuts([]txtool.Out{{Root: in.Out.Root, State: localdb.RootState{OS: *os}}}, &in.SKr)[0]
+		if len(dout.Nils) < 2 {
+			return nil, nil, fmt.Errorf("can not decrypt the utxo for root : %v", hexutil.Encode(in.Out.Root[:]))
+		}
+		// The state deletes the root of an output and the trace of a
+		// confidential one, and records the nullifier of both
+		nil_, del := dout.Nils[1], dout.Nils[0]
+		if os.Out_O != nil {
+			del = in.Out.Root
+		}
+		if spent[nil_] || zstate.State.HasIn(&nil_) || zstate.State.HasIn(&in.Out.Root) {
+			return nil, nil, fmt.Errorf("the utxo for root : %v already be used", hexutil.Encode(in.Out.Root[:]))
+		}
+		spent[nil_] = true
+		ins = append(ins, dout.Asset)
+		nils = append(nils, nil_)
+		dels = append(dels, del)
+	}
+	if param.Cmds.PkgClose == nil {
+		if err := checkTxParamBalance(param, ins); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var pkgs []SimulatedPkg
+	ownedPkg := func(id keys.Uint256, owner keys.PKr) error {
+		if pkg := zstate.Pkgs.GetPkgById(&id); pkg == nil || pkg.Closed {
+			return fmt.Errorf("pkg not exist or pkg is closed: %v", hexutil.Encode(id[:]))
+		} else if pkg.Pack.PKr != owner {
+			return fmt.Errorf("pkg not owned by %v", hexutil.Encode(owner[:]))
+		}
+		return nil
+	}
+	if transfer := param.Cmds.PkgTransfer; transfer != nil {
+		if err := ownedPkg(transfer.Id, transfer.Owner); err != nil {
+			return nil, nil, err
+		}
+		pkgs = append(pkgs, SimulatedPkg{transfer.Id, pkrToPKrAddress(transfer.PKr), false})
+	}
+	if close := param.Cmds.PkgClose; close != nil {
+		if err := ownedPkg(close.Id, close.Owner); err != nil {
+			return nil, nil, err
+		}
+		pkgs = append(pkgs, SimulatedPkg{close.Id, pkrToPKrAddress(close.Owner), true})
+	}
+
+	t := stx.T{
+		Ehash: types.Ehash(*param.GasPrice, param.Gas, []byte{}),
+		From:  param.From.PKr,
+		Fee:   param.Fee,
+	}
+	t.Desc_Cmd.BuyShare = param.Cmds.BuyShare
+	t.Desc_Cmd.RegistPool = param.Cmds.RegistPool
+	t.Desc_Cmd.ClosePool = param.Cmds.ClosePool
+	t.Desc_Cmd.Contract = param.Cmds.Contract
+	if create := param.Cmds.PkgCreate; create != nil {
+		t.Desc_Pkg.Create = &stx.PkgCreate{Id: create.Id, PKr: create.PKr}
+	}
+	tx := types.NewTxWithGTx(param.Gas, param.GasPrice, &t)
+
+	for i := range nils {
+		zstate.State.AddNil_Log(&nils[i])
+		zstate.State.AddDel_Log(&dels[i])
+	}
+	for _, out := range param.Outs {
+		zstate.AddOut_O(&stx.Out_O{Addr: out.PKr, Asset: out.Asset, Memo: out.Memo}, tx.Hash())
+	}
+	return tx, pkgs, nil
+}
+
+// checkTxParamBalance checks that the assets of the inputs of the parameters of
+// a transaction are exactly its outputs, its fee and the asset its command
+// sends. The asset of a closed package is only known by its commitment, so
+// that the parameters closing one are not checked.
+func checkTxParamBalance(param *txtool.GTxParam, ins []assets.Asset) error {
+	fee := param.Fee.Clone()
+	outs := []assets.Asset{{Tkn: &fee}}
+	for _, out := range param.Outs {
+		outs = append(outs, out.Asset)
+	}
+	switch cmds := param.Cmds; {
+	case cmds.BuyShare != nil:
+		outs = append(outs, cmds.BuyShare.Asset())
+	case cmds.RegistPool != nil:
+		outs = append(outs, cmds.RegistPool.Asset())
+	case cmds.Contract != nil:
+		outs = append(outs, cmds.Contract.Asset)
+	case cmds.PkgCreate != nil:
+		outs = append(outs, cmds.PkgCreate.Asset)
+	}
+
+	have, want := make(map[keys.Uint256]*big.Int), make(map[keys.Uint256]*big.Int)
+	add := func(balances map[keys.Uint256]*big.Int, tkn *assets.Token) {
+		if tkn == nil {
+			return
+		}
+		if _, ok := have[tkn.Currency]; !ok {
+			have[tkn.Currency], want[tkn.Currency] = new(big.Int), new(big.Int)
+		}
+		balances[tkn.Currency].Add(balances[tkn.Currency], tkn.Value.ToIntRef())
+	}
+	tickets := make(map[keys.Uint256]bool)
+	for _, in := range ins {
+		add(have, in.Tkn)
+		if in.Tkt != nil {
+			if tickets[in.Tkt.Value] {
+				return fmt.Errorf("in tkt duplicate: %v", hexutil.Encode(in.Tkt.Value[:]))
+			}
+			tickets[in.Tkt.Value] = true
+		}
+	}
+	for _, out := range outs {
+		add(want, out.Tkn)
+		if out.Tkt != nil {
+			if !tickets[out.Tkt.Value] {
+				return fmt.Errorf("out tkt not in ins: %v", hexutil.Encode(out.Tkt.Value[:]))
+			}
+			delete(tickets, out.Tkt.Value)
+		}
+	}
+	for currency, value := range have {
+		switch value.Cmp(want[currency]) {
+		case -1:
+			return fmt.Errorf("insufficient funds of %v: have %v want %v", utils.Uint256ToCurrency(&currency), value, want[currency])
+		case 1:
+			return fmt.Errorf("unspent funds of %v: have %v want %v", utils.Uint256ToCurrency(&currency), value, want[currency])
+		}
+	}
+	for ticket := range tickets {
+		return fmt.Errorf("unspent tkt: %v", hexutil.Encode(ticket[:]))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/consensus"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+var (
+	simulatePKr   = keys.PKr{1} // Receiver of the only output of the genesis
+	simulateOther = keys.PKr{2}
+	// simulateContract reverts every call
+	simulateContract = common.BytesToAddress([]byte("contract"))
+)
+
+type simulateChain struct{}
+
+func (simulateChain) Engine() consensus.Engine { return ethash.NewFaker() }
+
+func (simulateChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+
+func seroAsset(value int64) assets.Asset {
+	return assets.Asset{Tkn: &assets.Token{
+		Currency: utils.CurrencyToUint256("SERO"),
+		Value:    utils.U256(*big.NewInt(value)),
+	}}
+}
+
+// newSimulateState commits a genesis funding simulatePKr, and returns the
+// pending header on top of it, its state and the output of the genesis.
+func newSimulateState(t *testing.T) (*types.Header, *state.StateDB, txtool.Out) {
+	db := serodb.NewMemDatabase()
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			common.BytesToAddress(simulatePKr[:]): {Balance: big.NewInt(100000)},
+			simulateContract:                      {Code: []byte{0x60, 0x00, 0x60, 0x00, 0xfd}},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+	statedb, err := state.New(state.NewDatabase(db), genesis.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := genesis.Hash()
+	block := localdb.GetBlock(db, 0, hash.HashToUint256())
+	if block == nil || len(block.Roots) != 1 {
+		t.Fatalf("genesis output not found")
+	}
+	root := block.Roots[0]
+	header := &types.Header{
+		ParentHash: hash,
+		Number:     big.NewInt(1),
+		GasLimit:   genesis.GasLimit(),
+		Time:       new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+		Difficulty: big.NewInt(1),
+	}
+	return header, statedb, txtool.Out{Root: root, State: *localdb.GetRoot(db, &root)}
+}
+
+func TestSimulateTxParam(t *testing.T) {
+	var contract keys.PKr
+	copy(contract[:], simulateContract[:])
+
+	tests := []struct {
+		name   string
+		outs   []int64
+		double bool // the output of the genesis is spent twice
+		cmds   txtool.Cmds
+		status uint64
+		err    string
+	}{
+		{name: "transfer", outs: []int64{70000, 5000}, status: types.ReceiptStatusSuccessful},
+		{name: "revert", outs: []int64{75000}, cmds: txtool.Cmds{Contract: &stx.ContractCmd{To: &contract}}, status: types.ReceiptStatusFailed},
+		{name: "insufficient funds", outs: []int64{90000}, err: "insufficient funds of SERO: have 100000 want 115000"},
+		{name: "unspent funds", outs: []int64{50000}, err: "unspent funds of SERO: have 100000 want 75000"},
+		{name: "double spend", outs: []int64{175000}, double: true, err: "already be used"},
+	}
+	for _, tt := range tests {
+		header, statedb, in := newSimulateState(t)
+		param := &txtool.GTxParam{
+			Gas:      25000,
+			GasPrice: big.NewInt(1),
+			Fee:      *seroAsset(25000).Tkn,
+			From:     txtool.Kr{PKr: simulatePKr},
+			Ins:      []txtool.GIn{{SKr: simulatePKr, Out: in}},
+			Cmds:     tt.cmds,
+		}
+		if tt.double {
+			param.Ins = append(param.Ins, param.Ins[0])
+		}
+		for _, value := range tt.outs {
+			param.Outs = append(param.Outs, txtool.GOut{PKr: simulateOther, Asset: seroAsset(value)})
+		}
+
+		result, receipt, err := simulateTx(params.TestChainConfig, simulateChain{}, statedb, header, SimulateTxArgs{Param: param})
+		if err != nil {
+			t.Fatalf("%s: simulation failed: %v", tt.name, err)
+		}
+		if tt.err != "" {
+			if receipt != nil || !strings.Contains(result.Error, tt.err) {
+				t.Errorf("%s: error mismatch: have %q, want %q", tt.name, result.Error, tt.err)
+			}
+			continue
+		}
+		if receipt == nil {
+			t.Fatalf("%s: transaction rejected: %s", tt.name, result.Error)
+		}
+		if uint64(result.Status) != tt.status {
+			t.Errorf("%s: status mismatch: have %d, want %d", tt.name, result.Status, tt.status)
+		}
+		if len(result.Outs) != len(tt.outs) {
+			t.Errorf("%s: outputs mismatch: have %d, want %d", tt.name, len(result.Outs), len(tt.outs))
+		}
+		if len(result.Spent) != 1 || result.Spent[0] != in.Root {
+			t.Errorf("%s: spent mismatch: %v", tt.name, result.Spent)
+		}
+		// The input is spent in the state
+		dels := statedb.NextZState().State.GetBlockDels()
+		if len(dels) == 0 || dels[len(dels)-1] != in.Root {
+			t.Errorf("%s: input not deleted from the state: %v", tt.name, dels)
+		}
+	}
+}
@@ -54,6 +54,7 @@ type Backend interface {
 	ProtocolVersion() int
 	PeerCount() uint
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	SuggestFee(ctx context.Context, currency string, to *common.Address) (*big.Int, error)
 	ChainDb() serodb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
@@ -1,8 +1,10 @@
 package ethapi
 
 import (
+	"context"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/sero-cash/go-sero/zero/txs/stx"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/sero-cash/go-czero-import/keys"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/rpc"
 	"github.com/sero-cash/go-sero/zero/txs/assets"
 	"github.com/sero-cash/go-sero/zero/txtool/prepare"
 	"github.com/sero-cash/go-sero/zero/utils"
@@ -174,21 +177,24 @@ func (self *CmdsArgs) toCmds() prepare.Cmds {
 }
 
 type GenTxArgs struct {
-	From       PKAddress
-	RefundTo   *PKrAddress
-	Receptions []ReceptionArgs
-	Cmds       *CmdsArgs
-	Gas        uint64
-	GasPrice   *Big
-	Roots      []keys.Uint256
+	From        PKAddress
+	RefundTo    *PKrAddress
+	Receptions  []ReceptionArgs
+	Cmds        *CmdsArgs
+	Gas         uint64
+	GasPrice    *Big
+	GasCurrency Smbol //default SERO
+	Roots       []keys.Uint256
 }
 
 func (args GenTxArgs) check() error {
 	if len(args.Receptions) == 0 && args.Cmds == nil {
 		return errors.New("have no receptions")
 	}
-	if args.GasPrice == nil {
-		return fmt.Errorf("gasPrice not specified")
+	if args.GasCurrency.IsNotEmpty() && args.GasCurrency.IsNotSero() {
+		if args.Cmds == nil || args.Cmds.Contract == nil || args.Cmds.Contract.To == nil {
+			return fmt.Errorf("fee in %v can only be paid calling a contract", args.GasCurrency)
+		}
 	}
 
 	if args.RefundTo != nil {
@@ -221,12 +227,23 @@ func (args GenTxArgs) check() error {
 
 }
 
-func (args GenTxArgs) toTxParam() prepare.PreTxParam {
+func (args GenTxArgs) toTxParam(ctx context.Context, b Backend) (param prepare.PreTxParam, e error) {
+	if args.GasPrice == nil {
+		price, err := args.suggestPrice(ctx, b)
+		if err != nil {
+			return param, err
+		}
+		args.GasPrice = (*Big)(price)
+	}
 	gasPrice := args.GasPrice.ToInt()
 
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
+	fee, err := args.fee(ctx, b)
+	if err != nil {
+		return param, err
+	}
 	receptions := []prepare.Reception{}
 	for _, rec := range args.Receptions {
 		pkr := MixAdrressToPkr(rec.Addr)
@@ -249,16 +266,78 @@ func (args GenTxArgs) toTxParam() prepare.PreTxParam {
 	if args.Cmds != nil {
 		cmds = args.Cmds.toCmds()
 	}
-	return prepare.PreTxParam{
+	param = prepare.PreTxParam{
 		args.From.ToUint512(),
 		refundPkr,
 		receptions,
 		cmds,
-		assets.Token{
-			utils.CurrencyToUint256("SERO"),
-			utils.U256(*big.NewInt(0).Mul(big.NewInt(int64(args.Gas)), args.GasPrice.ToInt())),
-		},
+		fee,
 		gasPrice,
 		args.Roots,
 	}
+	return
+}
+
+// suggestPrice returns the recommended gas price of the transaction. The price
+// of a fee paid in a token is the one suggested in that token, converted back
+// at the token rate of the called contract.
+func (args GenTxArgs) suggestPrice(ctx context.Context, b Backend) (*big.Int, error) {
+	if args.GasCurrency.IsEmpty() || args.GasCurrency.IsSero() {
+		return b.SuggestPrice(ctx)
+	}
+	to := common.BytesToAddress(args.Cmds.Contract.To[:])
+	fee, err := b.SuggestFee(ctx, args.gasCurrency(), &to)
+	if err != nil {
+		return nil, err
+	}
+	tokens, tas, err := args.tokenRate(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	// Round down, the fee is rounded up converting the price again
+	fee.Mul(fee, tas)
+	return fee.Div(fee, tokens), nil
+}
+
+// fee returns the fee buying the gas of the transaction. A fee paid in a token
+// is converted at the token rate of the called contract, and rounded up so that
+// the transaction still buys all its gas.
+func (args GenTxArgs) fee(ctx context.Context, b Backend) (fee assets.Token, e error) {
+	value := big.NewInt(0).Mul(big.NewInt(int64(args.Gas)), args.GasPrice.ToInt())
+	if args.GasCurrency.IsEmpty() || args.GasCurrency.IsSero() {
+		return assets.Token{
+			Currency: utils.CurrencyToUint256("SERO"),
+			Value:    utils.U256(*value),
+		}, nil
+	}
+	tokens, tas, err := args.tokenRate(ctx, b)
+	if err != nil {
+		return fee, err
+	}
+	value.Mul(value, tokens)
+	value.Add(value, new(big.Int).Sub(tas, common.Big1))
+	return assets.Token{
+		Currency: utils.CurrencyToUint256(args.gasCurrency()),
+		Value:    utils.U256(*value.Div(value, tas)),
+	}, nil
+}
+
+func (args GenTxArgs) gasCurrency() string {
+	return strings.ToUpper(strings.TrimSpace(string(args.GasCurrency)))
+}
+
+// tokenRate returns the rate of the fee currency set by the called contract.
+func (args GenTxArgs) tokenRate(ctx context.Context, b Backend) (tokens *big.Int, tas *big.Int, e error) {
+	state, _, err := b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		return nil, nil, errors.New("latest state not found")
+	}
+	tokens, tas = state.GetTokenRate(common.BytesToAddress(args.Cmds.Contract.To[:]), args.gasCurrency())
+	if tokens.Sign() == 0 || tas.Sign() == 0 {
+		return nil, nil, errors.New("the smart contract dose not support alternative payment!")
+	}
+	return tokens, tas, nil
 }
@@ -129,6 +129,17 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -180,6 +191,10 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -405,6 +420,39 @@ const SER_JS = `
 web3._extend({
 	property: 'sero',
 	methods: [
+		new web3._extend.Method({
+			name: 'getTokens',
+			call: 'sero_getTokens',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getTokenInfo',
+			call: 'sero_getTokenInfo',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTicketCategories',
+			call: 'sero_getTicketCategories',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getContractAssets',
+			call: 'sero_getContractAssets',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'simulateTx',
+			call: 'sero_simulateTx',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'suggestFee',
+			call: 'sero_suggestFee',
+			params: 2,
+			inputFormatter: [null, null],
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'sero_sign',
@@ -524,6 +572,10 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'stratumWorkers',
+			call: 'miner_stratumWorkers'
+		}),
 	],
 	properties: []
 });
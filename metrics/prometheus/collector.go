@@ -0,0 +1,109 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sero-cash/go-sero/metrics"
+)
+
+var (
+	typeGaugeTpl           = "# TYPE %s gauge\n"
+	typeCounterTpl         = "# TYPE %s counter\n"
+	typeSummaryTpl         = "# TYPE %s summary\n"
+	keyValueTpl            = "%s %v\n\n"
+	keyQuantileTagValueTpl = "%s{quantile=\"%s\"} %v\n"
+	keyCountValueTpl       = "%s_count %v\n"
+)
+
+// percentiles are the quantiles reported for histograms and timers, the same
+// as the expvar handler reports.
+var percentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// collector is a collection of byte buffers that aggregate Prometheus reports
+// for different metric types.
+type collector struct {
+	buff *bytes.Buffer
+}
+
+// newCollector creates a new Prometheus metric aggregator.
+func newCollector() *collector {
+	return &collector{
+		buff: &bytes.Buffer{},
+	}
+}
+
+func (c *collector) addCounter(name string, m metrics.Counter) {
+	c.writeCounter(name, m.Count())
+}
+
+func (c *collector) addGauge(name string, m metrics.Gauge) {
+	c.writeGauge(name, m.Value())
+}
+
+func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
+	c.writeGauge(name, m.Value())
+}
+
+func (c *collector) addHistogram(name string, m metrics.Histogram) {
+	c.writeSummary(name, m.Count(), percentiles, m.Percentiles(percentiles))
+}
+
+func (c *collector) addMeter(name string, m metrics.Meter) {
+	c.writeCounter(name, m.Count())
+}
+
+func (c *collector) addTimer(name string, m metrics.Timer) {
+	c.writeSummary(name, m.Count(), percentiles, m.Percentiles(percentiles))
+}
+
+func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
+	values := m.Values()
+	if len(values) == 0 {
+		return
+	}
+	// Resetting timers take their percentiles in the 0-100 range.
+	qs := []float64{50, 95, 99}
+	ps := m.Percentiles(qs)
+
+	fs := make([]float64, len(ps))
+	for i, p := range ps {
+		fs[i] = float64(p)
+	}
+	c.writeSummary(name, int64(len(values)), []float64{0.5, 0.95, 0.99}, fs)
+}
+
+func (c *collector) writeGauge(name string, value interface{}) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
+	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+}
+
+func (c *collector) writeCounter(name string, value interface{}) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
+	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+}
+
+func (c *collector) writeSummary(name string, count int64, quantiles, values []float64) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, name))
+	for i, q := range quantiles {
+		c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, strconv.FormatFloat(q, 'f', -1, 64), values[i]))
+	}
+	c.buff.WriteString(fmt.Sprintf(keyCountValueTpl, name, count))
+	c.buff.WriteRune('\n')
+}
+
+// mutateKey turns a metric name into a valid Prometheus metric name.
+func mutateKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,87 @@
+package prometheus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/metrics"
+)
+
+func TestMain(m *testing.M) {
+	metrics.Enabled = true
+	os.Exit(m.Run())
+}
+
+func TestCollector(t *testing.T) {
+	c := newCollector()
+
+	counter := metrics.NewCounter()
+	counter.Inc(12345)
+	c.addCounter("test/counter", counter)
+
+	gauge := metrics.NewGauge()
+	gauge.Update(23456)
+	c.addGauge("test/gauge", gauge)
+
+	gaugeFloat64 := metrics.NewGaugeFloat64()
+	gaugeFloat64.Update(34567.89)
+	c.addGaugeFloat64("test/gauge_float64", gaugeFloat64)
+
+	meter := metrics.NewMeter()
+	defer meter.Stop()
+	meter.Mark(9999999)
+	c.addMeter("test/meter", meter)
+
+	timer := metrics.NewTimer()
+	defer timer.Stop()
+	timer.Update(20 * time.Millisecond)
+	timer.Update(21 * time.Millisecond)
+	timer.Update(22 * time.Millisecond)
+	timer.Update(120 * time.Millisecond)
+	timer.Update(23 * time.Millisecond)
+	timer.Update(24 * time.Millisecond)
+	c.addTimer("test/timer", timer)
+
+	emptyResettingTimer := metrics.NewResettingTimer().Snapshot()
+	c.addResettingTimer("test/empty_resetting_timer", emptyResettingTimer)
+
+	const expectedOutput = `# TYPE test_counter counter
+test_counter 12345
+
+# TYPE test_gauge gauge
+test_gauge 23456
+
+# TYPE test_gauge_float64 gauge
+test_gauge_float64 34567.89
+
+# TYPE test_meter counter
+test_meter 9999999
+
+# TYPE test_timer summary
+test_timer{quantile="0.5"} 2.25e+07
+test_timer{quantile="0.75"} 4.8e+07
+test_timer{quantile="0.95"} 1.2e+08
+test_timer{quantile="0.99"} 1.2e+08
+test_timer{quantile="0.999"} 1.2e+08
+test_timer_count 6
+
+`
+	if have := c.buff.String(); have != expectedOutput {
+		t.Fatalf("unexpected collector output.\nhave:\n%s\nwant:\n%s", have, expectedOutput)
+	}
+}
+
+func TestMutateKey(t *testing.T) {
+	tests := map[string]string{
+		"chain/inserts":          "chain_inserts",
+		"zero/verify/input":      "zero_verify_input",
+		"p2p/InboundTraffic":     "p2p_InboundTraffic",
+		"gero.stake/shares-size": "gero_stake_shares_size",
+	}
+	for key, want := range tests {
+		if have := mutateKey(key); have != want {
+			t.Errorf("%q: have %q, want %q", key, have, want)
+		}
+	}
+}
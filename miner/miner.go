@@ -50,7 +50,7 @@ type voter interface {
 
 	SendVoteEvent(vote *types.Vote)
 
-	AddLottery(lottery *types.Lottery)
+	AddLottery(lottery *types.Lottery) error
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -82,6 +82,11 @@ func New(sero Backend, config *params.ChainConfig, mux *event.TypeMux, voter vot
 	return miner
 }
 
+// SetTxPolicy replaces the policy ordering the transactions of new blocks.
+func (self *Miner) SetTxPolicy(policy TxPolicy) {
+	self.worker.setTxPolicy(policy)
+}
+
 // update keeps track of the downloader events. Please be aware that this is a one shot type of update loop.
 // It's entered once and as soon as `Done` or `Failed` has been broadcasted the events are unregistered and
 // the loop is exited. This to prevent a major security vuln where external parties can DOS you with blocks
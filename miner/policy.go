@@ -0,0 +1,234 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+)
+
+// TxOrder yields the pending transactions in the order the worker tries them.
+// Shift is called once a transaction is handled, Pop when it is dropped.
+type TxOrder interface {
+	Peek() *types.Transaction
+	Shift()
+	Pop() *types.Transaction
+}
+
+// TxTracker is optionally implemented by a TxOrder that needs to know the gas
+// used by each transaction applied to the block.
+type TxTracker interface {
+	Applied(tx *types.Transaction, gasUsed uint64)
+}
+
+// TxPolicy decides which pending transactions go into a block and in which order.
+type TxPolicy interface {
+	Order(txs types.Transactions, statedb *state.StateDB, header *types.Header) TxOrder
+}
+
+// PricePolicy is the default policy, highest gas price first.
+type PricePolicy struct{}
+
+func (PricePolicy) Order(txs types.Transactions, statedb *state.StateDB, header *types.Header) TxOrder {
+	return types.NewTransactionsByPrice(txs)
+}
+
+const (
+	OrderByPrice = "price" // highest gas price first
+	OrderByFee   = "fee"   // highest fee converted to SERO first
+)
+
+// TxPolicyConfig configures the built-in transaction policy of the miner.
+type TxPolicyConfig struct {
+	Order            string           // OrderByPrice or OrderByFee
+	StakeReserve     uint64           // percent of the block gas kept for stake commands
+	MaxSenderShare   uint64           // percent of the block gas one sender may use, 0 means no cap
+	ExcludeContracts []common.Address `toml:",omitempty"` // contracts whose calls are never mined
+}
+
+var DefaultTxPolicyConfig = TxPolicyConfig{
+	Order: OrderByPrice,
+}
+
+// NewTxPolicy creates the policy described by config, the default
+// configuration gives a PricePolicy.
+func NewTxPolicy(config TxPolicyConfig) (TxPolicy, error) {
+	if config.Order == "" {
+		config.Order = OrderByPrice
+	}
+	if config.Order != OrderByPrice && config.Order != OrderByFee {
+		return nil, fmt.Errorf("unknown transaction order %q", config.Order)
+	}
+	if config.StakeReserve > 100 || config.MaxSenderShare > 100 {
+		return nil, fmt.Errorf("transaction policy shares are percents")
+	}
+	if config.Order == OrderByPrice && config.StakeReserve == 0 && config.MaxSenderShare == 0 && len(config.ExcludeContracts) == 0 {
+		return PricePolicy{}, nil
+	}
+	policy := &configPolicy{config: config, excluded: make(map[common.Address]bool)}
+	for _, addr := range config.ExcludeContracts {
+		policy.excluded[addr] = true
+	}
+	return policy, nil
+}
+
+type configPolicy struct {
+	config   TxPolicyConfig
+	excluded map[common.Address]bool
+}
+
+func (self *configPolicy) Order(txs types.Transactions, statedb *state.StateDB, header *types.Header) TxOrder {
+	var inner TxOrder
+	if self.config.Order == OrderByFee {
+		inner = newFeeOrder(txs, statedb)
+	} else {
+		inner = types.NewTransactionsByPrice(txs)
+	}
+	return &filterOrder{
+		policy:   self,
+		inner:    inner,
+		gasLimit: header.GasLimit,
+		senders:  make(map[common.Address]uint64),
+	}
+}
+
+func isStakeTx(tx *types.Transaction) bool {
+	cmd := &tx.Stxt().Desc_Cmd
+	return cmd.BuyShare != nil || cmd.RegistPool != nil || cmd.ClosePool != nil
+}
+
+// filterOrder drops the transactions the policy does not admit in the block
+// built so far.
+type filterOrder struct {
+	policy   *configPolicy
+	inner    TxOrder
+	gasLimit uint64
+	gasUsed  uint64 // by transactions other than stake commands
+	senders  map[common.Address]uint64
+}
+
+func (self *filterOrder) admit(tx *types.Transaction) bool {
+	config := &self.policy.config
+	if cmd := tx.Stxt().Desc_Cmd.Contract; cmd != nil && cmd.To != nil {
+		if self.policy.excluded[common.BytesToAddress(cmd.To[:])] {
+			return false
+		}
+	}
+	if config.MaxSenderShare > 0 {
+		if self.senders[tx.From()]+tx.Gas() > self.gasLimit*config.MaxSenderShare/100 {
+			return false
+		}
+	}
+	if config.StakeReserve > 0 && !isStakeTx(tx) {
+		if self.gasUsed+tx.Gas() > self.gasLimit*(100-config.StakeReserve)/100 {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *filterOrder) Peek() *types.Transaction {
+	for {
+		tx := self.inner.Peek()
+		if tx == nil || self.admit(tx) {
+			return tx
+		}
+		log.Trace("Transaction skipped by policy", "hash", tx.Hash())
+		self.inner.Pop()
+	}
+}
+
+func (self *filterOrder) Shift() {
+	self.inner.Shift()
+}
+
+func (self *filterOrder) Pop() *types.Transaction {
+	return self.inner.Pop()
+}
+
+func (self *filterOrder) Applied(tx *types.Transaction, gasUsed uint64) {
+	self.senders[tx.From()] += gasUsed
+	if !isStakeTx(tx) {
+		self.gasUsed += gasUsed
+	}
+	if tracker, ok := self.inner.(TxTracker); ok {
+		tracker.Applied(tx, gasUsed)
+	}
+}
+
+// feeOrder sorts transactions by their fee converted to SERO with the token
+// rates set by the called contracts, divided by their gas. Transactions whose
+// fee can not be converted are left out.
+type feeOrder struct {
+	txs  types.Transactions
+	fees []*big.Int
+}
+
+func newFeeOrder(txs types.Transactions, statedb *state.StateDB) *feeOrder {
+	order := &feeOrder{}
+	for _, tx := range txs {
+		fee, err := statedb.GetSeroFee(tx.To(), &tx.Stxt().Fee)
+		if err != nil {
+			log.Trace("Transaction fee not convertible", "hash", tx.Hash(), "err", err)
+			continue
+		}
+		order.txs = append(order.txs, tx)
+		order.fees = append(order.fees, fee.ToInt())
+	}
+	sort.Stable(order)
+	return order
+}
+
+func (self *feeOrder) Len() int { return len(self.txs) }
+
+// Less compares fee_i/gas_i and fee_j/gas_j as fee_i*gas_j and fee_j*gas_i.
+func (self *feeOrder) Less(i, j int) bool {
+	ri := new(big.Int).Mul(self.fees[i], new(big.Int).SetUint64(self.txs[j].Gas()))
+	rj := new(big.Int).Mul(self.fees[j], new(big.Int).SetUint64(self.txs[i].Gas()))
+	return ri.Cmp(rj) > 0
+}
+
+func (self *feeOrder) Swap(i, j int) {
+	self.txs[i], self.txs[j] = self.txs[j], self.txs[i]
+	self.fees[i], self.fees[j] = self.fees[j], self.fees[i]
+}
+
+func (self *feeOrder) Peek() *types.Transaction {
+	if len(self.txs) == 0 {
+		return nil
+	}
+	return self.txs[0]
+}
+
+func (self *feeOrder) Shift() {
+	self.Pop()
+}
+
+func (self *feeOrder) Pop() *types.Transaction {
+	if len(self.txs) == 0 {
+		return nil
+	}
+	tx := self.txs[0]
+	self.txs, self.fees = self.txs[1:], self.fees[1:]
+	return tx
+}
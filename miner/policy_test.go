@@ -0,0 +1,142 @@
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func TestNewTxPolicy(t *testing.T) {
+	if policy, err := NewTxPolicy(DefaultTxPolicyConfig); err != nil {
+		t.Fatalf("default policy failed: %v", err)
+	} else if _, ok := policy.(PricePolicy); !ok {
+		t.Errorf("default policy should be PricePolicy, have %T", policy)
+	}
+	if _, err := NewTxPolicy(TxPolicyConfig{Order: "random"}); err == nil {
+		t.Errorf("unknown order accepted")
+	}
+	if _, err := NewTxPolicy(TxPolicyConfig{StakeReserve: 101}); err == nil {
+		t.Errorf("stake reserve above 100 percent accepted")
+	}
+	policy, err := NewTxPolicy(TxPolicyConfig{
+		Order:            OrderByFee,
+		StakeReserve:     10,
+		ExcludeContracts: []common.Address{common.BytesToAddress([]byte{1})},
+	})
+	if err != nil {
+		t.Fatalf("configured policy failed: %v", err)
+	}
+	if cp, ok := policy.(*configPolicy); !ok || !cp.excluded[common.BytesToAddress([]byte{1})] {
+		t.Errorf("excluded contract not registered")
+	}
+}
+
+// newPolicyTx creates a transaction of from with the given gas limit, its fee
+// paying the intrinsic gas only at the given price in SERO so that no gas is
+// refunded.
+func newPolicyTx(from byte, gas uint64, price int64, cmd stx.DescCmd) *types.Transaction {
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(params.TxGas), big.NewInt(price))
+	return types.NewTxWithGTx(gas, big.NewInt(price), &stx.T{
+		From:     keys.PKr{from},
+		Fee:      assets.Token{Currency: utils.CurrencyToUint256("SERO"), Value: utils.U256(*fee)},
+		Desc_Cmd: cmd,
+	})
+}
+
+// commitPolicyTxs commits the transactions into an empty block of the given
+// gas limit in the order of the policy, returning the work.
+func commitPolicyTxs(t *testing.T, config TxPolicyConfig, gasLimit uint64, txs ...*types.Transaction) *Work {
+	policy, err := NewTxPolicy(config)
+	if err != nil {
+		t.Fatalf("policy failed: %v", err)
+	}
+	statedb, _ := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: gasLimit, Time: big.NewInt(1), Difficulty: big.NewInt(1)}
+	env := &Work{config: params.TestChainConfig, state: statedb, header: header}
+	env.commitTransactions(new(event.TypeMux), policy.Order(txs, statedb, header), nil, common.Address{})
+	return env
+}
+
+func checkCommitted(t *testing.T, env *Work, want ...*types.Transaction) {
+	if len(env.txs) != len(want) {
+		t.Fatalf("committed transactions mismatch: have %d, want %d", len(env.txs), len(want))
+	}
+	for i, tx := range want {
+		if env.txs[i] != tx {
+			t.Errorf("transaction %d mismatch: have %x, want %x", i, env.txs[i].Hash(), tx.Hash())
+		}
+	}
+}
+
+// Tests that the fee order ranks transactions by their fee per gas.
+func TestFeeOrder(t *testing.T) {
+	var (
+		a = newPolicyTx(1, 25000, 3, stx.DescCmd{})  // 75000 fee, 3 per gas
+		b = newPolicyTx(2, 25000, 2, stx.DescCmd{})  // 50000 fee, 2 per gas
+		c = newPolicyTx(3, 100000, 4, stx.DescCmd{}) // 100000 fee, 1 per gas
+	)
+	env := commitPolicyTxs(t, TxPolicyConfig{Order: OrderByFee}, 1000000, c, b, a)
+	checkCommitted(t, env, a, b, c)
+}
+
+// Tests that the transactions of a sender are dropped past its block share.
+func TestMaxSenderShare(t *testing.T) {
+	var (
+		a1 = newPolicyTx(1, 25000, 3, stx.DescCmd{})
+		a2 = newPolicyTx(1, 25000, 2, stx.DescCmd{})
+		b  = newPolicyTx(2, 25000, 1, stx.DescCmd{})
+	)
+	env := commitPolicyTxs(t, TxPolicyConfig{MaxSenderShare: 50}, 80000, a1, a2, b)
+	checkCommitted(t, env, a1, b)
+}
+
+// Tests that the calls of excluded contracts are never committed.
+func TestExcludeContracts(t *testing.T) {
+	excluded := keys.PKr{0xff}
+	var (
+		a = newPolicyTx(1, 25000, 2, stx.DescCmd{Contract: &stx.ContractCmd{To: &excluded}})
+		b = newPolicyTx(2, 25000, 1, stx.DescCmd{})
+	)
+	env := commitPolicyTxs(t, TxPolicyConfig{ExcludeContracts: []common.Address{common.BytesToAddress(excluded[:])}}, 1000000, a, b)
+	checkCommitted(t, env, b)
+	if len(env.errHandledTxs) != 0 {
+		t.Errorf("excluded transaction tried")
+	}
+}
+
+// Tests that the gas reserved for stake commands is not used by other
+// transactions, but is by stake commands.
+func TestStakeReserve(t *testing.T) {
+	var (
+		a     = newPolicyTx(1, 25000, 3, stx.DescCmd{})
+		b     = newPolicyTx(2, 25000, 2, stx.DescCmd{})
+		stake = newPolicyTx(3, 25000, 1, stx.DescCmd{ClosePool: &stx.ClosePoolCmd{}})
+	)
+	env := commitPolicyTxs(t, TxPolicyConfig{StakeReserve: 50}, 80000, a, b, stake)
+
+	// The stake command is tried, failing as the pool does not exist
+	tried := map[*types.Transaction]bool{}
+	for _, tx := range append(env.txs, env.errHandledTxs...) {
+		tried[tx] = true
+	}
+	if len(env.txs) == 0 || env.txs[0] != a {
+		t.Errorf("first transaction not committed")
+	}
+	if tried[b] {
+		t.Errorf("transaction committed into the stake reserve")
+	}
+	if !tried[stake] {
+		t.Errorf("stake command not tried")
+	}
+}
@@ -0,0 +1,636 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/consensus"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+)
+
+const (
+	stratumMaxLine      = 4096                 // Longest request line accepted from a worker
+	stratumReadTimeout  = 10 * time.Minute     // Workers silent for longer are disconnected
+	stratumWriteTimeout = 10 * time.Second     // Time allowed to push a job to a worker
+	stratumWorkKeep     = 7 * 12 * time.Second // Age after which a job is stale
+	stratumRateWindow   = 10 * time.Minute     // Window of the hashrate estimation
+)
+
+var (
+	maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
+
+	errStratumNotLoggedIn = errors.New("not logged in")
+	errStratumNoWork      = errors.New("no work available yet")
+)
+
+// StratumConfig configures the stratum server of the miner.
+type StratumConfig struct {
+	Addr              string        // Listening address, empty disables the server
+	InitialDifficulty uint64        // Share difficulty of a new worker
+	MinDifficulty     uint64        // Lowest share difficulty vardiff goes to
+	ShareTime         time.Duration // Targeted time between two shares of a worker
+	RetargetShares    int           // Shares between two difficulty adjustments
+}
+
+var DefaultStratumConfig = StratumConfig{
+	InitialDifficulty: 1 << 32,
+	MinDifficulty:     1 << 24,
+	ShareTime:         10 * time.Second,
+	RetargetShares:    8,
+}
+
+// StratumWorker is the statistics of one worker connected to the stratum server.
+type StratumWorker struct {
+	Login      string         `json:"login"`
+	Name       string         `json:"name"`
+	Remote     string         `json:"remote"`
+	Difficulty *hexutil.Big   `json:"difficulty"`
+	Hashrate   hexutil.Uint64 `json:"hashrate"`
+	Accepted   uint64         `json:"accepted"`
+	Rejected   uint64         `json:"rejected"`
+	Stale      uint64         `json:"stale"`
+	Blocks     uint64         `json:"blocks"`
+	LastShare  time.Time      `json:"lastShare"`
+}
+
+type shareVerifier interface {
+	Compute(number uint64, powHash common.Hash, nonce uint64) (common.Hash, *big.Int)
+	VerifyShare(header *types.Header, difficulty *big.Int) (bool, error)
+}
+
+var _ shareVerifier = (*ethash.Ethash)(nil)
+
+type stratumWork struct {
+	work   *Work
+	nonces map[uint64]bool
+}
+
+// StratumServer is an Agent serving the mining work over the stratum protocol
+// used by ethash pools (eth_submitLogin, eth_getWork, eth_submitWork). Every new
+// work of the worker is pushed to the connected miners, each miner gets its own
+// share difficulty adjusted to the configured share time.
+type StratumServer struct {
+	mu sync.Mutex
+
+	config StratumConfig
+	engine shareVerifier
+
+	listener net.Listener
+	quitCh   chan struct{}
+	workCh   chan *Work
+	returnCh chan<- *Result
+
+	currentWork *Work
+	works       map[common.Hash]*stratumWork
+	sessions    map[*stratumSession]struct{}
+
+	running int32
+}
+
+func NewStratumServer(config StratumConfig, engine consensus.Engine) (*StratumServer, error) {
+	verifier, ok := engine.(shareVerifier)
+	if !ok {
+		return nil, fmt.Errorf("stratum: engine %T can not verify shares", engine)
+	}
+	if config.InitialDifficulty == 0 {
+		config.InitialDifficulty = DefaultStratumConfig.InitialDifficulty
+	}
+	if config.MinDifficulty == 0 || config.MinDifficulty > config.InitialDifficulty {
+		config.MinDifficulty = config.InitialDifficulty
+	}
+	if config.ShareTime <= 0 {
+		config.ShareTime = DefaultStratumConfig.ShareTime
+	}
+	if config.RetargetShares <= 0 {
+		config.RetargetShares = DefaultStratumConfig.RetargetShares
+	}
+	return &StratumServer{
+		config:   config,
+		engine:   verifier,
+		works:    make(map[common.Hash]*stratumWork),
+		sessions: make(map[*stratumSession]struct{}),
+	}, nil
+}
+
+func (self *StratumServer) Work() chan<- *Work {
+	return self.workCh
+}
+
+func (self *StratumServer) SetReturnCh(returnCh chan<- *Result) {
+	self.returnCh = returnCh
+}
+
+func (self *StratumServer) Start() {
+	if !atomic.CompareAndSwapInt32(&self.running, 0, 1) {
+		return
+	}
+	listener, err := net.Listen("tcp", self.config.Addr)
+	if err != nil {
+		log.Error("Stratum server failed to listen", "addr", self.config.Addr, "err", err)
+		atomic.StoreInt32(&self.running, 0)
+		return
+	}
+	log.Info("Stratum server started", "addr", listener.Addr())
+
+	self.mu.Lock()
+	self.listener = listener
+	self.mu.Unlock()
+	self.quitCh = make(chan struct{})
+	self.workCh = make(chan *Work, 1)
+	go self.accept(listener)
+	go self.loop(self.workCh, self.quitCh)
+}
+
+func (self *StratumServer) Stop() {
+	if !atomic.CompareAndSwapInt32(&self.running, 1, 0) {
+		return
+	}
+	close(self.quitCh)
+	close(self.workCh)
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.listener.Close()
+	for session := range self.sessions {
+		session.conn.Close()
+	}
+	self.currentWork = nil
+	self.works = make(map[common.Hash]*stratumWork)
+}
+
+// Addr returns the listening address, nil if the server is not running.
+func (self *StratumServer) Addr() net.Addr {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.listener == nil || atomic.LoadInt32(&self.running) == 0 {
+		return nil
+	}
+	return self.listener.Addr()
+}
+
+// GetHashRate returns the hashrate of all workers estimated from their shares.
+func (self *StratumServer) GetHashRate() (tot int64) {
+	for _, worker := range self.Workers() {
+		tot += int64(worker.Hashrate)
+	}
+	return
+}
+
+// Workers returns the statistics of the connected workers, sorted by login and name.
+func (self *StratumServer) Workers() (ret []StratumWorker) {
+	self.mu.Lock()
+	sessions := make([]*stratumSession, 0, len(self.sessions))
+	for session := range self.sessions {
+		sessions = append(sessions, session)
+	}
+	self.mu.Unlock()
+
+	for _, session := range sessions {
+		if stats, ok := session.stats(); ok {
+			ret = append(ret, stats)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Login != ret[j].Login {
+			return ret[i].Login < ret[j].Login
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return
+}
+
+func (self *StratumServer) loop(workCh chan *Work, quitCh chan struct{}) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quitCh:
+			return
+		case work := <-workCh:
+			if work == nil {
+				continue
+			}
+			self.mu.Lock()
+			self.currentWork = work
+			self.works[work.Block.HashNoNonce()] = &stratumWork{work, make(map[uint64]bool)}
+			sessions := make([]*stratumSession, 0, len(self.sessions))
+			for session := range self.sessions {
+				sessions = append(sessions, session)
+			}
+			self.mu.Unlock()
+
+			for _, session := range sessions {
+				session.pushJob()
+			}
+		case <-ticker.C:
+			self.mu.Lock()
+			for hash, work := range self.works {
+				if work.work != self.currentWork && time.Since(work.work.createdAt) > stratumWorkKeep {
+					delete(self.works, hash)
+				}
+			}
+			self.mu.Unlock()
+		}
+	}
+}
+
+func (self *StratumServer) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&self.running) == 1 {
+				log.Warn("Stratum server failed to accept", "err", err)
+			}
+			return
+		}
+		session := newStratumSession(self, conn)
+
+		self.mu.Lock()
+		self.sessions[session] = struct{}{}
+		self.mu.Unlock()
+
+		go func() {
+			session.serve()
+			self.mu.Lock()
+			delete(self.sessions, session)
+			self.mu.Unlock()
+		}()
+	}
+}
+
+func (self *StratumServer) current() *Work {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.currentWork
+}
+
+// submit checks a share and hands a sealed block to the worker.
+func (self *StratumServer) submit(nonce types.BlockNonce, powHash common.Hash, digest common.Hash, difficulty *big.Int) (block bool, stale bool, err error) {
+	self.mu.Lock()
+	work := self.works[powHash]
+	if work == nil {
+		self.mu.Unlock()
+		return false, true, nil
+	}
+	if work.nonces[nonce.Uint64()] {
+		self.mu.Unlock()
+		return false, false, errors.New("duplicate share")
+	}
+	work.nonces[nonce.Uint64()] = true
+	self.mu.Unlock()
+
+	header := work.work.Block.Header()
+	header.Nonce = nonce
+	header.MixDigest = digest
+	if block, err = self.engine.VerifyShare(header, difficulty); err != nil || !block {
+		return
+	}
+
+	self.mu.Lock()
+	_, pending := self.works[powHash]
+	delete(self.works, powHash)
+	self.mu.Unlock()
+	if !pending {
+		return false, true, nil
+	}
+	log.Info("Stratum share sealed a block", "number", header.Number, "hash", header.Hash())
+	self.returnCh <- &Result{work.work.Copy(), work.work.Block.WithSeal(header)}
+	return
+}
+
+type stratumRequest struct {
+	Id     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Worker string            `json:"worker"`
+}
+
+type stratumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type stratumResponse struct {
+	Id      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *stratumError   `json:"error,omitempty"`
+}
+
+type stratumSession struct {
+	server *StratumServer
+	conn   net.Conn
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu         sync.Mutex
+	login      string
+	name       string
+	difficulty *big.Int
+	jobs       map[common.Hash]*big.Int // difficulty each job was sent with
+	accepted   uint64
+	rejected   uint64
+	stale      uint64
+	blocks     uint64
+	lastShare  time.Time
+	retarget   time.Time // time of the last difficulty adjustment
+	retargetN  int       // shares since the last difficulty adjustment
+	shares     []stratumShare
+}
+
+type stratumShare struct {
+	time       time.Time
+	difficulty *big.Int
+}
+
+func newStratumSession(server *StratumServer, conn net.Conn) *stratumSession {
+	return &stratumSession{
+		server:     server,
+		conn:       conn,
+		enc:        json.NewEncoder(conn),
+		difficulty: new(big.Int).SetUint64(server.config.InitialDifficulty),
+		jobs:       make(map[common.Hash]*big.Int),
+		retarget:   time.Now(),
+	}
+}
+
+func (self *stratumSession) serve() {
+	defer self.conn.Close()
+	log.Debug("Stratum worker connected", "remote", self.conn.RemoteAddr())
+
+	reader := bufio.NewReaderSize(self.conn, stratumMaxLine)
+	for {
+		self.conn.SetReadDeadline(time.Now().Add(stratumReadTimeout))
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			log.Debug("Stratum worker disconnected", "remote", self.conn.RemoteAddr(), "err", err)
+			return
+		}
+		if isPrefix {
+			log.Debug("Stratum request too long", "remote", self.conn.RemoteAddr())
+			return
+		}
+		if len(line) == 0 {
+			continue
+		}
+		req := stratumRequest{}
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debug("Stratum malformed request", "remote", self.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := self.handle(&req)
+		if err := self.reply(req.Id, result, err); err != nil {
+			return
+		}
+	}
+}
+
+func (self *stratumSession) handle(req *stratumRequest) (interface{}, error) {
+	switch req.Method {
+	case "eth_submitLogin":
+		var login string
+		if len(req.Params) == 0 || json.Unmarshal(req.Params[0], &login) != nil || login == "" {
+			return nil, errors.New("invalid login")
+		}
+		self.mu.Lock()
+		self.login, self.name = login, req.Worker
+		self.mu.Unlock()
+		log.Info("Stratum worker logged in", "login", login, "worker", req.Worker, "remote", self.conn.RemoteAddr())
+		return true, nil
+
+	case "eth_getWork":
+		if !self.loggedIn() {
+			return nil, errStratumNotLoggedIn
+		}
+		job := self.job()
+		if job == nil {
+			return nil, errStratumNoWork
+		}
+		return job, nil
+
+	case "eth_submitWork":
+		if !self.loggedIn() {
+			return nil, errStratumNotLoggedIn
+		}
+		var args [3]string
+		if len(req.Params) != 3 {
+			return nil, errors.New("invalid params")
+		}
+		for i := range args {
+			if err := json.Unmarshal(req.Params[i], &args[i]); err != nil {
+				return nil, errors.New("invalid params")
+			}
+		}
+		nonce, err := hexutil.Decode(args[0])
+		if err != nil || len(nonce) != 8 {
+			return nil, errors.New("invalid nonce")
+		}
+		return self.submit(types.EncodeNonce(binary.BigEndian.Uint64(nonce)), common.HexToHash(args[1]), common.HexToHash(args[2])), nil
+
+	case "eth_submitHashrate":
+		return true, nil
+
+	default:
+		return nil, fmt.Errorf("method %s not supported", req.Method)
+	}
+}
+
+func (self *stratumSession) reply(id json.RawMessage, result interface{}, err error) error {
+	resp := stratumResponse{Id: id, Version: "2.0", Result: result}
+	if err != nil {
+		resp.Error = &stratumError{-1, err.Error()}
+	}
+	return self.write(&resp)
+}
+
+func (self *stratumSession) write(resp *stratumResponse) error {
+	self.writeMu.Lock()
+	defer self.writeMu.Unlock()
+	self.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	return self.enc.Encode(resp)
+}
+
+func (self *stratumSession) loggedIn() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.login != ""
+}
+
+// job returns the current work with the target of the session difficulty.
+func (self *stratumSession) job() []string {
+	work := self.server.current()
+	if work == nil {
+		return nil
+	}
+	block := work.Block
+	powHash := block.HashNoNonce()
+
+	self.mu.Lock()
+	difficulty := self.difficulty
+	if actual := block.Header().ActualDifficulty(); difficulty.Cmp(actual) > 0 {
+		difficulty = actual
+	}
+	self.jobs[powHash] = difficulty
+	for hash := range self.jobs {
+		self.server.mu.Lock()
+		_, ok := self.server.works[hash]
+		self.server.mu.Unlock()
+		if !ok {
+			delete(self.jobs, hash)
+		}
+	}
+	self.mu.Unlock()
+
+	target := new(big.Int).Div(maxUint256, difficulty)
+	return []string{
+		powHash.Hex(),
+		common.BytesToHash(ethash.SeedHash(block.NumberU64())).Hex(),
+		common.BytesToHash(target.Bytes()).Hex(),
+		hexutil.EncodeBig(block.Number()),
+	}
+}
+
+func (self *stratumSession) pushJob() {
+	if !self.loggedIn() {
+		return
+	}
+	if job := self.job(); job != nil {
+		if err := self.write(&stratumResponse{Id: json.RawMessage("0"), Version: "2.0", Result: job}); err != nil {
+			log.Debug("Stratum failed to push job", "remote", self.conn.RemoteAddr(), "err", err)
+			self.conn.Close()
+		}
+	}
+}
+
+func (self *stratumSession) submit(nonce types.BlockNonce, powHash common.Hash, digest common.Hash) bool {
+	self.mu.Lock()
+	difficulty := self.jobs[powHash]
+	self.mu.Unlock()
+	if difficulty == nil {
+		self.mu.Lock()
+		self.stale++
+		self.mu.Unlock()
+		return false
+	}
+
+	block, stale, err := self.server.submit(nonce, powHash, digest, difficulty)
+
+	self.mu.Lock()
+	switch {
+	case stale:
+		self.stale++
+	case err != nil:
+		log.Debug("Stratum share rejected", "login", self.login, "worker", self.name, "err", err)
+		self.rejected++
+	default:
+		now := time.Now()
+		self.accepted++
+		self.lastShare = now
+		self.shares = append(self.shares, stratumShare{now, difficulty})
+		if block {
+			self.blocks++
+		}
+		self.retargetN++
+	}
+	retarget := self.vardiff()
+	self.mu.Unlock()
+
+	if retarget {
+		self.pushJob()
+	}
+	return !stale && err == nil
+}
+
+// vardiff adjusts the share difficulty toward one share per ShareTime once
+// RetargetShares shares were accepted. It reports whether the difficulty changed.
+func (self *stratumSession) vardiff() bool {
+	config := &self.server.config
+	if self.retargetN < config.RetargetShares {
+		return false
+	}
+	elapsed := time.Since(self.retarget)
+	self.retarget = time.Now()
+	shares := self.retargetN
+	self.retargetN = 0
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+
+	// difficulty * ShareTime / average share interval
+	next := new(big.Int).Mul(self.difficulty, big.NewInt(int64(config.ShareTime)))
+	next.Mul(next, big.NewInt(int64(shares)))
+	next.Div(next, big.NewInt(int64(elapsed)))
+	if min := new(big.Int).SetUint64(config.MinDifficulty); next.Cmp(min) < 0 {
+		next = min
+	}
+	// Ignore variations below a quarter to keep the jobs steady
+	delta := new(big.Int).Sub(next, self.difficulty)
+	if new(big.Int).Mul(delta.Abs(delta), big.NewInt(4)).Cmp(self.difficulty) < 0 {
+		return false
+	}
+	log.Debug("Stratum worker difficulty retargeted", "login", self.login, "worker", self.name, "old", self.difficulty, "new", next)
+	self.difficulty = next
+	return true
+}
+
+func (self *stratumSession) stats() (ret StratumWorker, ok bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.login == "" {
+		return
+	}
+	// Drop the shares out of the hashrate window
+	since := time.Now().Add(-stratumRateWindow)
+	for len(self.shares) > 0 && self.shares[0].time.Before(since) {
+		self.shares = self.shares[1:]
+	}
+	work := new(big.Int)
+	for _, share := range self.shares {
+		work.Add(work, share.difficulty)
+	}
+	work.Div(work, big.NewInt(int64(stratumRateWindow/time.Second)))
+
+	return StratumWorker{
+		Login:      self.login,
+		Name:       self.name,
+		Remote:     self.conn.RemoteAddr().String(),
+		Difficulty: (*hexutil.Big)(new(big.Int).Set(self.difficulty)),
+		Hashrate:   hexutil.Uint64(work.Uint64()),
+		Accepted:   self.accepted,
+		Rejected:   self.rejected,
+		Stale:      self.stale,
+		Blocks:     self.blocks,
+		LastShare:  self.lastShare,
+	}, true
+}
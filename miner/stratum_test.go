@@ -0,0 +1,227 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+// fakeStratumMiner is an in-process stratum client mining with ethash.
+type fakeStratumMiner struct {
+	t      *testing.T
+	engine *ethash.Ethash
+	conn   net.Conn
+	reader *bufio.Reader
+	id     int
+	jobs   [][]string
+}
+
+type fakeStratumReply struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+func newFakeStratumMiner(t *testing.T, engine *ethash.Ethash, addr net.Addr) *fakeStratumMiner {
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("failed to dial stratum server: %v", err)
+	}
+	return &fakeStratumMiner{t: t, engine: engine, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// read returns the next message, collecting the pushed jobs.
+func (self *fakeStratumMiner) read() *fakeStratumReply {
+	self.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := self.reader.ReadBytes('\n')
+	if err != nil {
+		self.t.Fatalf("failed to read from stratum server: %v", err)
+	}
+	reply := fakeStratumReply{}
+	if err := json.Unmarshal(line, &reply); err != nil {
+		self.t.Fatalf("malformed stratum message %s: %v", line, err)
+	}
+	if reply.Id == 0 {
+		var job []string
+		if err := json.Unmarshal(reply.Result, &job); err != nil {
+			self.t.Fatalf("malformed stratum job %s: %v", reply.Result, err)
+		}
+		self.jobs = append(self.jobs, job)
+	}
+	return &reply
+}
+
+func (self *fakeStratumMiner) call(method string, params ...interface{}) *fakeStratumReply {
+	self.id++
+	req := map[string]interface{}{"id": self.id, "method": method, "params": params, "worker": "rig0"}
+	if err := json.NewEncoder(self.conn).Encode(req); err != nil {
+		self.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	for {
+		if reply := self.read(); reply.Id == self.id {
+			return reply
+		}
+	}
+}
+
+func (self *fakeStratumMiner) job() []string {
+	return self.jobs[len(self.jobs)-1]
+}
+
+// mine brute forces a nonce meeting the target of the latest job, skipping the
+// ones also meeting the given lower target if any.
+func (self *fakeStratumMiner) mine(start uint64, skip *big.Int) (uint64, common.Hash) {
+	job := self.job()
+	powHash := common.HexToHash(job[0])
+	target := new(big.Int).SetBytes(common.HexToHash(job[2]).Bytes())
+	number, _ := hexutil.DecodeUint64(job[3])
+	for nonce := start; ; nonce++ {
+		digest, result := self.engine.Compute(number, powHash, nonce)
+		if result.Cmp(target) <= 0 && (skip == nil || result.Cmp(skip) > 0) {
+			return nonce, digest
+		}
+	}
+}
+
+func (self *fakeStratumMiner) submit(nonce uint64, digest common.Hash) bool {
+	reply := self.call("eth_submitWork", types.EncodeNonce(nonce), self.job()[0], digest.Hex())
+	if reply.Error != nil {
+		self.t.Fatalf("share submission failed: %v", reply.Error.Message)
+	}
+	var ok bool
+	json.Unmarshal(reply.Result, &ok)
+	return ok
+}
+
+func newTestStratumWork(difficulty int64) *Work {
+	statedb, _ := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(difficulty), Time: big.NewInt(1)}
+	return &Work{state: statedb, header: header, Block: types.NewBlock(header, nil, nil), createdAt: time.Now()}
+}
+
+func TestStratumServer(t *testing.T) {
+	engine := ethash.NewTester()
+	config := StratumConfig{
+		Addr:              "127.0.0.1:0",
+		InitialDifficulty: 4,
+		MinDifficulty:     1,
+		ShareTime:         time.Hour,
+		RetargetShares:    3,
+	}
+	server, err := NewStratumServer(config, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *Result, 1)
+	server.SetReturnCh(results)
+	server.Start()
+	defer server.Stop()
+
+	miner := newFakeStratumMiner(t, engine, server.Addr())
+	defer miner.conn.Close()
+
+	if reply := miner.call("eth_getWork"); reply.Error == nil {
+		t.Fatalf("work served before login")
+	}
+	if reply := miner.call("eth_submitLogin", "pool-account"); reply.Error != nil {
+		t.Fatalf("login failed: %v", reply.Error.Message)
+	}
+	if reply := miner.call("eth_getWork"); reply.Error == nil {
+		t.Fatalf("work served before any job")
+	}
+
+	// A new work is pushed to the logged in miner with its share difficulty
+	work := newTestStratumWork(64)
+	server.Work() <- work
+	miner.read()
+	if have, want := miner.job()[0], work.Block.HashNoNonce().Hex(); have != want {
+		t.Fatalf("job pow hash mismatch: have %s, want %s", have, want)
+	}
+	shareTarget := new(big.Int).Div(maxUint256, big.NewInt(4))
+	if have := new(big.Int).SetBytes(common.HexToHash(miner.job()[2]).Bytes()); have.Cmp(shareTarget) != 0 {
+		t.Fatalf("share target mismatch: have %x, want %x", have, shareTarget)
+	}
+
+	// Shares far quicker than the share time raise the difficulty up to the block's
+	blockTarget := new(big.Int).Div(maxUint256, work.Block.Difficulty())
+	nonce := uint64(0)
+	for i := 0; i < config.RetargetShares; i++ {
+		var digest common.Hash
+		nonce, digest = miner.mine(nonce, blockTarget)
+		if !miner.submit(nonce, digest) {
+			t.Fatalf("share %d rejected", i)
+		}
+		if miner.submit(nonce, digest) {
+			t.Fatalf("duplicate share %d accepted", i)
+		}
+		nonce++
+	}
+	if len(miner.jobs) != 2 {
+		t.Fatalf("retargeted job not pushed: have %d jobs", len(miner.jobs))
+	}
+	if have := new(big.Int).SetBytes(common.HexToHash(miner.job()[2]).Bytes()); have.Cmp(blockTarget) != 0 {
+		t.Fatalf("retargeted target mismatch: have %x, want %x", have, blockTarget)
+	}
+
+	// A share at the block difficulty seals the block
+	nonce, digest := miner.mine(nonce, nil)
+	if !miner.submit(nonce, digest) {
+		t.Fatalf("block share rejected")
+	}
+	select {
+	case result := <-results:
+		if result.Block.Nonce() != nonce {
+			t.Errorf("sealed nonce mismatch: have %d, want %d", result.Block.Nonce(), nonce)
+		}
+		if err := engine.VerifySeal(nil, result.Block.Header()); err != nil {
+			t.Errorf("sealed block invalid: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("sealed block not returned")
+	}
+	// The sealed work is gone, later shares are stale
+	if miner.submit(nonce+1, digest) {
+		t.Errorf("share of a sealed work accepted")
+	}
+
+	workers := server.Workers()
+	if len(workers) != 1 {
+		t.Fatalf("worker count mismatch: have %d, want 1", len(workers))
+	}
+	stats := workers[0]
+	if stats.Login != "pool-account" || stats.Name != "rig0" {
+		t.Errorf("worker identity mismatch: have %s.%s", stats.Login, stats.Name)
+	}
+	if stats.Accepted != uint64(config.RetargetShares)+1 || stats.Rejected != uint64(config.RetargetShares) || stats.Stale != 1 || stats.Blocks != 1 {
+		t.Errorf("worker shares mismatch: accepted %d, rejected %d, stale %d, blocks %d", stats.Accepted, stats.Rejected, stats.Stale, stats.Blocks)
+	}
+	if stats.Difficulty.ToInt().Cmp(big.NewInt(4)) <= 0 {
+		t.Errorf("worker difficulty not raised: %v", stats.Difficulty.ToInt())
+	}
+}
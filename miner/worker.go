@@ -137,6 +137,9 @@ type worker struct {
 	coinbase address.AccountAddress
 	extra    []byte
 
+	policyMu sync.RWMutex
+	policy   TxPolicy
+
 	currentMu sync.Mutex
 	current   *Work
 
@@ -182,6 +185,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase add
 		unconfirmed: newUnconfirmedBlocks(sero.BlockChain(), miningLogAtDepth),
 		voter:       voter,
 		pendingVote: newPendingVote(),
+		policy:      PricePolicy{},
 	}
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = sero.TxPool().SubscribeNewTxsEvent(worker.txsCh)
@@ -198,6 +202,18 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase add
 	return worker
 }
 
+func (self *worker) setTxPolicy(policy TxPolicy) {
+	self.policyMu.Lock()
+	defer self.policyMu.Unlock()
+	self.policy = policy
+}
+
+func (self *worker) txPolicy() TxPolicy {
+	self.policyMu.RLock()
+	defer self.policyMu.RUnlock()
+	return self.policy
+}
+
 func (self *worker) setSerobase(addr address.AccountAddress) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
@@ -305,7 +321,7 @@ func (self *worker) update() {
 			//be automatically eliminated.
 			if atomic.LoadInt32(&self.mining) == 0 && self.current != nil {
 				self.currentMu.Lock()
-				txset := types.NewTransactionsByPrice(ev.Txs)
+				txset := self.txPolicy().Order(ev.Txs, self.current.state, self.current.header)
 				addr := common.Address{}
 				pkr := keys.Addr2PKr(self.coinbase.ToUint512(), nil)
 				addr.SetBytes(pkr[:])
@@ -523,7 +539,7 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPrice(pending)
+	txs := self.txPolicy().Order(pending, work.state, header)
 
 	if header.Number.Uint64() >= seroparam.SIP4() {
 		stakeState := stake.NewStakeState(work.state)
@@ -564,7 +580,7 @@ func (self *worker) updateSnapshot() {
 	self.snapshotState = self.current.state.Copy()
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPrice, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TxOrder, bc *core.BlockChain, coinbase common.Address) {
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
 	}
@@ -597,6 +613,9 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			env.tcount++
+			if tracker, ok := txs.(TxTracker); ok {
+				tracker.Applied(tx, env.receipts[len(env.receipts)-1].GasUsed)
+			}
 			txs.Shift()
 			env.handledTxs = append(env.handledTxs, tx)
 		default:
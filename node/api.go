@@ -106,6 +106,54 @@ func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer bans a remote node, given by its snode URL or its id, for the given
+// number of seconds or a day by default, disconnecting it if connected.
+func (api *PrivateAdminAPI) BanPeer(url string, seconds *uint64) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, err := parseNodeID(url)
+	if err != nil {
+		return false, err
+	}
+	var duration time.Duration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	server.BanPeer(id, duration)
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a remote node, given by its snode URL or its id,
+// resetting its score.
+func (api *PrivateAdminAPI) UnbanPeer(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, err := parseNodeID(url)
+	if err != nil {
+		return false, err
+	}
+	server.UnbanPeer(id)
+	return true, nil
+}
+
+// parseNodeID parses the id of a node, given by its snode URL or in hex.
+func parseNodeID(url string) (discover.NodeID, error) {
+	if id, err := discover.HexID(url); err == nil {
+		return id, nil
+	}
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return discover.NodeID{}, fmt.Errorf("invalid snode: %v", err)
+	}
+	return node.ID, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -282,6 +330,16 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerScores retrieves the scores of the connected peers, and of the peers
+// which misbehaved or got banned.
+func (api *PublicAdminAPI) PeerScores() ([]*p2p.PeerScore, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -150,6 +150,16 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCAuth configures the credentials accepted by the HTTP and websocket RPC
+	// endpoints, each allowed to call its own namespaces and methods. Every
+	// request is accepted if it's nil.
+	RPCAuth *rpc.AuthConfig `toml:",omitempty"`
+
+	// RPCRateLimit limits the cost of the requests each HTTP and websocket client
+	// may send per second, and the size of their batches. The clients are not
+	// limited if it's nil.
+	RPCRateLimit *rpc.RateLimitConfig `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -67,6 +67,9 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcAuth    *rpc.Authenticator // Authenticator of the HTTP and websocket clients (nil = open)
+	rpcLimiter *rpc.RateLimiter   // Rate limiter of the HTTP and websocket clients (nil = unlimited)
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -165,6 +168,10 @@ func (n *Node) Start() error {
 	running := &p2p.Server{Config: n.serverConfig}
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
+	if err := n.loadRPCGuards(); err != nil {
+		return err
+	}
+
 	// Otherwise copy and specialize the P2P configuration
 	services := make(map[reflect.Type]Service)
 	for _, constructor := range n.serviceFuncs {
@@ -174,6 +181,8 @@ func (n *Node) Start() error {
 			services:       make(map[reflect.Type]Service),
 			EventMux:       n.eventmux,
 			AccountManager: n.accman,
+			rpcAuth:        n.rpcAuth,
+			rpcLimiter:     n.rpcLimiter,
 		}
 		for kind, s := range services { // copy needed for threaded access
 			ctx.services[kind] = s
@@ -246,6 +255,27 @@ func (n *Node) openDataDir() error {
 	return nil
 }
 
+// loadRPCGuards loads the credentials and the limits of the HTTP and websocket
+// clients, shared with the services serving their own HTTP endpoints.
+func (n *Node) loadRPCGuards() error {
+	n.rpcAuth, n.rpcLimiter = nil, nil
+	if n.config.RPCAuth != nil {
+		auth, err := rpc.NewAuthenticator(n.config.RPCAuth)
+		if err != nil {
+			return err
+		}
+		n.rpcAuth = auth
+	}
+	if n.config.RPCRateLimit != nil {
+		limiter, err := rpc.NewRateLimiter(n.config.RPCRateLimit)
+		if err != nil {
+			return err
+		}
+		n.rpcLimiter = limiter
+	}
+	return nil
+}
+
 // startRPC is a helper method to start all the various RPC endpoint during node
 // startup. It's not meant to be called at any time afterwards as it makes certain
 // assumptions about the state of the node.
@@ -336,7 +366,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts, n.rpcAuth, n.rpcLimiter)
 	if err != nil {
 		return err
 	}
@@ -369,7 +399,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.rpcAuth, n.rpcLimiter)
 	if err != nil {
 		return err
 	}
@@ -34,6 +34,9 @@ type ServiceContext struct {
 	services       map[reflect.Type]Service // Index of the already constructed services
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
+
+	rpcAuth    *rpc.Authenticator
+	rpcLimiter *rpc.RateLimiter
 }
 
 // OpenDatabase opens an existing database with the given name (or creates one
@@ -57,6 +60,18 @@ func (ctx *ServiceContext) ResolvePath(path string) string {
 	return ctx.config.ResolvePath(path)
 }
 
+// RPCAuthenticator returns the authenticator of the HTTP and websocket RPC
+// clients, nil if every request is accepted.
+func (ctx *ServiceContext) RPCAuthenticator() *rpc.Authenticator {
+	return ctx.rpcAuth
+}
+
+// RPCRateLimiter returns the rate limiter of the HTTP and websocket RPC
+// clients, nil if they are unlimited.
+func (ctx *ServiceContext) RPCRateLimiter() *rpc.RateLimiter {
+	return ctx.rpcLimiter
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
@@ -80,8 +80,10 @@ type dialstate struct {
 	static        map[discover.NodeID]*dialTask
 	hist          *dialHistory
 
-	start     time.Time        // time when the dialer was first used
-	bootnodes []*discover.Node // default dials when there are no peers
+	start     time.Time             // time when the dialer was first used
+	bootnodes []*discover.Node      // default dials when there are no peers
+	dnsNodes  <-chan *discover.Node // nodes found through DNS discovery
+	scores    *peerScores           // scores of the peers, rejecting the misbehaving ones
 }
 
 type discoverTable interface {
@@ -226,6 +228,15 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			}
 		}
 	}
+	// Use the nodes found through DNS discovery for half of the remaining
+	// dynamic dials.
+	if dnsCandidates := needDynDials / 2; dnsCandidates > 0 {
+		for _, n := range s.readDNSNodes(dnsCandidates) {
+			if addDial(dynDialedConn, n) {
+				needDynDials--
+			}
+		}
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -258,8 +269,24 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errPoorScore        = errors.New("banned or poorly scored")
 )
 
+// readDNSNodes takes up to max nodes found through DNS discovery, without
+// waiting for more.
+func (s *dialstate) readDNSNodes(max int) []*discover.Node {
+	var nodes []*discover.Node
+	for len(nodes) < max {
+		select {
+		case n := <-s.dnsNodes:
+			nodes = append(nodes, n)
+		default:
+			return nodes
+		}
+	}
+	return nodes
+}
+
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
 	_, dialing := s.dialing[n.ID]
 	switch {
@@ -273,6 +300,8 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errNotWhitelisted
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
+	case s.scores != nil && s.scores.rejected(n.ID, time.Now()):
+		return errPoorScore
 	}
 	return nil
 }
@@ -57,6 +57,7 @@ type nodeDB struct {
 var (
 	nodeDBVersionKey = []byte("version") // Version of the database to flush if changes
 	nodeDBItemPrefix = []byte("n:")      // Identifier to prefix node entries with
+	nodeDBBanPrefix  = []byte("b:")      // Identifier to prefix peer bans with, kept apart from expiring nodes
 
 	nodeDBDiscoverRoot      = ":discover"
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
@@ -235,6 +236,9 @@ func (db *nodeDB) expirer() {
 			if err := db.expireNodes(); err != nil {
 				log.Error("Failed to expire nodedb items", "err", err)
 			}
+			if err := db.expireBans(); err != nil {
+				log.Error("Failed to expire nodedb bans", "err", err)
+			}
 		case <-db.quit:
 			return
 		}
@@ -303,6 +307,50 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// updateBan stores the expiry of the ban of a peer, lifting it if zero.
+func (db *nodeDB) updateBan(id NodeID, until time.Time) error {
+	key := append(append([]byte{}, nodeDBBanPrefix...), id[:]...)
+	if until.IsZero() {
+		return db.lvl.Delete(key, nil)
+	}
+	return db.storeInt64(key, until.Unix())
+}
+
+// bans retrieves the expiry of the bans of all peers still banned.
+func (db *nodeDB) bans() map[NodeID]time.Time {
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	now, bans := time.Now(), make(map[NodeID]time.Time)
+	for it.Next() {
+		var id NodeID
+		if len(it.Key()) != len(nodeDBBanPrefix)+len(id) {
+			continue
+		}
+		copy(id[:], it.Key()[len(nodeDBBanPrefix):])
+		if until := time.Unix(db.fetchInt64(it.Key()), 0); until.After(now) {
+			bans[id] = until
+		}
+	}
+	return bans
+}
+
+// expireBans deletes the bans which have run out.
+func (db *nodeDB) expireBans() error {
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	now := time.Now()
+	for it.Next() {
+		if until := time.Unix(db.fetchInt64(it.Key()), 0); !until.After(now) {
+			if err := db.lvl.Delete(it.Key(), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
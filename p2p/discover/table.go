@@ -148,6 +148,17 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// Ban persists the ban of a peer until the given time in the node database,
+// lifting it if the time is zero.
+func (tab *Table) Ban(id NodeID, until time.Time) error {
+	return tab.db.updateBan(id, until)
+}
+
+// Bans returns the expiry of the bans of all peers still banned.
+func (tab *Table) Bans() map[NodeID]time.Time {
+	return tab.db.bans()
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
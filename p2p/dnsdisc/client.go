@@ -0,0 +1,465 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery through DNS, reading signed trees
+// of node records from TXT records (EIP-1459).
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/p2p/enr"
+)
+
+var (
+	errNoRoot        = errors.New("no tree root found")
+	errEmptyTree     = errors.New("no node record in tree")
+	errNoEntry       = errors.New("no valid tree entry found")
+	errHashMismatch  = errors.New("entry hash mismatch")
+	errLinkInENRTree = errors.New("link in node record subtree")
+	errENRInLinkTree = errors.New("node record in link subtree")
+)
+
+// Resolver looks up the TXT records of a domain, net.Resolver being one.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// Config holds the settings of the client.
+type Config struct {
+	Timeout         time.Duration // Timeout of the DNS lookups
+	RecheckInterval time.Duration // Time between checks of the tree roots
+	ErrorDelay      time.Duration // Time waited after a failed lookup
+	CacheLimit      int           // Maximum number of entries kept in memory
+	Resolver        Resolver      // Resolver of the DNS lookups, the system one by default
+	Logger          log.Logger
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = 30 * time.Minute
+	}
+	if cfg.ErrorDelay == 0 {
+		cfg.ErrorDelay = 10 * time.Second
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = 1000
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// Client discovers nodes by reading the trees published in DNS.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	entries, _ := lru.New(cfg.CacheLimit)
+	return &Client{cfg: cfg, entries: entries}
+}
+
+// SyncTree downloads the whole tree at the given link.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	loc, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ctx := context.Background()
+	root, err := c.resolveRoot(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tree{root: root, entries: make(map[string]entry)}
+	if err := c.syncAll(ctx, t, loc.domain, root.eroot, false); err != nil {
+		return nil, err
+	}
+	if err := c.syncAll(ctx, t, loc.domain, root.lroot, true); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// syncAll downloads a subtree of the tree.
+func (c *Client) syncAll(ctx context.Context, t *Tree, domain, hash string, link bool) error {
+	e, err := c.resolveEntry(ctx, domain, hash)
+	if err != nil {
+		return err
+	}
+	t.entries[hash] = e
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := c.syncAll(ctx, t, domain, child, link); err != nil {
+				return err
+			}
+		}
+	case *linkEntry:
+		if !link {
+			return errLinkInENRTree
+		}
+	case *enrEntry:
+		if link {
+			return errENRInLinkTree
+		}
+	}
+	return nil
+}
+
+// resolveRoot looks up the root of the tree at the given link, checking that it
+// is signed by the key of the link.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (*rootEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			root, err := parseRoot(txt)
+			if err != nil {
+				return nil, err
+			}
+			if !root.verifySignature(loc.pubkey) {
+				return nil, errInvalidSig
+			}
+			return root, nil
+		}
+	}
+	return nil, errNoRoot
+}
+
+// resolveEntry looks up an entry of the tree by hash, from the cache if it was
+// already seen.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	cacheKey := hash + "." + domain
+	if e, ok := c.entries.Get(cacheKey); ok {
+		return e.(entry), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, cacheKey)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", cacheKey, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if subdomain(e) != hash {
+			return nil, errHashMismatch
+		}
+		c.entries.Add(cacheKey, e)
+		return e, nil
+	}
+	return nil, errNoEntry
+}
+
+// RandomIterator walks the trees at random, returning their nodes. The trees
+// linked by them are walked as well.
+type RandomIterator struct {
+	c   *Client
+	cur *discover.Node
+
+	ctx    context.Context
+	cancel func()
+
+	lock  sync.Mutex
+	trees map[string]*clientTree // Trees being walked, by link
+}
+
+// NewIterator creates an iterator over the trees at the given links.
+func (c *Client) NewIterator(urls ...string) (*RandomIterator, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := &RandomIterator{
+		c:      c,
+		ctx:    ctx,
+		cancel: cancel,
+		trees:  make(map[string]*clientTree),
+	}
+	for _, url := range urls {
+		loc, err := parseLink(url)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid enrtree URL %q: %v", url, err)
+		}
+		it.addTree(loc)
+	}
+	return it, nil
+}
+
+// Node returns the current node.
+func (it *RandomIterator) Node() *discover.Node {
+	return it.cur
+}
+
+// Close ends the iteration, interrupting Next.
+func (it *RandomIterator) Close() {
+	it.cancel()
+}
+
+// Next moves to the next node of a random tree, blocking until one is found. It
+// returns false once the iterator is closed.
+func (it *RandomIterator) Next() bool {
+	it.cur = nil
+	for it.cur == nil {
+		tree := it.pickTree()
+		if tree == nil {
+			return false
+		}
+		n, err := tree.syncRandom(it.ctx)
+		if err != nil {
+			it.c.cfg.Logger.Debug("Error in DNS random node sync", "tree", tree.loc.domain, "err", err)
+			if !it.wait() {
+				return false
+			}
+		}
+		it.cur = n
+	}
+	return true
+}
+
+// wait pauses after a failed lookup, or a walk of a tree which found no node.
+// It returns false if the iterator was closed meanwhile.
+func (it *RandomIterator) wait() bool {
+	timer := time.NewTimer(it.c.cfg.ErrorDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-it.ctx.Done():
+		return false
+	}
+}
+
+// pickTree returns a random tree, nil if the iterator is closed or has none.
+func (it *RandomIterator) pickTree() *clientTree {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+
+	if it.ctx.Err() != nil || len(it.trees) == 0 {
+		return nil
+	}
+	i := rand.Intn(len(it.trees))
+	for _, tree := range it.trees {
+		if i == 0 {
+			return tree
+		}
+		i--
+	}
+	return nil
+}
+
+// addTree starts walking the tree at the given link, if not done already.
+func (it *RandomIterator) addTree(loc *linkEntry) {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+
+	if _, ok := it.trees[loc.String()]; !ok {
+		it.trees[loc.String()] = newClientTree(it.c, loc, it.addTree)
+	}
+}
+
+// clientTree is a tree being walked by the iterator.
+type clientTree struct {
+	c       *Client
+	loc     *linkEntry
+	addLink func(*linkEntry)
+
+	root      *rootEntry
+	lastCheck time.Time
+	enrs      *subtreeSync // Current walk of the node records
+	links     *subtreeSync // Walk of the links, done once per root
+}
+
+func newClientTree(c *Client, loc *linkEntry, addLink func(*linkEntry)) *clientTree {
+	return &clientTree{c: c, loc: loc, addLink: addLink}
+}
+
+// nextRootCheck returns the time the root of the tree gets checked again.
+func (t *clientTree) nextRootCheck() time.Time {
+	return t.lastCheck.Add(t.c.cfg.RecheckInterval)
+}
+
+// syncRandom looks up a random entry of the tree. It returns a node when the
+// entry is a node record, nil when it's a branch or a link.
+func (t *clientTree) syncRandom(ctx context.Context) (*discover.Node, error) {
+	if t.root == nil || !time.Now().Before(t.nextRootCheck()) {
+		if err := t.updateRoot(ctx); err != nil {
+			return nil, err
+		}
+	}
+	// Follow the links first, there are usually few of them
+	if !t.links.done() {
+		e, err := t.links.next(ctx, t.c, t.loc.domain)
+		if err != nil {
+			return nil, err
+		}
+		if le, ok := e.(*linkEntry); ok {
+			t.addLink(le)
+		}
+		return nil, nil
+	}
+	// Walk the node records again once all of them were seen
+	if t.enrs.done() {
+		found := t.enrs.leaves
+		t.enrs = newSubtreeSync(t.root.eroot, false)
+		if found == 0 {
+			return nil, errEmptyTree
+		}
+	}
+	e, err := t.enrs.next(ctx, t.c, t.loc.domain)
+	if err != nil {
+		return nil, err
+	}
+	if ee, ok := e.(*enrEntry); ok {
+		n, err := recordNode(ee.node)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, nil
+}
+
+// updateRoot checks the root of the tree, restarting the walks if it changed.
+func (t *clientTree) updateRoot(ctx context.Context) error {
+	t.lastCheck = time.Now()
+	root, err := t.c.resolveRoot(ctx, t.loc)
+	if err != nil {
+		return err
+	}
+	if t.root != nil && root.seq < t.root.seq {
+		return fmt.Errorf("root sequence number went backwards: %d < %d", root.seq, t.root.seq)
+	}
+	if t.root == nil || root.eroot != t.root.eroot {
+		t.enrs = newSubtreeSync(root.eroot, false)
+	}
+	if t.root == nil || root.lroot != t.root.lroot {
+		t.links = newSubtreeSync(root.lroot, true)
+	}
+	t.root = root
+	return nil
+}
+
+// subtreeSync is a walk of a subtree, in random order.
+type subtreeSync struct {
+	root    string
+	link    bool     // Whether the subtree holds links or node records
+	missing []string // Hashes of the entries still to look up
+	leaves  int      // Number of leaves found
+}
+
+func newSubtreeSync(root string, link bool) *subtreeSync {
+	return &subtreeSync{root: root, link: link, missing: []string{root}}
+}
+
+func (ts *subtreeSync) done() bool {
+	return len(ts.missing) == 0
+}
+
+// next looks up a random missing entry of the subtree.
+func (ts *subtreeSync) next(ctx context.Context, c *Client, domain string) (entry, error) {
+	i := rand.Intn(len(ts.missing))
+	hash := ts.missing[i]
+	e, err := c.resolveEntry(ctx, domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	ts.missing = append(ts.missing[:i], ts.missing[i+1:]...)
+
+	switch e := e.(type) {
+	case *branchEntry:
+		ts.missing = append(ts.missing, e.children...)
+	case *linkEntry:
+		if !ts.link {
+			return nil, errLinkInENRTree
+		}
+		ts.leaves++
+	case *enrEntry:
+		if ts.link {
+			return nil, errENRInLinkTree
+		}
+		ts.leaves++
+	}
+	return e, nil
+}
+
+// recordNode converts a node record to a dialable node.
+func recordNode(r *enr.Record) (*discover.Node, error) {
+	var (
+		key enr.Secp256k1
+		ip  enr.IP
+		tcp enr.TCP
+		udp enr.UDP
+	)
+	if err := r.Load(&key); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&ip); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&tcp); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&udp); err != nil {
+		udp = enr.UDP(tcp)
+	}
+	return discover.NewNode(discover.PubkeyID((*ecdsa.PublicKey)(&key)), net.IP(ip), uint16(udp), uint16(tcp)), nil
+}
+
+// NewRecord creates the record of the node with the given key and endpoint.
+func NewRecord(key *ecdsa.PrivateKey, ip net.IP, tcp, udp uint16) (*enr.Record, error) {
+	var r enr.Record
+	r.Set(enr.IP(ip))
+	r.Set(enr.TCP(tcp))
+	r.Set(enr.UDP(udp))
+	if err := enr.SignV4(&r, key); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,194 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/p2p/enr"
+)
+
+// mapResolver is an in-memory resolver serving the given TXT records.
+type mapResolver map[string]string
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, fmt.Errorf("no such host %s", name)
+}
+
+func (mr mapResolver) add(records map[string]string) {
+	for name, record := range records {
+		mr[name] = record
+	}
+}
+
+// testKey returns a deterministic key.
+func testKey(i int) *ecdsa.PrivateKey {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte{byte(i)}))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// testNodes returns signed records of nodes with distinct keys and endpoints.
+func testNodes(n int) []*enr.Record {
+	records := make([]*enr.Record, n)
+	for i := range records {
+		r, err := NewRecord(testKey(100+i), net.IP{10, 0, byte(i >> 8), byte(i)}, 60602, 60602)
+		if err != nil {
+			panic(err)
+		}
+		records[i] = r
+	}
+	return records
+}
+
+// testTree signs a tree of the given nodes and links under the domain.
+func testTree(t *testing.T, key *ecdsa.PrivateKey, domain string, nodes []*enr.Record, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, url
+}
+
+func nodeIDs(records []*enr.Record) map[discover.NodeID]bool {
+	ids := make(map[discover.NodeID]bool)
+	for _, r := range records {
+		n, err := recordNode(r)
+		if err != nil {
+			panic(err)
+		}
+		ids[n.ID] = true
+	}
+	return ids
+}
+
+// Tests that a tree of several levels published in DNS syncs back whole.
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(40)
+	_, link := testTree(t, testKey(2), "other.nodes.example.org", nil, nil)
+	tree, url := testTree(t, testKey(1), "nodes.example.org", nodes, []string{link})
+
+	r := mapResolver{}
+	r.add(tree.ToTXT("nodes.example.org"))
+	c := NewClient(Config{Resolver: r})
+
+	synced, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if synced.Seq() != tree.Seq() {
+		t.Errorf("sequence number mismatch: have %d, want %d", synced.Seq(), tree.Seq())
+	}
+	if len(synced.Nodes()) != len(nodes) {
+		t.Errorf("node count mismatch: have %d, want %d", len(synced.Nodes()), len(nodes))
+	}
+	if links := synced.Links(); len(links) != 1 || links[0] != link {
+		t.Errorf("links mismatch: have %v, want [%s]", links, link)
+	}
+}
+
+// Tests that trees signed with another key than the one of the link, or whose
+// entries were altered, are rejected.
+func TestClientSyncTreeBadData(t *testing.T) {
+	tree, url := testTree(t, testKey(1), "nodes.example.org", testNodes(4), nil)
+	_, otherURL := testTree(t, testKey(2), "nodes.example.org", nil, nil)
+
+	r := mapResolver{}
+	r.add(tree.ToTXT("nodes.example.org"))
+	if _, err := NewClient(Config{Resolver: r}).SyncTree(otherURL); err != errInvalidSig {
+		t.Errorf("wrong error for foreign signature: have %v, want %v", err, errInvalidSig)
+	}
+	// Swap two node records, so that they don't match their names
+	var names []string
+	for name, record := range r {
+		if _, err := ParseRecord(record); err == nil {
+			names = append(names, name)
+		}
+	}
+	r[names[0]], r[names[1]] = r[names[1]], r[names[0]]
+	if _, err := NewClient(Config{Resolver: r}).SyncTree(url); err != errHashMismatch {
+		t.Errorf("wrong error for altered entry: have %v, want %v", err, errHashMismatch)
+	}
+}
+
+// Tests that the iterator returns the nodes of the tree and of the linked ones.
+func TestIteratorLinks(t *testing.T) {
+	nodes := testNodes(30)
+	linked, link := testTree(t, testKey(2), "linked.example.org", nodes[20:], nil)
+	tree, url := testTree(t, testKey(1), "nodes.example.org", nodes[:20], []string{link})
+
+	r := mapResolver{}
+	r.add(tree.ToTXT("nodes.example.org"))
+	r.add(linked.ToTXT("linked.example.org"))
+
+	it, err := NewClient(Config{Resolver: r}).NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	want := nodeIDs(nodes)
+	for seen := 0; len(want) > 0; seen++ {
+		if seen > 100*len(nodes) {
+			t.Fatalf("%d nodes never returned", len(want))
+		}
+		if !it.Next() {
+			t.Fatal("iterator stopped")
+		}
+		delete(want, it.Node().ID)
+	}
+}
+
+// Tests that closing the iterator interrupts a pending Next.
+func TestIteratorClose(t *testing.T) {
+	_, url := testTree(t, testKey(1), "nodes.example.org", testNodes(1), nil)
+
+	// Nothing is published, the lookups keep failing
+	it, err := NewClient(Config{Resolver: mapResolver{}, ErrorDelay: time.Hour}).NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+
+	time.Sleep(50 * time.Millisecond)
+	it.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Next returned a node")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next not interrupted")
+	}
+}
@@ -0,0 +1,352 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"crypto/ecdsa"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/p2p/enr"
+	"github.com/sero-cash/go-sero/rlp"
+)
+
+const (
+	rootPrefix   = "enrtree-root:v1"
+	linkPrefix   = "enrtree://"
+	branchPrefix = "enrtree-branch:"
+	enrPrefix    = "enr:"
+
+	// hashAbbrev is the number of bytes of the entry hashes, naming the entries.
+	hashAbbrev = 16
+
+	// maxChildren is the number of children of a branch entry, keeping it within
+	// the size of a TXT record of a UDP DNS reply.
+	maxChildren = 370 / (26 + 1)
+)
+
+var (
+	b32format = base32.StdEncoding.WithPadding(base32.NoPadding)
+	b64format = base64.RawURLEncoding
+)
+
+var (
+	errUnknownEntry = errors.New("unknown entry type")
+	errNoPubkey     = errors.New("missing public key")
+	errBadPubkey    = errors.New("invalid public key")
+	errInvalidENR   = errors.New("invalid node record")
+	errInvalidChild = errors.New("invalid child hash")
+	errInvalidSig   = errors.New("invalid root signature")
+	errSyntax       = errors.New("invalid syntax")
+)
+
+// Tree is a merkle tree of node records and of links to other trees, whose root
+// is signed by its publisher.
+type Tree struct {
+	root    *rootEntry
+	entries map[string]entry
+}
+
+// MakeTree creates a tree holding the given node records and links.
+func MakeTree(seq uint, nodes []*enr.Record, links []string) (*Tree, error) {
+	// Sort the records by their text, so that the tree doesn't depend on the
+	// order of the input
+	records := make([]entry, len(nodes))
+	for i, node := range nodes {
+		if node.NodeAddr() == nil {
+			return nil, fmt.Errorf("record %d is not signed", i)
+		}
+		records[i] = &enrEntry{node: node}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].String() < records[j].String() })
+
+	linkEntries := make([]entry, len(links))
+	for i, link := range links {
+		le, err := parseLink(link)
+		if err != nil {
+			return nil, err
+		}
+		linkEntries[i] = le
+	}
+	sort.Slice(linkEntries, func(i, j int) bool { return linkEntries[i].String() < linkEntries[j].String() })
+
+	t := &Tree{entries: make(map[string]entry)}
+	eroot := t.build(records)
+	t.entries[subdomain(eroot)] = eroot
+	lroot := t.build(linkEntries)
+	t.entries[subdomain(lroot)] = lroot
+	t.root = &rootEntry{seq: seq, eroot: subdomain(eroot), lroot: subdomain(lroot)}
+	return t, nil
+}
+
+// build adds the entries to the tree, under as many levels of branches as
+// needed, returning the top entry.
+func (t *Tree) build(entries []entry) entry {
+	if len(entries) == 1 {
+		return entries[0]
+	}
+	if len(entries) <= maxChildren {
+		hashes := make([]string, len(entries))
+		for i, e := range entries {
+			hashes[i] = subdomain(e)
+			t.entries[hashes[i]] = e
+		}
+		return &branchEntry{hashes}
+	}
+	var subtrees []entry
+	for len(entries) > 0 {
+		n := maxChildren
+		if len(entries) < n {
+			n = len(entries)
+		}
+		sub := t.build(entries[:n])
+		entries = entries[n:]
+		subtrees = append(subtrees, sub)
+		t.entries[subdomain(sub)] = sub
+	}
+	return t.build(subtrees)
+}
+
+// Sign signs the tree with the given key, returning the link of the tree in the
+// given domain.
+func (t *Tree) Sign(key *ecdsa.PrivateKey, domain string) (string, error) {
+	root := *t.root
+	sig, err := crypto.Sign(root.sigHash(), key)
+	if err != nil {
+		return "", err
+	}
+	root.sig = sig
+	t.root = &root
+	link := &linkEntry{domain: domain, pubkey: &key.PublicKey}
+	return link.String(), nil
+}
+
+// Seq returns the sequence number of the tree.
+func (t *Tree) Seq() uint {
+	return t.root.seq
+}
+
+// Links returns the links to other trees.
+func (t *Tree) Links() []string {
+	var links []string
+	for _, e := range t.entries {
+		if le, ok := e.(*linkEntry); ok {
+			links = append(links, le.String())
+		}
+	}
+	sort.Strings(links)
+	return links
+}
+
+// Nodes returns the node records of the tree.
+func (t *Tree) Nodes() []*enr.Record {
+	var nodes []*enr.Record
+	for _, e := range t.entries {
+		if ee, ok := e.(*enrEntry); ok {
+			nodes = append(nodes, ee.node)
+		}
+	}
+	return nodes
+}
+
+// ToTXT returns the TXT records of the tree, by their name in the given domain.
+func (t *Tree) ToTXT(domain string) map[string]string {
+	records := map[string]string{domain: t.root.String()}
+	for hash, e := range t.entries {
+		name := hash
+		if domain != "" {
+			name = hash + "." + domain
+		}
+		records[name] = e.String()
+	}
+	return records
+}
+
+// entry is a TXT record of a tree.
+type entry interface {
+	fmt.Stringer
+}
+
+type (
+	rootEntry struct {
+		eroot string
+		lroot string
+		seq   uint
+		sig   []byte
+	}
+	branchEntry struct {
+		children []string
+	}
+	enrEntry struct {
+		node *enr.Record
+	}
+	linkEntry struct {
+		domain string
+		pubkey *ecdsa.PublicKey
+	}
+)
+
+// subdomain returns the name of an entry, the abbreviated hash of its text.
+func subdomain(e entry) string {
+	h := crypto.Keccak256([]byte(e.String()))
+	return b32format.EncodeToString(h[:hashAbbrev])
+}
+
+func (e *rootEntry) String() string {
+	return fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d sig=%s", e.eroot, e.lroot, e.seq, b64format.EncodeToString(e.sig))
+}
+
+// sigHash returns the hash signed by the publisher of the tree.
+func (e *rootEntry) sigHash() []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d", e.eroot, e.lroot, e.seq)))
+}
+
+// verifySignature checks that the root is signed by the given key.
+func (e *rootEntry) verifySignature(pubkey *ecdsa.PublicKey) bool {
+	if len(e.sig) != 65 {
+		return false
+	}
+	return crypto.VerifySignature(crypto.FromECDSAPub(pubkey), e.sigHash(), e.sig[:64])
+}
+
+func (e *branchEntry) String() string {
+	return branchPrefix + strings.Join(e.children, ",")
+}
+
+func (e *enrEntry) String() string {
+	text, _ := RecordString(e.node)
+	return text
+}
+
+func (e *linkEntry) String() string {
+	return linkPrefix + b32format.EncodeToString(crypto.CompressPubkey(e.pubkey)) + "@" + e.domain
+}
+
+// RecordString returns the text form of a signed node record, as stored in the
+// trees.
+func RecordString(r *enr.Record) (string, error) {
+	enc, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return "", err
+	}
+	return enrPrefix + b64format.EncodeToString(enc), nil
+}
+
+// ParseRecord decodes the text form of a node record, checking its signature.
+func ParseRecord(text string) (*enr.Record, error) {
+	if !strings.HasPrefix(text, enrPrefix) {
+		return nil, errInvalidENR
+	}
+	enc, err := b64format.DecodeString(text[len(enrPrefix):])
+	if err != nil {
+		return nil, errInvalidENR
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(enc, &r); err != nil {
+		return nil, fmt.Errorf("%v: %v", errInvalidENR, err)
+	}
+	return &r, nil
+}
+
+// parseEntry decodes a non-root entry of a tree.
+func parseEntry(text string) (entry, error) {
+	switch {
+	case strings.HasPrefix(text, linkPrefix):
+		return parseLink(text)
+	case strings.HasPrefix(text, branchPrefix):
+		return parseBranch(text[len(branchPrefix):])
+	case strings.HasPrefix(text, enrPrefix):
+		r, err := ParseRecord(text)
+		if err != nil {
+			return nil, err
+		}
+		return &enrEntry{node: r}, nil
+	default:
+		return nil, errUnknownEntry
+	}
+}
+
+func parseRoot(text string) (*rootEntry, error) {
+	var (
+		eroot, lroot, sig string
+		seq               uint
+	)
+	if _, err := fmt.Sscanf(text, rootPrefix+" e=%s l=%s seq=%d sig=%s", &eroot, &lroot, &seq, &sig); err != nil {
+		return nil, errSyntax
+	}
+	if !isValidHash(eroot) || !isValidHash(lroot) {
+		return nil, errInvalidChild
+	}
+	sigb, err := b64format.DecodeString(sig)
+	if err != nil || len(sigb) != 65 {
+		return nil, errInvalidSig
+	}
+	return &rootEntry{eroot: eroot, lroot: lroot, seq: seq, sig: sigb}, nil
+}
+
+func parseBranch(text string) (entry, error) {
+	if text == "" {
+		return &branchEntry{}, nil
+	}
+	hashes := strings.Split(text, ",")
+	for _, hash := range hashes {
+		if !isValidHash(hash) {
+			return nil, errInvalidChild
+		}
+	}
+	return &branchEntry{hashes}, nil
+}
+
+// parseLink decodes a link to a tree, enrtree://<key>@<domain>.
+func parseLink(text string) (*linkEntry, error) {
+	if !strings.HasPrefix(text, linkPrefix) {
+		return nil, errSyntax
+	}
+	text = text[len(linkPrefix):]
+	pos := strings.IndexByte(text, '@')
+	if pos == -1 {
+		return nil, errNoPubkey
+	}
+	keystring, domain := text[:pos], text[pos+1:]
+	if domain == "" {
+		return nil, errSyntax
+	}
+	keybytes, err := b32format.DecodeString(keystring)
+	if err != nil {
+		return nil, errBadPubkey
+	}
+	key, err := crypto.DecompressPubkey(keybytes)
+	if err != nil {
+		return nil, errBadPubkey
+	}
+	return &linkEntry{domain: domain, pubkey: key}, nil
+}
+
+func isValidHash(s string) bool {
+	dlen := b32format.DecodedLen(len(s))
+	if dlen < 12 || dlen > 32 {
+		return false
+	}
+	buf := make([]byte, 32)
+	_, err := b32format.Decode(buf, []byte(s))
+	return err == nil
+}
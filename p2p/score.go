@@ -0,0 +1,268 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/metrics"
+	"github.com/sero-cash/go-sero/p2p/discover"
+)
+
+const (
+	// banThreshold is the score at which a peer gets banned for banDuration.
+	banThreshold = -100
+	banDuration  = 24 * time.Hour
+
+	// rejectThreshold is the score below which a peer is neither dialed nor
+	// accepted, until its score recovers.
+	rejectThreshold = -50
+
+	// scoreHalfLife is the time it takes for the score of a peer to recover
+	// half way back to zero.
+	scoreHalfLife = 10 * time.Minute
+
+	// maxScores is the number of peers whose score is tracked, the ones closest
+	// to zero being forgotten first.
+	maxScores = 1024
+)
+
+var (
+	peerFaultMeter = metrics.NewRegisteredMeter("p2p/peers/faults", nil)
+	peerBanMeter   = metrics.NewRegisteredMeter("p2p/peers/bans", nil)
+)
+
+// Misbehaviour is a fault of a remote peer, lowering its score.
+type Misbehaviour int
+
+const (
+	BadBlock    Misbehaviour = iota // Invalid block or header
+	BadSeal                         // Block or header with an invalid proof-of-work
+	BadVote                         // Invalid vote or lottery
+	BadMessage                      // Undecodable or unexpected message
+	BadResponse                     // Invalid response to a request
+	Timeout                         // Request timed out or stalled
+)
+
+// misbehaviours gives the name and the penalty of the faults. An invalid seal
+// is proven by the block itself and bans the peer at once, while the other
+// faults may stem from a bug or a fork rule of either side and need to repeat
+// before a ban.
+var misbehaviours = []struct {
+	name    string
+	penalty float64
+}{
+	BadBlock:    {"badBlock", 40},
+	BadSeal:     {"badSeal", -banThreshold},
+	BadVote:     {"badVote", 20},
+	BadMessage:  {"badMessage", 50},
+	BadResponse: {"badResponse", 25},
+	Timeout:     {"timeout", 10},
+}
+
+func (m Misbehaviour) String() string {
+	if int(m) < len(misbehaviours) {
+		return misbehaviours[m].name
+	}
+	return "unknown"
+}
+
+// PeerScore is the reputation of a peer, as reported by the admin API.
+type PeerScore struct {
+	ID          string         `json:"id"`
+	Score       float64        `json:"score"`
+	Faults      map[string]int `json:"faults,omitempty"`
+	BannedUntil *time.Time     `json:"bannedUntil,omitempty"`
+}
+
+// banStore persists the bans of peers, implemented by the discovery table.
+type banStore interface {
+	Ban(id discover.NodeID, until time.Time) error
+	Bans() map[discover.NodeID]time.Time
+}
+
+// peerScore is the score of a peer, decaying towards zero since its update.
+type peerScore struct {
+	value   float64
+	updated time.Time
+	faults  map[Misbehaviour]int
+}
+
+// current returns the score decayed to the given time.
+func (self *peerScore) current(now time.Time) float64 {
+	return self.value * math.Exp2(-float64(now.Sub(self.updated))/float64(scoreHalfLife))
+}
+
+// peerScores tracks the scores and the bans of peers.
+type peerScores struct {
+	scores map[discover.NodeID]*peerScore
+	bans   map[discover.NodeID]time.Time
+	store  banStore // Persists the bans, nil if discovery is off
+	lock   sync.Mutex
+}
+
+// load sets the store of the bans, loading the ones still running.
+func (self *peerScores) load(store banStore) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.store = store
+	if self.bans == nil {
+		self.bans = make(map[discover.NodeID]time.Time)
+	}
+	for id, until := range store.Bans() {
+		self.bans[id] = until
+	}
+}
+
+// report lowers the score of a peer for a fault, returning whether the peer got
+// banned.
+func (self *peerScores) report(id discover.NodeID, fault Misbehaviour, now time.Time) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	peerFaultMeter.Mark(1)
+	if self.scores == nil {
+		self.scores = make(map[discover.NodeID]*peerScore)
+	}
+	score := self.scores[id]
+	if score == nil {
+		if len(self.scores) >= maxScores {
+			self.prune(now)
+		}
+		score = &peerScore{faults: make(map[Misbehaviour]int)}
+		self.scores[id] = score
+	}
+	if int(fault) < len(misbehaviours) {
+		score.value = score.current(now) - misbehaviours[fault].penalty
+	}
+	score.updated = now
+	score.faults[fault]++
+
+	if score.value > banThreshold {
+		return false
+	}
+	self.ban(id, now.Add(banDuration))
+	return true
+}
+
+// prune forgets the half of the scores closest to zero.
+func (self *peerScores) prune(now time.Time) {
+	ids := make([]discover.NodeID, 0, len(self.scores))
+	for id := range self.scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return self.scores[ids[i]].current(now) > self.scores[ids[j]].current(now)
+	})
+	for _, id := range ids[:len(ids)/2] {
+		delete(self.scores, id)
+	}
+}
+
+// ban bans a peer until the given time, lifting its ban if zero. The lock must
+// be held.
+func (self *peerScores) ban(id discover.NodeID, until time.Time) {
+	if self.bans == nil {
+		self.bans = make(map[discover.NodeID]time.Time)
+	}
+	if until.IsZero() {
+		delete(self.bans, id)
+		delete(self.scores, id)
+	} else {
+		peerBanMeter.Mark(1)
+		self.bans[id] = until
+	}
+	if self.store != nil {
+		if err := self.store.Ban(id, until); err != nil {
+			log.Warn("Failed to store peer ban", "id", id, "err", err)
+		}
+	}
+}
+
+// setBan bans a peer until the given time, lifting its ban if zero.
+func (self *peerScores) setBan(id discover.NodeID, until time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.ban(id, until)
+}
+
+// rejected returns whether a peer is banned, or has a score too low to be dialed
+// or accepted.
+func (self *peerScores) rejected(id discover.NodeID, now time.Time) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if until, ok := self.bans[id]; ok {
+		if until.After(now) {
+			return true
+		}
+		delete(self.bans, id)
+	}
+	if score := self.scores[id]; score != nil {
+		return score.current(now) < rejectThreshold
+	}
+	return false
+}
+
+// info returns the scores of the given peers and of all the scored and banned
+// ones, sorted by their score.
+func (self *peerScores) info(peers []discover.NodeID, now time.Time) []*PeerScore {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	infos := make(map[discover.NodeID]*PeerScore)
+	get := func(id discover.NodeID) *PeerScore {
+		if infos[id] == nil {
+			infos[id] = &PeerScore{ID: id.String()}
+		}
+		return infos[id]
+	}
+	for _, id := range peers {
+		get(id)
+	}
+	for id, score := range self.scores {
+		info := get(id)
+		info.Score = score.current(now)
+		info.Faults = make(map[string]int)
+		for fault, count := range score.faults {
+			info.Faults[fault.String()] = count
+		}
+	}
+	for id, until := range self.bans {
+		if until.After(now) {
+			until := until
+			get(id).BannedUntil = &until
+		}
+	}
+	result := make([]*PeerScore, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score < result[j].Score
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
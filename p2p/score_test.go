@@ -0,0 +1,138 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/p2p/discover"
+)
+
+// mapBanStore is an in-memory store of bans.
+type mapBanStore map[discover.NodeID]time.Time
+
+func (s mapBanStore) Ban(id discover.NodeID, until time.Time) error {
+	if until.IsZero() {
+		delete(s, id)
+	} else {
+		s[id] = until
+	}
+	return nil
+}
+
+func (s mapBanStore) Bans() map[discover.NodeID]time.Time {
+	return s
+}
+
+// Tests that misbehaving peers are rejected, then banned, and that their score
+// recovers over time.
+func TestPeerScores(t *testing.T) {
+	var (
+		scores peerScores
+		store  = mapBanStore{}
+		id     = discover.NodeID{1}
+		now    = time.Now()
+	)
+	scores.load(store)
+
+	if scores.report(id, Timeout, now) || scores.rejected(id, now) {
+		t.Fatal("peer rejected after a single timeout")
+	}
+	if scores.report(id, BadMessage, now) || !scores.rejected(id, now) {
+		t.Fatal("peer not rejected with a poor score")
+	}
+	if scores.rejected(id, now.Add(2*scoreHalfLife)) {
+		t.Fatal("peer score not recovered")
+	}
+	if !scores.report(id, BadBlock, now) {
+		t.Fatal("peer not banned after a bad block")
+	}
+	if until := store[id]; !until.Equal(now.Add(banDuration)) {
+		t.Fatalf("stored ban mismatch: have %v, want %v", until, now.Add(banDuration))
+	}
+	// Bans outlive the scores, and restarts
+	var restarted peerScores
+	restarted.load(store)
+	if !restarted.rejected(id, now.Add(banDuration/2)) {
+		t.Fatal("ban not restored")
+	}
+	if restarted.rejected(id, now.Add(banDuration+time.Second)) {
+		t.Fatal("ban not expired")
+	}
+	scores.setBan(id, time.Time{})
+	if scores.rejected(id, now) || len(store) != 0 {
+		t.Fatal("ban not lifted")
+	}
+}
+
+// Tests that the scores reported by the admin API include the connected, the
+// misbehaving and the banned peers, worst first.
+func TestPeerScoresInfo(t *testing.T) {
+	var (
+		scores peerScores
+		now    = time.Now()
+	)
+	scores.report(discover.NodeID{2}, BadVote, now)
+	scores.report(discover.NodeID{2}, BadVote, now)
+	scores.setBan(discover.NodeID{3}, now.Add(time.Hour))
+
+	infos := scores.info([]discover.NodeID{{1}, {2}}, now)
+	if len(infos) != 3 {
+		t.Fatalf("score count mismatch: have %d, want 3", len(infos))
+	}
+	if infos[0].ID != (discover.NodeID{2}).String() || infos[0].Score != -40 || infos[0].Faults["badVote"] != 2 {
+		t.Errorf("worst peer mismatch: have %+v", infos[0])
+	}
+	for _, info := range infos[1:] {
+		banned := info.ID == (discover.NodeID{3}).String()
+		if info.Score != 0 || (info.BannedUntil != nil) != banned {
+			t.Errorf("peer score mismatch: have %+v", info)
+		}
+	}
+}
+
+// Tests that a single bad block does not ban a peer, unlike a forged seal or
+// repeated bad blocks.
+func TestPeerScoreBadBlocks(t *testing.T) {
+	var (
+		scores peerScores
+		now    = time.Now()
+	)
+	scores.load(mapBanStore{})
+
+	if !scores.report(discover.NodeID{1}, BadSeal, now) {
+		t.Fatal("peer not banned after a forged seal")
+	}
+	id := discover.NodeID{2}
+	if scores.report(id, BadBlock, now) {
+		t.Fatal("peer banned after a single bad block")
+	}
+	if scores.report(id, BadBlock, now.Add(time.Second)) {
+		t.Fatal("peer banned after two bad blocks")
+	}
+	if !scores.report(id, BadBlock, now.Add(2*time.Second)) {
+		t.Fatal("peer not banned after repeated bad blocks")
+	}
+	// Bad blocks far apart are forgiven
+	id = discover.NodeID{3}
+	for i := 0; i < 5; i++ {
+		if scores.report(id, BadBlock, now.Add(time.Duration(i)*3*scoreHalfLife)) {
+			t.Fatalf("peer banned after %d spread bad blocks", i+1)
+		}
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/p2p/discover"
 	"github.com/sero-cash/go-sero/p2p/discv5"
+	"github.com/sero-cash/go-sero/p2p/dnsdisc"
 	"github.com/sero-cash/go-sero/p2p/nat"
 	"github.com/sero-cash/go-sero/p2p/netutil"
 )
@@ -89,6 +90,10 @@ type Config struct {
 	// with the rest of the network.
 	BootstrapNodes []*discover.Node
 
+	// DNSDiscovery are the enrtree:// links of the DNS discovery trees, whose
+	// nodes are dialed like the ones found by the discovery protocol.
+	DNSDiscovery []string `toml:",omitempty"`
+
 	// BootstrapNodesV5 are used to establish connectivity
 	// with the rest of the network using the V5 discovery
 	// protocol.
@@ -162,6 +167,8 @@ type Server struct {
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
+	dnsIter      *dnsdisc.RandomIterator
+	scores       peerScores
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -336,6 +343,54 @@ func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
 	}
 }
 
+// ReportPeer lowers the score of a peer for misbehaving, banning and dropping it
+// once the score gets too low.
+func (srv *Server) ReportPeer(id discover.NodeID, fault Misbehaviour) {
+	if srv.scores.report(id, fault, time.Now()) {
+		srv.log.Debug("Banning misbehaving peer", "id", id, "fault", fault, "duration", banDuration)
+		srv.disconnect(id)
+	}
+}
+
+// BanPeer bans a peer for the given duration, dropping it if connected. A zero
+// duration bans it for the default duration.
+func (srv *Server) BanPeer(id discover.NodeID, duration time.Duration) {
+	if duration <= 0 {
+		duration = banDuration
+	}
+	srv.scores.setBan(id, time.Now().Add(duration))
+	srv.disconnect(id)
+}
+
+// UnbanPeer lifts the ban of a peer, resetting its score.
+func (srv *Server) UnbanPeer(id discover.NodeID) {
+	srv.scores.setBan(id, time.Time{})
+}
+
+// PeerScores returns the scores of the connected, misbehaving and banned peers.
+func (srv *Server) PeerScores() []*PeerScore {
+	var ids []discover.NodeID
+	for _, p := range srv.Peers() {
+		ids = append(ids, p.ID())
+	}
+	return srv.scores.info(ids, time.Now())
+}
+
+// disconnect drops the peer with the given id if connected.
+func (srv *Server) disconnect(id discover.NodeID) {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+	if !running {
+		return
+	}
+	for _, p := range srv.Peers() {
+		if p.ID() == id {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -385,11 +440,26 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	if srv.dnsIter != nil {
+		srv.dnsIter.Close()
+	}
 	close(srv.quit)
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
 }
 
+// dnsLoop feeds the nodes found through DNS discovery to the dialer.
+func (srv *Server) dnsLoop(it *dnsdisc.RandomIterator, nodes chan<- *discover.Node) {
+	defer srv.loopWG.Done()
+	for it.Next() {
+		select {
+		case nodes <- it.Node():
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
 // sharedUDPConn implements a shared connection. Write sends messages to the underlying connection while read returns
 // messages that were found unprocessable and sent to the unhandled channel by the primary listener.
 type sharedUDPConn struct {
@@ -521,8 +591,24 @@ func (srv *Server) Start() (err error) {
 		srv.DiscV5 = ntab
 	}
 
+	// Bans outlive restarts in the node database of the discovery table
+	if store, ok := srv.ntab.(banStore); ok {
+		srv.scores.load(store)
+	}
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer.scores = &srv.scores
+	if len(srv.DNSDiscovery) > 0 && dynPeers > 0 {
+		it, err := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log}).NewIterator(srv.DNSDiscovery...)
+		if err != nil {
+			return err
+		}
+		nodes := make(chan *discover.Node)
+		dialer.dnsNodes = nodes
+		srv.dnsIter = it
+		srv.loopWG.Add(1)
+		go srv.dnsLoop(it, nodes)
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -770,6 +856,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case !c.is(trustedConn) && srv.scores.rejected(c.id, time.Now()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
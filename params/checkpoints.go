@@ -0,0 +1,43 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import "github.com/sero-cash/go-sero/common"
+
+// Checkpoint pins a canonical block. A zero StakeHash skips the stake state check.
+type Checkpoint struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	StakeHash common.Hash `json:"stakeHash"`
+}
+
+// The built in lists pin the genesis blocks, the stake state starting empty.
+// Later blocks are pinned from a synced node when cutting a release, or come
+// from a signed checkpoint file.
+
+// MainnetCheckpoints are the checkpoints of the main network, in ascending order.
+var MainnetCheckpoints = []Checkpoint{
+	{Number: 0, Hash: MainnetGenesisHash},
+}
+
+// AlphanetCheckpoints are the checkpoints of the alpha test network, in ascending order.
+var AlphanetCheckpoints = []Checkpoint{
+	{Number: 0, Hash: AlphanetGenesisHash},
+}
+
+// CheckpointSigners are the hex encoded public keys trusted to sign checkpoint files.
+var CheckpointSigners = []string{}
@@ -0,0 +1,246 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/log"
+)
+
+const (
+	// jwtMaxSkew is the maximum difference between the issuance time of a JWT and
+	// the local time, bounding the window in which a token can be replayed.
+	jwtMaxSkew = 60 * time.Second
+
+	apiKeyHeader = "X-API-Key"
+
+	// publicPrincipal is the name of the clients without credentials.
+	publicPrincipal = "public"
+)
+
+// DefaultAuditedAPIs are the privileged namespaces whose calls are logged when
+// no audit list is configured.
+var DefaultAuditedAPIs = []string{"admin", "exchange", "personal", "stake"}
+
+var (
+	errMissingCredential = errors.New("missing credential")
+	errInvalidCredential = errors.New("invalid credential")
+)
+
+// Credential authenticates RPC clients, either by JWTs signed with a secret or
+// by a static API key, allowing them to call the listed namespaces ("sero"),
+// methods ("exchange_seed2Sk") or everything ("*").
+type Credential struct {
+	Name   string   // Name of the credential in the audit log
+	Secret string   `toml:",omitempty"` // File holding the hex HMAC secret of the JWT bearer tokens
+	Key    string   `toml:",omitempty"` // Static API key, sent in the X-API-Key header
+	Allow  []string // Namespaces and methods allowed to the holders of the credential
+}
+
+// AuthConfig configures the authentication of the HTTP and websocket RPC
+// endpoints.
+type AuthConfig struct {
+	Credentials []Credential
+
+	// Public are the namespaces and methods callable without credentials. The
+	// requests without credentials are rejected if empty.
+	Public []string `toml:",omitempty"`
+
+	// Audit are the namespaces and methods whose calls are logged, defaulting to
+	// DefaultAuditedAPIs.
+	Audit []string `toml:",omitempty"`
+}
+
+// permissions is an allowlist of namespaces and methods.
+type permissions map[string]bool
+
+func newPermissions(allow []string) permissions {
+	perms := make(permissions)
+	for _, name := range allow {
+		perms[name] = true
+	}
+	return perms
+}
+
+// allows returns whether the method of the namespace is in the allowlist.
+func (p permissions) allows(namespace, method string) bool {
+	return p["*"] || p[namespace] || p[namespace+serviceMethodSeparator+method]
+}
+
+// principal is an authenticated RPC client.
+type principal struct {
+	name  string
+	perms permissions
+}
+
+type principalKey struct{}
+
+// Authenticator checks the credentials of the RPC requests, and the permission
+// of the authenticated clients to call the requested methods.
+type Authenticator struct {
+	secrets []*principal // JWT holders, indexed like their secrets
+	keys    [][]byte
+	apiKeys map[string]*principal
+	public  *principal // Clients without credentials, nil if rejected
+	audit   permissions
+}
+
+// NewAuthenticator creates an authenticator of the given credentials, loading
+// their JWT secrets.
+func NewAuthenticator(config *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{apiKeys: make(map[string]*principal)}
+	for i, cred := range config.Credentials {
+		name := cred.Name
+		if name == "" {
+			name = fmt.Sprintf("credential-%d", i)
+		}
+		p := &principal{name: name, perms: newPermissions(cred.Allow)}
+		switch {
+		case cred.Secret != "" && cred.Key != "":
+			return nil, fmt.Errorf("credential %s has both a JWT secret and an API key", name)
+		case cred.Secret != "":
+			secret, err := loadSecret(cred.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("credential %s: %v", name, err)
+			}
+			a.secrets = append(a.secrets, p)
+			a.keys = append(a.keys, secret)
+		case cred.Key != "":
+			if _, ok := a.apiKeys[cred.Key]; ok {
+				return nil, fmt.Errorf("credential %s reuses an API key", name)
+			}
+			a.apiKeys[cred.Key] = p
+		default:
+			return nil, fmt.Errorf("credential %s has neither a JWT secret nor an API key", name)
+		}
+	}
+	if len(config.Public) > 0 {
+		a.public = &principal{name: publicPrincipal, perms: newPermissions(config.Public)}
+	}
+	audit := config.Audit
+	if audit == nil {
+		audit = DefaultAuditedAPIs
+	}
+	a.audit = newPermissions(audit)
+	return a, nil
+}
+
+// loadSecret reads a hex HMAC secret of at least 32 bytes from a file.
+func loadSecret(file string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(string(blob))
+	if !strings.HasPrefix(text, "0x") {
+		text = "0x" + text
+	}
+	secret, err := hexutil.Decode(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret: %v", err)
+	}
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("JWT secret too short: %d bytes, want at least 32", len(secret))
+	}
+	return secret, nil
+}
+
+// authenticate returns the client sending a request, from its JWT bearer token
+// or its API key.
+func (a *Authenticator) authenticate(r *http.Request) (*principal, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return nil, errInvalidCredential
+		}
+		return a.verifyJWT(strings.TrimPrefix(auth, "Bearer "), time.Now())
+	}
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		for k, p := range a.apiKeys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				return p, nil
+			}
+		}
+		return nil, errInvalidCredential
+	}
+	if a.public != nil {
+		return a.public, nil
+	}
+	return nil, errMissingCredential
+}
+
+// verifyJWT returns the holder of the secret signing the token, which must have
+// been issued around the given time.
+func (a *Authenticator) verifyJWT(token string, now time.Time) (*principal, error) {
+	for i, key := range a.keys {
+		var claims jwt.StandardClaims
+		parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return key, nil
+		})
+		if err != nil || !parsed.Valid {
+			continue
+		}
+		if claims.IssuedAt == 0 {
+			return nil, errors.New("missing token issuance time")
+		}
+		if skew := now.Sub(time.Unix(claims.IssuedAt, 0)); skew > jwtMaxSkew || skew < -jwtMaxSkew {
+			return nil, errors.New("stale token")
+		}
+		return a.secrets[i], nil
+	}
+	return nil, errInvalidCredential
+}
+
+// authorize checks that the client of the request may call the method, logging
+// the privileged calls.
+func (a *Authenticator) authorize(ctx context.Context, namespace, method string) Error {
+	p, ok := ctx.Value(principalKey{}).(*principal)
+	if !ok || namespace == MetadataApi {
+		return nil
+	}
+	if !p.perms.allows(namespace, method) {
+		log.Warn("Unauthorized RPC call", "credential", p.name, "method", namespace+serviceMethodSeparator+method, "remote", ctx.Value("remote"))
+		return &unauthorizedError{namespace, method}
+	}
+	if a.audit.allows(namespace, method) {
+		log.Info("Privileged RPC call", "credential", p.name, "method", namespace+serviceMethodSeparator+method, "remote", ctx.Value("remote"))
+	}
+	return nil
+}
+
+// withPrincipal returns a context carrying the authenticated client.
+func withPrincipal(ctx context.Context, p *principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// SetAuthenticator makes the server check the credentials of the HTTP and
+// websocket requests, and the permissions of the clients.
+func (s *Server) SetAuthenticator(auth *Authenticator) {
+	s.auth = auth
+}
@@ -0,0 +1,168 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type AuthTestService struct{}
+
+func (s *AuthTestService) Echo(text string) string { return text }
+func (s *AuthTestService) Secret() string          { return "secret" }
+
+// newAuthTestServer starts an HTTP server of the test service under the public
+// and the private namespaces, authenticating the clients.
+func newAuthTestServer(t *testing.T, config *AuthConfig) *httptest.Server {
+	auth, err := NewAuthenticator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer()
+	for _, namespace := range []string{"open", "private"} {
+		if err := srv.RegisterName(namespace, new(AuthTestService)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.SetAuthenticator(auth)
+	return httptest.NewServer(srv)
+}
+
+// call sends a request with the given headers, returning the HTTP status and
+// the JSON-RPC error code of the response.
+func call(t *testing.T, url, method string, headers map[string]string) (int, int) {
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":["hello"]}`
+	if method != "open_echo" && method != "private_echo" {
+		body = `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[]}`
+	}
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, 0
+	}
+	var result struct {
+		Error *struct{ Code int } `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Error != nil {
+		return resp.StatusCode, result.Error.Code
+	}
+	return resp.StatusCode, 0
+}
+
+// Tests that API keys only call the namespaces and methods of their allowlist,
+// and that clients without credentials only call the public ones.
+func TestAuthAPIKeys(t *testing.T) {
+	server := newAuthTestServer(t, &AuthConfig{
+		Credentials: []Credential{
+			{Name: "reader", Key: "reader-key", Allow: []string{"open", "private_echo"}},
+			{Name: "admin", Key: "admin-key", Allow: []string{"*"}},
+		},
+		Public: []string{"open_echo"},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		method  string
+		key     string
+		status  int
+		errcode int
+	}{
+		{"open_echo", "", http.StatusOK, 0},
+		{"open_secret", "", http.StatusOK, -32001},
+		{"private_echo", "", http.StatusOK, -32001},
+		{"open_secret", "reader-key", http.StatusOK, 0},
+		{"private_echo", "reader-key", http.StatusOK, 0},
+		{"private_secret", "reader-key", http.StatusOK, -32001},
+		{"private_secret", "admin-key", http.StatusOK, 0},
+		{"open_echo", "wrong-key", http.StatusUnauthorized, 0},
+	}
+	for i, tt := range tests {
+		headers := map[string]string{}
+		if tt.key != "" {
+			headers[apiKeyHeader] = tt.key
+		}
+		status, errcode := call(t, server.URL, tt.method, headers)
+		if status != tt.status || errcode != tt.errcode {
+			t.Errorf("test %d: %s with key %q: have status %d, error %d, want %d, %d", i, tt.method, tt.key, status, errcode, tt.status, tt.errcode)
+		}
+	}
+}
+
+// Tests that JWTs are accepted if signed with a configured secret and issued
+// recently, and that requests without credentials are rejected without a
+// public allowlist.
+func TestAuthJWT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := []byte(strings.Repeat("k", 32))
+	file := filepath.Join(dir, "jwtsecret")
+	if err := ioutil.WriteFile(file, []byte("0x6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	server := newAuthTestServer(t, &AuthConfig{
+		Credentials: []Credential{{Name: "operator", Secret: file, Allow: []string{"private"}}},
+	})
+	defer server.Close()
+
+	token := func(key []byte, issued time.Time) map[string]string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: issued.Unix()}).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]string{"Authorization": "Bearer " + signed}
+	}
+	if status, errcode := call(t, server.URL, "private_secret", token(secret, time.Now())); status != http.StatusOK || errcode != 0 {
+		t.Errorf("valid token: have status %d, error %d", status, errcode)
+	}
+	if _, errcode := call(t, server.URL, "open_secret", token(secret, time.Now())); errcode != -32001 {
+		t.Errorf("disallowed namespace: have error %d, want -32001", errcode)
+	}
+	if status, _ := call(t, server.URL, "private_secret", token(secret, time.Now().Add(-time.Hour))); status != http.StatusUnauthorized {
+		t.Errorf("stale token: have status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := call(t, server.URL, "private_secret", token([]byte(strings.Repeat("x", 32)), time.Now())); status != http.StatusUnauthorized {
+		t.Errorf("foreign token: have status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := call(t, server.URL, "private_secret", nil); status != http.StatusUnauthorized {
+		t.Errorf("missing credential: have status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
@@ -22,8 +22,9 @@ import (
 	"github.com/sero-cash/go-sero/log"
 )
 
-// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
+// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules,
+// authenticating the clients with auth and limiting them with limiter if not nil
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts, auth *Authenticator, limiter *RateLimiter) (net.Listener, *Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -39,6 +40,8 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 			log.Debug("HTTP registered", "namespace", api.Namespace)
 		}
 	}
+	handler.SetAuthenticator(auth)
+	handler.SetRateLimiter(limiter)
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
@@ -51,8 +54,9 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	return listener, handler, err
 }
 
-// StartWSEndpoint starts a websocket endpoint
-func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool) (net.Listener, *Server, error) {
+// StartWSEndpoint starts a websocket endpoint, authenticating the clients with
+// auth and limiting them with limiter if not nil
+func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool, auth *Authenticator, limiter *RateLimiter) (net.Listener, *Server, error) {
 
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
@@ -69,6 +73,8 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 			log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
 		}
 	}
+	handler.SetAuthenticator(auth)
+	handler.SetRateLimiter(limiter)
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
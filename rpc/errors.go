@@ -30,6 +30,18 @@ func (e *methodNotFoundError) Error() string {
 	return fmt.Sprintf("The method %s%s%s does not exist/is not available", e.service, serviceMethodSeparator, e.method)
 }
 
+// client isn't allowed to call the method
+type unauthorizedError struct {
+	service string
+	method  string
+}
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("The method %s%s%s is not allowed", e.service, serviceMethodSeparator, e.method)
+}
+
 // received message isn't a valid request
 type invalidRequestError struct{ message string }
 
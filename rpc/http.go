@@ -196,10 +196,9 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
-// NewHTTPServer creates a new HTTP RPC server around an API provider.
-//
-// Deprecated: Server implements http.Handler
-func NewHTTPServer(cors []string, vhosts []string, timeouts HTTPTimeouts, srv *Server) *http.Server {
+// NewHTTPServer creates a new HTTP server around a handler, typically an RPC
+// server, wrapping it in the CORS and virtual host checks.
+func NewHTTPServer(cors []string, vhosts []string, timeouts HTTPTimeouts, srv http.Handler) *http.Server {
 	// Wrap the CORS-handler within a host-handler
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
@@ -236,10 +235,18 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	ctx := r.Context()
+	if srv.auth != nil {
+		p, err := srv.auth.authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx = withPrincipal(ctx, p)
+	}
 	// All checks passed, create a codec that reads direct from the request body
 	// untilEOF and writes the response to w and order the server to process a
 	// single request.
-	ctx := r.Context()
 	ctx = context.WithValue(ctx, "remote", r.RemoteAddr)
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
@@ -252,6 +259,53 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	srv.ServeSingleRequest(ctx, codec, OptionMethodInvocation)
 }
 
+// guardedHandler applies the checks of the HTTP RPC endpoint to the requests
+// of another HTTP API.
+type guardedHandler struct {
+	handler   http.Handler
+	auth      *Authenticator
+	limiter   *RateLimiter
+	namespace string
+	method    string
+}
+
+// NewGuardedHandler wraps the handler of another HTTP API, such as GraphQL, in
+// the request validation, the body size limit, the authentication and the rate
+// limit of the HTTP RPC endpoint. Every request counts as a call of the method
+// of the namespace. The authenticator and the rate limiter may be nil.
+func NewGuardedHandler(handler http.Handler, auth *Authenticator, limiter *RateLimiter, namespace, method string) http.Handler {
+	return &guardedHandler{handler, auth, limiter, namespace, method}
+}
+
+func (h *guardedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if code, err := validateRequest(r); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	ctx := context.WithValue(r.Context(), "remote", r.RemoteAddr)
+	if h.auth != nil {
+		p, err := h.auth.authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx = withPrincipal(ctx, p)
+		if err := h.auth.authorize(ctx, h.namespace, h.method); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if h.limiter != nil {
+		if err := h.limiter.take(ctx, h.namespace, h.method, time.Now()); err != nil {
+			w.Header().Set("Retry-After", fmt.Sprint(int64(err.retryAfter/time.Second)+1))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestContentLength)
+	h.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // validateRequest returns a non-zero response code and error message if the
 // request is invalid.
 func validateRequest(r *http.Request) (int, error) {
@@ -270,7 +324,7 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -0,0 +1,222 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/sero-cash/go-sero/metrics"
+)
+
+const (
+	// DefaultMaxBatch is the maximum number of requests of a batch, if not
+	// configured.
+	DefaultMaxBatch = 100
+
+	// maxLimitedClients is the number of clients whose token buckets are kept,
+	// the least recently seen ones being dropped, with full buckets.
+	maxLimitedClients = 4096
+)
+
+// DefaultMethodCosts are the costs of the heavy methods, if not configured. The
+// other methods cost 1.
+var DefaultMethodCosts = map[string]float64{
+	"debug_traceBlock":       50,
+	"debug_traceChain":       100,
+	"debug_traceTransaction": 20,
+	"exchange_getRecords":    20,
+	"graphql_query":          10,
+	"light_getOutsByPKr":     20,
+	"sero_getLogs":           10,
+}
+
+var (
+	rpcCostMeter         = metrics.NewRegisteredMeter("rpc/requests/cost", nil)
+	rpcLimitedMeter      = metrics.NewRegisteredMeter("rpc/requests/limited", nil)
+	rpcBatchLimitedMeter = metrics.NewRegisteredMeter("rpc/batches/limited", nil)
+	rpcClientsGauge      = metrics.NewRegisteredGauge("rpc/clients", nil)
+)
+
+// RateLimitConfig configures the limits of the requests of the HTTP and
+// websocket clients, identified by their credential or their IP address.
+type RateLimitConfig struct {
+	Rate  float64 // Cost a client may spend per second
+	Burst float64 // Cost a client may spend at once
+
+	// Costs are the costs of methods ("debug_traceBlock") or of namespaces
+	// ("exchange"), the methods costing 1 otherwise. DefaultMethodCosts is used
+	// if nil.
+	Costs map[string]float64 `toml:",omitempty"`
+
+	// MaxBatch is the maximum number of requests of a batch, DefaultMaxBatch if
+	// zero.
+	MaxBatch int `toml:",omitempty"`
+}
+
+// tokenBucket limits the cost of the requests of a client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter enforces the per-client limits of the cost of the requests.
+type RateLimiter struct {
+	rate     float64
+	burst    float64
+	costs    map[string]float64
+	maxBatch int
+
+	buckets *lru.Cache // Token buckets of the clients, by identity
+	lock    sync.Mutex
+}
+
+// NewRateLimiter creates a rate limiter of the given configuration.
+func NewRateLimiter(config *RateLimitConfig) (*RateLimiter, error) {
+	if config.Rate <= 0 || config.Burst < 1 {
+		return nil, fmt.Errorf("invalid RPC rate limit: rate %v, burst %v", config.Rate, config.Burst)
+	}
+	costs := config.Costs
+	if costs == nil {
+		costs = DefaultMethodCosts
+	}
+	for name, cost := range costs {
+		if cost < 0 {
+			return nil, fmt.Errorf("invalid RPC cost of %s: %v", name, cost)
+		}
+	}
+	maxBatch := config.MaxBatch
+	if maxBatch == 0 {
+		maxBatch = DefaultMaxBatch
+	}
+	buckets, _ := lru.New(maxLimitedClients)
+	return &RateLimiter{
+		rate:     config.Rate,
+		burst:    config.Burst,
+		costs:    costs,
+		maxBatch: maxBatch,
+		buckets:  buckets,
+	}, nil
+}
+
+// cost returns the cost of a method, the one of the method taking precedence
+// over the one of its namespace. The methods costing more than the burst spend
+// a full bucket.
+func (l *RateLimiter) cost(namespace, method string) float64 {
+	cost, ok := l.costs[namespace+serviceMethodSeparator+method]
+	if !ok {
+		if cost, ok = l.costs[namespace]; !ok {
+			cost = 1
+		}
+	}
+	return math.Min(cost, l.burst)
+}
+
+// take spends the cost of a method from the bucket of the client of the
+// request, failing if the bucket holds too few tokens. The requests of local
+// clients, over IPC or in process, are not limited.
+func (l *RateLimiter) take(ctx context.Context, namespace, method string, now time.Time) *rateLimitError {
+	client := clientIdentity(ctx)
+	if client == "" {
+		return nil
+	}
+	cost := l.cost(namespace, method)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var bucket *tokenBucket
+	if cached, ok := l.buckets.Get(client); ok {
+		bucket = cached.(*tokenBucket)
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	} else {
+		bucket = &tokenBucket{tokens: l.burst}
+		l.buckets.Add(client, bucket)
+		rpcClientsGauge.Update(int64(l.buckets.Len()))
+	}
+	bucket.last = now
+
+	if bucket.tokens < cost {
+		rpcLimitedMeter.Mark(1)
+		wait := time.Duration((cost - bucket.tokens) / l.rate * float64(time.Second))
+		return &rateLimitError{namespace: namespace, method: method, cost: cost, retryAfter: wait}
+	}
+	bucket.tokens -= cost
+	rpcCostMeter.Mark(int64(math.Ceil(cost)))
+	return nil
+}
+
+// clientIdentity returns the identity of the client of a request, its
+// credential if it authenticated with one, or else its IP address.
+func clientIdentity(ctx context.Context) string {
+	if p, ok := ctx.Value(principalKey{}).(*principal); ok && p.name != publicPrincipal {
+		return "credential:" + p.name
+	}
+	remote, ok := ctx.Value("remote").(string)
+	if !ok || remote == "" {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// SetRateLimiter makes the server enforce the limits of the rate limiter on the
+// HTTP and websocket clients.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	s.limiter = limiter
+}
+
+// rateLimitError is returned if a client exceeds its rate limit.
+type rateLimitError struct {
+	namespace  string
+	method     string
+	cost       float64
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded calling %s%s%s", e.namespace, serviceMethodSeparator, e.method)
+}
+
+// info returns the details of the error, sent as its data.
+func (e *rateLimitError) info() interface{} {
+	return map[string]interface{}{
+		"cost":       e.cost,
+		"retryAfter": int64(e.retryAfter / time.Millisecond),
+	}
+}
+
+// batchLimitError is returned if a batch holds too many requests.
+type batchLimitError struct {
+	size  int
+	limit int
+}
+
+func (e *batchLimitError) ErrorCode() int { return -32005 }
+
+func (e *batchLimitError) Error() string {
+	return fmt.Sprintf("batch of %d requests exceeds the limit of %d", e.size, e.limit)
+}
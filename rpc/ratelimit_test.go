@@ -0,0 +1,139 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the clients spend the cost of their calls from distinct buckets,
+// refilled over time, and that local clients are not limited.
+func TestRateLimiterBuckets(t *testing.T) {
+	limiter, err := NewRateLimiter(&RateLimitConfig{
+		Rate:  1,
+		Burst: 10,
+		Costs: map[string]float64{"private": 4, "private_echo": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		now   = time.Now()
+		alice = context.WithValue(context.Background(), "remote", "10.0.0.1:5000")
+		bob   = context.WithValue(context.Background(), "remote", "10.0.0.2:5000")
+		local = context.Background()
+	)
+	// Method costs take precedence over namespace costs
+	for i := 0; i < 2; i++ {
+		if err := limiter.take(alice, "private", "secret", now); err != nil {
+			t.Fatalf("call %d limited: %v", i, err)
+		}
+	}
+	if err := limiter.take(alice, "private", "echo", now); err != nil {
+		t.Fatalf("echo limited: %v", err)
+	}
+	limited := limiter.take(alice, "private", "secret", now)
+	if limited == nil {
+		t.Fatal("exhausted bucket not limited")
+	}
+	if limited.retryAfter != 4*time.Second {
+		t.Errorf("retry delay mismatch: have %v, want 4s", limited.retryAfter)
+	}
+	// Other ports of the same host share the bucket, other hosts and local
+	// clients don't
+	if err := limiter.take(context.WithValue(context.Background(), "remote", "10.0.0.1:6000"), "open", "echo", now); err == nil {
+		t.Error("host bucket not shared")
+	}
+	if err := limiter.take(bob, "private", "secret", now); err != nil {
+		t.Errorf("other client limited: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := limiter.take(local, "private", "secret", now); err != nil {
+			t.Fatalf("local client limited: %v", err)
+		}
+	}
+	// The bucket refills at the configured rate
+	if err := limiter.take(alice, "private", "secret", now.Add(4*time.Second)); err != nil {
+		t.Errorf("refilled bucket limited: %v", err)
+	}
+}
+
+// Tests that limited requests get structured errors, and that oversized batches
+// are rejected whole.
+func TestRateLimiterHTTP(t *testing.T) {
+	limiter, err := NewRateLimiter(&RateLimitConfig{Rate: 0.001, Burst: 3, MaxBatch: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer()
+	if err := srv.RegisterName("open", new(AuthTestService)); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetRateLimiter(limiter)
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	post := func(body string) []byte {
+		resp, err := http.Post(server.URL, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var raw json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	request := `{"jsonrpc":"2.0","id":1,"method":"open_secret","params":[]}`
+
+	var batchResp struct {
+		Error *jsonError `json:"error"`
+	}
+	if err := json.Unmarshal(post("["+request+","+request+","+request+"]"), &batchResp); err != nil {
+		t.Fatal(err)
+	}
+	if batchResp.Error == nil || batchResp.Error.Code != -32005 {
+		t.Fatalf("oversized batch not rejected: %+v", batchResp.Error)
+	}
+	for i := 0; i < 3; i++ {
+		if _, errcode := call(t, server.URL, "open_secret", nil); errcode != 0 {
+			t.Fatalf("call %d limited: error %d", i, errcode)
+		}
+	}
+	var resp struct {
+		Error *struct {
+			Code int
+			Data struct {
+				Cost       float64
+				RetryAfter int64
+			}
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(post(request), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32005 || resp.Error.Data.Cost != 1 || resp.Error.Data.RetryAfter <= 0 {
+		t.Fatalf("rate limit error mismatch: %+v", resp.Error)
+	}
+}
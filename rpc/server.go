@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/sero-cash/go-sero/log"
@@ -184,6 +185,15 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			}
 			return nil
 		}
+		// Reject the batches holding too many requests at once
+		if batch && s.limiter != nil && len(reqs) > s.limiter.maxBatch {
+			rpcBatchLimitedMeter.Mark(1)
+			codec.Write(codec.CreateErrorResponse(nil, &batchLimitError{size: len(reqs), limit: s.limiter.maxBatch}))
+			if singleShot {
+				return nil
+			}
+			continue
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -274,6 +284,17 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	if s.auth != nil {
+		if err := s.auth.authorize(ctx, req.svcname, req.method); err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil
+		}
+	}
+	if s.limiter != nil {
+		if err := s.limiter.take(ctx, req.svcname, req.method, time.Now()); err != nil {
+			return codec.CreateErrorResponseWithInfo(&req.id, err, err.info()), nil
+		}
+	}
+
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
@@ -407,7 +428,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		if r.isPubSub { // sero_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: r.method, callb: callb}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -424,7 +445,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: r.method, callb: callb}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
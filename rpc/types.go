@@ -57,6 +57,7 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
@@ -70,6 +71,8 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 // Server represents a RPC server
 type Server struct {
 	services serviceRegistry
+	auth     *Authenticator // Checks the credentials of HTTP and websocket clients, nil if open
+	limiter  *RateLimiter   // Limits the requests of HTTP and websocket clients, nil if unlimited
 
 	run      int32
 	codecsMu sync.Mutex
@@ -56,8 +56,21 @@ var websocketJSONCodec = websocket.Codec{
 // allowedOrigins should be a comma-separated list of allowed origin URLs.
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
+	validateOrigin := wsHandshakeValidator(allowedOrigins)
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins),
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			if err := validateOrigin(cfg, req); err != nil {
+				return err
+			}
+			// Reject the clients without valid credentials before the upgrade
+			if srv.auth != nil {
+				if _, err := srv.auth.authenticate(req); err != nil {
+					log.Warn("Rejected WS-RPC client", "remote", req.RemoteAddr, "err", err)
+					return err
+				}
+			}
+			return nil
+		},
 		Handler: func(conn *websocket.Conn) {
 			// Create a custom encode/decode pair to enforce payload size and number encoding
 			conn.MaxPayloadBytes = int(maxRequestContentLength)
@@ -68,7 +81,20 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			codec := NewCodec(conn, encoder, decoder)
+			defer codec.Close()
+
+			ctx := context.WithValue(context.Background(), "remote", conn.Request().RemoteAddr)
+			if srv.auth != nil {
+				// The credentials were checked by the handshake, the client
+				// keeps the permissions of the connection time
+				p, err := srv.auth.authenticate(conn.Request())
+				if err != nil {
+					return
+				}
+				ctx = withPrincipal(ctx, p)
+			}
+			srv.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
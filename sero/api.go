@@ -211,6 +211,14 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(api.s.miner.HashRate())
 }
 
+// StratumWorkers returns the statistics of the workers connected to the stratum server.
+func (api *PrivateMinerAPI) StratumWorkers() ([]miner.StratumWorker, error) {
+	if api.s.stratum == nil {
+		return nil, errors.New("stratum server not enabled")
+	}
+	return api.s.stratum.Workers(), nil
+}
+
 // PrivateAdminAPI is the collection of Sero full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -320,14 +328,26 @@ func NewPublicDebugAPI(eth *Sero) *PublicDebugAPI {
 	return &PublicDebugAPI{sero: eth}
 }
 
-// DumpBlock retrieves the entire state of the database at a given block.
-func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+// DumpBlock retrieves the entire state of the database at a given block,
+// with the assets of the accounts if withAssets is set.
+func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber, withAssets *bool) (state.Dump, error) {
+	dump := func(stateDb *state.StateDB, number uint64) (state.Dump, error) {
+		if withAssets == nil || !*withAssets {
+			return stateDb.RawDump(), nil
+		}
+		received, err := core.ContractTickets(api.sero.ChainDb(), number)
+		if err != nil {
+			return state.Dump{}, err
+		}
+		return stateDb.RawDumpWithAssets(received), nil
+	}
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
-		// the miner and operate on those
-		_, stateDb := api.sero.miner.Pending()
-		return stateDb.RawDump(), nil
+		// the miner and operate on those, the tickets received in
+		// the pending block being in its state
+		block, stateDb := api.sero.miner.Pending()
+		return dump(stateDb, block.NumberU64()-1)
 	}
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
@@ -342,7 +362,7 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	if err != nil {
 		return state.Dump{}, err
 	}
-	return stateDb.RawDump(), nil
+	return dump(stateDb, block.NumberU64())
 }
 
 // PrivateDebugAPI is the collection of Sero full node APIs exposed over
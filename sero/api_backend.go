@@ -232,6 +232,10 @@ func (b *SeroAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *SeroAPIBackend) SuggestFee(ctx context.Context, currency string, to *common.Address) (*big.Int, error) {
+	return b.gpo.SuggestFee(ctx, currency, to)
+}
+
 func (b *SeroAPIBackend) ChainDb() serodb.Database {
 	return b.sero.ChainDb()
 }
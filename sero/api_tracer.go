@@ -574,15 +574,17 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
+		// Constuct the native or JavaScript tracer to execute with
+		var traceFn tracers.ResultTracer
+		if traceFn, err = tracers.NewTracer(*config.Tracer); err != nil {
 			return nil, err
 		}
+		tracer = traceFn
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			traceFn.Stop(errors.New("execution timeout"))
 		}()
 		defer cancel()
 
@@ -609,7 +611,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *tracers.Tracer:
+	case tracers.ResultTracer:
 		return tracer.GetResult()
 
 	default:
@@ -61,6 +61,7 @@ import (
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/filters"
 	"github.com/sero-cash/go-sero/sero/gasprice"
+	"github.com/sero-cash/go-sero/sero/registry"
 	"github.com/sero-cash/go-sero/serodb"
 	"github.com/sero-cash/go-sero/zero/wallet/light"
 )
@@ -99,9 +100,12 @@ type Sero struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	registryIndexer *core.ChainIndexer // Token and ticket category registry indexer
+
 	APIBackend *SeroAPIBackend
 
 	miner    *miner.Miner
+	stratum  *miner.StratumServer
 	gasPrice *big.Int
 	serobase address.AccountAddress
 
@@ -120,7 +124,7 @@ func (s *Sero) AddLesServer(ls LesServer) {
 // initialisation of the common Sero object)
 func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 	if config.SyncMode == downloader.LightSync {
-		return nil, errors.New("can't run sero.Sero in light sync mode, use les.LightEthereum")
+		return nil, errors.New("can't run sero.Sero in light sync mode, use zlight.LightSero")
 	}
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
@@ -148,6 +152,8 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 		serobase:       config.Serobase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+
+		registryIndexer: registry.NewIndexer(chainDb),
 	}
 
 	log.Info("Initialising Sero protocol", "versions", ProtocolVersions, "network", config.NetworkId)
@@ -160,7 +166,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 		rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 	}
 	var (
-		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, ReceiptErrors: config.ReceiptErrors}
 		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
 	)
 	sero.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, sero.chainConfig, sero.engine, vmConfig, sero.accountManager)
@@ -177,6 +183,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	sero.bloomIndexer.Start(sero.blockchain)
+	sero.registryIndexer.Start(sero.blockchain)
 
 	//if config.TxPool.Journal != "" {
 	//	config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -190,6 +197,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 	}
 	sero.miner = miner.New(sero, sero.chainConfig, sero.EventMux(), sero.voter, sero.engine)
 	sero.miner.SetExtra(makeExtraData(config.ExtraData))
+	policy, err := miner.NewTxPolicy(config.TxPolicy)
+	if err != nil {
+		return nil, err
+	}
+	sero.miner.SetTxPolicy(policy)
+	if config.Stratum.Addr != "" {
+		if sero.stratum, err = miner.NewStratumServer(config.Stratum, sero.engine); err != nil {
+			return nil, err
+		}
+		sero.miner.Register(sero.stratum)
+	}
 
 	sero.APIBackend = &SeroAPIBackend{sero, nil}
 	gpoParams := config.GPO
@@ -294,6 +312,11 @@ func (s *Sero) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
 			Public:    true,
+		}, {
+			Namespace: "sero",
+			Version:   "1.0",
+			Service:   registry.NewPublicRegistryAPI(registry.New(s.chainDb)),
+			Public:    true,
 		}, {
 			Namespace: "miner",
 			Version:   "1.0",
@@ -404,6 +427,7 @@ func (s *Sero) Voter() *voter.Voter                { return s.voter }
 func (s *Sero) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Sero) Engine() consensus.Engine           { return s.engine }
 func (s *Sero) ChainDb() serodb.Database           { return s.chainDb }
+func (s *Sero) LightNode() *light.LightNode        { return s.lightNode }
 func (s *Sero) IsListening() bool                  { return true } // Always listening
 func (s *Sero) EthVersion() int                    { return int(s.protocolManager.SubProtocols[0].Version) }
 func (s *Sero) NetVersion() uint64                 { return s.networkID }
@@ -436,6 +460,7 @@ func (s *Sero) Start(srvr *p2p.Server) error {
 		maxPeers -= s.config.LightPeers
 	}
 	// Start the networking layer and the light server if requested
+	s.protocolManager.reporter = srvr
 	s.protocolManager.Start(maxPeers)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
@@ -447,6 +472,7 @@ func (s *Sero) Start(srvr *p2p.Server) error {
 // Sero protocol.
 func (s *Sero) Stop() error {
 	s.bloomIndexer.Close()
+	s.registryIndexer.Close()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -0,0 +1,175 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package sero
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+)
+
+const (
+	// maxPartialBlocks is the maximum number of compact blocks per peer waiting
+	// for their missing transactions.
+	maxPartialBlocks = 4
+
+	// blockTxsTimeout is the time after which a compact block still missing
+	// transactions is given up.
+	blockTxsTimeout = 5 * time.Second
+
+	// maxPropagatedBlocks is the number of propagated blocks kept around to serve
+	// the transactions of their compact blocks.
+	maxPropagatedBlocks = 16
+)
+
+var (
+	errTxRootMismatch  = errors.New("transaction root mismatch")
+	errTxCountMismatch = errors.New("transaction count mismatch")
+)
+
+// shortTxID identifies a transaction of a compact block. It is salted with the
+// block hash, so that colliding transactions can't be crafted in advance for
+// every block.
+type shortTxID [6]byte
+
+// newShortTxID returns the short ID of a transaction in the given block.
+func newShortTxID(block common.Hash, tx common.Hash) (id shortTxID) {
+	copy(id[:], crypto.Keccak256(block[:], tx[:]))
+	return id
+}
+
+// newCompactBlock packs a block for a peer, sending in full only the
+// transactions the peer is not known to have.
+func newCompactBlock(block *types.Block, td *big.Int, known func(common.Hash) bool) *compactBlockData {
+	hash := block.Hash()
+	data := &compactBlockData{Header: block.Header(), TD: td}
+	for i, tx := range block.Transactions() {
+		if known(tx.Hash()) {
+			data.ShortIDs = append(data.ShortIDs, newShortTxID(hash, tx.Hash()))
+		} else {
+			data.Prefilled = append(data.Prefilled, prefilledTx{Index: uint32(i), Tx: tx})
+		}
+	}
+	return data
+}
+
+// partialBlock is a compact block being rebuilt from the local transactions.
+type partialBlock struct {
+	header    *types.Header
+	td        *big.Int
+	txs       []*types.Transaction
+	missing   []uint32  // Indexes of the transactions to fetch from the peer
+	requested time.Time // Time the missing transactions were requested
+}
+
+// newPartialBlock rebuilds a compact block with the transactions found by
+// short ID in the given index. A nil entry of the index marks a short ID
+// shared by several transactions, which are then fetched from the peer.
+func newPartialBlock(data *compactBlockData, index map[shortTxID]*types.Transaction) (*partialBlock, error) {
+	if data.Header == nil || data.TD == nil {
+		return nil, errors.New("missing header or total difficulty")
+	}
+	total := len(data.ShortIDs) + len(data.Prefilled)
+	partial := &partialBlock{
+		header: data.Header,
+		td:     data.TD,
+		txs:    make([]*types.Transaction, total),
+	}
+	for i, prefilled := range data.Prefilled {
+		if prefilled.Tx == nil {
+			return nil, fmt.Errorf("prefilled transaction %d is nil", i)
+		}
+		if int(prefilled.Index) >= total || (i > 0 && prefilled.Index <= data.Prefilled[i-1].Index) {
+			return nil, fmt.Errorf("prefilled transaction %d has invalid index %d", i, prefilled.Index)
+		}
+		partial.txs[prefilled.Index] = prefilled.Tx
+	}
+	// The short IDs fill the positions left by the prefilled transactions
+	ids := data.ShortIDs
+	for i := range partial.txs {
+		if partial.txs[i] != nil {
+			continue
+		}
+		if tx := index[ids[0]]; tx != nil {
+			partial.txs[i] = tx
+		} else {
+			partial.missing = append(partial.missing, uint32(i))
+		}
+		ids = ids[1:]
+	}
+	return partial, nil
+}
+
+// fill completes the block with the missing transactions sent by the peer.
+func (self *partialBlock) fill(txs []*types.Transaction) error {
+	if len(txs) != len(self.missing) {
+		return errTxCountMismatch
+	}
+	for i, index := range self.missing {
+		if txs[i] == nil {
+			return fmt.Errorf("transaction %d is nil", i)
+		}
+		self.txs[index] = txs[i]
+	}
+	self.missing = nil
+	return nil
+}
+
+// block assembles the rebuilt block, making sure that its transactions are the
+// ones of the header.
+func (self *partialBlock) block() (*types.Block, error) {
+	if types.DeriveSha(types.Transactions(self.txs)) != self.header.TxHash {
+		return nil, errTxRootMismatch
+	}
+	return types.NewBlockWithHeader(self.header).WithBody(self.txs), nil
+}
+
+// addPartialBlock tracks a compact block waiting for its missing transactions,
+// dropping the ones which timed out. It returns false if the peer has too many
+// of them already.
+func (p *peer) addPartialBlock(hash common.Hash, partial *partialBlock) bool {
+	for hash, pending := range p.partials {
+		if time.Since(pending.requested) > blockTxsTimeout {
+			delete(p.partials, hash)
+		}
+	}
+	if len(p.partials) >= maxPartialBlocks {
+		return false
+	}
+	partial.requested = time.Now()
+	p.partials[hash] = partial
+	return true
+}
+
+// shortTxIndex indexes the transactions by their short ID in the given block.
+func shortTxIndex(block common.Hash, txs []*types.Transaction) map[shortTxID]*types.Transaction {
+	index := make(map[shortTxID]*types.Transaction, len(txs))
+	for _, tx := range txs {
+		id := newShortTxID(block, tx.Hash())
+		if _, ok := index[id]; ok {
+			index[id] = nil
+		} else {
+			index[id] = tx
+		}
+	}
+	return index
+}
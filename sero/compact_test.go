@@ -0,0 +1,304 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package sero
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/sero/fetcher"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+)
+
+// newTestBlock returns a block holding the given number of transactions, with
+// signatures standing in for the size of the zero proofs.
+func newTestBlock(n int) *types.Block {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		t := &stx.T{Ehash: keys.Uint256{byte(i + 1)}}
+		for j := range t.Sign {
+			t.Sign[j], t.Bsign[j] = byte(i), byte(j)
+		}
+		txs[i] = types.NewTxWithGTx(25000, big.NewInt(1000000000), t)
+	}
+	return types.NewBlock(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}, txs, nil)
+}
+
+// newTestPeers returns two sero/64 peers connected through a message pipe.
+func newTestPeers() (*peer, *peer) {
+	rw1, rw2 := p2p.MsgPipe()
+	p1 := newPeer(sero64, p2p.NewPeer(discover.NodeID{1}, "sender", nil), rw1)
+	p2 := newPeer(sero64, p2p.NewPeer(discover.NodeID{2}, "receiver", nil), rw2)
+	return p1, p2
+}
+
+// readMsg reads the next message from the peer, checking its code.
+func readMsg(t *testing.T, p *peer, code uint64) p2p.Msg {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Code != code {
+		t.Fatalf("message code mismatch: have %#x, want %#x", msg.Code, code)
+	}
+	return msg
+}
+
+// Tests that a compact block is rebuilt from the pool of the receiver, with the
+// transactions it lacks fetched in a single round trip.
+func TestCompactBlockRelay(t *testing.T) {
+	block, td := newTestBlock(16), big.NewInt(2)
+	txs := block.Transactions()
+
+	sender, receiver := newTestPeers()
+	defer sender.rw.(*p2p.MsgPipeRW).Close()
+
+	// The sender saw all but the last two transactions from the receiver, which
+	// lost one of them from its pool since
+	for _, tx := range txs[:14] {
+		sender.MarkTransaction(tx.Hash())
+	}
+	pool := append(types.Transactions{}, txs[:10]...)
+	pool = append(pool, txs[11:]...)
+
+	go sender.SendNewCompactBlock(block, td)
+	msg := readMsg(t, receiver, NewCompactBlockMsg)
+	var request compactBlockData
+	if err := msg.Decode(&request); err != nil {
+		t.Fatal(err)
+	}
+	if len(request.ShortIDs) != 14 || len(request.Prefilled) != 2 {
+		t.Fatalf("compact block mismatch: have %d short IDs and %d prefilled, want 14 and 2", len(request.ShortIDs), len(request.Prefilled))
+	}
+	partial, err := newPartialBlock(&request, shortTxIndex(request.Header.Hash(), pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partial.missing) != 1 || partial.missing[0] != 10 {
+		t.Fatalf("missing transactions mismatch: have %v, want [10]", partial.missing)
+	}
+	// Fetch the missing transaction and rebuild the block
+	go receiver.RequestBlockTxs(block.Hash(), partial.missing)
+	msg = readMsg(t, sender, GetBlockTxsMsg)
+	var query getBlockTxsData
+	if err := msg.Decode(&query); err != nil {
+		t.Fatal(err)
+	}
+	go sender.SendBlockTxs(query.Hash, []*types.Transaction{txs[query.Indexes[0]]})
+	msg = readMsg(t, receiver, BlockTxsMsg)
+	var response blockTxsData
+	if err := msg.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if err := partial.fill(response.Txs); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := partial.block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.Hash() != block.Hash() || rebuilt.TxHash() != block.TxHash() {
+		t.Errorf("rebuilt block mismatch: have %x, want %x", rebuilt.Hash(), block.Hash())
+	}
+}
+
+// Tests that a wrong transaction picked from the pool is caught by the
+// transaction root of the header.
+func TestCompactBlockMismatch(t *testing.T) {
+	block, other := newTestBlock(4), newTestBlock(5)
+
+	data := newCompactBlock(block, big.NewInt(2), func(common.Hash) bool { return true })
+	index := shortTxIndex(block.Hash(), block.Transactions())
+	index[data.ShortIDs[2]] = other.Transactions()[4]
+
+	partial, err := newPartialBlock(data, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.block(); err != errTxRootMismatch {
+		t.Errorf("error mismatch: have %v, want %v", err, errTxRootMismatch)
+	}
+	// Malformed prefilled indexes are rejected outright
+	data.Prefilled = []prefilledTx{{Index: 5, Tx: other.Transactions()[0]}}
+	if _, err := newPartialBlock(data, index); err == nil {
+		t.Error("out of range prefilled transaction accepted")
+	}
+}
+
+// Tests the bandwidth saved by relaying compact blocks to peers which have seen
+// the transactions of the block, compared to full blocks.
+func TestCompactBlockBandwidth(t *testing.T) {
+	block, td := newTestBlock(64), big.NewInt(2)
+
+	sender, receiver := newTestPeers()
+	defer sender.rw.(*p2p.MsgPipeRW).Close()
+
+	go sender.SendNewBlock(block, td)
+	full := readMsg(t, receiver, NewBlockMsg)
+	full.Discard()
+
+	for _, tx := range block.Transactions() {
+		sender.MarkTransaction(tx.Hash())
+	}
+	go sender.SendNewCompactBlock(block, td)
+	compact := readMsg(t, receiver, NewCompactBlockMsg)
+	compact.Discard()
+
+	if compact.Size*4 > full.Size {
+		t.Errorf("compact block too large: %d bytes, full block %d bytes", compact.Size, full.Size)
+	}
+	t.Logf("full block %d bytes, compact block %d bytes, %.1f%% saved", full.Size, compact.Size, 100-100*float64(compact.Size)/float64(full.Size))
+}
+
+// testTxPool is a transaction pool serving a fixed set of pending transactions.
+type testTxPool struct {
+	pending types.Transactions
+}
+
+func (pool *testTxPool) AddRemotes([]*types.Transaction) []error { return nil }
+
+func (pool *testTxPool) Pending() (types.Transactions, error) {
+	return append(types.Transactions{}, pool.pending...), nil
+}
+
+func (pool *testTxPool) SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription {
+	return new(event.Feed).Subscribe(make(chan core.NewTxsEvent))
+}
+
+// newTestHandler creates a protocol manager relaying compact blocks with the
+// given pool, the blocks it imports being sent to the returned channel. The
+// parent of the test blocks is known to its fetcher.
+func newTestHandler(pool types.Transactions) (*ProtocolManager, chan *types.Block) {
+	pm := &ProtocolManager{txpool: &testTxPool{pool}}
+	pm.propagated, _ = lru.New(maxPropagatedBlocks)
+
+	parent := types.NewBlockWithHeader(&types.Header{Number: new(big.Int), Difficulty: big.NewInt(1)})
+	inserted := make(chan *types.Block, 1)
+	pm.fetcher = fetcher.New(
+		func(hash common.Hash) *types.Block {
+			if hash == (common.Hash{}) {
+				return parent
+			}
+			return nil
+		},
+		func(*types.Header) error { return nil },
+		func(*types.Block, bool) {},
+		func() uint64 { return 0 },
+		func(blocks types.Blocks) (int, error) {
+			for _, block := range blocks {
+				inserted <- block
+			}
+			return len(blocks), nil
+		},
+		func(string, p2p.Misbehaviour) {},
+	)
+	return pm, inserted
+}
+
+// Tests the compact block relay through the message handler: the transactions
+// missing from the pool are fetched from the peer and the block imported, the
+// transactions of a propagated block are served, and an empty reply falls back
+// to fetching the full block.
+func TestCompactBlockHandler(t *testing.T) {
+	block, td := newTestBlock(16), big.NewInt(2)
+	txs := block.Transactions()
+
+	pool := append(types.Transactions{}, txs[:10]...)
+	pool = append(pool, txs[11:]...)
+	pm, inserted := newTestHandler(pool)
+	pm.fetcher.Start()
+	defer pm.fetcher.Stop()
+
+	remote, local := newTestPeers()
+	defer remote.rw.(*p2p.MsgPipeRW).Close()
+	local.td = big.NewInt(100)
+	go func() {
+		for pm.handleMsg(local) == nil {
+		}
+	}()
+	for _, tx := range txs {
+		remote.MarkTransaction(tx.Hash())
+	}
+
+	// The compact block misses one transaction of the pool, which is fetched
+	if err := remote.SendNewCompactBlock(block, td); err != nil {
+		t.Fatal(err)
+	}
+	var query getBlockTxsData
+	if err := readMsg(t, remote, GetBlockTxsMsg).Decode(&query); err != nil {
+		t.Fatal(err)
+	}
+	if query.Hash != block.Hash() || len(query.Indexes) != 1 || query.Indexes[0] != 10 {
+		t.Fatalf("transaction query mismatch: have %x %v, want %x [10]", query.Hash, query.Indexes, block.Hash())
+	}
+	if err := remote.SendBlockTxs(query.Hash, []*types.Transaction{txs[10]}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case imported := <-inserted:
+		if imported.Hash() != block.Hash() || len(imported.Transactions()) != len(txs) {
+			t.Fatalf("imported block mismatch: have %x, want %x", imported.Hash(), block.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rebuilt block not imported")
+	}
+
+	// The transactions of a propagated block are served
+	pm.propagated.Add(block.Hash(), block)
+	if err := remote.RequestBlockTxs(block.Hash(), []uint32{3, 7}); err != nil {
+		t.Fatal(err)
+	}
+	var response blockTxsData
+	if err := readMsg(t, remote, BlockTxsMsg).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Txs) != 2 || response.Txs[0].Hash() != txs[3].Hash() || response.Txs[1].Hash() != txs[7].Hash() {
+		t.Fatalf("served transactions mismatch: have %d transactions", len(response.Txs))
+	}
+
+	// A peer which lost the block replies empty, the full block is fetched
+	other := newTestBlock(12)
+	pm.txpool = &testTxPool{other.Transactions()[1:]}
+	for _, tx := range other.Transactions() {
+		remote.MarkTransaction(tx.Hash())
+	}
+	if err := remote.SendNewCompactBlock(other, td); err != nil {
+		t.Fatal(err)
+	}
+	if err := readMsg(t, remote, GetBlockTxsMsg).Decode(&query); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.SendBlockTxs(query.Hash, nil); err != nil {
+		t.Fatal(err)
+	}
+	var headers getBlockHeadersData
+	if err := readMsg(t, remote, GetBlockHeadersMsg).Decode(&headers); err != nil {
+		t.Fatal(err)
+	}
+	if headers.Origin.Hash != other.Hash() || headers.Amount != 1 {
+		t.Errorf("full block fallback mismatch: have %x, want %x", headers.Origin.Hash, other.Hash())
+	}
+}
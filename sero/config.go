@@ -29,6 +29,7 @@ import (
 	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/consensus/ethash"
 	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/miner"
 	"github.com/sero-cash/go-sero/params"
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/gasprice"
@@ -50,6 +51,8 @@ var DefaultConfig = Config{
 	TrieCache:     256,
 	TrieTimeout:   60 * time.Minute,
 	GasPrice:      big.NewInt(params.Gta),
+	TxPolicy:      miner.DefaultTxPolicyConfig,
+	Stratum:       miner.DefaultStratumConfig,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -107,6 +110,8 @@ type Config struct {
 	MinerThreads int                    `toml:",omitempty"`
 	ExtraData    []byte                 `toml:",omitempty"`
 	GasPrice     *big.Int
+	TxPolicy     miner.TxPolicyConfig
+	Stratum      miner.StratumConfig
 
 	// Ethash options
 	Ethash ethash.Config
@@ -120,6 +125,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables storing the errors of the failed transactions with the receipts
+	ReceiptErrors bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
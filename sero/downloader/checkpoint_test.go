@@ -0,0 +1,150 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
+)
+
+// makeCheckpointTestChain creates a header chain on top of genesis, the fork
+// byte telling apart the chains of different peers.
+func makeCheckpointTestChain(genesis *types.Header, n int, fork byte) []*types.Header {
+	headers := []*types.Header{genesis}
+	for i := 1; i <= n; i++ {
+		headers = append(headers, &types.Header{
+			ParentHash: headers[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(1),
+			Extra:      []byte{fork},
+		})
+	}
+	return headers
+}
+
+// checkpointTestPeer is a simulated peer serving the headers of its chain.
+type checkpointTestPeer struct {
+	id      string
+	dl      *Downloader
+	headers []*types.Header
+}
+
+func (p *checkpointTestPeer) Head() (common.Hash, *big.Int) {
+	head := p.headers[len(p.headers)-1]
+	return head.Hash(), new(big.Int).Add(head.Number, common.Big1)
+}
+
+func (p *checkpointTestPeer) RequestHeadersByHash(hash common.Hash, amount int, skip int, reverse bool) error {
+	for _, header := range p.headers {
+		if header.Hash() == hash {
+			return p.RequestHeadersByNumber(header.Number.Uint64(), amount, skip, reverse)
+		}
+	}
+	return p.dl.DeliverHeaders(p.id, nil)
+}
+
+func (p *checkpointTestPeer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
+	var headers []*types.Header
+	for i := 0; i < amount && origin < uint64(len(p.headers)); i++ {
+		headers = append(headers, p.headers[origin])
+		if reverse {
+			if origin < uint64(skip+1) {
+				break
+			}
+			origin -= uint64(skip + 1)
+		} else {
+			origin += uint64(skip + 1)
+		}
+	}
+	return p.dl.DeliverHeaders(p.id, headers)
+}
+
+func (p *checkpointTestPeer) RequestBodies([]common.Hash) error   { panic("not implemented") }
+func (p *checkpointTestPeer) RequestReceipts([]common.Hash) error { panic("not implemented") }
+func (p *checkpointTestPeer) RequestNodeData([]common.Hash) error { panic("not implemented") }
+
+// Tests that a peer serving a chain conflicting with a checkpoint is dropped
+// before anything is synchronised, and that a peer on the checkpointed chain
+// passes the check.
+func TestCheckpointMismatch(t *testing.T) {
+	db := serodb.NewMemDatabase()
+	genesis := &types.Header{Number: new(big.Int), Difficulty: big.NewInt(1), Extra: []byte("checkpoint test")}
+	rawdb.WriteHeader(db, genesis)
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	canonical := makeCheckpointTestChain(genesis, 64, 0x01)
+	checkpoint.Register(genesis.Hash(), []params.Checkpoint{{Number: 32, Hash: canonical[32].Hash()}})
+
+	var dropped map[string]p2p.Misbehaviour
+	dl := New(FullSync, db, new(event.TypeMux), nil, nil, func(id string, fault p2p.Misbehaviour) {
+		dropped[id] = fault
+	})
+	defer dl.Terminate()
+
+	// A peer on a conflicting chain fails the synchronisation and is dropped
+	dropped = make(map[string]p2p.Misbehaviour)
+	forked := &checkpointTestPeer{id: "forked", dl: dl, headers: makeCheckpointTestChain(genesis, 64, 0x02)}
+	dl.RegisterPeer(forked.id, 63, forked)
+	head, td := forked.Head()
+	if err := dl.Synchronise(forked.id, head, td, FullSync); err != errCheckpointMismatch {
+		t.Fatalf("conflicting chain error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	if fault, ok := dropped[forked.id]; !ok || fault != p2p.BadResponse {
+		t.Errorf("conflicting peer not dropped for a bad response: %v", dropped)
+	}
+
+	// A peer on the checkpointed chain goes through it
+	honest := &checkpointTestPeer{id: "honest", dl: dl, headers: canonical}
+	dl.RegisterPeer(honest.id, 63, honest)
+	dl.cancelCh = make(chan struct{})
+	if err := dl.verifyCheckpoint(dl.peers.Peer(honest.id), dl.checkpoints().LatestBelow(64)); err != nil {
+		t.Fatalf("checkpointed chain rejected: %v", err)
+	}
+}
+
+// Tests that fast sync only starts from a checkpoint close to the pivot, the
+// older ones only validating the chain.
+func TestCheckpointPivot(t *testing.T) {
+	pivot := uint64(10000)
+	tests := []struct {
+		checkpoint *params.Checkpoint
+		origin     uint64
+		want       uint64
+	}{
+		{nil, 0, pivot}, // No checkpoint
+		{&params.Checkpoint{Number: pivot - 10}, 0, pivot - 10},                           // Recent checkpoint
+		{&params.Checkpoint{Number: pivot - fsMinFullBlocks}, 0, pivot - fsMinFullBlocks}, // Oldest usable checkpoint
+		{&params.Checkpoint{Number: pivot - fsMinFullBlocks - 1}, 0, pivot},               // Old checkpoint
+		{&params.Checkpoint{Number: 100}, 0, pivot},                                       // Ancient checkpoint
+		{&params.Checkpoint{Number: pivot - 10}, pivot - 5, pivot},                        // Already synced checkpoint
+		{&params.Checkpoint{Number: pivot + 10}, 0, pivot},                                // Checkpoint above the pivot
+	}
+	for i, tt := range tests {
+		if have := checkpointPivot(tt.checkpoint, tt.origin, pivot); have != tt.want {
+			t.Errorf("test %d: pivot mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
@@ -32,8 +32,10 @@ import (
 	"github.com/sero-cash/go-sero/event"
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/metrics"
+	"github.com/sero-cash/go-sero/p2p"
 	"github.com/sero-cash/go-sero/params"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
 )
 
 var (
@@ -42,6 +44,8 @@ var (
 	MaxSkeletonSize = 128 // Number of header fetches to need for a skeleton assembly
 	MaxReceiptFetch = 256 // Amount of transaction receipts to allow fetching per request
 	MaxStateFetch   = 384 // Amount of node state values to allow fetching per request
+	MaxStorageFetch = 128 // Amount of accounts to allow fetching the storage of per request
+	MaxCodeFetch    = 64  // Amount of contract codes to allow fetching per request
 
 	MaxForkAncestry  = 3 * params.EpochDuration // Maximum chain reorganisation
 	rttMinEstimate   = 2 * time.Second          // Minimum round-trip time to target for download requests
@@ -87,6 +91,7 @@ var (
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer doesn't speak recent enough protocol version (need version >= 62)")
+	errCheckpointMismatch      = errors.New("remote chain conflicts with a checkpoint")
 )
 
 type Downloader struct {
@@ -131,6 +136,10 @@ type Downloader struct {
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [sero/63] Channel receiving inbound node state data
 
+	// for the state range retrieval of snap sync
+	rangeSync *rangeSync   // [sero/65] Currently running state range retrieval, nil if none
+	rangeLock sync.RWMutex // Lock protecting the range retrieval in delivers
+
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
 	cancelCh   chan struct{}  // Channel to cancel mid-flight syncs
@@ -246,7 +255,7 @@ func (d *Downloader) Progress() sero.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -308,6 +317,19 @@ func (d *Downloader) UnregisterPeer(id string) error {
 	return nil
 }
 
+// syncFault returns the misbehaviour of a peer failing a synchronisation with
+// the given error.
+func syncFault(err error) p2p.Misbehaviour {
+	switch err {
+	case errTimeout, errStallingPeer:
+		return p2p.Timeout
+	case errInvalidChain:
+		return p2p.BadBlock
+	default:
+		return p2p.BadResponse
+	}
+}
+
 // Synchronise tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
 func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode SyncMode) error {
@@ -318,14 +340,14 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errCheckpointMismatch:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 			log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", id)
 		} else {
-			d.dropPeer(id)
+			d.dropPeer(id, syncFault(err))
 		}
 	default:
 		log.Warn("Synchronisation failed, retrying", "err", err)
@@ -433,6 +455,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	// Make sure the remote chain goes through the latest checkpoint below its head
+	checkpoint := d.checkpoints().LatestBelow(height)
+	if checkpoint != nil {
+		if err := d.verifyCheckpoint(p, checkpoint); err != nil {
+			return err
+		}
+	}
+
 	origin, err := d.findAncestor(p, height)
 	if err != nil {
 		return err
@@ -446,7 +476,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 
 	// Ensure our origin point is below any fast sync pivot point
 	pivot := uint64(0)
-	if d.mode == FastSync {
+	if d.mode.isFast() {
 		if height <= uint64(fsMinFullBlocks) {
 			origin = 0
 		} else {
@@ -455,9 +485,13 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 				origin = pivot - 1
 			}
 		}
+		if cp := checkpointPivot(checkpoint, origin, pivot); cp != pivot {
+			log.Info("Fast syncing from checkpoint", "number", checkpoint.Number, "hash", checkpoint.Hash)
+			pivot = cp
+		}
 	}
 	d.committed = 1
-	if d.mode == FastSync && pivot != 0 {
+	if d.mode.isFast() && pivot != 0 {
 		d.committed = 0
 	}
 	// Initiate the sync using a concurrent header and content retrieval algorithm
@@ -472,14 +506,27 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		func() error { return d.fetchReceipts(origin + 1) },        // Receipts are retrieved during fast sync
 		func() error { return d.processHeaders(origin+1, pivot, td) },
 	}
-	if d.mode == FastSync {
-		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
+	if d.mode.isFast() {
+		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest, pivot) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
 	return d.spawnSync(fetchers)
 }
 
+// checkpointPivot returns the fast sync pivot, moved down to the checkpoint if
+// it is not synced yet and close enough to the pivot for the peers to still
+// serve its state. An older checkpoint only validates the chain.
+func checkpointPivot(checkpoint *params.Checkpoint, origin, pivot uint64) uint64 {
+	if checkpoint == nil || checkpoint.Number <= origin || checkpoint.Number >= pivot {
+		return pivot
+	}
+	if pivot-checkpoint.Number > uint64(fsMinFullBlocks) {
+		return pivot
+	}
+	return checkpoint.Number
+}
+
 // spawnSync runs d.process and all given fetcher functions to completion in
 // separate goroutines, returning the first error that appears.
 func (d *Downloader) spawnSync(fetchers []func() error) error {
@@ -590,6 +637,53 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 	}
 }
 
+// checkpoints returns the checkpoints of the local network.
+func (d *Downloader) checkpoints() *checkpoint.Set {
+	return checkpoint.ForGenesis(rawdb.ReadCanonicalHash(d.stateDB, 0))
+}
+
+// verifyCheckpoint requests the header at the checkpoint number from the peer
+// and ensures it matches the checkpoint.
+func (d *Downloader) verifyCheckpoint(p *peerConnection, checkpoint *params.Checkpoint) error {
+	p.log.Debug("Verifying remote checkpoint", "number", checkpoint.Number, "hash", checkpoint.Hash)
+
+	go p.peer.RequestHeadersByNumber(checkpoint.Number, 1, 0, false)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 {
+				p.log.Debug("Multiple headers for single request", "headers", len(headers))
+				return errBadPeer
+			}
+			if headers[0].Number.Uint64() != checkpoint.Number || headers[0].Hash() != checkpoint.Hash {
+				p.log.Warn("Remote chain conflicts with checkpoint", "number", headers[0].Number, "hash", headers[0].Hash(), "checkpoint", checkpoint.Hash)
+				return errCheckpointMismatch
+			}
+			return nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint header timed out", "elapsed", ttl)
+			return errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
 // findAncestor tries to locate the common ancestor link of the local chain and
 // a remote peers blockchain. In the general case when our node was in sync and
 // on the correct chain, checking the top N links should already get us a match.
@@ -601,12 +695,18 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 
 	if d.mode == FullSync {
 		ceil = d.blockchain.CurrentBlock().NumberU64()
-	} else if d.mode == FastSync {
+	} else if d.mode.isFast() {
 		ceil = d.blockchain.CurrentFastBlock().NumberU64()
 	}
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
+	// Never rewind past a checkpoint the local chain already contains
+	if checkpoint := d.checkpoints().LatestBelow(ceil); checkpoint != nil && int64(checkpoint.Number)-1 > floor {
+		if header := d.lightchain.GetHeaderByHash(checkpoint.Hash); header != nil && header.Number.Uint64() == checkpoint.Number {
+			floor = int64(checkpoint.Number) - 1
+		}
+	}
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 
 	// Request the topmost blocks to short circuit binary ancestor lookup
@@ -872,7 +972,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
-			d.dropPeer(p.id)
+			d.dropPeer(p.id, p2p.Timeout)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
 			for _, ch := range []chan bool{d.bodyWakeCh, d.receiptWakeCh} {
@@ -1092,7 +1192,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 							// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 							peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pid)
 						} else {
-							d.dropPeer(pid)
+							d.dropPeer(pid, p2p.Timeout)
 						}
 					}
 				}
@@ -1234,7 +1334,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					head := d.lightchain.CurrentHeader()
 					if td.Cmp(d.lightchain.GetTd(head.Hash(), head.Number.Uint64())) > 0 {
 						return errStallingPeer
@@ -1262,7 +1362,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				chunk := headers[:limit]
 
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.isFast() || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1290,7 +1390,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode.isFast() {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -1373,7 +1473,7 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 
 // processFastSyncContent takes fetch results from the queue and writes them to the
 // database. It also controls the synchronisation of state nodes of the pivot block.
-func (d *Downloader) processFastSyncContent(latest *types.Header) error {
+func (d *Downloader) processFastSyncContent(latest *types.Header, pivot uint64) error {
 	// Start syncing state of the reported head block. This should get us most of
 	// the state of the pivot block.
 	stateSync := d.syncState(latest.Root)
@@ -1383,12 +1483,8 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			d.queue.Close() // wake up WaitResults
 		}
 	}()
-	// Figure out the ideal pivot block. Note, that this goalpost may move if the
-	// sync takes long enough for the chain head to move significantly.
-	pivot := uint64(0)
-	if height := latest.Number.Uint64(); height > uint64(fsMinFullBlocks) {
-		pivot = height - uint64(fsMinFullBlocks)
-	}
+	// The pivot block was chosen by syncWithPeer. Note, that this goalpost may move
+	// if the sync takes long enough for the chain head to move significantly.
 	// To cater for moving pivot points, track the pivot block and subsequently
 	// accumulated download results separately.
 	var (
@@ -1548,6 +1644,45 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverAccountRange injects a range of the account trie received from a
+// remote node.
+func (d *Downloader) DeliverAccountRange(id string, reqID uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) error {
+	return d.deliverRange(id, reqID, &accountRangePack{hashes, accounts, proof}, len(hashes))
+}
+
+// DeliverStorageRanges injects a batch of storage trie ranges received from a
+// remote node.
+func (d *Downloader) DeliverStorageRanges(id string, reqID uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) error {
+	items := 0
+	for _, keys := range hashes {
+		items += len(keys)
+	}
+	return d.deliverRange(id, reqID, &storageRangesPack{hashes, slots, proof}, items)
+}
+
+// DeliverByteCodes injects a batch of contract codes received from a remote node.
+func (d *Downloader) DeliverByteCodes(id string, reqID uint64, codes [][]byte) error {
+	return d.deliverRange(id, reqID, &byteCodesPack{codes}, len(codes))
+}
+
+// deliverRange hands a state range response over to the running range retrieval.
+func (d *Downloader) deliverRange(id string, reqID uint64, packet interface{}, items int) (err error) {
+	// Update the delivery metrics for both good and failed deliveries
+	rangeInMeter.Mark(int64(items))
+	defer func() {
+		if err != nil {
+			rangeDropMeter.Mark(int64(items))
+		}
+	}()
+	d.rangeLock.RLock()
+	defer d.rangeLock.RUnlock()
+
+	if d.rangeSync == nil {
+		return errNoSyncActive
+	}
+	return d.rangeSync.deliver(id, reqID, packet)
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
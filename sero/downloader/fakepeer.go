@@ -24,6 +24,7 @@ import (
 	"github.com/sero-cash/go-sero/core/rawdb"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
 )
 
 // FakePeer is a mock downloader peer that operates on a local database instance
@@ -157,3 +158,26 @@ func (p *FakePeer) RequestNodeData(hashes []common.Hash) error {
 	p.dl.DeliverNodeData(p.id, data)
 	return nil
 }
+
+// RequestAccountRange implements downloader.RangePeer, returning a range of the
+// account trie of the given state.
+func (p *FakePeer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	hashes, values, proof := ServiceAccountRange(trie.NewDatabase(p.db), root, origin, limit, bytes)
+	p.dl.DeliverAccountRange(p.id, id, hashes, values, proof)
+	return nil
+}
+
+// RequestStorageRanges implements downloader.RangePeer, returning the storage
+// ranges of the given accounts.
+func (p *FakePeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	hashes, slots, proof := ServiceStorageRanges(trie.NewDatabase(p.db), root, accounts, origin, limit, bytes)
+	p.dl.DeliverStorageRanges(p.id, id, hashes, slots, proof)
+	return nil
+}
+
+// RequestByteCodes implements downloader.RangePeer, returning the contract codes
+// with the given hashes.
+func (p *FakePeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	p.dl.DeliverByteCodes(p.id, id, ServiceByteCodes(trie.NewDatabase(p.db), hashes, bytes))
+	return nil
+}
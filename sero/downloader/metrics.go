@@ -40,4 +40,7 @@ var (
 
 	stateInMeter   = metrics.NewRegisteredMeter("sero/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("sero/downloader/states/drop", nil)
+
+	rangeInMeter   = metrics.NewRegisteredMeter("sero/downloader/ranges/in", nil)
+	rangeDropMeter = metrics.NewRegisteredMeter("sero/downloader/ranges/drop", nil)
 )
@@ -90,6 +90,14 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// RangePeer encapsulates the methods required to retrieve the state in proven
+// ranges from a remote [sero/65+] peer.
+type RangePeer interface {
+	RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -222,6 +230,46 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 	return nil
 }
 
+// FetchAccountRange sends an account range retrieval request to the remote peer.
+func (p *peerConnection) FetchAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	return p.fetchRange("account range", func(peer RangePeer) error {
+		return peer.RequestAccountRange(id, root, origin, limit, bytes)
+	})
+}
+
+// FetchStorageRanges sends a storage ranges retrieval request to the remote peer.
+func (p *peerConnection) FetchStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	return p.fetchRange("storage range", func(peer RangePeer) error {
+		return peer.RequestStorageRanges(id, root, accounts, origin, limit, bytes)
+	})
+}
+
+// FetchByteCodes sends a contract code retrieval request to the remote peer.
+func (p *peerConnection) FetchByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	return p.fetchRange("byte code", func(peer RangePeer) error {
+		return peer.RequestByteCodes(id, hashes, bytes)
+	})
+}
+
+// fetchRange sends a state range request to the remote peer. The ranges share
+// the activity state of the node data retrievals, as both sync the state.
+func (p *peerConnection) fetchRange(kind string, request func(RangePeer) error) error {
+	// Sanity check the protocol version
+	peer, ok := p.peer.(RangePeer)
+	if p.version < 65 || !ok {
+		panic(fmt.Sprintf("%s fetch [sero/65+] requested on sero/%d", kind, p.version))
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.stateStarted = time.Now()
+
+	go request(peer)
+
+	return nil
+}
+
 // SetHeadersIdle sets the peer to idle, allowing it to execute new header retrieval
 // requests. Its estimated header retrieval throughput is updated with that measured
 // just now.
@@ -477,7 +525,7 @@ func (ps *peerSet) HeaderIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.headerThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 65, idle, throughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
@@ -491,7 +539,7 @@ func (ps *peerSet) BodyIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.blockThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 65, idle, throughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
@@ -505,7 +553,7 @@ func (ps *peerSet) ReceiptIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.receiptThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 65, idle, throughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
@@ -519,7 +567,23 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 		defer p.lock.RUnlock()
 		return p.stateThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 65, idle, throughput)
+}
+
+// RangeIdlePeers retrieves a flat list of all the currently node-data-idle
+// peers able to serve state ranges within the active peer set, ordered by
+// their reputation.
+func (ps *peerSet) RangeIdlePeers() ([]*peerConnection, int) {
+	idle := func(p *peerConnection) bool {
+		_, ok := p.peer.(RangePeer)
+		return ok && atomic.LoadInt32(&p.stateIdle) == 0
+	}
+	throughput := func(p *peerConnection) float64 {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+		return p.stateThroughput
+	}
+	return ps.idlePeers(65, 65, idle, throughput)
 }
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
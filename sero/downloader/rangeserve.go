@@ -0,0 +1,159 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+)
+
+// maxRangeHash is the last hash of the key space of a trie.
+var maxRangeHash = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+// ServiceAccountRange retrieves the leaves of the account trie of the state at
+// root, from origin up to the first one at or past limit, or until the given
+// number of bytes is reached. The leaves of the zero state are included, as they
+// are stored in the account trie. The proof holds the merkle proofs of origin
+// and of the last leaf, it is left empty if the leaves are the whole trie.
+//
+// Nothing is returned if the state is not available.
+func ServiceAccountRange(triedb *trie.Database, root, origin, limit common.Hash, bytes uint64) (hashes []common.Hash, values [][]byte, proof [][]byte) {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, nil, nil
+	}
+	hashes, values, complete, err := serviceRange(tr, origin, limit, bytes)
+	if err != nil {
+		return nil, nil, nil
+	}
+	if origin == (common.Hash{}) && complete {
+		return hashes, values, nil
+	}
+	return hashes, values, proveRange(tr, origin, hashes)
+}
+
+// ServiceStorageRanges retrieves the storage slots of the given accounts of the
+// state at root, until the given number of bytes is reached. The origin and limit
+// of the range only apply to the first and the last account. The proof holds the
+// merkle proofs of the edges of the last range if it is not the whole storage of
+// its account.
+//
+// The accounts are served in order, stopping at the first one not available.
+func ServiceStorageRanges(triedb *trie.Database, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) (hashes [][]common.Hash, slots [][][]byte, proof [][]byte) {
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, nil, nil
+	}
+	var size uint64
+	for i, account := range accounts {
+		if size >= bytes {
+			break
+		}
+		enc, err := accTrie.TryGet(account[:])
+		if err != nil || len(enc) == 0 {
+			break
+		}
+		var data state.Account
+		if err := rlp.DecodeBytes(enc, &data); err != nil {
+			break
+		}
+		stTrie, err := trie.New(data.Root, triedb)
+		if err != nil {
+			break
+		}
+		// The origin applies to the first account, the limit to the last one
+		start, end := common.Hash{}, maxRangeHash
+		if i == 0 {
+			start = origin
+		}
+		if i == len(accounts)-1 {
+			end = limit
+		}
+		keys, values, complete, err := serviceRange(stTrie, start, end, bytes-size)
+		if err != nil {
+			break
+		}
+		hashes = append(hashes, keys)
+		slots = append(slots, values)
+		for _, value := range values {
+			size += uint64(common.HashLength + len(value))
+		}
+		// Stop at the first partial range, its proof ends the response
+		if start != (common.Hash{}) || !complete {
+			proof = proveRange(stTrie, start, keys)
+			break
+		}
+	}
+	return hashes, slots, proof
+}
+
+// ServiceByteCodes retrieves the contract codes with the given hashes, until the
+// given number of bytes is reached. Unknown codes are skipped.
+func ServiceByteCodes(triedb *trie.Database, hashes []common.Hash, bytes uint64) (codes [][]byte) {
+	var size uint64
+	for _, hash := range hashes {
+		if size >= bytes || len(codes) >= MaxCodeFetch {
+			break
+		}
+		if code, err := triedb.Node(hash); err == nil && crypto.Keccak256Hash(code) == hash {
+			codes = append(codes, code)
+			size += uint64(len(code))
+		}
+	}
+	return codes
+}
+
+// serviceRange iterates the leaves of a trie from origin, stopping after the
+// first leaf at or past limit, or when the given number of bytes is reached. It
+// reports whether all the leaves from origin were retrieved.
+func serviceRange(tr *trie.Trie, origin, limit common.Hash, size uint64) (keys []common.Hash, values [][]byte, complete bool, err error) {
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for {
+		if !it.Next() {
+			return keys, values, true, it.Err
+		}
+		key := common.BytesToHash(it.Key)
+		keys = append(keys, key)
+		values = append(values, common.CopyBytes(it.Value))
+
+		if uint64(common.HashLength+len(it.Value)) >= size || bytes.Compare(key[:], limit[:]) >= 0 {
+			return keys, values, !it.Next() && it.Err == nil, it.Err
+		}
+		size -= uint64(common.HashLength + len(it.Value))
+	}
+}
+
+// proveRange returns the merkle proofs of origin and of the last key of a range.
+func proveRange(tr *trie.Trie, origin common.Hash, keys []common.Hash) [][]byte {
+	proofDb := serodb.NewMemDatabase()
+	tr.Prove(origin[:], 0, proofDb)
+	if len(keys) > 0 {
+		tr.Prove(keys[len(keys)-1][:], 0, proofDb)
+	}
+	var proof [][]byte
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return proof
+}
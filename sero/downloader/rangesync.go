@@ -0,0 +1,807 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+)
+
+const (
+	rangeChunks       = 16         // Number of chunks the account space is split into
+	rangeRequestBytes = 512 * 1024 // Soft limit of the size of a state range response
+)
+
+var (
+	emptyRoot     = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+
+	errUnrequestedRange = errors.New("unrequested state range")
+)
+
+// accountRangePack is a range of the account trie delivered by a remote peer.
+type accountRangePack struct {
+	hashes []common.Hash
+	values [][]byte
+	proof  [][]byte
+}
+
+// storageRangesPack is a batch of storage trie ranges delivered by a remote peer.
+type storageRangesPack struct {
+	hashes [][]common.Hash
+	slots  [][][]byte
+	proof  [][]byte
+}
+
+// byteCodesPack is a batch of contract codes delivered by a remote peer.
+type byteCodesPack struct {
+	codes [][]byte
+}
+
+// rangeReq is a state range retrieval request sent to a remote peer. Exactly one
+// of the account, storages and codes fields is set.
+type rangeReq struct {
+	id       uint64          // Request id to match up the response with
+	peer     *peerConnection // Peer that we're requesting from
+	timer    *time.Timer     // Timer to fire when the RTT timeout expires
+	account  *accountTask    // Account chunk requested
+	storages []*storageTask  // Storage tries requested
+	codes    []*codeTask     // Contract codes requested
+	response interface{}     // Response pack of the peer (nil for timeouts)
+	dropped  bool            // Flag whether the peer dropped off early
+}
+
+// items returns the number of items requested.
+func (req *rangeReq) items() int {
+	if req.account != nil {
+		return 1
+	}
+	return len(req.storages) + len(req.codes)
+}
+
+// accountTask is a chunk of the account trie to retrieve.
+type accountTask struct {
+	next common.Hash   // Next account to retrieve
+	last common.Hash   // Last account of the chunk
+	res  *accountRange // Retrieved range waiting for its storage and code
+	done bool          // Flag whether the chunk is retrieved or left to heal
+
+	req      *rangeReq           // Pending request, nil if none
+	attempts map[string]struct{} // Peers that failed to deliver the chunk
+}
+
+// accountRange is a verified range of the account trie. Its trie nodes are only
+// written once the storage and code of all its accounts are, as the healing
+// skips the sub tries of the nodes already known.
+type accountRange struct {
+	task    *accountTask
+	hashes  []common.Hash
+	values  [][]byte
+	cont    bool // Flag whether the chunk has more accounts past the range
+	pending int  // Number of storage tries and codes still to retrieve
+	failed  bool // Flag whether a storage trie or code failed, leaving the range to heal
+}
+
+// storageTask is the storage trie of one or more accounts to retrieve.
+type storageTask struct {
+	account common.Hash     // Account to request the storage of
+	root    common.Hash     // Root hash of the storage trie
+	owners  []*accountRange // Account ranges waiting for the storage trie
+	next    common.Hash     // Next slot to retrieve
+	trie    *leafTrie       // Slots retrieved so far, if retrieved in several ranges
+
+	req      *rangeReq
+	attempts map[string]struct{}
+}
+
+// codeTask is a contract code to retrieve.
+type codeTask struct {
+	hash   common.Hash
+	owners []*accountRange
+
+	req      *rangeReq
+	attempts map[string]struct{}
+}
+
+// rangeSync retrieves the leaves of a state in contiguous ranges, verified by the
+// merkle proofs of their edges, and regenerates the trie nodes locally. The nodes
+// on the edges of the ranges are mostly not the ones of the state, they are never
+// referenced and are replaced by the healing trie node retrieval that follows,
+// along with the ranges no peer could deliver.
+type rangeSync struct {
+	d      *Downloader
+	root   common.Hash
+	cancel chan struct{} // Channel to signal a termination request
+
+	tasks    []*accountTask               // Account chunks to retrieve
+	storages map[common.Hash]*storageTask // Storage tries to retrieve, by root
+	codes    map[common.Hash]*codeTask    // Contract codes to retrieve, by hash
+
+	batch   serodb.Batch // Retrieved data not yet written to the database
+	written int          // Number of entries in the batch
+
+	nextID   uint64               // Id of the next request
+	requests map[uint64]*rangeReq // Requests in flight, by id
+	finished []*rangeReq          // Requests delivered, timed out or dropped
+	lock     sync.Mutex           // Lock protecting the requests
+	wake     chan struct{}        // Channel to signal finished requests
+}
+
+// newRangeSync creates a state range retrieval, splitting the account space into
+// chunks to be retrieved concurrently.
+func newRangeSync(d *Downloader, root common.Hash, cancel chan struct{}) *rangeSync {
+	s := &rangeSync{
+		d:        d,
+		root:     root,
+		cancel:   cancel,
+		storages: make(map[common.Hash]*storageTask),
+		codes:    make(map[common.Hash]*codeTask),
+		batch:    d.stateDB.NewBatch(),
+		nextID:   rand.Uint64(),
+		requests: make(map[uint64]*rangeReq),
+		wake:     make(chan struct{}, 1),
+	}
+	step := new(big.Int).Div(new(big.Int).Exp(common.Big2, common.Big256, nil), big.NewInt(rangeChunks))
+	next := new(big.Int)
+	for i := 0; i < rangeChunks; i++ {
+		last := maxRangeHash
+		if i < rangeChunks-1 {
+			last = common.BigToHash(new(big.Int).Sub(new(big.Int).Add(next, step), common.Big1))
+		}
+		s.tasks = append(s.tasks, &accountTask{next: common.BigToHash(next), last: last, attempts: make(map[string]struct{})})
+		next.Add(next, step)
+	}
+	return s
+}
+
+// run retrieves the state ranges until every chunk is either retrieved or left
+// to the healing.
+func (s *rangeSync) run() error {
+	s.d.rangeLock.Lock()
+	s.d.rangeSync = s
+	s.d.rangeLock.Unlock()
+
+	defer func() {
+		s.d.rangeLock.Lock()
+		s.d.rangeSync = nil
+		s.d.rangeLock.Unlock()
+
+		// Cancel the requests left, setting the peers idle for the healing
+		s.lock.Lock()
+		for _, req := range s.requests {
+			req.timer.Stop()
+			req.peer.SetNodeDataIdle(req.items())
+		}
+		for _, req := range s.finished {
+			req.peer.SetNodeDataIdle(req.items())
+		}
+		s.lock.Unlock()
+	}()
+	// Listen for peer arrivals and departures to assign and revert tasks
+	newPeer := make(chan *peerConnection, 1024)
+	newSub := s.d.peers.SubscribeNewPeers(newPeer)
+	defer newSub.Unsubscribe()
+
+	peerDrop := make(chan *peerConnection, 1024)
+	dropSub := s.d.peers.SubscribePeerDrops(peerDrop)
+	defer dropSub.Unsubscribe()
+
+	for !s.done() {
+		if err := s.commit(false); err != nil {
+			return err
+		}
+		s.assignTasks()
+
+		// If nothing is in flight, no peer can deliver the ranges left
+		s.lock.Lock()
+		stalled := len(s.requests) == 0 && len(s.finished) == 0
+		s.lock.Unlock()
+		if stalled {
+			log.Warn("State ranges unavailable, leaving them to heal", "root", s.root)
+			break
+		}
+		select {
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case p := <-peerDrop:
+			s.revert(p.id)
+
+		case <-s.wake:
+
+		case <-s.cancel:
+			return errCancelStateFetch
+
+		case <-s.d.cancelCh:
+			return errCancelStateFetch
+		}
+		s.process()
+	}
+	return s.commit(true)
+}
+
+// done returns whether every chunk is retrieved or left to heal.
+func (s *rangeSync) done() bool {
+	for _, task := range s.tasks {
+		if !task.done {
+			return false
+		}
+	}
+	return len(s.storages) == 0 && len(s.codes) == 0
+}
+
+// deliver hands a response pack over to the request it answers.
+func (s *rangeSync) deliver(peer string, id uint64, packet interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req := s.requests[id]
+	if req == nil || req.peer.id != peer {
+		return errUnrequestedRange
+	}
+	req.timer.Stop()
+	req.response = packet
+	s.finish(req)
+	return nil
+}
+
+// revert finishes the requests in flight of a dropped peer.
+func (s *rangeSync) revert(peer string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, req := range s.requests {
+		if req.peer.id == peer {
+			req.timer.Stop()
+			req.dropped = true
+			s.finish(req)
+		}
+	}
+}
+
+// finish moves a request in flight over to the processing queue. The lock must
+// be held.
+func (s *rangeSync) finish(req *rangeReq) {
+	delete(s.requests, req.id)
+	s.finished = append(s.finished, req)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// assignTasks assigns new tasks to all the idle peers able to serve ranges. The
+// codes and storage tries come first, as they complete the ranges retrieved.
+func (s *rangeSync) assignTasks() {
+	peers, total := s.d.peers.RangeIdlePeers()
+	s.abandon(total)
+
+	for _, p := range peers {
+		req := &rangeReq{peer: p}
+		switch {
+		case s.fillCodes(req):
+			hashes := make([]common.Hash, len(req.codes))
+			for i, task := range req.codes {
+				hashes[i] = task.hash
+			}
+			s.send(req, func(id uint64) error {
+				return p.FetchByteCodes(id, hashes, rangeRequestBytes)
+			})
+
+		case s.fillStorages(req):
+			accounts := make([]common.Hash, len(req.storages))
+			for i, task := range req.storages {
+				accounts[i] = task.account
+			}
+			origin := req.storages[0].next
+			s.send(req, func(id uint64) error {
+				return p.FetchStorageRanges(id, s.root, accounts, origin, maxRangeHash, rangeRequestBytes)
+			})
+
+		case s.fillAccounts(req):
+			task := req.account
+			s.send(req, func(id uint64) error {
+				return p.FetchAccountRange(id, s.root, task.next, task.last, rangeRequestBytes)
+			})
+		}
+	}
+}
+
+// abandon leaves the tasks every peer failed to the healing.
+func (s *rangeSync) abandon(peers int) {
+	for _, task := range s.tasks {
+		if !task.done && task.res == nil && task.req == nil && len(task.attempts) >= peers {
+			task.done = true
+		}
+	}
+	for _, task := range s.storages {
+		if task.req == nil && len(task.attempts) >= peers {
+			s.failStorage(task)
+		}
+	}
+	for _, task := range s.codes {
+		if task.req == nil && len(task.attempts) >= peers {
+			delete(s.codes, task.hash)
+			s.release(task.owners, true)
+		}
+	}
+}
+
+// fillCodes assigns the request a batch of codes not yet tried with its peer.
+func (s *rangeSync) fillCodes(req *rangeReq) bool {
+	for _, task := range s.codes {
+		if len(req.codes) == MaxCodeFetch {
+			break
+		}
+		if _, ok := task.attempts[req.peer.id]; ok || task.req != nil {
+			continue
+		}
+		task.req = req
+		req.codes = append(req.codes, task)
+	}
+	return len(req.codes) > 0
+}
+
+// fillStorages assigns the request a storage trie partially retrieved, or else a
+// batch of storage tries not yet tried with its peer.
+func (s *rangeSync) fillStorages(req *rangeReq) bool {
+	for _, task := range s.storages {
+		if _, ok := task.attempts[req.peer.id]; ok || task.req != nil || task.trie == nil {
+			continue
+		}
+		task.req = req
+		req.storages = append(req.storages, task)
+		return true
+	}
+	for _, task := range s.storages {
+		if len(req.storages) == MaxStorageFetch {
+			break
+		}
+		if _, ok := task.attempts[req.peer.id]; ok || task.req != nil || task.trie != nil {
+			continue
+		}
+		task.req = req
+		req.storages = append(req.storages, task)
+	}
+	return len(req.storages) > 0
+}
+
+// fillAccounts assigns the request an account chunk not yet tried with its peer.
+func (s *rangeSync) fillAccounts(req *rangeReq) bool {
+	for _, task := range s.tasks {
+		if _, ok := task.attempts[req.peer.id]; ok || task.done || task.res != nil || task.req != nil {
+			continue
+		}
+		task.req = req
+		req.account = task
+		return true
+	}
+	return false
+}
+
+// send tracks a request and sends it to its peer.
+func (s *rangeSync) send(req *rangeReq, fetch func(id uint64) error) {
+	s.lock.Lock()
+	s.nextID++
+	req.id = s.nextID
+	req.timer = time.AfterFunc(s.d.requestTTL(), func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		// Skip the timeout if the response arrived meanwhile
+		if s.requests[req.id] == req {
+			s.finish(req)
+		}
+	})
+	s.requests[req.id] = req
+	s.lock.Unlock()
+
+	req.peer.log.Trace("Requesting new batch of data", "type", "state ranges", "id", req.id, "count", req.items())
+	if err := fetch(req.id); err != nil {
+		s.lock.Lock()
+		req.timer.Stop()
+		delete(s.requests, req.id)
+		s.lock.Unlock()
+
+		s.unassign(req)
+	}
+}
+
+// unassign returns the tasks of a request to the queue.
+func (s *rangeSync) unassign(req *rangeReq) {
+	if req.account != nil {
+		req.account.req = nil
+	}
+	for _, task := range req.storages {
+		task.req = nil
+	}
+	for _, task := range req.codes {
+		task.req = nil
+	}
+}
+
+// process handles the finished requests, verifying and storing the responses.
+func (s *rangeSync) process() {
+	s.lock.Lock()
+	finished := s.finished
+	s.finished = nil
+	s.lock.Unlock()
+
+	for _, req := range finished {
+		log.Trace("Received state range response", "peer", req.peer.id, "id", req.id, "dropped", req.dropped, "timeout", !req.dropped && req.response == nil)
+
+		s.unassign(req)
+		delivered := 0
+		switch {
+		case req.account != nil:
+			delivered = s.processAccounts(req)
+		case len(req.storages) > 0:
+			delivered = s.processStorages(req)
+		default:
+			delivered = s.processCodes(req)
+		}
+		req.peer.SetNodeDataIdle(delivered)
+	}
+}
+
+// processAccounts verifies an account range and queues the storage tries and
+// codes its accounts lack, returning the number of accounts delivered.
+func (s *rangeSync) processAccounts(req *rangeReq) int {
+	task := req.account
+	res, _ := req.response.(*accountRangePack)
+	if res == nil || len(res.hashes) == 0 && len(res.proof) == 0 {
+		// Timed out, dropped or the peer lacks the state
+		task.attempts[req.peer.id] = struct{}{}
+		return 0
+	}
+	keys := make([][]byte, len(res.hashes))
+	for i := range res.hashes {
+		keys[i] = res.hashes[i][:]
+	}
+	last := task.next[:]
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	cont, err := trie.VerifyRangeProof(s.root, task.next[:], last, keys, res.values, rangeProof(res.proof))
+	if err != nil {
+		log.Warn("Invalid account range, dropping peer", "peer", req.peer.id, "err", err)
+		s.d.dropPeer(req.peer.id, p2p.BadResponse)
+		task.attempts[req.peer.id] = struct{}{}
+		return 0
+	}
+	// The range ends at the first account past the chunk, which is not needed
+	hashes, values := res.hashes, res.values
+	for len(hashes) > 0 && bytes.Compare(hashes[len(hashes)-1][:], task.last[:]) > 0 {
+		hashes, values = hashes[:len(hashes)-1], values[:len(values)-1]
+		cont = false
+	}
+	// The leaves of the zero state are no accounts and need nothing else
+	accounts := make([]*state.Account, len(values))
+	for i, value := range values {
+		if accounts[i], err = state.DecodeAccountLeaf(value); err != nil {
+			log.Warn("Invalid account in range, dropping peer", "peer", req.peer.id, "err", err)
+			s.d.dropPeer(req.peer.id, p2p.BadResponse)
+			task.attempts[req.peer.id] = struct{}{}
+			return 0
+		}
+	}
+	rng := &accountRange{task: task, hashes: hashes, values: values, cont: cont}
+	task.res = rng
+
+	for i, account := range accounts {
+		if account == nil {
+			continue
+		}
+		if root := account.Root; root != (common.Hash{}) && root != emptyRoot && !s.known(root) {
+			if storage := s.storages[root]; storage != nil {
+				storage.owners = append(storage.owners, rng)
+			} else {
+				s.storages[root] = &storageTask{account: hashes[i], root: root, owners: []*accountRange{rng}, attempts: make(map[string]struct{})}
+			}
+			rng.pending++
+		}
+		if hash := common.BytesToHash(account.CodeHash); len(account.CodeHash) > 0 && hash != emptyCodeHash && !s.known(hash) {
+			if code := s.codes[hash]; code != nil {
+				code.owners = append(code.owners, rng)
+			} else {
+				s.codes[hash] = &codeTask{hash: hash, owners: []*accountRange{rng}, attempts: make(map[string]struct{})}
+			}
+			rng.pending++
+		}
+	}
+	if rng.pending == 0 {
+		s.forward(rng)
+	}
+	return len(hashes)
+}
+
+// processStorages verifies a batch of storage ranges, writing the storage tries
+// completed, returning the number of slots delivered.
+func (s *rangeSync) processStorages(req *rangeReq) int {
+	res, _ := req.response.(*storageRangesPack)
+	if res == nil || len(res.hashes) == 0 {
+		// Timed out, dropped or the peer lacks the state
+		for _, task := range req.storages {
+			task.attempts[req.peer.id] = struct{}{}
+		}
+		return 0
+	}
+	if len(res.hashes) > len(req.storages) || len(res.slots) != len(res.hashes) {
+		log.Warn("Invalid storage ranges, dropping peer", "peer", req.peer.id, "tries", len(req.storages), "hashes", len(res.hashes), "slots", len(res.slots))
+		s.d.dropPeer(req.peer.id, p2p.BadResponse)
+		for _, task := range req.storages {
+			task.attempts[req.peer.id] = struct{}{}
+		}
+		return 0
+	}
+	delivered := 0
+	for i, hashes := range res.hashes {
+		task, values := req.storages[i], res.slots[i]
+		if task.trie == nil {
+			task.trie = newLeafTrie()
+		}
+		// Only the last range may be partial, the others are whole storage tries
+		var (
+			cont bool
+			err  error
+		)
+		if i == len(res.hashes)-1 && len(res.proof) > 0 {
+			keys := make([][]byte, len(hashes))
+			for j := range hashes {
+				keys[j] = hashes[j][:]
+			}
+			last := task.next[:]
+			if len(keys) > 0 {
+				last = keys[len(keys)-1]
+			}
+			cont, err = trie.VerifyRangeProof(task.root, task.next[:], last, keys, values, rangeProof(res.proof))
+		} else if task.next != (common.Hash{}) {
+			err = errors.New("partial storage trie without proof")
+		}
+		if err == nil && len(hashes) != len(values) {
+			err = errors.New("inconsistent storage range")
+		}
+		if err == nil {
+			err = task.trie.update(hashes, values)
+		}
+		if err == nil && !cont {
+			if hash := task.trie.hash(); hash != task.root {
+				err = errors.New("storage trie root mismatch")
+			}
+		}
+		if err != nil {
+			log.Warn("Invalid storage range, dropping peer", "peer", req.peer.id, "account", task.account, "err", err)
+			s.d.dropPeer(req.peer.id, p2p.BadResponse)
+
+			// The slots retrieved so far may be the faulty ones
+			task.trie, task.next = nil, common.Hash{}
+			for _, task := range req.storages[i:] {
+				task.attempts[req.peer.id] = struct{}{}
+			}
+			return delivered
+		}
+		delivered += len(hashes)
+		if cont {
+			task.next, _ = incHash(hashes[len(hashes)-1])
+			continue
+		}
+		if err := s.write(task.trie); err != nil {
+			log.Warn("Failed to regenerate storage trie", "root", task.root, "err", err)
+			s.failStorage(task)
+			continue
+		}
+		delete(s.storages, task.root)
+		s.release(task.owners, false)
+	}
+	return delivered
+}
+
+// processCodes stores the delivered codes, returning their number.
+func (s *rangeSync) processCodes(req *rangeReq) int {
+	res, _ := req.response.(*byteCodesPack)
+	var codes [][]byte
+	if res != nil {
+		codes = res.codes
+	}
+	requested := make(map[common.Hash]*codeTask, len(req.codes))
+	for _, task := range req.codes {
+		requested[task.hash] = task
+	}
+	delivered := 0
+	for _, code := range codes {
+		hash := crypto.Keccak256Hash(code)
+		task := requested[hash]
+		if task == nil {
+			continue
+		}
+		s.batch.Put(hash[:], code)
+		s.written++
+
+		delete(requested, hash)
+		delete(s.codes, hash)
+		s.release(task.owners, false)
+		delivered++
+	}
+	// Codes not delivered are retried with other peers
+	for _, task := range requested {
+		task.attempts[req.peer.id] = struct{}{}
+	}
+	return delivered
+}
+
+// failStorage leaves a storage trie, and the ranges waiting for it, to the
+// healing.
+func (s *rangeSync) failStorage(task *storageTask) {
+	delete(s.storages, task.root)
+	s.release(task.owners, true)
+}
+
+// release marks a storage trie or code of the given ranges as done, forwarding
+// the ranges having nothing left to wait for.
+func (s *rangeSync) release(owners []*accountRange, failed bool) {
+	for _, rng := range owners {
+		rng.failed = rng.failed || failed
+		if rng.pending--; rng.pending == 0 {
+			s.forward(rng)
+		}
+	}
+}
+
+// forward writes the trie nodes of an account range whose storage tries and
+// codes are all retrieved, and moves its chunk on to the next range.
+func (s *rangeSync) forward(rng *accountRange) {
+	task := rng.task
+	task.res = nil
+
+	if !rng.failed {
+		t := newLeafTrie()
+		if err := t.update(rng.hashes, rng.values); err != nil {
+			log.Warn("Failed to regenerate account range", "err", err)
+		} else if err := s.write(t); err != nil {
+			log.Warn("Failed to regenerate account range", "err", err)
+		}
+	}
+	if !rng.cont || len(rng.hashes) == 0 {
+		task.done = true
+		return
+	}
+	next, overflow := incHash(rng.hashes[len(rng.hashes)-1])
+	task.next, task.done = next, overflow
+}
+
+// known returns whether a trie node or code is already in the database.
+func (s *rangeSync) known(hash common.Hash) bool {
+	ok, _ := s.d.stateDB.Has(hash[:])
+	return ok
+}
+
+// write regenerates the trie nodes of a trie into the batch.
+func (s *rangeSync) write(t *leafTrie) error {
+	written, err := t.write(s.batch)
+	s.written += written
+	return err
+}
+
+// commit writes the batch to the database once it is large enough.
+func (s *rangeSync) commit(force bool) error {
+	if s.written == 0 || !force && s.batch.ValueSize() < serodb.IdealBatchSize {
+		return nil
+	}
+	start := time.Now()
+	if err := s.batch.Write(); err != nil {
+		return err
+	}
+	s.batch.Reset()
+
+	s.d.syncStatsLock.Lock()
+	s.d.syncStatsState.processed += uint64(s.written)
+	processed := s.d.syncStatsState.processed
+	s.d.syncStatsLock.Unlock()
+
+	log.Info("Imported new state ranges", "count", s.written, "elapsed", common.PrettyDuration(time.Since(start)), "processed", processed, "storages", len(s.storages), "codes", len(s.codes))
+	rawdb.WriteFastTrieProgress(s.d.stateDB, processed)
+	s.written = 0
+	return nil
+}
+
+// leafTrie is a trie regenerated from retrieved leaves.
+type leafTrie struct {
+	trie   *trie.Trie
+	triedb *trie.Database
+	diskdb *serodb.MemDatabase
+}
+
+// newLeafTrie creates an empty trie to insert retrieved leaves into.
+func newLeafTrie() *leafTrie {
+	diskdb := serodb.NewMemDatabase()
+	triedb := trie.NewDatabase(diskdb)
+	tr, _ := trie.New(common.Hash{}, triedb)
+	return &leafTrie{trie: tr, triedb: triedb, diskdb: diskdb}
+}
+
+// update inserts leaves into the trie.
+func (t *leafTrie) update(keys []common.Hash, values [][]byte) error {
+	for i, key := range keys {
+		if err := t.trie.TryUpdate(key[:], values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hash returns the root hash of the trie.
+func (t *leafTrie) hash() common.Hash {
+	return t.trie.Hash()
+}
+
+// write commits the trie and copies its nodes into the batch, returning their
+// number.
+func (t *leafTrie) write(batch serodb.Putter) (int, error) {
+	root, err := t.trie.Commit(nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.triedb.Commit(root, false); err != nil {
+		return 0, err
+	}
+	keys := t.diskdb.Keys()
+	for _, key := range keys {
+		node, _ := t.diskdb.Get(key)
+		if err := batch.Put(key, node); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// rangeProof collects the nodes of a range proof into a database to verify the
+// range with. No proof means the range is the whole trie.
+func rangeProof(nodes [][]byte) trie.DatabaseReader {
+	if len(nodes) == 0 {
+		return nil
+	}
+	db := serodb.NewMemDatabase()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// incHash returns the hash following the given one, and whether it overflowed.
+func incHash(h common.Hash) (common.Hash, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			return h, false
+		}
+	}
+	return h, true
+}
@@ -0,0 +1,313 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+)
+
+// makeRangeTestState creates a state holding accounts with storage and code,
+// an account with a storage trie spanning many ranges, and zero state entries
+// stored raw in the account trie.
+func makeRangeTestState(t *testing.T) (serodb.Database, common.Hash) {
+	db := serodb.NewMemDatabase()
+	triedb := trie.NewDatabase(db)
+
+	accounts, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	for i := 0; i < 400; i++ {
+		account := state.Account{Root: emptyRoot, CodeHash: emptyCodeHash[:]}
+		if i%3 == 0 {
+			slots := 3
+			if i == 99 {
+				slots = 500
+			}
+			storage, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+			for j := 0; j < slots; j++ {
+				storage.Update([]byte(fmt.Sprintf("slot-%d", j)), []byte(fmt.Sprintf("value-%d-%d", i, j)))
+			}
+			root, err := storage.Commit(nil)
+			if err != nil {
+				t.Fatalf("failed to commit storage trie: %v", err)
+			}
+			triedb.Commit(root, false)
+			account.Root = root
+		}
+		if i%5 == 0 {
+			code := []byte(fmt.Sprintf("code-%d", i%20))
+			db.Put(crypto.Keccak256(code), code)
+			account.CodeHash = crypto.Keccak256(code)
+		}
+		enc, _ := rlp.EncodeToBytes(&account)
+		accounts.Update([]byte(fmt.Sprintf("account-%d", i)), enc)
+	}
+	for i := 0; i < 200; i++ {
+		accounts.Update([]byte(fmt.Sprintf("zstate-%d", i)), []byte(fmt.Sprintf("zero state object %d", i)))
+	}
+	root, err := accounts.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	triedb.Commit(root, false)
+	return db, root
+}
+
+// checkRangeTestState verifies that a database holds the whole state of the
+// source one.
+func checkRangeTestState(t *testing.T, src, dst serodb.Database, root common.Hash) {
+	srcTrie, _ := trie.New(root, trie.NewDatabase(src))
+	dstTrie, err := trie.New(root, trie.NewDatabase(dst))
+	if err != nil {
+		t.Fatalf("state root missing: %v", err)
+	}
+	want, have := trie.NewIterator(srcTrie.NodeIterator(nil)), trie.NewIterator(dstTrie.NodeIterator(nil))
+	for want.Next() {
+		if !have.Next() {
+			t.Fatalf("leaf %x missing: %v", want.Key, have.Err)
+		}
+		if !bytes.Equal(want.Key, have.Key) || !bytes.Equal(want.Value, have.Value) {
+			t.Fatalf("leaf mismatch: have %x=%x, want %x=%x", have.Key, have.Value, want.Key, want.Value)
+		}
+		var account state.Account
+		if rlp.DecodeBytes(want.Value, &account) != nil {
+			continue
+		}
+		storage, err := trie.New(account.Root, trie.NewDatabase(dst))
+		if err != nil {
+			t.Fatalf("storage trie of %x missing: %v", want.Key, err)
+		}
+		it := storage.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if it.Error() != nil {
+			t.Fatalf("storage trie of %x incomplete: %v", want.Key, it.Error())
+		}
+		if code, _ := dst.Get(account.CodeHash); !bytes.Equal(account.CodeHash, emptyCodeHash[:]) && len(code) == 0 {
+			t.Fatalf("code of %x missing", want.Key)
+		}
+	}
+	if have.Next() {
+		t.Fatalf("unexpected leaf %x", have.Key)
+	}
+	if want.Err != nil || have.Err != nil {
+		t.Fatalf("iteration failed: %v, %v", want.Err, have.Err)
+	}
+}
+
+// rangeTestPeer is a simulated peer serving the state ranges and trie nodes of
+// a local database.
+type rangeTestPeer struct {
+	id     string
+	db     serodb.Database
+	dl     *Downloader
+	limit  uint64 // Cap of the response sizes, forcing many ranges
+	tamper bool   // Flag whether to corrupt the account ranges served
+
+	ranges int32 // Number of state ranges served
+	nodes  int32 // Number of trie nodes served
+}
+
+func (p *rangeTestPeer) Head() (common.Hash, *big.Int) { panic("not implemented") }
+func (p *rangeTestPeer) RequestHeadersByHash(common.Hash, int, int, bool) error {
+	panic("not implemented")
+}
+func (p *rangeTestPeer) RequestHeadersByNumber(uint64, int, int, bool) error {
+	panic("not implemented")
+}
+func (p *rangeTestPeer) RequestBodies([]common.Hash) error   { panic("not implemented") }
+func (p *rangeTestPeer) RequestReceipts([]common.Hash) error { panic("not implemented") }
+
+func (p *rangeTestPeer) RequestNodeData(hashes []common.Hash) error {
+	var data [][]byte
+	for _, hash := range hashes {
+		if entry, err := p.db.Get(hash[:]); err == nil {
+			data = append(data, entry)
+		}
+	}
+	atomic.AddInt32(&p.nodes, int32(len(data)))
+	p.dl.DeliverNodeData(p.id, data)
+	return nil
+}
+
+// rangePeer wraps a rangeTestPeer with the range retrieval methods, leaving
+// them out for peers speaking an older protocol.
+type rangePeer struct {
+	*rangeTestPeer
+}
+
+func (p rangePeer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	if bytes > p.limit {
+		bytes = p.limit
+	}
+	hashes, values, proof := ServiceAccountRange(trie.NewDatabase(p.db), root, origin, limit, bytes)
+	if p.tamper && len(values) > 0 {
+		values[0] = append(common.CopyBytes(values[0]), 0x01)
+	}
+	atomic.AddInt32(&p.ranges, 1)
+	p.dl.DeliverAccountRange(p.id, id, hashes, values, proof)
+	return nil
+}
+
+func (p rangePeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	if bytes > p.limit {
+		bytes = p.limit
+	}
+	hashes, slots, proof := ServiceStorageRanges(trie.NewDatabase(p.db), root, accounts, origin, limit, bytes)
+	atomic.AddInt32(&p.ranges, 1)
+	p.dl.DeliverStorageRanges(p.id, id, hashes, slots, proof)
+	return nil
+}
+
+func (p rangePeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	atomic.AddInt32(&p.ranges, 1)
+	p.dl.DeliverByteCodes(p.id, id, ServiceByteCodes(trie.NewDatabase(p.db), hashes, bytes))
+	return nil
+}
+
+// rangeTester is a downloader syncing the state from simulated peers.
+type rangeTester struct {
+	dl      *Downloader
+	db      serodb.Database
+	peers   map[string]*rangeTestPeer
+	dropped map[string]p2p.Misbehaviour
+	lock    sync.Mutex
+}
+
+func newRangeTester() *rangeTester {
+	tester := &rangeTester{
+		db:      serodb.NewMemDatabase(),
+		peers:   make(map[string]*rangeTestPeer),
+		dropped: make(map[string]p2p.Misbehaviour),
+	}
+	tester.dl = New(SnapSync, tester.db, new(event.TypeMux), nil, nil, tester.dropPeer)
+
+	// Node data is only delivered within a sync cycle
+	tester.dl.cancelCh = make(chan struct{})
+	return tester
+}
+
+func (tester *rangeTester) newPeer(id string, version int, src serodb.Database, limit uint64, tamper bool) {
+	peer := &rangeTestPeer{id: id, db: src, dl: tester.dl, limit: limit, tamper: tamper}
+	tester.peers[id] = peer
+	if version >= 65 {
+		tester.dl.RegisterPeer(id, version, rangePeer{peer})
+	} else {
+		tester.dl.RegisterPeer(id, version, peer)
+	}
+}
+
+func (tester *rangeTester) dropPeer(id string, fault p2p.Misbehaviour) {
+	tester.lock.Lock()
+	tester.dropped[id] = fault
+	tester.lock.Unlock()
+	tester.dl.UnregisterPeer(id)
+}
+
+func (tester *rangeTester) sync(t *testing.T, root common.Hash) {
+	done := make(chan error, 1)
+	go func() { done <- tester.dl.syncState(root).Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("state sync failed: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("state sync timed out")
+	}
+}
+
+// Tests that snap sync retrieves the state in ranges, leaving little to heal.
+func TestSnapSync(t *testing.T) {
+	src, root := makeRangeTestState(t)
+
+	tester := newRangeTester()
+	defer tester.dl.Terminate()
+	for i := 0; i < 3; i++ {
+		tester.newPeer(fmt.Sprintf("peer-%d", i), 65, src, 2048, false)
+	}
+	tester.sync(t, root)
+	checkRangeTestState(t, src, tester.db, root)
+
+	var ranges, nodes int32
+	for _, peer := range tester.peers {
+		ranges += atomic.LoadInt32(&peer.ranges)
+		nodes += atomic.LoadInt32(&peer.nodes)
+	}
+	if ranges == 0 {
+		t.Fatalf("no state ranges retrieved")
+	}
+	if total := int32(len(src.(*serodb.MemDatabase).Keys())); nodes >= total/4 {
+		t.Errorf("healing retrieved too many nodes: have %d, state holds %d", nodes, total)
+	}
+	if len(tester.dropped) > 0 {
+		t.Errorf("honest peers dropped: %v", tester.dropped)
+	}
+}
+
+// Tests that peers serving forged ranges are dropped, the state being retrieved
+// from the honest ones.
+func TestSnapSyncForgedRanges(t *testing.T) {
+	src, root := makeRangeTestState(t)
+
+	tester := newRangeTester()
+	defer tester.dl.Terminate()
+	tester.newPeer("honest", 65, src, 2048, false)
+	tester.newPeer("forger", 65, src, 2048, true)
+
+	tester.sync(t, root)
+	checkRangeTestState(t, src, tester.db, root)
+
+	tester.lock.Lock()
+	defer tester.lock.Unlock()
+	if fault, ok := tester.dropped["forger"]; !ok || fault != p2p.BadResponse {
+		t.Errorf("forging peer not dropped for bad responses: %v", tester.dropped)
+	}
+	if _, ok := tester.dropped["honest"]; ok {
+		t.Errorf("honest peer dropped")
+	}
+}
+
+// Tests that snap sync falls back to healing the whole state if no peer serves
+// ranges.
+func TestSnapSyncWithoutRangePeers(t *testing.T) {
+	src, root := makeRangeTestState(t)
+
+	tester := newRangeTester()
+	defer tester.dl.Terminate()
+	tester.newPeer("legacy", 64, src, 0, false)
+
+	tester.sync(t, root)
+	checkRangeTestState(t, src, tester.db, root)
+
+	if nodes := atomic.LoadInt32(&tester.peers["legacy"].nodes); nodes == 0 {
+		t.Fatalf("state not healed from the legacy peer")
+	}
+}
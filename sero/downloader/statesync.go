@@ -27,6 +27,7 @@ import (
 	"github.com/sero-cash/go-sero/core/state"
 	"github.com/sero-cash/go-sero/crypto/sha3"
 	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/p2p"
 	"github.com/sero-cash/go-sero/serodb"
 	"github.com/sero-cash/go-sero/trie"
 )
@@ -212,7 +213,8 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	d *Downloader // Downloader instance to access and manage current peerset
+	d    *Downloader // Downloader instance to access and manage current peerset
+	root common.Hash // Root hash of the state to retrieve
 
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
@@ -239,6 +241,7 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -250,8 +253,17 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 
 // run starts the task assignment and response processing loop, blocking until
 // it finishes, and finally notifying any goroutines waiting for the loop to
-// finish.
+// finish. In snap sync, the state is retrieved in ranges first, the loop then
+// only heals what the ranges missed.
 func (s *stateSync) run() {
+	if s.d.mode == SnapSync {
+		if s.err = newRangeSync(s.d, s.root, s.cancel).run(); s.err != nil {
+			close(s.done)
+			return
+		}
+		// Reschedule from the root, skipping the sub tries retrieved
+		s.sched = state.NewStateSync(s.root, s.d.stateDB)
+	}
 	s.err = s.loop()
 	close(s.done)
 }
@@ -310,7 +322,7 @@ func (s *stateSync) loop() (err error) {
 				// 2 items are the minimum requested, if even that times out, we've no use of
 				// this peer at the moment.
 				log.Warn("Stalling state sync, dropping peer", "peer", req.peer.id)
-				s.d.dropPeer(req.peer.id)
+				s.d.dropPeer(req.peer.id, p2p.Timeout)
 			}
 			// Process all the received blobs and check for stale delivery
 			if err = s.process(req); err != nil {
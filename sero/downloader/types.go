@@ -20,10 +20,12 @@ import (
 	"fmt"
 
 	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/p2p"
 )
 
-// peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+// peerDropFn is a callback type for dropping a peer detected as malicious,
+// reporting its misbehaviour.
+type peerDropFn func(id string, fault p2p.Misbehaviour)
 
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
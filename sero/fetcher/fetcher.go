@@ -26,6 +26,7 @@ import (
 	"github.com/sero-cash/go-sero/consensus"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/p2p"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
@@ -64,8 +65,9 @@ type chainHeightFn func() uint64
 // chainInsertFn is a callback type to insert a batch of blocks into the local chain.
 type chainInsertFn func(types.Blocks) (int, error)
 
-// peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+// peerDropFn is a callback type for dropping a peer detected as malicious,
+// reporting its misbehaviour.
+type peerDropFn func(id string, fault p2p.Misbehaviour)
 
 // announce is the hash notification of the availability of a new block in the
 // network.
@@ -449,7 +451,7 @@ func (f *Fetcher) loop() {
 					// If the delivered header does not match the promised number, drop the announcer
 					if header.Number.Uint64() != announce.number {
 						log.Trace("Invalid block number fetched", "peer", announce.origin, "hash", header.Hash(), "announced", announce.number, "provided", header.Number)
-						f.dropPeer(announce.origin)
+						f.dropPeer(announce.origin, p2p.BadResponse)
 						f.forgetHash(hash)
 						continue
 					}
@@ -656,10 +658,16 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 		case consensus.ErrFutureBlock:
 			// Weird future block, don't fail, but neither propagate
 
+		case consensus.ErrInvalidPoW, consensus.ErrInvalidMixDigest:
+			// Forged seal, ban the peer at once
+			log.Debug("Propagated block seal invalid", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
+			f.dropPeer(peer, p2p.BadSeal)
+			return
+
 		default:
 			// Something went very wrong, drop the peer
 			log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
-			f.dropPeer(peer)
+			f.dropPeer(peer, p2p.BadBlock)
 			return
 		}
 		// Run the actual import and log any issues
@@ -18,8 +18,10 @@ package gasprice
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/sero-cash/go-sero/internal/ethapi"
@@ -28,6 +30,7 @@ import (
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/params"
 	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/utils"
 )
 
 var maxPrice = big.NewInt(500 * params.Gta)
@@ -40,10 +43,14 @@ type Config struct {
 
 // Oracle recommends gas prices based on the content of recent
 // blocks. Suitable for both light and full clients.
+//
+// The prices are sampled per fee currency, among the transactions paying
+// their fee in that currency. They are expressed in SERO per gas, which is
+// what transactions carry whatever the currency of their fee.
 type Oracle struct {
 	backend   ethapi.Backend
-	lastHead  common.Hash
-	lastPrice *big.Int
+	defPrice  *big.Int
+	cache     map[string]cachedPrice // Last suggestion per fee currency
 	cacheLock sync.RWMutex
 	fetchLock sync.Mutex
 
@@ -51,6 +58,12 @@ type Oracle struct {
 	percentile                       int
 }
 
+// cachedPrice is the price suggested for a fee currency at a given head.
+type cachedPrice struct {
+	head  common.Hash
+	price *big.Int
+}
+
 // NewOracle returns a new oracle.
 func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 	blocks := params.Blocks
@@ -66,7 +79,8 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 	}
 	return &Oracle{
 		backend:     backend,
-		lastPrice:   params.Default,
+		defPrice:    params.Default,
+		cache:       make(map[string]cachedPrice),
 		checkBlocks: blocks,
 		maxEmpty:    blocks / 2,
 		maxBlocks:   blocks * 5,
@@ -76,15 +90,60 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 
 // SuggestPrice returns the recommended gas price.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
-	gpo.cacheLock.RLock()
-	lastHead := gpo.lastHead
-	lastPrice := gpo.lastPrice
-	gpo.cacheLock.RUnlock()
+	return gpo.suggestPrice(ctx, params.DefaultCurrency)
+}
 
+// SuggestFee returns the recommended gas price of the transactions paying
+// their fee in the given currency, in that currency. The SERO price is
+// converted through the token rate set by the contract at to, or by the
+// contract which issued the token if to is nil.
+func (gpo *Oracle) SuggestFee(ctx context.Context, currency string, to *common.Address) (*big.Int, error) {
+	currency = strings.ToUpper(currency)
+	price, err := gpo.suggestPrice(ctx, currency)
+	if err != nil || currency == params.DefaultCurrency {
+		return price, err
+	}
+	state, _, err := gpo.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	var contract common.Address
+	if to != nil {
+		contract = *to
+	} else if contract = state.GetContrctAddressByToken(currency); contract == (common.Address{}) {
+		return nil, fmt.Errorf("unknown fee currency %s", currency)
+	}
+	tokens, tas := state.GetTokenRate(contract, currency)
+	if tokens.Sign() == 0 || tas.Sign() == 0 {
+		return nil, fmt.Errorf("no token rate for fee currency %s", currency)
+	}
+	// Round up, the gas bought with the fee is rounded down
+	fee := new(big.Int).Mul(price, tokens)
+	fee.Add(fee, new(big.Int).Sub(tas, common.Big1))
+	return fee.Div(fee, tas), nil
+}
+
+// suggestPrice returns the recommended gas price, in SERO, of the
+// transactions paying their fee in the given currency. It falls back to the
+// SERO price if no recent transaction paid its fee in the currency.
+func (gpo *Oracle) suggestPrice(ctx context.Context, currency string) (*big.Int, error) {
 	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	headHash := head.Hash()
-	if headHash == lastHead {
-		return lastPrice, nil
+
+	gpo.cacheLock.RLock()
+	last, ok := gpo.cache[currency]
+	gpo.cacheLock.RUnlock()
+	if ok && headHash == last.head {
+		return last.price, nil
+	}
+	// Nobody may have paid in the currency lately, the SERO price is then the
+	// best guess
+	fallback := gpo.defPrice
+	if currency != params.DefaultCurrency {
+		var err error
+		if fallback, err = gpo.suggestPrice(ctx, params.DefaultCurrency); err != nil {
+			return nil, err
+		}
 	}
 
 	gpo.fetchLock.Lock()
@@ -92,11 +151,14 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 
 	// try checking the cache again, maybe the last fetch fetched what we need
 	gpo.cacheLock.RLock()
-	lastHead = gpo.lastHead
-	lastPrice = gpo.lastPrice
+	last, ok = gpo.cache[currency]
 	gpo.cacheLock.RUnlock()
-	if headHash == lastHead {
-		return lastPrice, nil
+	if ok && headHash == last.head {
+		return last.price, nil
+	}
+	lastPrice := last.price
+	if lastPrice == nil {
+		lastPrice = fallback
 	}
 
 	blockNum := head.Number.Uint64()
@@ -105,7 +167,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	exp := 0
 	var blockPrices []*big.Int
 	for sent < gpo.checkBlocks && blockNum > 0 {
-		go gpo.getBlockPrices(ctx, blockNum, ch)
+		go gpo.getBlockPrices(ctx, blockNum, currency, ch)
 		sent++
 		exp++
 		blockNum--
@@ -126,7 +188,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			continue
 		}
 		if blockNum > 0 && sent < gpo.maxBlocks {
-			go gpo.getBlockPrices(ctx, blockNum, ch)
+			go gpo.getBlockPrices(ctx, blockNum, currency, ch)
 			sent++
 			exp++
 			blockNum--
@@ -136,14 +198,15 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	if len(blockPrices) > 0 {
 		sort.Sort(bigIntArray(blockPrices))
 		price = blockPrices[(len(blockPrices)-1)*gpo.percentile/100]
+	} else if currency != params.DefaultCurrency {
+		price = fallback
 	}
 	if price.Cmp(maxPrice) > 0 {
 		price = new(big.Int).Set(maxPrice)
 	}
 
 	gpo.cacheLock.Lock()
-	gpo.lastHead = headHash
-	gpo.lastPrice = price
+	gpo.cache[currency] = cachedPrice{headHash, price}
 	gpo.cacheLock.Unlock()
 	return price, nil
 }
@@ -159,9 +222,10 @@ func (t transactionsByGasPrice) Len() int           { return len(t) }
 func (t transactionsByGasPrice) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
 func (t transactionsByGasPrice) Less(i, j int) bool { return t[i].GasPrice().Cmp(t[j].GasPrice()) < 0 }
 
-// getBlockPrices calculates the lowest transaction gas price in a given block
-// and sends it to the result channel. If the block is empty, price is nil.
-func (gpo *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, ch chan getBlockPricesResult) {
+// getBlockPrices calculates the lowest gas price of the transactions paying
+// their fee in currency in a given block and sends it to the result channel.
+// If no transaction of the block paid in currency, price is nil.
+func (gpo *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, currency string, ch chan getBlockPricesResult) {
 	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
 		ch <- getBlockPricesResult{nil, err}
@@ -174,6 +238,9 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, ch chan
 	sort.Sort(transactionsByGasPrice(txs))
 
 	for _, tx := range txs {
+		if utils.Uint256ToCurrency(&tx.Stxt().Fee.Currency) != currency {
+			continue
+		}
 		//sender, err := types.Sender(abi, tx)
 		sender := tx.From()
 		if err == nil && sender != block.Coinbase() {
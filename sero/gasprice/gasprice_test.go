@@ -0,0 +1,214 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/internal/ethapi"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+var testContract = common.BytesToAddress([]byte("contract"))
+
+// testBackend serves a chain whose blocks have one transaction paying its fee
+// in SERO, and from the block 4 on one paying it in ABC, at 3 ABC for 7 SERO.
+type testBackend struct {
+	ethapi.Backend
+	blocks []*types.Block
+	state  *state.StateDB
+	gpo    *Oracle
+	param  *prepare.PreTxParam // Last transaction generated
+}
+
+func newTestBackend(t *testing.T, head int) *testBackend {
+	statedb, err := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.RegisterToken(testContract, "ABC")
+	statedb.SetTokenRate(testContract, "ABC", big.NewInt(3), big.NewInt(7))
+
+	b := &testBackend{state: statedb}
+	parent := &types.Header{Number: new(big.Int), Difficulty: big.NewInt(1)}
+	b.blocks = append(b.blocks, types.NewBlock(parent, nil, nil))
+	for i := 1; i <= head; i++ {
+		txs := []*types.Transaction{newTestTx("SERO", int64(i*100))}
+		if i >= 4 {
+			txs = append(txs, newTestTx("ABC", int64(i*1000)))
+		}
+		header := &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(int64(i)), Difficulty: big.NewInt(1)}
+		b.blocks = append(b.blocks, types.NewBlock(header, txs, nil))
+		parent = header
+	}
+	b.gpo = NewOracle(b, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(1)})
+	return b
+}
+
+func newTestTx(currency string, price int64) *types.Transaction {
+	t := stx.T{
+		From: keys.PKr{1},
+		Fee:  assets.Token{Currency: utils.CurrencyToUint256(currency), Value: utils.U256(*big.NewInt(21000 * price))},
+	}
+	return types.NewTxWithGTx(21000, big.NewInt(price), &t)
+}
+
+func (b *testBackend) block(number rpc.BlockNumber) *types.Block {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.blocks[len(b.blocks)-1]
+	}
+	if int(number) < len(b.blocks) {
+		return b.blocks[number]
+	}
+	return nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return b.block(number).Header(), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	return b.block(number), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	return b.state, b.block(number).Header(), nil
+}
+
+func (b *testBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return b.gpo.SuggestPrice(ctx)
+}
+
+func (b *testBackend) SuggestFee(ctx context.Context, currency string, to *common.Address) (*big.Int, error) {
+	return b.gpo.SuggestFee(ctx, currency, to)
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager {
+	return accounts.NewManager()
+}
+
+func (b *testBackend) GenTx(param prepare.PreTxParam) (*txtool.GTxParam, error) {
+	b.param = &param
+	return &txtool.GTxParam{}, nil
+}
+
+func TestSuggestPrice(t *testing.T) {
+	b := newTestBackend(t, 5)
+	ctx := context.Background()
+
+	// The blocks 3 to 5 are sampled
+	if price, err := b.gpo.SuggestPrice(ctx); err != nil || price.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("SERO price mismatch: have %v, %v, want 400", price, err)
+	}
+	// The block 3 has no transaction paying in ABC
+	if price, err := b.gpo.suggestPrice(ctx, "ABC"); err != nil || price.Cmp(big.NewInt(4000)) != 0 {
+		t.Errorf("ABC price mismatch: have %v, %v, want 4000", price, err)
+	}
+	// Nobody paid in XYZ, the SERO price is the best guess
+	if price, err := b.gpo.suggestPrice(ctx, "XYZ"); err != nil || price.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("XYZ price mismatch: have %v, %v, want 400", price, err)
+	}
+
+	// The suggestions follow the head
+	b.blocks = b.blocks[:len(b.blocks)-1]
+	if price, _ := b.gpo.SuggestPrice(ctx); price.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("SERO price not updated: have %v, want 300", price)
+	}
+}
+
+func TestSuggestFee(t *testing.T) {
+	b := newTestBackend(t, 5)
+	ctx := context.Background()
+
+	// 4000 SERO at 3 ABC for 7 SERO, rounded up
+	for _, to := range []*common.Address{&testContract, nil} {
+		if fee, err := b.gpo.SuggestFee(ctx, "abc", to); err != nil || fee.Cmp(big.NewInt(1715)) != 0 {
+			t.Errorf("ABC fee to %v mismatch: have %v, %v, want 1715", to, fee, err)
+		}
+	}
+	if fee, err := b.gpo.SuggestFee(ctx, "SERO", nil); err != nil || fee.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("SERO fee mismatch: have %v, %v, want 400", fee, err)
+	}
+	if _, err := b.gpo.SuggestFee(ctx, "XYZ", nil); err == nil {
+		t.Errorf("fee suggested in an unknown currency")
+	}
+	other := common.BytesToAddress([]byte("other"))
+	if _, err := b.gpo.SuggestFee(ctx, "ABC", &other); err == nil {
+		t.Errorf("fee suggested without token rate")
+	}
+}
+
+func TestGenTxFee(t *testing.T) {
+	b := newTestBackend(t, 5)
+	var api *ethapi.PublicExchangeAPI
+	for _, a := range ethapi.GetAPIs(b) {
+		if a.Namespace == "exchange" {
+			api = a.Service.(*ethapi.PublicExchangeAPI)
+		}
+	}
+	var to ethapi.ContractAddress
+	copy(to[:], testContract[:])
+	args := ethapi.GenTxArgs{
+		Cmds: &ethapi.CmdsArgs{Contract: &ethapi.ContractArgs{To: &to}},
+		Gas:  25000,
+	}
+
+	// The SERO fee is priced by the SERO suggestion
+	if _, err := api.GenTx(context.Background(), args); err != nil {
+		t.Fatalf("SERO transaction not generated: %v", err)
+	}
+	if currency := utils.Uint256ToCurrency(&b.param.Fee.Currency); currency != "SERO" || b.param.GasPrice.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("SERO fee mismatch: have %s at %v, want SERO at 400", currency, b.param.GasPrice)
+	}
+	if fee := b.param.Fee.Value.ToIntRef(); fee.Cmp(big.NewInt(25000*400)) != 0 {
+		t.Errorf("SERO fee mismatch: have %v, want %v", fee, 25000*400)
+	}
+
+	// The ABC fee is priced by the ABC suggestion, and still buys all the gas
+	args.GasCurrency = "ABC"
+	if _, err := api.GenTx(context.Background(), args); err != nil {
+		t.Fatalf("ABC transaction not generated: %v", err)
+	}
+	if currency := utils.Uint256ToCurrency(&b.param.Fee.Currency); currency != "ABC" {
+		t.Errorf("fee currency mismatch: have %s, want ABC", currency)
+	}
+	if b.param.GasPrice.Cmp(big.NewInt(1715*7/3)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want %v", b.param.GasPrice, 1715*7/3)
+	}
+	fee := b.param.Fee.Value.ToIntRef()
+	if fee.Cmp(big.NewInt(25000*1715)) > 0 {
+		t.Errorf("fee above the suggestion: have %v, want at most %v", fee, 25000*1715)
+	}
+	gas := new(big.Int).Mul(fee, big.NewInt(7))
+	gas.Div(gas, big.NewInt(3)).Div(gas, b.param.GasPrice)
+	if gas.Cmp(big.NewInt(25000)) < 0 {
+		t.Errorf("fee buys %v gas, want 25000", gas)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/consensus/ethash"
 	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/miner"
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/gasprice"
 )
@@ -35,10 +36,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int                    `toml:",omitempty"`
 		ExtraData               hexutil.Bytes          `toml:",omitempty"`
 		GasPrice                *big.Int
+		TxPolicy                miner.TxPolicyConfig
+		Stratum                 miner.StratumConfig
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		ReceiptErrors           bool
 		DocRoot                 string `toml:"-"`
 	}
 	var enc Config
@@ -58,10 +62,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.TxPolicy = c.TxPolicy
+	enc.Stratum = c.Stratum
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.ReceiptErrors = c.ReceiptErrors
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -85,10 +92,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int                    `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes          `toml:",omitempty"`
 		GasPrice                *big.Int
+		TxPolicy                *miner.TxPolicyConfig
+		Stratum                 *miner.StratumConfig
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		ReceiptErrors           *bool
 		DocRoot                 *string `toml:"-"`
 	}
 	var dec Config
@@ -143,6 +153,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.TxPolicy != nil {
+		c.TxPolicy = *dec.TxPolicy
+	}
+	if dec.Stratum != nil {
+		c.Stratum = *dec.Stratum
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -155,6 +171,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.ReceiptErrors != nil {
+		c.ReceiptErrors = *dec.ReceiptErrors
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -0,0 +1,77 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package sero
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// voteRate and voteBurst limit the votes accepted from a peer. A block gets a
+	// few votes of the selected shares and of their pools, forks included.
+	voteRate  = 8
+	voteBurst = 32
+
+	// lotteryRate and lotteryBurst limit the lotteries accepted from a peer, one
+	// per mined block.
+	lotteryRate  = 2
+	lotteryBurst = 8
+
+	// penaltyRate and maxPenalties are the number of invalid votes and lotteries
+	// a peer may send per second, and at once, before being dropped.
+	penaltyRate  = 1.0 / 60
+	maxPenalties = 5
+)
+
+// gossipLimiter is a token bucket limiting the rate of the messages of a peer.
+type gossipLimiter struct {
+	rate   float64 // Tokens added per second
+	burst  float64 // Maximum number of tokens
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+// newGossipLimiter creates a full token bucket.
+func newGossipLimiter(rate float64, burst int) *gossipLimiter {
+	return &gossipLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// allow takes a token from the bucket, returning false if it's empty.
+func (self *gossipLimiter) allow() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	now := time.Now()
+	self.tokens += now.Sub(self.last).Seconds() * self.rate
+	if self.tokens > self.burst {
+		self.tokens = self.burst
+	}
+	self.last = now
+
+	if self.tokens < 1 {
+		return false
+	}
+	self.tokens--
+	return true
+}
@@ -0,0 +1,69 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package sero
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+)
+
+// Tests that the gossip limiter lets bursts through and then refills at its
+// rate.
+func TestGossipLimiter(t *testing.T) {
+	limiter := newGossipLimiter(voteRate, voteBurst)
+	for i := 0; i < voteBurst; i++ {
+		if !limiter.allow() {
+			t.Fatalf("message %d of the burst refused", i)
+		}
+	}
+	if limiter.allow() {
+		t.Fatal("message over the burst allowed")
+	}
+	// Half a second later, half the rate is available again
+	limiter.last = limiter.last.Add(-500 * time.Millisecond)
+	for i := 0; i < voteRate/2; i++ {
+		if !limiter.allow() {
+			t.Fatalf("message %d after refill refused", i)
+		}
+	}
+	if limiter.allow() {
+		t.Error("message over the refill allowed")
+	}
+}
+
+// Tests that the known votes of a peer drop the least recently seen ones first.
+func TestKnownVotes(t *testing.T) {
+	p, _ := newTestPeers()
+
+	first := common.Hash{0xff}
+	p.MarkVote(first)
+	for i := 0; i < maxKnownVotes; i++ {
+		p.MarkVote(common.Hash{byte(i)})
+		p.MarkVote(first) // Keep the first vote fresh
+	}
+	if !p.knownVotes.Contains(first) {
+		t.Error("recently seen vote dropped")
+	}
+	if p.knownVotes.Contains(common.Hash{0}) {
+		t.Error("least recently seen vote kept")
+	}
+	if p.knownVotes.Len() != maxKnownVotes {
+		t.Errorf("known votes mismatch: have %d, want %d", p.knownVotes.Len(), maxKnownVotes)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/golang-lru"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/consensus"
 	"github.com/sero-cash/go-sero/core"
@@ -39,6 +40,7 @@ import (
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/fetcher"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/voter"
 )
 
 const (
@@ -56,14 +58,25 @@ const (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// protoError is a breach of the protocol by a remote peer.
+type protoError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protoError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protoError{code: code, msg: fmt.Sprintf(format, v...)}
 }
 
 type ProtocolManager struct {
 	networkID uint64
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  uint32 // Flag whether the fast sync retrieves the state in ranges
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
@@ -71,10 +84,12 @@ type ProtocolManager struct {
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
 	maxPeers    int
+	reporter    peerReporter // Scores misbehaving peers, nil if not networked
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
+	propagated *lru.Cache // Recently propagated blocks, serving the compact block transactions
 
 	SubProtocols []p2p.Protocol
 
@@ -115,19 +130,23 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
 	}
+	manager.propagated, _ = lru.New(maxPropagatedBlocks)
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if mode != downloader.FullSync && mode != downloader.LightSync && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		manager.fastSync = uint32(1)
 	}
+	if mode == downloader.SnapSync {
+		manager.snapSync = uint32(1)
+	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < sero63 {
+		if (mode == downloader.FastSync || mode == downloader.SnapSync) && version < sero63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -162,7 +181,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.dropPeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -179,7 +198,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropPeer)
 
 	return manager, nil
 }
@@ -203,6 +222,23 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// reportPeer reports the misbehaviour of a peer to the p2p server, which bans
+// it once its score gets too low.
+func (pm *ProtocolManager) reportPeer(id string, fault p2p.Misbehaviour) {
+	peer := pm.peers.Peer(id)
+	if peer == nil || pm.reporter == nil {
+		return
+	}
+	peer.Log().Debug("Reporting misbehaving Sero peer", "fault", fault)
+	pm.reporter.ReportPeer(peer.ID(), fault)
+}
+
+// dropPeer reports the misbehaviour of a peer and disconnects it.
+func (pm *ProtocolManager) dropPeer(id string, fault p2p.Misbehaviour) {
+	pm.reportPeer(id, fault)
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -299,6 +335,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Sero message handling failed", "err", err)
+			if perr, ok := err.(*protoError); ok {
+				pm.reportPeer(p.id, perr.code.fault())
+			}
 			return err
 		}
 	}
@@ -599,24 +638,138 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
 
-		// Assuming the block is importable by the peer, but possibly not yet done so,
-		// calculate the head hash and TD that the peer truly must have.
-		var (
-			trueHead = request.Block.ParentHash()
-			trueTD   = new(big.Int).Sub(request.TD, request.Block.Difficulty())
-		)
-		// Update the peers total difficulty if better than the previous
-		if _, td := p.Head(); trueTD.Cmp(td) > 0 {
-			p.SetHead(trueHead, trueTD)
-
-			// Schedule a sync if above ours. Note, this will not fire a sync for a gap of
-			// a singe block (as the true TD is below the propagated block), however this
-			// scenario should easily be covered by the fetcher.
-			currentBlock := pm.blockchain.CurrentBlock()
-			if trueTD.Cmp(pm.blockchain.GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
-				go pm.synchronise(p)
+		pm.updatePeerHead(p, request.Block.Header(), request.TD)
+
+	case p.version >= sero64 && msg.Code == NewCompactBlockMsg:
+		// Retrieve and decode the propagated compact block
+		var request compactBlockData
+		if err := msg.Decode(&request); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		partial, err := newPartialBlock(&request, pm.poolTxIndex(request.Header))
+		if err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hash := request.Header.Hash()
+		p.MarkBlock(hash)
+
+		// Import the block if the pool held all of its transactions, fetching the
+		// missing ones otherwise
+		if len(partial.missing) == 0 {
+			pm.importPartialBlock(p, partial, msg.ReceivedAt)
+		} else if !p.addPartialBlock(hash, partial) {
+			pm.fetcher.Notify(p.id, hash, request.Header.Number.Uint64(), msg.ReceivedAt, p.RequestOneHeader, p.RequestBodies)
+		} else if err := p.RequestBlockTxs(hash, partial.missing); err != nil {
+			return err
+		}
+		pm.updatePeerHead(p, request.Header, request.TD)
+
+	case p.version >= sero64 && msg.Code == GetBlockTxsMsg:
+		// Decode the compact block transactions query
+		var query getBlockTxsData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		// Gather the transactions of the block if it's still known, an empty reply
+		// makes the peer fall back to fetching the full block
+		var txs []*types.Transaction
+		if block := pm.propagatedBlock(query.Hash); block != nil {
+			body := block.Transactions()
+			for _, index := range query.Indexes {
+				if int(index) >= len(body) {
+					return errResp(ErrDecode, "transaction index %d out of range", index)
+				}
+				txs = append(txs, body[index])
 			}
 		}
+		return p.SendBlockTxs(query.Hash, txs)
+
+	case p.version >= sero64 && msg.Code == BlockTxsMsg:
+		// The transactions of a compact block arrived, complete and import it
+		var response blockTxsData
+		if err := msg.Decode(&response); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		partial := p.partials[response.Hash]
+		if partial == nil {
+			break
+		}
+		delete(p.partials, response.Hash)
+
+		if err := partial.fill(response.Txs); err != nil {
+			p.Log().Debug("Compact block transactions rejected", "hash", response.Hash, "err", err)
+			pm.fetcher.Notify(p.id, response.Hash, partial.header.Number.Uint64(), msg.ReceivedAt, p.RequestOneHeader, p.RequestBodies)
+			break
+		}
+		for _, tx := range response.Txs {
+			p.MarkTransaction(tx.Hash())
+		}
+		pm.importPartialBlock(p, partial, msg.ReceivedAt)
+
+	case p.version >= sero65 && msg.Code == GetAccountRangeMsg:
+		// Decode the account range query
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		hashes, values, proof := downloader.ServiceAccountRange(pm.blockchain.StateCache().TrieDB(), query.Root, query.Origin, query.Limit, rangeResponseLimit(query.Bytes))
+		return p.SendAccountRange(query.ID, hashes, values, proof)
+
+	case p.version >= sero65 && msg.Code == AccountRangeMsg:
+		// A range of the account trie arrived to one of our previous requests
+		var response accountRangeData
+		if err := msg.Decode(&response); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		hashes, values := unpackRange(response.Accounts)
+		if err := pm.downloader.DeliverAccountRange(p.id, response.ID, hashes, values, response.Proof); err != nil {
+			log.Debug("Failed to deliver account range", "err", err)
+		}
+
+	case p.version >= sero65 && msg.Code == GetStorageRangesMsg:
+		// Decode the storage ranges query
+		var query getStorageRangesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if len(query.Accounts) > downloader.MaxStorageFetch {
+			query.Accounts = query.Accounts[:downloader.MaxStorageFetch]
+		}
+		hashes, slots, proof := downloader.ServiceStorageRanges(pm.blockchain.StateCache().TrieDB(), query.Root, query.Accounts, query.Origin, query.Limit, rangeResponseLimit(query.Bytes))
+		return p.SendStorageRanges(query.ID, hashes, slots, proof)
+
+	case p.version >= sero65 && msg.Code == StorageRangesMsg:
+		// Storage ranges arrived to one of our previous requests
+		var response storageRangesData
+		if err := msg.Decode(&response); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		hashes, slots := make([][]common.Hash, len(response.Slots)), make([][][]byte, len(response.Slots))
+		for i, leaves := range response.Slots {
+			hashes[i], slots[i] = unpackRange(leaves)
+		}
+		if err := pm.downloader.DeliverStorageRanges(p.id, response.ID, hashes, slots, response.Proof); err != nil {
+			log.Debug("Failed to deliver storage ranges", "err", err)
+		}
+
+	case p.version >= sero65 && msg.Code == GetByteCodesMsg:
+		// Decode the contract codes query
+		var query getByteCodesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		codes := downloader.ServiceByteCodes(pm.blockchain.StateCache().TrieDB(), query.Hashes, rangeResponseLimit(query.Bytes))
+		return p.SendByteCodes(query.ID, codes)
+
+	case p.version >= sero65 && msg.Code == ByteCodesMsg:
+		// Contract codes arrived to one of our previous requests
+		var response byteCodesData
+		if err := msg.Decode(&response); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverByteCodes(p.id, response.ID, response.Codes); err != nil {
+			log.Debug("Failed to deliver byte codes", "err", err)
+		}
 
 	case msg.Code == TxMsg:
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
@@ -644,7 +797,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		p.MarkVote(vote.Hash())
-		pm.voter.AddVote(&vote)
+		if !p.voteLimit.allow() {
+			propVoteLimitedMeter.Mark(1)
+			break
+		}
+		if err := pm.voter.AddVote(&vote); err == voter.ErrInvalidVote {
+			if !p.penalties.allow() {
+				return errResp(ErrInvalidGossip, "vote %x: %v", vote.Hash(), err)
+			}
+			pm.reportPeer(p.id, p2p.BadVote)
+		}
 
 	case msg.Code == NewLotteryMsg:
 
@@ -654,7 +816,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		p.MarkLottery(lottery.PosHash)
-		pm.voter.AddLottery(&lottery)
+		if !p.lotteryLimit.allow() {
+			propLotteryLimitedMeter.Mark(1)
+			break
+		}
+		if err := pm.voter.AddLottery(&lottery); err == voter.ErrInvalidVote {
+			if !p.penalties.allow() {
+				return errResp(ErrInvalidGossip, "lottery %x: %v", lottery.PosHash, err)
+			}
+			pm.reportPeer(p.id, p2p.BadVote)
+		}
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -662,6 +833,74 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// updatePeerHead updates the head of a peer which propagated the given block,
+// and synchronises with it if it is ahead of us.
+func (pm *ProtocolManager) updatePeerHead(p *peer, header *types.Header, td *big.Int) {
+	// Assuming the block is importable by the peer, but possibly not yet done so,
+	// calculate the head hash and TD that the peer truly must have.
+	var (
+		trueHead = header.ParentHash
+		trueTD   = new(big.Int).Sub(td, header.Difficulty)
+	)
+	// Update the peers total difficulty if better than the previous
+	if _, td := p.Head(); trueTD.Cmp(td) > 0 {
+		p.SetHead(trueHead, trueTD)
+
+		// Schedule a sync if above ours. Note, this will not fire a sync for a gap of
+		// a singe block (as the true TD is below the propagated block), however this
+		// scenario should easily be covered by the fetcher.
+		currentBlock := pm.blockchain.CurrentBlock()
+		if trueTD.Cmp(pm.blockchain.GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
+			go pm.synchronise(p)
+		}
+	}
+}
+
+// poolTxIndex indexes the pending transactions of the pool by their short ID
+// in the given block.
+func (pm *ProtocolManager) poolTxIndex(header *types.Header) map[shortTxID]*types.Transaction {
+	if header == nil {
+		return nil
+	}
+	pending, err := pm.txpool.Pending()
+	if err != nil {
+		return nil
+	}
+	return shortTxIndex(header.Hash(), pending)
+}
+
+// importPartialBlock schedules a rebuilt compact block for import, falling back
+// to fetching the full block if its transactions don't match the header.
+func (pm *ProtocolManager) importPartialBlock(p *peer, partial *partialBlock, receivedAt time.Time) {
+	block, err := partial.block()
+	if err != nil {
+		p.Log().Debug("Compact block rebuild failed", "number", partial.header.Number, "hash", partial.header.Hash(), "err", err)
+		pm.fetcher.Notify(p.id, partial.header.Hash(), partial.header.Number.Uint64(), receivedAt, p.RequestOneHeader, p.RequestBodies)
+		return
+	}
+	block.ReceivedAt = receivedAt
+	block.ReceivedFrom = p
+
+	pm.fetcher.Enqueue(p.id, block)
+}
+
+// propagatedBlock retrieves a block recently propagated or imported, to serve
+// the transactions of its compact block.
+func (pm *ProtocolManager) propagatedBlock(hash common.Hash) *types.Block {
+	if block, ok := pm.propagated.Get(hash); ok {
+		return block.(*types.Block)
+	}
+	return pm.blockchain.GetBlockByHash(hash)
+}
+
+// rangeResponseLimit caps the size of a state range response requested by a peer.
+func rangeResponseLimit(bytes uint64) uint64 {
+	if bytes > softResponseLimit {
+		return softResponseLimit
+	}
+	return bytes
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -678,7 +917,9 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 			log.Error("Propagating dangling block", "number", block.Number(), "hash", hash)
 			return
 		}
-		// Send the block to a subset of our peers
+		// Send the block to a subset of our peers, keeping it around for the
+		// compact block transaction queries
+		pm.propagated.Add(hash, block)
 		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
 		for _, peer := range transfer {
 			peer.AsyncSendNewBlock(block, td)
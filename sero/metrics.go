@@ -22,38 +22,48 @@ import (
 )
 
 var (
-	propTxnInPacketsMeter     = metrics.NewRegisteredMeter("sero/prop/txns/in/packets", nil)
-	propTxnInTrafficMeter     = metrics.NewRegisteredMeter("sero/prop/txns/in/traffic", nil)
-	propTxnOutPacketsMeter    = metrics.NewRegisteredMeter("sero/prop/txns/out/packets", nil)
-	propTxnOutTrafficMeter    = metrics.NewRegisteredMeter("sero/prop/txns/out/traffic", nil)
-	propHashInPacketsMeter    = metrics.NewRegisteredMeter("sero/prop/hashes/in/packets", nil)
-	propHashInTrafficMeter    = metrics.NewRegisteredMeter("sero/prop/hashes/in/traffic", nil)
-	propHashOutPacketsMeter   = metrics.NewRegisteredMeter("sero/prop/hashes/out/packets", nil)
-	propHashOutTrafficMeter   = metrics.NewRegisteredMeter("sero/prop/hashes/out/traffic", nil)
-	propBlockInPacketsMeter   = metrics.NewRegisteredMeter("sero/prop/blocks/in/packets", nil)
-	propBlockInTrafficMeter   = metrics.NewRegisteredMeter("sero/prop/blocks/in/traffic", nil)
-	propBlockOutPacketsMeter  = metrics.NewRegisteredMeter("sero/prop/blocks/out/packets", nil)
-	propBlockOutTrafficMeter  = metrics.NewRegisteredMeter("sero/prop/blocks/out/traffic", nil)
-	reqHeaderInPacketsMeter   = metrics.NewRegisteredMeter("sero/req/headers/in/packets", nil)
-	reqHeaderInTrafficMeter   = metrics.NewRegisteredMeter("sero/req/headers/in/traffic", nil)
-	reqHeaderOutPacketsMeter  = metrics.NewRegisteredMeter("sero/req/headers/out/packets", nil)
-	reqHeaderOutTrafficMeter  = metrics.NewRegisteredMeter("sero/req/headers/out/traffic", nil)
-	reqBodyInPacketsMeter     = metrics.NewRegisteredMeter("sero/req/bodies/in/packets", nil)
-	reqBodyInTrafficMeter     = metrics.NewRegisteredMeter("sero/req/bodies/in/traffic", nil)
-	reqBodyOutPacketsMeter    = metrics.NewRegisteredMeter("sero/req/bodies/out/packets", nil)
-	reqBodyOutTrafficMeter    = metrics.NewRegisteredMeter("sero/req/bodies/out/traffic", nil)
-	reqStateInPacketsMeter    = metrics.NewRegisteredMeter("sero/req/states/in/packets", nil)
-	reqStateInTrafficMeter    = metrics.NewRegisteredMeter("sero/req/states/in/traffic", nil)
-	reqStateOutPacketsMeter   = metrics.NewRegisteredMeter("sero/req/states/out/packets", nil)
-	reqStateOutTrafficMeter   = metrics.NewRegisteredMeter("sero/req/states/out/traffic", nil)
-	reqReceiptInPacketsMeter  = metrics.NewRegisteredMeter("sero/req/receipts/in/packets", nil)
-	reqReceiptInTrafficMeter  = metrics.NewRegisteredMeter("sero/req/receipts/in/traffic", nil)
-	reqReceiptOutPacketsMeter = metrics.NewRegisteredMeter("sero/req/receipts/out/packets", nil)
-	reqReceiptOutTrafficMeter = metrics.NewRegisteredMeter("sero/req/receipts/out/traffic", nil)
-	miscInPacketsMeter        = metrics.NewRegisteredMeter("sero/misc/in/packets", nil)
-	miscInTrafficMeter        = metrics.NewRegisteredMeter("sero/misc/in/traffic", nil)
-	miscOutPacketsMeter       = metrics.NewRegisteredMeter("sero/misc/out/packets", nil)
-	miscOutTrafficMeter       = metrics.NewRegisteredMeter("sero/misc/out/traffic", nil)
+	propTxnInPacketsMeter      = metrics.NewRegisteredMeter("sero/prop/txns/in/packets", nil)
+	propTxnInTrafficMeter      = metrics.NewRegisteredMeter("sero/prop/txns/in/traffic", nil)
+	propTxnOutPacketsMeter     = metrics.NewRegisteredMeter("sero/prop/txns/out/packets", nil)
+	propTxnOutTrafficMeter     = metrics.NewRegisteredMeter("sero/prop/txns/out/traffic", nil)
+	propHashInPacketsMeter     = metrics.NewRegisteredMeter("sero/prop/hashes/in/packets", nil)
+	propHashInTrafficMeter     = metrics.NewRegisteredMeter("sero/prop/hashes/in/traffic", nil)
+	propHashOutPacketsMeter    = metrics.NewRegisteredMeter("sero/prop/hashes/out/packets", nil)
+	propHashOutTrafficMeter    = metrics.NewRegisteredMeter("sero/prop/hashes/out/traffic", nil)
+	propBlockInPacketsMeter    = metrics.NewRegisteredMeter("sero/prop/blocks/in/packets", nil)
+	propBlockInTrafficMeter    = metrics.NewRegisteredMeter("sero/prop/blocks/in/traffic", nil)
+	propBlockOutPacketsMeter   = metrics.NewRegisteredMeter("sero/prop/blocks/out/packets", nil)
+	propBlockOutTrafficMeter   = metrics.NewRegisteredMeter("sero/prop/blocks/out/traffic", nil)
+	propVoteInPacketsMeter     = metrics.NewRegisteredMeter("sero/prop/votes/in/packets", nil)
+	propVoteInTrafficMeter     = metrics.NewRegisteredMeter("sero/prop/votes/in/traffic", nil)
+	propVoteOutPacketsMeter    = metrics.NewRegisteredMeter("sero/prop/votes/out/packets", nil)
+	propVoteOutTrafficMeter    = metrics.NewRegisteredMeter("sero/prop/votes/out/traffic", nil)
+	propVoteLimitedMeter       = metrics.NewRegisteredMeter("sero/prop/votes/limited", nil)
+	propLotteryInPacketsMeter  = metrics.NewRegisteredMeter("sero/prop/lotterys/in/packets", nil)
+	propLotteryInTrafficMeter  = metrics.NewRegisteredMeter("sero/prop/lotterys/in/traffic", nil)
+	propLotteryOutPacketsMeter = metrics.NewRegisteredMeter("sero/prop/lotterys/out/packets", nil)
+	propLotteryOutTrafficMeter = metrics.NewRegisteredMeter("sero/prop/lotterys/out/traffic", nil)
+	propLotteryLimitedMeter    = metrics.NewRegisteredMeter("sero/prop/lotterys/limited", nil)
+	reqHeaderInPacketsMeter    = metrics.NewRegisteredMeter("sero/req/headers/in/packets", nil)
+	reqHeaderInTrafficMeter    = metrics.NewRegisteredMeter("sero/req/headers/in/traffic", nil)
+	reqHeaderOutPacketsMeter   = metrics.NewRegisteredMeter("sero/req/headers/out/packets", nil)
+	reqHeaderOutTrafficMeter   = metrics.NewRegisteredMeter("sero/req/headers/out/traffic", nil)
+	reqBodyInPacketsMeter      = metrics.NewRegisteredMeter("sero/req/bodies/in/packets", nil)
+	reqBodyInTrafficMeter      = metrics.NewRegisteredMeter("sero/req/bodies/in/traffic", nil)
+	reqBodyOutPacketsMeter     = metrics.NewRegisteredMeter("sero/req/bodies/out/packets", nil)
+	reqBodyOutTrafficMeter     = metrics.NewRegisteredMeter("sero/req/bodies/out/traffic", nil)
+	reqStateInPacketsMeter     = metrics.NewRegisteredMeter("sero/req/states/in/packets", nil)
+	reqStateInTrafficMeter     = metrics.NewRegisteredMeter("sero/req/states/in/traffic", nil)
+	reqStateOutPacketsMeter    = metrics.NewRegisteredMeter("sero/req/states/out/packets", nil)
+	reqStateOutTrafficMeter    = metrics.NewRegisteredMeter("sero/req/states/out/traffic", nil)
+	reqReceiptInPacketsMeter   = metrics.NewRegisteredMeter("sero/req/receipts/in/packets", nil)
+	reqReceiptInTrafficMeter   = metrics.NewRegisteredMeter("sero/req/receipts/in/traffic", nil)
+	reqReceiptOutPacketsMeter  = metrics.NewRegisteredMeter("sero/req/receipts/out/packets", nil)
+	reqReceiptOutTrafficMeter  = metrics.NewRegisteredMeter("sero/req/receipts/out/traffic", nil)
+	miscInPacketsMeter         = metrics.NewRegisteredMeter("sero/misc/in/packets", nil)
+	miscInTrafficMeter         = metrics.NewRegisteredMeter("sero/misc/in/traffic", nil)
+	miscOutPacketsMeter        = metrics.NewRegisteredMeter("sero/misc/out/packets", nil)
+	miscOutTrafficMeter        = metrics.NewRegisteredMeter("sero/misc/out/traffic", nil)
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -94,6 +104,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 
 	case rw.version >= sero63 && msg.Code == NodeDataMsg:
 		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
+	case rw.version >= sero65 && (msg.Code == AccountRangeMsg || msg.Code == StorageRangesMsg || msg.Code == ByteCodesMsg):
+		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
 	case rw.version >= sero63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptInPacketsMeter, reqReceiptInTrafficMeter
 
@@ -103,6 +115,10 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
+	case msg.Code == NewVoteMsg:
+		packets, traffic = propVoteInPacketsMeter, propVoteInTrafficMeter
+	case msg.Code == NewLotteryMsg:
+		packets, traffic = propLotteryInPacketsMeter, propLotteryInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -121,6 +137,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 
 	case rw.version >= sero63 && msg.Code == NodeDataMsg:
 		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
+	case rw.version >= sero65 && (msg.Code == AccountRangeMsg || msg.Code == StorageRangesMsg || msg.Code == ByteCodesMsg):
+		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
 	case rw.version >= sero63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptOutPacketsMeter, reqReceiptOutTrafficMeter
 
@@ -130,6 +148,10 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
+	case msg.Code == NewVoteMsg:
+		packets, traffic = propVoteOutPacketsMeter, propVoteOutTrafficMeter
+	case msg.Code == NewLotteryMsg:
+		packets, traffic = propLotteryOutPacketsMeter, propLotteryOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/golang-lru"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/p2p"
@@ -90,8 +91,8 @@ type peer struct {
 	td   *big.Int
 	lock sync.RWMutex
 
-	knownLotterys  mapset.Set
-	knownVotes     mapset.Set
+	knownLotterys  *lru.Cache                // Set of lottery hashes known to be known by this peer
+	knownVotes     *lru.Cache                // Set of vote hashes known to be known by this peer
 	knownTxs       mapset.Set                // Set of transaction hashes known to be known by this peer
 	knownBlocks    mapset.Set                // Set of block hashes known to be known by this peer
 	queuedTxs      chan []*types.Transaction // Queue of transactions to broadcast to the peer
@@ -100,16 +101,24 @@ type peer struct {
 	queuedLotterys chan *types.Lottery
 	queuedVotes    chan *types.Vote
 	term           chan struct{} // Termination channel to stop the broadcaster
+
+	partials map[common.Hash]*partialBlock // Compact blocks waiting for transactions, only used by the handler
+
+	voteLimit    *gossipLimiter // Rate limit of the votes relayed by the peer
+	lotteryLimit *gossipLimiter // Rate limit of the lotteries relayed by the peer
+	penalties    *gossipLimiter // Allowance of invalid votes and lotteries before dropping the peer
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	knownLotterys, _ := lru.New(maxKnownLotterys)
+	knownVotes, _ := lru.New(maxKnownVotes)
 	return &peer{
 		Peer:           p,
 		rw:             rw,
 		version:        version,
 		id:             fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownLotterys:  mapset.NewSet(),
-		knownVotes:     mapset.NewSet(),
+		knownLotterys:  knownLotterys,
+		knownVotes:     knownVotes,
 		knownTxs:       mapset.NewSet(),
 		knownBlocks:    mapset.NewSet(),
 		queuedTxs:      make(chan []*types.Transaction, maxQueuedTxs),
@@ -118,6 +127,10 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		queuedLotterys: make(chan *types.Lottery, maxQueuedLotterys),
 		queuedVotes:    make(chan *types.Vote, maxQueuedVotes),
 		term:           make(chan struct{}),
+		partials:       make(map[common.Hash]*partialBlock),
+		voteLimit:      newGossipLimiter(voteRate, voteBurst),
+		lotteryLimit:   newGossipLimiter(lotteryRate, lotteryBurst),
+		penalties:      newGossipLimiter(penaltyRate, maxPenalties),
 	}
 }
 
@@ -134,7 +147,11 @@ func (p *peer) broadcast() {
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
 		case prop := <-p.queuedProps:
-			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
+			send := p.SendNewBlock
+			if p.version >= sero64 {
+				send = p.SendNewCompactBlock
+			}
+			if err := send(prop.block, prop.td); err != nil {
 				return
 			}
 			p.Log().Trace("Propagated block", "number", prop.block.Number(), "hash", prop.block.Hash(), "td", prop.td)
@@ -217,20 +234,17 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 	p.knownTxs.Add(hash)
 }
 
+// MarkVote marks a vote as known for the peer, ensuring that it will never be
+// propagated to this particular peer. The least recently seen votes are dropped
+// first once the memory allowance is reached.
 func (p *peer) MarkVote(hash common.Hash) {
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownVotes.Cardinality() >= maxKnownVotes {
-		p.knownVotes.Pop()
-	}
-	p.knownVotes.Add(hash)
+	p.knownVotes.Add(hash, nil)
 }
 
+// MarkLottery marks a lottery as known for the peer, ensuring that it will never
+// be propagated to this particular peer.
 func (p *peer) MarkLottery(hash common.Hash) {
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownLotterys.Cardinality() >= maxKnownLotterys {
-		p.knownLotterys.Pop()
-	}
-	p.knownLotterys.Add(hash)
+	p.knownLotterys.Add(hash, nil)
 }
 
 // SendTransactions sends transactions to the peer and includes the hashes
@@ -287,31 +301,52 @@ func (p *peer) SendNewBlock(block *types.Block, td *big.Int) error {
 	return p2p.Send(p.rw, NewBlockMsg, []interface{}{block, td})
 }
 
+// SendNewCompactBlock propagates a block to a remote peer, leaving out the
+// transactions the peer is known to have.
+func (p *peer) SendNewCompactBlock(block *types.Block, td *big.Int) error {
+	p.knownBlocks.Add(block.Hash())
+	return p2p.Send(p.rw, NewCompactBlockMsg, newCompactBlock(block, td, func(hash common.Hash) bool {
+		return p.knownTxs.Contains(hash)
+	}))
+}
+
+// SendBlockTxs sends the requested transactions of a compact block.
+func (p *peer) SendBlockTxs(hash common.Hash, txs []*types.Transaction) error {
+	return p2p.Send(p.rw, BlockTxsMsg, &blockTxsData{Hash: hash, Txs: txs})
+}
+
+// RequestBlockTxs fetches the transactions of a compact block missing from the
+// local pool.
+func (p *peer) RequestBlockTxs(hash common.Hash, indexes []uint32) error {
+	p.Log().Debug("Fetching compact block transactions", "hash", hash, "count", len(indexes))
+	return p2p.Send(p.rw, GetBlockTxsMsg, &getBlockTxsData{Hash: hash, Indexes: indexes})
+}
+
 func (p *peer) AsyncSendNewVote(vote *types.Vote) {
 	select {
 	case p.queuedVotes <- vote:
-		p.knownVotes.Add(vote.Hash())
+		p.knownVotes.Add(vote.Hash(), nil)
 	default:
 		p.Log().Debug("Dropping vote announcement", "hash", vote.Hash())
 	}
 }
 
 func (p *peer) SendNewVote(vote *types.Vote) error {
-	p.knownVotes.Add(vote.Hash())
+	p.knownVotes.Add(vote.Hash(), nil)
 	return p2p.Send(p.rw, NewVoteMsg, vote)
 }
 
 func (p *peer) AsyncSendNewLottery(lottery *types.Lottery) {
 	select {
 	case p.queuedLotterys <- lottery:
-		p.knownLotterys.Add(lottery.PosHash)
+		p.knownLotterys.Add(lottery.PosHash, nil)
 	default:
 		p.Log().Debug("Dropping lottery announcement", "hash", lottery.PosHash)
 	}
 }
 
 func (p *peer) SendNewLottery(lottery *types.Lottery) error {
-	p.knownLotterys.Add(lottery.PosHash)
+	p.knownLotterys.Add(lottery.PosHash, nil)
 	return p2p.Send(p.rw, NewLotteryMsg, lottery)
 }
 
@@ -395,6 +430,46 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
 }
 
+// SendAccountRange sends a range of the account trie, with the proof of its edges.
+func (p *peer) SendAccountRange(id uint64, hashes []common.Hash, values [][]byte, proof [][]byte) error {
+	return p2p.Send(p.rw, AccountRangeMsg, &accountRangeData{ID: id, Accounts: packRange(hashes, values), Proof: proof})
+}
+
+// SendStorageRanges sends the storage ranges of a batch of accounts, with the
+// proof of the edges of the last one.
+func (p *peer) SendStorageRanges(id uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) error {
+	ranges := make([][]rangeLeaf, len(hashes))
+	for i := range hashes {
+		ranges[i] = packRange(hashes[i], slots[i])
+	}
+	return p2p.Send(p.rw, StorageRangesMsg, &storageRangesData{ID: id, Slots: ranges, Proof: proof})
+}
+
+// SendByteCodes sends a batch of contract codes.
+func (p *peer) SendByteCodes(id uint64, codes [][]byte) error {
+	return p2p.Send(p.rw, ByteCodesMsg, &byteCodesData{ID: id, Codes: codes})
+}
+
+// RequestAccountRange fetches a range of the account trie of a state, starting
+// at origin and stopping at limit, or at the given number of bytes.
+func (p *peer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of accounts", "reqid", id, "root", root, "origin", origin, "limit", limit, "bytes", common.StorageSize(bytes))
+	return p2p.Send(p.rw, GetAccountRangeMsg, &getAccountRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestStorageRanges fetches the storage tries of a batch of accounts, origin
+// applying to the first account and limit to the last one.
+func (p *peer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching ranges of storage slots", "reqid", id, "root", root, "count", len(accounts), "origin", origin, "bytes", common.StorageSize(bytes))
+	return p2p.Send(p.rw, GetStorageRangesMsg, &getStorageRangesData{ID: id, Root: root, Accounts: accounts, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestByteCodes fetches a batch of contract codes by hash.
+func (p *peer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching batch of byte codes", "reqid", id, "count", len(hashes), "bytes", common.StorageSize(bytes))
+	return p2p.Send(p.rw, GetByteCodesMsg, &getByteCodesData{ID: id, Hashes: hashes, Bytes: bytes})
+}
+
 // Handshake executes the sero protocol handshake, negotiating version number,
 // network IDs, difficulties, head and genesis blocks.
 func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
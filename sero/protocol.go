@@ -25,6 +25,8 @@ import (
 	"github.com/sero-cash/go-sero/core"
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/p2p/discover"
 	"github.com/sero-cash/go-sero/rlp"
 )
 
@@ -32,16 +34,18 @@ import (
 const (
 	sero62 = 62
 	sero63 = 63
+	sero64 = 64
+	sero65 = 65
 )
 
 // ProtocolName is the official short name of the protocol used during capability negotiation.
 var ProtocolName = "sero"
 
 // ProtocolVersions are the upported versions of the sero protocol (first is primary).
-var ProtocolVersions = []uint{sero63, sero62}
+var ProtocolVersions = []uint{sero65, sero64, sero63, sero62}
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{24, 8}
+var ProtocolLengths = []uint64{33, 27, 24, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -65,6 +69,19 @@ const (
 
 	NewVoteMsg    = 0x16
 	NewLotteryMsg = 0x17
+
+	// Protocol messages belonging to sero/64
+	NewCompactBlockMsg = 0x18
+	GetBlockTxsMsg     = 0x19
+	BlockTxsMsg        = 0x1a
+
+	// Protocol messages belonging to sero/65
+	GetAccountRangeMsg  = 0x1b
+	AccountRangeMsg     = 0x1c
+	GetStorageRangesMsg = 0x1d
+	StorageRangesMsg    = 0x1e
+	GetByteCodesMsg     = 0x1f
+	ByteCodesMsg        = 0x20
 )
 
 type errCode int
@@ -79,12 +96,22 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrInvalidGossip
 )
 
 func (e errCode) String() string {
 	return errorToString[int(e)]
 }
 
+// fault returns the misbehaviour of a peer sending a message failing with the
+// error code.
+func (e errCode) fault() p2p.Misbehaviour {
+	if e == ErrInvalidGossip {
+		return p2p.BadVote
+	}
+	return p2p.BadMessage
+}
+
 // XXX change once legacy code is out
 var errorToString = map[int]string{
 	ErrMsgTooLarge:             "Message too long",
@@ -96,6 +123,12 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrInvalidGossip:           "Invalid vote or lottery",
+}
+
+// peerReporter scores the misbehaviour of peers, implemented by p2p.Server.
+type peerReporter interface {
+	ReportPeer(id discover.NodeID, fault p2p.Misbehaviour)
 }
 
 type txPool interface {
@@ -114,8 +147,8 @@ type txPool interface {
 type shareVoter interface {
 	SubscribeNewVoteEvent(chan<- core.NewVoteEvent) event.Subscription
 	SubscribeNewLotteryEvent(chan<- core.NewLotteryEvent) event.Subscription
-	AddLottery(lottery *types.Lottery)
-	AddVote(vote *types.Vote)
+	AddLottery(lottery *types.Lottery) error
+	AddVote(vote *types.Vote) error
 }
 
 // statusData is the network packet for the status message.
@@ -183,6 +216,102 @@ type newBlockData struct {
 	TD    *big.Int
 }
 
+// compactBlockData is the network packet for the compact block propagation.
+type compactBlockData struct {
+	Header    *types.Header
+	TD        *big.Int
+	ShortIDs  []shortTxID   // Transactions the peer is expected to have, in block order
+	Prefilled []prefilledTx // Transactions sent in full, by ascending index
+}
+
+// prefilledTx is a transaction sent in full within a compact block.
+type prefilledTx struct {
+	Index uint32 // Position of the transaction in the block
+	Tx    *types.Transaction
+}
+
+// getBlockTxsData represents a query of the transactions of a compact block.
+type getBlockTxsData struct {
+	Hash    common.Hash // Hash of the compact block
+	Indexes []uint32    // Positions of the transactions in the block
+}
+
+// blockTxsData is the network packet for the transactions of a compact block.
+type blockTxsData struct {
+	Hash common.Hash
+	Txs  []*types.Transaction
+}
+
+// getAccountRangeData represents a query of a range of the account trie.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up the response with
+	Root   common.Hash // Root hash of the account trie to serve
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountRangeData is the network packet for a range of the account trie.
+type accountRangeData struct {
+	ID       uint64
+	Accounts []rangeLeaf
+	Proof    [][]byte // Merkle proofs of the edges of the range
+}
+
+// getStorageRangesData represents a query of the storage tries of accounts.
+type getStorageRangesData struct {
+	ID       uint64
+	Root     common.Hash   // Root hash of the account trie to serve
+	Accounts []common.Hash // Hashes of the accounts to retrieve the storage of
+	Origin   common.Hash   // Hash of the first slot of the first account
+	Limit    common.Hash   // Hash of the last slot of the last account
+	Bytes    uint64
+}
+
+// storageRangesData is the network packet for the storage ranges of accounts.
+type storageRangesData struct {
+	ID    uint64
+	Slots [][]rangeLeaf
+	Proof [][]byte // Merkle proofs of the edges of the last range
+}
+
+// getByteCodesData represents a query of contract codes.
+type getByteCodesData struct {
+	ID     uint64
+	Hashes []common.Hash
+	Bytes  uint64
+}
+
+// byteCodesData is the network packet for contract codes.
+type byteCodesData struct {
+	ID    uint64
+	Codes [][]byte
+}
+
+// rangeLeaf is a leaf of a trie range.
+type rangeLeaf struct {
+	Hash  common.Hash
+	Value []byte
+}
+
+// packRange zips the keys and values of a trie range into leaves.
+func packRange(keys []common.Hash, values [][]byte) []rangeLeaf {
+	leaves := make([]rangeLeaf, len(keys))
+	for i := range keys {
+		leaves[i] = rangeLeaf{Hash: keys[i], Value: values[i]}
+	}
+	return leaves
+}
+
+// unpackRange splits the leaves of a trie range into keys and values.
+func unpackRange(leaves []rangeLeaf) ([]common.Hash, [][]byte) {
+	keys, values := make([]common.Hash, len(leaves)), make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		keys[i], values[i] = leaf.Hash, leaf.Value
+	}
+	return keys, values
+}
+
 // blockBody represents the data content of a single block.
 type blockBody struct {
 	Transactions []*types.Transaction // Transactions contained within a block
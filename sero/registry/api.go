@@ -0,0 +1,142 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+)
+
+// RPCToken is the RPC representation of a token.
+type RPCToken struct {
+	Currency    string          `json:"currency"`
+	Contract    *common.Address `json:"contract"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	TxHash      *common.Hash    `json:"txHash"`
+	TotalSupply *hexutil.Big    `json:"totalSupply"`
+}
+
+func newRPCToken(token *Token) *RPCToken {
+	return &RPCToken{
+		Currency:    token.Currency,
+		Contract:    &token.Contract,
+		BlockNumber: hexutil.Uint64(token.BlockNumber),
+		TxHash:      &token.TxHash,
+		TotalSupply: (*hexutil.Big)(token.TotalSupply),
+	}
+}
+
+// RPCHistory tells whether an answer of the registry covers the whole chain.
+type RPCHistory struct {
+	Complete  bool           `json:"complete"`
+	FromBlock hexutil.Uint64 `json:"fromBlock"` // First block whose issuances are all known
+}
+
+// RPCTokens is the RPC representation of the token list.
+type RPCTokens struct {
+	Tokens []*RPCToken `json:"tokens"`
+	RPCHistory
+}
+
+// RPCTokenInfo is the RPC representation of a token with the history it is
+// built from.
+type RPCTokenInfo struct {
+	*RPCToken
+	RPCHistory
+}
+
+// RPCTicketCategory is the RPC representation of a ticket category.
+type RPCTicketCategory struct {
+	Category    string         `json:"category"`
+	Contract    common.Address `json:"contract"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"txHash"`
+}
+
+// RPCTicketCategories is the RPC representation of the ticket category list.
+type RPCTicketCategories struct {
+	Categories []*RPCTicketCategory `json:"categories"`
+	RPCHistory
+}
+
+// PublicRegistryAPI provides an API to access the tokens and the ticket
+// categories registered by the contracts.
+type PublicRegistryAPI struct {
+	registry *Registry
+}
+
+// NewPublicRegistryAPI creates a new registry API.
+func NewPublicRegistryAPI(registry *Registry) *PublicRegistryAPI {
+	return &PublicRegistryAPI{registry}
+}
+
+// history returns the part of the chain the registry knows the issuances of.
+func (api *PublicRegistryAPI) history() RPCHistory {
+	from := api.registry.FromBlock()
+	return RPCHistory{Complete: from == 0, FromBlock: hexutil.Uint64(from)}
+}
+
+// GetTokens returns the tokens issued by the contracts, sorted by currency.
+// If the node did not execute the whole chain, the list is flagged incomplete
+// and misses the tokens issued before the block it is known from.
+func (api *PublicRegistryAPI) GetTokens() *RPCTokens {
+	result := &RPCTokens{Tokens: []*RPCToken{}, RPCHistory: api.history()}
+	for _, token := range api.registry.Tokens() {
+		result.Tokens = append(result.Tokens, newRPCToken(token))
+	}
+	return result
+}
+
+// GetTokenInfo returns the token of the given currency. SERO is reported
+// without contract nor supply. If the node did not execute the whole chain,
+// the token is flagged incomplete, its supply and first issuance only covering
+// the blocks it is known from.
+func (api *PublicRegistryAPI) GetTokenInfo(currency string) (*RPCTokenInfo, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "SERO" {
+		return &RPCTokenInfo{RPCToken: &RPCToken{Currency: currency}, RPCHistory: RPCHistory{Complete: true}}, nil
+	}
+	history := api.history()
+	token := api.registry.Token(currency)
+	if token == nil {
+		if !history.Complete {
+			return nil, fmt.Errorf("unknown currency %s, the issuances before block %d are unknown", currency, history.FromBlock)
+		}
+		return nil, fmt.Errorf("unknown currency %s", currency)
+	}
+	return &RPCTokenInfo{RPCToken: newRPCToken(token), RPCHistory: history}, nil
+}
+
+// GetTicketCategories returns the ticket categories registered by the
+// contracts, sorted by name. If the node did not execute the whole chain, the
+// list is flagged incomplete and misses the categories registered before the
+// block it is known from.
+func (api *PublicRegistryAPI) GetTicketCategories() *RPCTicketCategories {
+	result := &RPCTicketCategories{Categories: []*RPCTicketCategory{}, RPCHistory: api.history()}
+	for _, category := range api.registry.TicketCategories() {
+		result.Categories = append(result.Categories, &RPCTicketCategory{
+			Category:    category.Category,
+			Contract:    category.Contract,
+			BlockNumber: hexutil.Uint64(category.BlockNumber),
+			TxHash:      category.TxHash,
+		})
+	}
+	return result
+}
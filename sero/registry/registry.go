@@ -0,0 +1,403 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package registry indexes the tokens issued and the ticket categories
+// registered by the contracts of the canonical chain.
+//
+// The issuances are read from the ones stored with the blocks by
+// BlockChain.WriteBlockWithState, that is with the blocks executed by the
+// node. The blocks not executed, those of a fast sync or imported before the
+// issuances were stored, are not executed again: their tokens and categories
+// are unknown, and as system calls leave no log in the receipts, they can't be
+// recovered from them either. Once the indexer met such a block the registry
+// API flags its answers as incomplete, with the first block from which the
+// issuances are all known.
+package registry
+
+import (
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+const (
+	// SectionSize is the number of blocks of a registry index section.
+	SectionSize = 32
+
+	// registryConfirms is the number of confirmation blocks before a section
+	// is indexed.
+	registryConfirms = 12
+
+	// registryThrottling is the time to wait between processing two
+	// consecutive sections.
+	registryThrottling = 100 * time.Millisecond
+)
+
+var (
+	tokenListKey     = []byte("tokens")
+	categoryListKey  = []byte("categories")
+	tokenPrefix      = []byte("token-")    // tokenPrefix + currency -> token
+	categoryPrefix   = []byte("category-") // categoryPrefix + category -> ticket category
+	sectionLogPrefix = []byte("section-")  // sectionLogPrefix + section (uint64 big endian) -> section issuances
+	gapKey           = []byte("gap")       // gapKey -> last indexed block (uint64 big endian) without stored issuances
+)
+
+// Token is a token issued by a contract.
+type Token struct {
+	Currency    string
+	Contract    common.Address
+	BlockNumber uint64      // Block of the first issuance
+	TxHash      common.Hash // Transaction of the first issuance
+	TotalSupply *big.Int    // Sum of all the issuances
+}
+
+// TicketCategory is a ticket category registered by a contract.
+type TicketCategory struct {
+	Category    string
+	Contract    common.Address
+	BlockNumber uint64      // Block a contract first received a ticket of the category in
+	TxHash      common.Hash // Transaction a contract first received a ticket of the category in
+}
+
+// sectionIssuance is an issuance processed in a section, kept to undo the
+// section on reorgs.
+type sectionIssuance struct {
+	BlockNumber uint64
+	Issuance    *types.Issuance
+}
+
+// Registry reads the tokens and ticket categories indexed by the Indexer.
+type Registry struct {
+	chainDb serodb.Database
+	db      serodb.Database // Index table of the registry
+}
+
+// New returns the registry stored in the given chain database.
+func New(chainDb serodb.Database) *Registry {
+	return &Registry{chainDb: chainDb, db: serodb.NewTable(chainDb, string(rawdb.RegistryIndexPrefix))}
+}
+
+// FromBlock returns the first block from which the issuances of every indexed
+// block were stored, 0 if they all were. The tokens and categories of the
+// blocks before it are unknown, as are their part of the token supplies.
+func (self *Registry) FromBlock() uint64 {
+	if gap := self.readGap(); gap > 0 {
+		return gap + 1
+	}
+	return 0
+}
+
+// readGap returns the last indexed block whose issuances were not stored, 0
+// if none.
+func (self *Registry) readGap() uint64 {
+	data, _ := self.db.Get(gapKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func (self *Registry) readList(key []byte) (ret []string) {
+	if data, _ := self.db.Get(key); len(data) > 0 {
+		rlp.DecodeBytes(data, &ret)
+	}
+	return
+}
+
+// Token returns the token of the given currency, nil if unknown.
+func (self *Registry) Token(currency string) *Token {
+	data, _ := self.db.Get(append(common.CopyBytes(tokenPrefix), currency...))
+	if len(data) == 0 {
+		return nil
+	}
+	token := new(Token)
+	if err := rlp.DecodeBytes(data, token); err != nil {
+		return nil
+	}
+	return token
+}
+
+// Tokens returns all the tokens sorted by currency.
+func (self *Registry) Tokens() []*Token {
+	tokens := []*Token{}
+	for _, currency := range self.readList(tokenListKey) {
+		if token := self.Token(currency); token != nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// TicketCategory returns the ticket category of the given name, nil if unknown.
+func (self *Registry) TicketCategory(category string) *TicketCategory {
+	data, _ := self.db.Get(append(common.CopyBytes(categoryPrefix), category...))
+	if len(data) == 0 {
+		return nil
+	}
+	ret := new(TicketCategory)
+	if err := rlp.DecodeBytes(data, ret); err != nil {
+		return nil
+	}
+	return ret
+}
+
+// TicketCategories returns all the ticket categories sorted by name.
+func (self *Registry) TicketCategories() []*TicketCategory {
+	categories := []*TicketCategory{}
+	for _, category := range self.readList(categoryListKey) {
+		if ret := self.TicketCategory(category); ret != nil {
+			categories = append(categories, ret)
+		}
+	}
+	return categories
+}
+
+// Indexer implements a core.ChainIndexer, building the registry from the
+// issuances stored with the blocks.
+type Indexer struct {
+	*Registry
+
+	section    uint64
+	gap        uint64 // Last block of the section without stored issuances
+	issuances  []sectionIssuance
+	tokens     map[string]*Token
+	categories map[string]*TicketCategory
+}
+
+// NewIndexer returns a chain indexer building the registry of the canonical
+// chain.
+func NewIndexer(chainDb serodb.Database) *core.ChainIndexer {
+	backend := &Indexer{Registry: New(chainDb)}
+	return core.NewChainIndexer(chainDb, backend.db, backend, SectionSize, registryConfirms, registryThrottling, "registry")
+}
+
+func encodeNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+func sectionLogKey(section uint64) []byte {
+	key := make([]byte, len(sectionLogPrefix)+8)
+	copy(key, sectionLogPrefix)
+	binary.BigEndian.PutUint64(key[len(sectionLogPrefix):], section)
+	return key
+}
+
+// Reset implements core.ChainIndexerBackend, undoing the sections processed
+// from the given one on, if any, before starting it.
+func (self *Indexer) Reset(section uint64, prevHead common.Hash) error {
+	self.section, self.gap, self.issuances = section, 0, nil
+	self.tokens, self.categories = make(map[string]*Token), make(map[string]*TicketCategory)
+
+	var logs [][]sectionIssuance
+	for s := section; ; s++ {
+		data, _ := self.db.Get(sectionLogKey(s))
+		if len(data) == 0 {
+			break
+		}
+		var issuances []sectionIssuance
+		if err := rlp.DecodeBytes(data, &issuances); err != nil {
+			return err
+		}
+		logs = append(logs, issuances)
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+	tokens, categories := self.readList(tokenListKey), self.readList(categoryListKey)
+	batch := self.db.NewBatch()
+	for i := len(logs) - 1; i >= 0; i-- {
+		for j := len(logs[i]) - 1; j >= 0; j-- {
+			number, issuance := logs[i][j].BlockNumber, logs[i][j].Issuance
+			if issuance.Currency != "" {
+				token := self.token(issuance.Currency)
+				if token == nil {
+					continue
+				}
+				token.TotalSupply.Sub(token.TotalSupply, issuance.Total)
+				if token.BlockNumber == number && token.TxHash == issuance.TxHash {
+					self.tokens[issuance.Currency] = nil
+					tokens = remove(tokens, issuance.Currency)
+					batch.Delete(append(common.CopyBytes(tokenPrefix), issuance.Currency...))
+				}
+			}
+			if issuance.Category != "" {
+				if category := self.category(issuance.Category); category != nil && category.BlockNumber == number {
+					self.categories[issuance.Category] = nil
+					categories = remove(categories, issuance.Category)
+					batch.Delete(append(common.CopyBytes(categoryPrefix), issuance.Category...))
+				}
+			}
+		}
+		batch.Delete(sectionLogKey(section + uint64(i)))
+	}
+	for currency, token := range self.tokens {
+		if token == nil {
+			continue
+		}
+		data, err := rlp.EncodeToBytes(token)
+		if err != nil {
+			return err
+		}
+		batch.Put(append(common.CopyBytes(tokenPrefix), currency...), data)
+	}
+	if err := self.write(batch, tokens, categories); err != nil {
+		return err
+	}
+	self.tokens, self.categories = make(map[string]*Token), make(map[string]*TicketCategory)
+	return nil
+}
+
+// token returns the token of the section being processed, nil if unknown or
+// undone.
+func (self *Indexer) token(currency string) *Token {
+	if token, ok := self.tokens[currency]; ok {
+		return token
+	}
+	token := self.Token(currency)
+	if token != nil {
+		self.tokens[currency] = token
+	}
+	return token
+}
+
+// category returns the ticket category of the section being processed, nil
+// if unknown or undone.
+func (self *Indexer) category(name string) *TicketCategory {
+	if category, ok := self.categories[name]; ok {
+		return category
+	}
+	category := self.TicketCategory(name)
+	if category != nil {
+		self.categories[name] = category
+	}
+	return category
+}
+
+// Process implements core.ChainIndexerBackend, adding the issuances of a new
+// header into the registry.
+func (self *Indexer) Process(header *types.Header) {
+	number := header.Number.Uint64()
+	// The genesis block issues nothing and has no issuances stored
+	if number > 0 && !rawdb.HasIssuances(self.chainDb, header.Hash(), number) {
+		self.gap = number
+	}
+	for _, issuance := range rawdb.ReadIssuances(self.chainDb, header.Hash(), number) {
+		self.issuances = append(self.issuances, sectionIssuance{number, issuance})
+		if issuance.Currency != "" {
+			token := self.token(issuance.Currency)
+			if token == nil {
+				token = &Token{
+					Currency:    issuance.Currency,
+					Contract:    issuance.Contract,
+					BlockNumber: number,
+					TxHash:      issuance.TxHash,
+					TotalSupply: new(big.Int),
+				}
+				self.tokens[issuance.Currency] = token
+			}
+			token.TotalSupply.Add(token.TotalSupply, issuance.Total)
+		}
+		if issuance.Category != "" && self.category(issuance.Category) == nil {
+			self.categories[issuance.Category] = &TicketCategory{
+				Category:    issuance.Category,
+				Contract:    issuance.Contract,
+				BlockNumber: number,
+				TxHash:      issuance.TxHash,
+			}
+		}
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, writing the updated tokens and
+// ticket categories of the section into the database.
+func (self *Indexer) Commit() error {
+	batch := self.db.NewBatch()
+	tokens, categories := self.readList(tokenListKey), self.readList(categoryListKey)
+	for currency, token := range self.tokens {
+		data, err := rlp.EncodeToBytes(token)
+		if err != nil {
+			return err
+		}
+		batch.Put(append(common.CopyBytes(tokenPrefix), currency...), data)
+		tokens = insert(tokens, currency)
+	}
+	for name, category := range self.categories {
+		data, err := rlp.EncodeToBytes(category)
+		if err != nil {
+			return err
+		}
+		batch.Put(append(common.CopyBytes(categoryPrefix), name...), data)
+		categories = insert(categories, name)
+	}
+	// The section log is written even if empty, so that the undoing of the
+	// sections on reorgs can stop at the first missing one.
+	data, err := rlp.EncodeToBytes(self.issuances)
+	if err != nil {
+		return err
+	}
+	batch.Put(sectionLogKey(self.section), data)
+	// The gap is kept across reorgs, the blocks not executed being the old
+	// ones of a fast sync
+	if self.gap > self.readGap() {
+		batch.Put(gapKey, encodeNumber(self.gap))
+	}
+	return self.write(batch, tokens, categories)
+}
+
+// write stores the lists of the tokens and categories and flushes the batch.
+func (self *Indexer) write(batch serodb.Batch, tokens, categories []string) error {
+	data, err := rlp.EncodeToBytes(tokens)
+	if err != nil {
+		return err
+	}
+	batch.Put(tokenListKey, data)
+	if data, err = rlp.EncodeToBytes(categories); err != nil {
+		return err
+	}
+	batch.Put(categoryListKey, data)
+	return batch.Write()
+}
+
+// insert adds name to the sorted list if missing.
+func insert(list []string, name string) []string {
+	i := sort.SearchStrings(list, name)
+	if i < len(list) && list[i] == name {
+		return list
+	}
+	list = append(list, "")
+	copy(list[i+1:], list[i:])
+	list[i] = name
+	return list
+}
+
+// remove deletes name from the sorted list.
+func remove(list []string, name string) []string {
+	i := sort.SearchStrings(list, name)
+	if i < len(list) && list[i] == name {
+		return append(list[:i], list[i+1:]...)
+	}
+	return list
+}
@@ -0,0 +1,146 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package registry
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+// processSection runs the indexer over a section whose blocks made the given
+// issuances.
+func processSection(t *testing.T, db serodb.Database, indexer *Indexer, section uint64, issuances map[uint64][]*types.Issuance) {
+	if err := indexer.Reset(section, common.Hash{}); err != nil {
+		t.Fatalf("section %d reset failed: %v", section, err)
+	}
+	for i := uint64(0); i < SectionSize; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(section*SectionSize + i), Extra: []byte("test")}
+		rawdb.DeleteIssuances(db, header.Hash(), header.Number.Uint64())
+		rawdb.WriteIssuances(db, header.Hash(), header.Number.Uint64(), issuances[header.Number.Uint64()])
+		indexer.Process(header)
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("section %d commit failed: %v", section, err)
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	db := serodb.NewMemDatabase()
+	indexer := &Indexer{Registry: New(db)}
+	contract := common.BytesToAddress([]byte("contract"))
+
+	processSection(t, db, indexer, 0, map[uint64][]*types.Issuance{
+		3: {
+			{Contract: contract, Currency: "BBB", Total: big.NewInt(100), TxHash: common.HexToHash("0x01")},
+			{Contract: contract, Currency: "AAA", Total: big.NewInt(5), TxHash: common.HexToHash("0x01")},
+		},
+		7: {{Contract: contract, Category: "CAT", Total: new(big.Int), TxHash: common.HexToHash("0x02")}},
+	})
+	processSection(t, db, indexer, 1, map[uint64][]*types.Issuance{
+		SectionSize + 1: {{Contract: contract, Currency: "BBB", Total: big.NewInt(50), TxHash: common.HexToHash("0x03")}},
+		SectionSize + 2: {{Contract: contract, Currency: "CCC", Total: big.NewInt(1), TxHash: common.HexToHash("0x04")}},
+	})
+
+	tokens := indexer.Tokens()
+	if len(tokens) != 3 || tokens[0].Currency != "AAA" || tokens[1].Currency != "BBB" || tokens[2].Currency != "CCC" {
+		t.Fatalf("token list mismatch: %v", tokens)
+	}
+	if bbb := tokens[1]; bbb.TotalSupply.Int64() != 150 || bbb.BlockNumber != 3 || bbb.Contract != contract {
+		t.Errorf("token mismatch: %+v", bbb)
+	}
+	if categories := indexer.TicketCategories(); len(categories) != 1 || categories[0].Category != "CAT" || categories[0].BlockNumber != 7 {
+		t.Errorf("category list mismatch: %v", categories)
+	}
+
+	// Reprocessing the second section after a reorg undoes it first
+	processSection(t, db, indexer, 1, nil)
+	if token := indexer.Token("CCC"); token != nil {
+		t.Errorf("reorged token still registered: %+v", token)
+	}
+	if token := indexer.Token("BBB"); token == nil || token.TotalSupply.Int64() != 100 {
+		t.Errorf("reorged issuance not undone: %+v", token)
+	}
+	if tokens := indexer.Tokens(); len(tokens) != 2 {
+		t.Errorf("token count mismatch: have %d, want 2", len(tokens))
+	}
+
+	// Reprocessing from the first section wipes everything
+	processSection(t, db, indexer, 0, nil)
+	if tokens, categories := indexer.Tokens(), indexer.TicketCategories(); len(tokens) != 0 || len(categories) != 0 {
+		t.Errorf("registry not emptied: %v %v", tokens, categories)
+	}
+}
+
+func TestIncompleteHistory(t *testing.T) {
+	db := serodb.NewMemDatabase()
+	indexer := &Indexer{Registry: New(db)}
+	api := NewPublicRegistryAPI(indexer.Registry)
+	contract := common.BytesToAddress([]byte("contract"))
+
+	// A chain whose blocks were all executed is complete
+	processSection(t, db, indexer, 0, nil)
+	if tokens := api.GetTokens(); !tokens.Complete || tokens.FromBlock != 0 || len(tokens.Tokens) != 0 {
+		t.Fatalf("complete chain mismatch: %+v", tokens)
+	}
+	// A chain fast synced up to a pivot block is not
+	if err := indexer.Reset(1, common.Hash{}); err != nil {
+		t.Fatalf("section reset failed: %v", err)
+	}
+	for i := uint64(0); i < SectionSize; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(SectionSize + i), Extra: []byte("test")}
+		if i >= 8 {
+			var issuances []*types.Issuance
+			if i == 10 {
+				issuances = []*types.Issuance{
+					{Contract: contract, Currency: "AAA", Total: big.NewInt(5), TxHash: common.HexToHash("0x01")},
+					{Contract: contract, Category: "CAT", Total: new(big.Int), TxHash: common.HexToHash("0x01")},
+				}
+			}
+			rawdb.WriteIssuances(db, header.Hash(), header.Number.Uint64(), issuances)
+		}
+		indexer.Process(header)
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("section commit failed: %v", err)
+	}
+	from := uint64(SectionSize + 8)
+	if tokens := api.GetTokens(); tokens.Complete || uint64(tokens.FromBlock) != from || len(tokens.Tokens) != 1 {
+		t.Errorf("partial token list mismatch: %+v", tokens)
+	}
+	if categories := api.GetTicketCategories(); categories.Complete || uint64(categories.FromBlock) != from || len(categories.Categories) != 1 {
+		t.Errorf("partial category list mismatch: %+v", categories)
+	}
+	if info, err := api.GetTokenInfo("aaa"); err != nil || info.Complete || uint64(info.FromBlock) != from || info.TotalSupply.ToInt().Int64() != 5 {
+		t.Errorf("partial token info mismatch: %+v, %v", info, err)
+	}
+	if _, err := api.GetTokenInfo("BBB"); err == nil {
+		t.Errorf("unknown token found")
+	}
+	if info, err := api.GetTokenInfo("SERO"); err != nil || !info.Complete {
+		t.Fatalf("SERO info mismatch: %+v, %v", info, err)
+	}
+	// Nor once the section is indexed again
+	processSection(t, db, indexer, 1, nil)
+	if tokens := api.GetTokens(); tokens.Complete || uint64(tokens.FromBlock) != from {
+		t.Fatalf("reindexed chain mismatch: %+v", tokens)
+	}
+}
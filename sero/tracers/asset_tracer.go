@@ -0,0 +1,155 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+)
+
+// assetFlow is a movement or an issuance of tokens and tickets.
+type assetFlow struct {
+	Type     string          `json:"type"` // call, create, send or issue
+	From     *common.Address `json:"from,omitempty"`
+	To       common.Address  `json:"to"`
+	Depth    int             `json:"depth"`
+	Asset    *assetJSON      `json:"asset"`
+	Reverted bool            `json:"reverted,omitempty"`
+}
+
+// pendingIssue is an issuance waiting for its system call result.
+type pendingIssue struct {
+	flow   *assetFlow
+	depth  int
+	offset uint64
+}
+
+// assetTracer reports the flat list of the assets transferred by the calls of
+// a transaction, by the send system calls of its contracts and the tokens they
+// issue. Flows undone by a failed call are flagged as reverted.
+type assetTracer struct {
+	interrupt
+	flows    []*assetFlow
+	frames   []int // index of the first flow of each open call
+	syscalls vm.SysCallTracer
+	sending  bool
+	issue    *pendingIssue
+}
+
+func newAssetTracer() *assetTracer {
+	return &assetTracer{}
+}
+
+func (self *assetTracer) enter(typ string, from common.Address, to common.Address, asset *assets.Asset) {
+	self.frames = append(self.frames, len(self.flows))
+	if flow := newAssetJSON(asset); flow != nil {
+		self.flows = append(self.flows, &assetFlow{Type: typ, From: &from, To: to, Depth: len(self.frames) - 1, Asset: flow})
+	}
+}
+
+func (self *assetTracer) exit(err error) {
+	if len(self.frames) == 0 {
+		return
+	}
+	start := self.frames[len(self.frames)-1]
+	self.frames = self.frames[:len(self.frames)-1]
+	self.issue = nil
+	if err != nil {
+		for _, flow := range self.flows[start:] {
+			flow.Reverted = true
+		}
+	}
+}
+
+func (self *assetTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, asset *assets.Asset) error {
+	typ := "call"
+	if create {
+		typ = "create"
+	}
+	self.enter(typ, from, to, asset)
+	return nil
+}
+
+func (self *assetTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	self.check(env)
+	self.sending = false
+	if self.issue != nil && self.issue.depth == depth {
+		// The issuance result is written back to the last word of the LOG data
+		if self.issue.offset+32 <= uint64(memory.Len()) && new(big.Int).SetBytes(memory.GetPtr(int64(self.issue.offset), 32)).Sign() > 0 {
+			self.flows = append(self.flows, self.issue.flow)
+		}
+		self.issue = nil
+	}
+	n := len(self.syscalls.Calls)
+	self.syscalls.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	for _, record := range self.syscalls.Calls[n:] {
+		switch call := record.Call.(type) {
+		case vm.Send:
+			self.sending = true
+		case vm.IssueToken:
+			flow := &assetFlow{
+				Type:  "issue",
+				To:    record.Contract,
+				Depth: len(self.frames) - 1,
+				Asset: &assetJSON{Currency: call.Currency, Value: (*hexutil.Big)(call.Total)},
+			}
+			self.issue = &pendingIssue{flow, depth, stack.Back(0).Uint64() + stack.Back(1).Uint64() - 32}
+		}
+	}
+	return nil
+}
+
+func (self *assetTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (self *assetTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, asset *assets.Asset) {
+	switch {
+	case self.sending:
+		self.enter("send", from, to, asset)
+	case typ == vm.CREATE:
+		self.enter("create", from, to, asset)
+	default:
+		self.enter("call", from, to, asset)
+	}
+	self.sending = false
+}
+
+func (self *assetTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	self.exit(err)
+}
+
+func (self *assetTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	self.exit(err)
+	return nil
+}
+
+func (self *assetTracer) GetResult() (json.RawMessage, error) {
+	if err := self.stopped(); err != nil {
+		return nil, err
+	}
+	if self.flows == nil {
+		return json.Marshal([]*assetFlow{})
+	}
+	return json.Marshal(self.flows)
+}
@@ -0,0 +1,123 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+)
+
+// callFrame is a call of the transaction along with its nested calls.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Asset   *assetJSON     `json:"asset,omitempty"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Calls   []*callFrame   `json:"calls,omitempty"`
+}
+
+func newCallFrame(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, asset *assets.Asset) *callFrame {
+	return &callFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    to,
+		Asset: newAssetJSON(asset),
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+}
+
+func (self *callFrame) finish(output []byte, gasUsed uint64, err error) {
+	self.GasUsed = hexutil.Uint64(gasUsed)
+	if err != nil {
+		self.Error = err.Error()
+		return
+	}
+	self.Output = common.CopyBytes(output)
+}
+
+// callTracer reports the tree of the calls made by a transaction, with the
+// tokens and tickets each of them carries.
+type callTracer struct {
+	interrupt
+	stack []*callFrame
+}
+
+func newCallTracer() *callTracer {
+	return &callTracer{}
+}
+
+func (self *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, asset *assets.Asset) error {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	self.stack = []*callFrame{newCallFrame(typ, from, to, input, gas, asset)}
+	return nil
+}
+
+func (self *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	self.check(env)
+	return nil
+}
+
+func (self *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (self *callTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, asset *assets.Asset) {
+	self.stack = append(self.stack, newCallFrame(typ, from, to, input, gas, asset))
+}
+
+func (self *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if len(self.stack) < 2 {
+		return
+	}
+	frame := self.stack[len(self.stack)-1]
+	frame.finish(output, gasUsed, err)
+
+	self.stack = self.stack[:len(self.stack)-1]
+	parent := self.stack[len(self.stack)-1]
+	parent.Calls = append(parent.Calls, frame)
+}
+
+func (self *callTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	if len(self.stack) > 0 {
+		self.stack[0].finish(output, gasUsed, err)
+	}
+	return nil
+}
+
+func (self *callTracer) GetResult() (json.RawMessage, error) {
+	if err := self.stopped(); err != nil {
+		return nil, err
+	}
+	if len(self.stack) != 1 {
+		return nil, errIncompleteTrace
+	}
+	return json.Marshal(self.stack[0])
+}
@@ -0,0 +1,110 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// ResultTracer is a transaction tracer producing a JSON result, either one of
+// the native Go tracers or a JavaScript one.
+type ResultTracer interface {
+	vm.Tracer
+	GetResult() (json.RawMessage, error)
+	Stop(err error)
+}
+
+var errIncompleteTrace = errors.New("incomplete trace")
+
+// natives contains the constructors of the native tracers by name. They take
+// precedence over the JavaScript tracers of the same name.
+var natives = map[string]func() ResultTracer{
+	"callTracer":     func() ResultTracer { return newCallTracer() },
+	"prestateTracer": func() ResultTracer { return newPrestateTracer() },
+	"assetTracer":    func() ResultTracer { return newAssetTracer() },
+}
+
+// NewTracer returns the native tracer of the given name, or else the
+// JavaScript tracer defined by code, which can also be a built-in name.
+func NewTracer(code string) (ResultTracer, error) {
+	if ctor, ok := natives[code]; ok {
+		return ctor(), nil
+	}
+	return New(code)
+}
+
+// assetJSON is the JSON representation of an asset.
+type assetJSON struct {
+	Currency string       `json:"currency,omitempty"`
+	Value    *hexutil.Big `json:"value,omitempty"`
+	Category string       `json:"category,omitempty"`
+	Ticket   *common.Hash `json:"ticket,omitempty"`
+}
+
+// newAssetJSON converts asset, returning nil if it carries nothing.
+func newAssetJSON(asset *assets.Asset) *assetJSON {
+	if asset == nil || (asset.Tkn == nil && asset.Tkt == nil) {
+		return nil
+	}
+	ret := &assetJSON{}
+	if asset.Tkn != nil {
+		ret.Currency = utils.Uint256ToCurrency(&asset.Tkn.Currency)
+		ret.Value = (*hexutil.Big)(new(big.Int).Set(asset.Tkn.Value.ToInt()))
+	}
+	if asset.Tkt != nil {
+		ticket := common.BytesToHash(asset.Tkt.Value[:])
+		ret.Category = utils.Uint256ToCurrency(&asset.Tkt.Category)
+		ret.Ticket = &ticket
+	}
+	return ret
+}
+
+// interrupt implements the stopping of the native tracers.
+type interrupt struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (self *interrupt) Stop(err error) {
+	self.reason = err
+	atomic.StoreUint32(&self.interrupt, 1)
+}
+
+// check aborts the execution if the tracer was stopped.
+func (self *interrupt) check(env *vm.EVM) {
+	if atomic.LoadUint32(&self.interrupt) > 0 {
+		env.Cancel()
+	}
+}
+
+// stopped returns the reason the tracer was stopped for, if any.
+func (self *interrupt) stopped() error {
+	if atomic.LoadUint32(&self.interrupt) > 0 {
+		return self.reason
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/core/vm/runtime"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+)
+
+var (
+	testOrigin = common.BytesToAddress([]byte("origin"))
+	testCaller = common.BytesToAddress([]byte("caller"))
+	testCallee = common.BytesToAddress([]byte("callee"))
+)
+
+// runNative calls a contract sending 10 SERO to a callee sending back 5 of them
+// before reverting if revert is set, and returns the result of the tracer.
+func runNative(t *testing.T, name string, revert bool, result interface{}) {
+	statedb, _ := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	callee := testCallee.ToCaddr()
+	statedb.AddNonceAddress(callee[:], testCallee)
+	statedb.AddBalance(testOrigin, "SERO", big.NewInt(10))
+
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 5,
+		byte(vm.PUSH20),
+	}
+	code = append(code, callee[:]...)
+	code = append(code, byte(vm.PUSH2), 0xff, 0xff, byte(vm.CALL), byte(vm.STOP))
+	statedb.SetCode(testCaller, code)
+	if revert {
+		statedb.SetCode(testCallee, []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)})
+	} else {
+		statedb.SetCode(testCallee, []byte{byte(vm.STOP)})
+	}
+
+	tracer, err := NewTracer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset := assets.NewAssetByName("SERO", big.NewInt(10), "", keys.Uint256{})
+	cfg := &runtime.Config{
+		Origin:    testOrigin,
+		State:     statedb,
+		Asset:     &asset,
+		EVMConfig: vm.Config{Debug: true, Tracer: tracer},
+	}
+	if _, _, err := runtime.Call(testCaller, nil, cfg); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if err := json.Unmarshal(res, result); err != nil {
+		t.Fatalf("%s result %s malformed: %v", name, res, err)
+	}
+}
+
+func TestNativeCallTracer(t *testing.T) {
+	var frame callFrame
+	runNative(t, "callTracer", false, &frame)
+
+	if frame.Type != "CALL" || frame.To != testCaller || frame.Asset == nil || frame.Asset.Currency != "SERO" || frame.Asset.Value.ToInt().Int64() != 10 {
+		t.Fatalf("root frame mismatch: %+v", frame)
+	}
+	if len(frame.Calls) != 1 {
+		t.Fatalf("nested call count mismatch: have %d, want 1", len(frame.Calls))
+	}
+	inner := frame.Calls[0]
+	if inner.From != testCaller || inner.To != testCallee || inner.Asset == nil || inner.Asset.Value.ToInt().Int64() != 5 {
+		t.Errorf("nested frame mismatch: %+v", inner)
+	}
+}
+
+func TestNativeAssetTracer(t *testing.T) {
+	var flows []assetFlow
+	runNative(t, "assetTracer", true, &flows)
+
+	if len(flows) != 2 {
+		t.Fatalf("flow count mismatch: have %d, want 2", len(flows))
+	}
+	if flows[0].To != testCaller || flows[0].Depth != 0 || flows[0].Reverted || flows[0].Asset.Value.ToInt().Int64() != 10 {
+		t.Errorf("root flow mismatch: %+v", flows[0])
+	}
+	if flows[1].To != testCallee || flows[1].Depth != 1 || !flows[1].Reverted || flows[1].Asset.Value.ToInt().Int64() != 5 {
+		t.Errorf("reverted flow mismatch: %+v", flows[1])
+	}
+}
+
+func TestNativePrestateTracer(t *testing.T) {
+	var prestate map[common.Address]*prestateAccount
+	runNative(t, "prestateTracer", false, &prestate)
+
+	caller, callee := prestate[testCaller], prestate[testCallee]
+	if caller == nil || callee == nil {
+		t.Fatalf("touched contracts missing: %v", prestate)
+	}
+	if balance := caller.Balance["SERO"]; balance == nil || balance.ToInt().Sign() != 0 {
+		t.Errorf("caller balance mismatch: have %v, want 0", balance)
+	}
+	if balance := prestate[testOrigin].Balance["SERO"]; balance == nil || balance.ToInt().Int64() != 10 {
+		t.Errorf("origin balance mismatch: have %v, want 10", balance)
+	}
+	if len(callee.Code) != 1 {
+		t.Errorf("callee code mismatch: %x", callee.Code)
+	}
+}
@@ -0,0 +1,157 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// prestateAccount is the state of a contract before the transaction.
+type prestateAccount struct {
+	Balance map[string]*hexutil.Big     `json:"balance"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateTracer reports the balances of every currency, the code and the
+// storage slots of the contracts a transaction touches, as they were before
+// the transaction. Plain accounts hold their assets in the zero state and are
+// not reported.
+type prestateTracer struct {
+	interrupt
+	prestate map[common.Address]*prestateAccount
+
+	from  common.Address
+	to    common.Address
+	asset *assets.Asset
+	ready bool
+}
+
+func newPrestateTracer() *prestateTracer {
+	return &prestateTracer{prestate: make(map[common.Address]*prestateAccount)}
+}
+
+// lookupAccount records the state of the contract at addr if not done yet.
+func (self *prestateTracer) lookupAccount(db vm.StateDB, addr common.Address) *prestateAccount {
+	if account, ok := self.prestate[addr]; ok || !db.IsContract(addr) {
+		return account
+	}
+	account := &prestateAccount{
+		Balance: make(map[string]*hexutil.Big),
+		Code:    common.CopyBytes(db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	for currency, balance := range db.Balances(addr) {
+		account.Balance[currency] = (*hexutil.Big)(new(big.Int).Set(balance))
+	}
+	self.prestate[addr] = account
+	return account
+}
+
+// lookupStorage records the storage slot of the contract at addr if not done yet.
+func (self *prestateTracer) lookupStorage(db vm.StateDB, addr common.Address, key common.Hash) {
+	account := self.lookupAccount(db, addr)
+	if account == nil {
+		return
+	}
+	if _, ok := account.Storage[key]; !ok {
+		account.Storage[key] = db.GetState(addr, key)
+	}
+}
+
+// adjust moves the token transferred by the transaction itself back to its
+// sender, since it is already transferred when the first step is traced.
+func (self *prestateTracer) adjust(db vm.StateDB) {
+	to, from := self.lookupAccount(db, self.to), self.lookupAccount(db, self.from)
+	if self.asset == nil || self.asset.Tkn == nil {
+		return
+	}
+	currency, amount := utils.Uint256ToCurrency(&self.asset.Tkn.Currency), self.asset.Tkn.Value.ToInt()
+	if to != nil {
+		if balance := to.Balance[currency]; balance != nil {
+			to.Balance[currency] = (*hexutil.Big)(new(big.Int).Sub(balance.ToInt(), amount))
+		}
+	}
+	if from != nil {
+		balance := new(big.Int)
+		if from.Balance[currency] != nil {
+			balance.Set(from.Balance[currency].ToInt())
+		}
+		from.Balance[currency] = (*hexutil.Big)(balance.Add(balance, amount))
+	}
+}
+
+func (self *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, asset *assets.Asset) error {
+	self.from, self.to, self.asset = from, to, asset
+	return nil
+}
+
+func (self *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	self.check(env)
+	if err != nil {
+		return nil
+	}
+	if !self.ready {
+		self.adjust(env.StateDB)
+		self.ready = true
+	}
+	switch {
+	case op == vm.SLOAD || op == vm.SSTORE:
+		if len(stack.Data()) > 0 {
+			self.lookupStorage(env.StateDB, contract.Address(), common.BigToHash(stack.Back(0)))
+		}
+	case op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL:
+		if len(stack.Data()) > 1 {
+			self.lookupAccount(env.StateDB, contract.GetNonceAddress(env.StateDB, common.BigToContractAddress(stack.Back(1))))
+		}
+	case op >= vm.LOG1 && op <= vm.LOG4:
+		// The recipient of a send system call
+		if len(stack.Data()) < 3 || common.BigToHash(stack.Back(2)) != vm.TopicSend {
+			return nil
+		}
+		start, size := stack.Back(0), stack.Back(1)
+		if !start.IsUint64() || size.Cmp(big.NewInt(32)) < 0 || memory.Len() < 32 || start.Uint64() > uint64(memory.Len()-32) {
+			return nil
+		}
+		to := memory.GetPtr(start.Int64(), 32)
+		self.lookupAccount(env.StateDB, env.StateDB.GetNonceAddress(to[12:32]))
+	}
+	return nil
+}
+
+func (self *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (self *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+func (self *prestateTracer) GetResult() (json.RawMessage, error) {
+	if err := self.stopped(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(self.prestate)
+}
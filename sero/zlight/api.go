@@ -0,0 +1,64 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/internal/ethapi"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/light"
+)
+
+// PublicLightAPI serves the zero wallets from the light client, with the same
+// methods as the light node API of the full nodes.
+type PublicLightAPI struct {
+	ls *LightSero
+}
+
+// BlockNumber returns the number of the head of the synced headers.
+func (self *PublicLightAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(self.ls.CurrentHeader().Number.Uint64())
+}
+
+// GetOutsByPKr returns the outputs received by the addresses from the start
+// block to the end one.
+func (self *PublicLightAPI) GetOutsByPKr(ctx context.Context, addresses []*ethapi.MixAdrress, start, end uint64) (light.BlockOutResp, error) {
+	pkrs := []keys.PKr{}
+	for _, address := range addresses {
+		if address == nil || len(*address) != 96 {
+			return light.BlockOutResp{}, fmt.Errorf("address is invalid")
+		}
+		var pkr keys.PKr
+		copy(pkr[:], (*address)[:])
+		pkrs = append(pkrs, pkr)
+	}
+	return self.ls.GetOuts(pkrs, start, end)
+}
+
+// CheckNil returns the given nils which are spent.
+func (self *PublicLightAPI) CheckNil(Nils []keys.Uint256) ([]light.NilValue, error) {
+	return self.ls.CheckNils(Nils)
+}
+
+// GetAnchor returns the witnesses of the outputs of the given roots.
+func (self *PublicLightAPI) GetAnchor(roots []keys.Uint256) ([]txtool.Witness, error) {
+	return self.ls.GetWitnesses(roots)
+}
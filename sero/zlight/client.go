@@ -0,0 +1,577 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/consensus"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/node"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/sero"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/light"
+)
+
+const (
+	requestTimeout  = 10 * time.Second // Maximum time to wait for the response of a server
+	forceSyncCycle  = 10 * time.Second // Time interval to force syncs, even without announces
+	maxRequestTries = 3                // Maximum number of servers a request is sent to
+)
+
+var (
+	errNoServer       = errors.New("no zlight server connected")
+	errRequestTimeout = errors.New("request timed out")
+	errQuitting       = errors.New("light client quitting")
+)
+
+// pendingRequest is a request waiting for the response of a server.
+type pendingRequest struct {
+	peer string
+	resp chan interface{}
+}
+
+// LightSero is the light client of the zero wallets. It syncs the block headers
+// only, and fetches the outputs, the nil checks and the witnesses from the
+// zlight servers, verifying them against the headers.
+type LightSero struct {
+	config    *sero.Config
+	chainDb   serodb.Database
+	engine    consensus.Engine
+	hc        *core.HeaderChain
+	chainmu   sync.Mutex // Serialises the header imports
+	networkId uint64
+
+	peers       *peerSet
+	reqID       uint64 // Last request id, accessed atomically
+	pending     map[uint64]*pendingRequest
+	pendingLock sync.Mutex
+
+	syncCh chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates the light client service, in place of the full node when syncing
+// in light mode.
+func New(ctx *node.ServiceContext, config *sero.Config) (*LightSero, error) {
+	chainDb, err := sero.CreateDB(ctx, config, "lightchaindata")
+	if err != nil {
+		return nil, err
+	}
+	chainConfig, _, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
+	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
+		return nil, genesisErr
+	}
+	log.Info("Initialised chain configuration", "config", chainConfig)
+
+	self := &LightSero{
+		config:    config,
+		chainDb:   chainDb,
+		engine:    sero.CreateConsensusEngine(ctx, &config.Ethash, chainConfig, chainDb),
+		networkId: config.NetworkId,
+		peers:     newPeerSet(),
+		pending:   make(map[uint64]*pendingRequest),
+		syncCh:    make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+	}
+	if self.hc, err = core.NewHeaderChain(chainDb, chainConfig, self.engine, self.interrupted); err != nil {
+		return nil, err
+	}
+	// The headers are written without their blocks, resume from the head header
+	if head := rawdb.ReadHeadHeaderHash(chainDb); head != (common.Hash{}) {
+		if header := self.hc.GetHeaderByHash(head); header != nil {
+			self.hc.SetCurrentHeader(header)
+		}
+	}
+	log.Info("Initialising zlight client", "versions", ProtocolVersions, "network", config.NetworkId, "head", self.hc.CurrentHeader().Number)
+	return self, nil
+}
+
+func (self *LightSero) interrupted() bool {
+	select {
+	case <-self.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// Protocols implements node.Service.
+func (self *LightSero) Protocols() []p2p.Protocol {
+	return makeProtocols(self.peers, self.handle)
+}
+
+// APIs implements node.Service, serving the light node API of the zero wallets.
+func (self *LightSero) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "light",
+			Version:   "1.0",
+			Service:   &PublicLightAPI{self},
+			Public:    true,
+		},
+	}
+}
+
+// Start implements node.Service.
+func (self *LightSero) Start(srvr *p2p.Server) error {
+	self.wg.Add(1)
+	go self.syncLoop()
+	return nil
+}
+
+// Stop implements node.Service.
+func (self *LightSero) Stop() error {
+	close(self.quit)
+	self.peers.Close()
+	self.wg.Wait()
+	self.chainDb.Close()
+	log.Info("Zlight client stopped")
+	return nil
+}
+
+// CurrentHeader returns the head of the synced headers.
+func (self *LightSero) CurrentHeader() *types.Header {
+	return self.hc.CurrentHeader()
+}
+
+// handle is the callback invoked to manage the life cycle of a server. When
+// this function terminates, the peer is disconnected.
+func (self *LightSero) handle(p *peer) error {
+	var (
+		genesis = self.hc.GetHeaderByNumber(0)
+		head    = self.hc.CurrentHeader()
+		hash    = head.Hash()
+		number  = head.Number.Uint64()
+		td      = self.hc.GetTd(hash, number)
+	)
+	if err := p.Handshake(self.networkId, td, hash, number, genesis.Hash(), false); err != nil {
+		p.Log().Debug("Zlight handshake failed", "err", err)
+		return err
+	}
+	if err := self.peers.Register(p); err != nil {
+		return err
+	}
+	defer self.peers.Unregister(p.id)
+
+	self.wg.Add(1)
+	defer self.wg.Done()
+
+	p.Log().Debug("Zlight server connected", "name", p.Name())
+	self.triggerSync()
+	for {
+		if err := self.handleMsg(p); err != nil {
+			p.Log().Debug("Zlight message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg handles the next announce or response of a server. The connection
+// is torn down upon returning any error.
+func (self *LightSero) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case StatusMsg:
+		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+
+	case AnnounceMsg:
+		var announce announceData
+		if err := msg.Decode(&announce); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if announce.TD == nil {
+			return errResp(ErrDecode, "announce without total difficulty")
+		}
+		p.SetHead(announce.Hash, announce.Number, announce.TD)
+		self.triggerSync()
+
+	case BlockHeadersMsg:
+		var resp blockHeadersData
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		self.deliver(p, resp.ReqID, &resp)
+
+	case OutsMsg, WitnessesMsg:
+		var resp outsData
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		self.deliver(p, resp.ReqID, &resp)
+
+	case NilsMsg:
+		var resp nilsData
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		self.deliver(p, resp.ReqID, &resp)
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
+// deliver hands a response over to its pending request. Responses coming too
+// late or from another peer are dropped.
+func (self *LightSero) deliver(p *peer, reqID uint64, resp interface{}) {
+	self.pendingLock.Lock()
+	req := self.pending[reqID]
+	self.pendingLock.Unlock()
+
+	if req == nil || req.peer != p.id {
+		p.Log().Debug("Dropped unrequested zlight response", "reqid", reqID)
+		return
+	}
+	select {
+	case req.resp <- resp:
+	default:
+	}
+}
+
+// request sends a request to a server, and waits for its response.
+func (self *LightSero) request(p *peer, reqID uint64, code uint64, data interface{}) (interface{}, error) {
+	req := &pendingRequest{peer: p.id, resp: make(chan interface{}, 1)}
+	self.pendingLock.Lock()
+	self.pending[reqID] = req
+	self.pendingLock.Unlock()
+
+	defer func() {
+		self.pendingLock.Lock()
+		delete(self.pending, reqID)
+		self.pendingLock.Unlock()
+	}()
+
+	if err := p2p.Send(p.rw, code, data); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case resp := <-req.resp:
+		return resp, nil
+	case <-timeout.C:
+		return nil, errRequestTimeout
+	case <-self.quit:
+		return nil, errQuitting
+	}
+}
+
+func (self *LightSero) nextReqID() uint64 {
+	return atomic.AddUint64(&self.reqID, 1)
+}
+
+// retry runs a request on the best servers until one of them succeeds.
+// Servers whose responses do not verify are dropped.
+func (self *LightSero) retry(run func(p *peer) error) error {
+	peers := self.peers.AllPeers()
+	sort.Slice(peers, func(i, j int) bool {
+		_, _, tdi := peers[i].Head()
+		_, _, tdj := peers[j].Head()
+		return tdi.Cmp(tdj) > 0
+	})
+	err := errNoServer
+	for i, p := range peers {
+		if i == maxRequestTries {
+			break
+		}
+		if err = run(p); err == nil {
+			return nil
+		}
+		p.Log().Debug("Zlight request failed", "err", err)
+	}
+	return err
+}
+
+// dropPeer disconnects a server which sent invalid data. It is unregistered
+// right away so that no request is sent to it while disconnecting.
+func (self *LightSero) dropPeer(p *peer, err error) error {
+	p.Log().Warn("Dropping invalid zlight server", "err", err)
+	self.peers.Unregister(p.id)
+	p.Disconnect(p2p.DiscUselessPeer)
+	return err
+}
+
+// proofHeader returns the header the responses of a server are proven against:
+// the local head, or the head of the server if it is behind.
+func (self *LightSero) proofHeader(p *peer) *types.Header {
+	head := self.hc.CurrentHeader()
+	if _, number, _ := p.Head(); number < head.Number.Uint64() {
+		if header := self.hc.GetHeaderByNumber(number); header != nil {
+			return header
+		}
+	}
+	return head
+}
+
+func (self *LightSero) triggerSync() {
+	select {
+	case self.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+func (self *LightSero) syncLoop() {
+	defer self.wg.Done()
+
+	ticker := time.NewTicker(forceSyncCycle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-self.syncCh:
+		case <-ticker.C:
+		case <-self.quit:
+			return
+		}
+		self.synchronise()
+	}
+}
+
+// synchronise imports the headers of the best server, if its chain is heavier
+// than the local one.
+func (self *LightSero) synchronise() {
+	p := self.peers.BestPeer()
+	if p == nil {
+		return
+	}
+	_, number, td := p.Head()
+	head := self.hc.CurrentHeader()
+	if td.Cmp(self.hc.GetTd(head.Hash(), head.Number.Uint64())) <= 0 {
+		return
+	}
+	from := head.Number.Uint64() + 1
+	for from <= number && !self.interrupted() {
+		reqID := self.nextReqID()
+		resp, err := self.request(p, reqID, GetBlockHeadersMsg, &getBlockHeadersData{ReqID: reqID, Origin: from, Amount: MaxHeaderFetch})
+		if err != nil {
+			p.Log().Debug("Zlight header fetch failed", "err", err)
+			return
+		}
+		headers := resp.(*blockHeadersData).Headers
+		if len(headers) == 0 {
+			return
+		}
+		if headers[0].Number.Uint64() != from {
+			self.dropPeer(p, fmt.Errorf("headers from %d, requested from %d", headers[0].Number, from))
+			return
+		}
+		err = self.insertHeaders(headers)
+		if err == consensus.ErrUnknownAncestor && from > 1 {
+			// The server is on another branch, step back towards the common ancestor
+			if from > MaxHeaderFetch {
+				from -= MaxHeaderFetch
+			} else {
+				from = 1
+			}
+			continue
+		}
+		if err != nil {
+			self.dropPeer(p, err)
+			return
+		}
+		from = headers[len(headers)-1].Number.Uint64() + 1
+	}
+}
+
+// insertHeaders verifies a batch of headers and writes them into the header
+// chain.
+func (self *LightSero) insertHeaders(headers []*types.Header) error {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+
+	if _, err := self.hc.ValidateHeaderChain(headers, 1); err != nil {
+		return err
+	}
+	whFunc := func(header *types.Header) error {
+		_, err := self.hc.WriteHeader(header)
+		return err
+	}
+	_, err := self.hc.InsertHeaderChain(headers, whFunc, time.Now())
+	return err
+}
+
+// GetOuts returns the outputs received by the PKrs from the start block to the
+// end one, each of them proven to be in the Merkle tree of the synced state.
+// A server may look the outputs up to an earlier block than the end one, which
+// is returned as the current number, but not to an earlier one than the start
+// block. That a server left no output out can not be proven.
+func (self *LightSero) GetOuts(pkrs []keys.PKr, start, end uint64) (ret light.BlockOutResp, e error) {
+	if len(pkrs) > MaxPKrs {
+		e = fmt.Errorf("too many pkrs: %d > %d", len(pkrs), MaxPKrs)
+		return
+	}
+	if end < start {
+		e = fmt.Errorf("invalid block range [%d, %d]", start, end)
+		return
+	}
+	wanted := make(map[keys.PKr]bool)
+	for _, pkr := range pkrs {
+		wanted[pkr] = true
+	}
+	e = self.retry(func(p *peer) error {
+		header := self.proofHeader(p)
+		reqID := self.nextReqID()
+		res, err := self.request(p, reqID, GetOutsMsg, &getOutsData{ReqID: reqID, Block: header.Hash(), PKrs: pkrs, Start: start, End: end})
+		if err != nil {
+			return err
+		}
+		resp := res.(*outsData)
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if resp.End > end || resp.End > header.Number.Uint64() {
+			return self.dropPeer(p, fmt.Errorf("outputs looked up to %d, beyond %d", resp.End, end))
+		}
+		if resp.End < start {
+			return self.dropPeer(p, fmt.Errorf("outputs looked up to %d, before %d", resp.End, start))
+		}
+		if err := verifyOuts(header.Root, resp.Outs, resp.Proof); err != nil {
+			return self.dropPeer(p, err)
+		}
+		ret = light.BlockOutResp{CurrentNum: resp.End, BlockOuts: []light.BlockOut{}}
+		for _, out := range resp.Outs {
+			if !wanted[*out.Out.State.OS.ToPKr()] {
+				return self.dropPeer(p, fmt.Errorf("unrequested output %v", hexutil.Encode(out.Out.Root[:])))
+			}
+			if num := out.Out.State.Num; num < start || num > resp.End {
+				return self.dropPeer(p, fmt.Errorf("output %v of block %d out of range [%d, %d]", hexutil.Encode(out.Out.Root[:]), num, start, resp.End))
+			}
+			last := len(ret.BlockOuts) - 1
+			if last >= 0 && ret.BlockOuts[last].Num > out.Out.State.Num {
+				return self.dropPeer(p, fmt.Errorf("output %v of block %d after block %d", hexutil.Encode(out.Out.Root[:]), out.Out.State.Num, ret.BlockOuts[last].Num))
+			}
+			if last >= 0 && ret.BlockOuts[last].Num == out.Out.State.Num {
+				ret.BlockOuts[last].Outs = append(ret.BlockOuts[last].Outs, out.Out)
+			} else {
+				ret.BlockOuts = append(ret.BlockOuts, light.BlockOut{Num: out.Out.State.Num, Outs: []txtool.Out{out.Out}})
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// CheckNils returns the nils spent in the synced state, as proven by the
+// servers. The spending transactions are only filled from the hints of the
+// servers.
+func (self *LightSero) CheckNils(nils []keys.Uint256) (ret []light.NilValue, e error) {
+	for len(nils) > 0 {
+		batch := nils
+		if len(batch) > MaxNils {
+			batch = batch[:MaxNils]
+		}
+		nils = nils[len(batch):]
+
+		e = self.retry(func(p *peer) error {
+			header := self.proofHeader(p)
+			reqID := self.nextReqID()
+			res, err := self.request(p, reqID, GetNilsMsg, &getNilsData{ReqID: reqID, Block: header.Hash(), Nils: batch})
+			if err != nil {
+				return err
+			}
+			resp := res.(*nilsData)
+			if resp.Error != "" {
+				return errors.New(resp.Error)
+			}
+			spent, err := verifyNils(header.Root, batch, resp.Proof)
+			if err != nil {
+				return self.dropPeer(p, err)
+			}
+			hints := make(map[keys.Uint256]light.NilValue)
+			for _, hint := range resp.Spent {
+				hints[hint.Nil] = hint
+			}
+			for i, in := range batch {
+				if spent[i] {
+					value := hints[in]
+					value.Nil = in
+					ret = append(ret, value)
+				}
+			}
+			return nil
+		})
+		if e != nil {
+			return nil, e
+		}
+	}
+	return
+}
+
+// GetWitnesses returns the witnesses of the outputs of the given roots, each of
+// them proven to be in the Merkle tree of the synced state.
+func (self *LightSero) GetWitnesses(roots []keys.Uint256) (ret []txtool.Witness, e error) {
+	if len(roots) > MaxRoots {
+		e = fmt.Errorf("too many roots: %d > %d", len(roots), MaxRoots)
+		return
+	}
+	e = self.retry(func(p *peer) error {
+		header := self.proofHeader(p)
+		reqID := self.nextReqID()
+		res, err := self.request(p, reqID, GetWitnessesMsg, &getWitnessesData{ReqID: reqID, Block: header.Hash(), Roots: roots})
+		if err != nil {
+			return err
+		}
+		resp := res.(*outsData)
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if len(resp.Outs) != len(roots) {
+			return self.dropPeer(p, fmt.Errorf("%d witnesses for %d roots", len(resp.Outs), len(roots)))
+		}
+		for i, out := range resp.Outs {
+			if out.Out.Root != roots[i] {
+				return self.dropPeer(p, fmt.Errorf("unrequested witness %v", hexutil.Encode(out.Out.Root[:])))
+			}
+		}
+		if err := verifyOuts(header.Root, resp.Outs, resp.Proof); err != nil {
+			return self.dropPeer(p, err)
+		}
+		ret = make([]txtool.Witness, len(resp.Outs))
+		for i, out := range resp.Outs {
+			ret[i] = out.Witness
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,177 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+	"github.com/sero-cash/go-sero/zero/txs/zstate/txstate"
+	"github.com/sero-cash/go-sero/zero/txs/zstate/txstate/data"
+	"github.com/sero-cash/go-sero/zero/txs/zstate/txstate/data_v1"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+var (
+	nilSet  = utils.NewHSet(data.ZSTATE0_INNAME)
+	rootSet = utils.NewHSet(data_v1.ZSTATE0_ROOT_OUT)
+)
+
+// nilKey returns the key marking a nil as spent in the state trie.
+func nilKey(in *keys.Uint256) []byte {
+	return nilSet.K2Name(in)
+}
+
+// rootKeys returns the keys marking a root of the Merkle tree in the state
+// trie, the one of the roots added from SIP2 on and the one of the roots added
+// before.
+func rootKeys(root *keys.Uint256) [][]byte {
+	return [][]byte{rootSet.K2Name(root), data.OutName0(root)}
+}
+
+// prover is a state trie, able to prove the values of its keys.
+type prover interface {
+	Prove(key []byte, fromLevel uint, proofDb serodb.Putter) error
+}
+
+// nodeList gathers the state trie nodes of several proofs, keeping each of them
+// once.
+type nodeList struct {
+	nodes  [][]byte
+	hashes map[string]struct{}
+}
+
+func newNodeList() *nodeList {
+	return &nodeList{hashes: make(map[string]struct{})}
+}
+
+// Put implements serodb.Putter.
+func (self *nodeList) Put(key []byte, value []byte) error {
+	if _, ok := self.hashes[string(key)]; !ok {
+		self.hashes[string(key)] = struct{}{}
+		self.nodes = append(self.nodes, common.CopyBytes(value))
+	}
+	return nil
+}
+
+// prove adds the nodes proving the values, or the absence, of the given keys
+// in the state trie.
+func (self *nodeList) prove(tr prover, names ...[]byte) error {
+	for _, name := range names {
+		if err := tr.Prove(crypto.Keccak256(name), 0, self); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeSet returns the database of the nodes of a proof keyed by their hashes.
+func nodeSet(nodes [][]byte) *serodb.MemDatabase {
+	db := serodb.NewMemDatabase()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// verifyKey returns the value of a key in the state trie of the given root,
+// nil if it is absent, checked against the nodes of a proof.
+func verifyKey(root common.Hash, key []byte, nodes *serodb.MemDatabase) ([]byte, error) {
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256(key), nodes)
+	return value, err
+}
+
+// verifyNils returns whether each nil is spent in the state trie of the given
+// root.
+func verifyNils(root common.Hash, nils []keys.Uint256, proof [][]byte) ([]bool, error) {
+	nodes := nodeSet(proof)
+	spent := make([]bool, len(nils))
+	for i := range nils {
+		value, err := verifyKey(root, nilKey(&nils[i]), nodes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof of nil %v: %v", hexutil.Encode(nils[i][:]), err)
+		}
+		spent[i] = len(value) > 0 && value[0] == 1
+	}
+	return spent, nil
+}
+
+// verifyAnchor checks that the anchor is a root of the Merkle tree in the state
+// trie of the given root.
+func verifyAnchor(root common.Hash, anchor *keys.Uint256, nodes *serodb.MemDatabase) error {
+	for _, key := range rootKeys(anchor) {
+		value, err := verifyKey(root, key, nodes)
+		if err != nil {
+			return fmt.Errorf("invalid proof of anchor %v: %v", hexutil.Encode(anchor[:]), err)
+		}
+		if len(value) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("anchor %v is not a root of the merkle tree", hexutil.Encode(anchor[:]))
+}
+
+// insertionRoot returns the root of the Merkle tree right after the leaf of
+// the given position was appended, which is the root naming its output. The
+// left brothers of the witness were already there, the right ones were empty.
+func insertionRoot(cm *keys.Uint256, pos uint64, paths *[txstate.DEPTH]keys.Uint256) keys.Uint256 {
+	var brothers [txstate.DEPTH]keys.Uint256
+	for depth := range brothers {
+		if (pos>>uint(depth))&1 == 1 {
+			brothers[depth] = paths[depth]
+		} else {
+			brothers[depth] = cpt.EmptyRoots()[depth]
+		}
+	}
+	return txstate.CalcRoot(cm, pos, &brothers)
+}
+
+// verifyOuts checks the roots and the witnesses of the outputs against their
+// commitments, the witnesses against their shared anchor, and the anchor
+// against the state trie of the given root.
+func verifyOuts(root common.Hash, outs []provenOut, proof [][]byte) error {
+	if len(outs) == 0 {
+		return nil
+	}
+	anchor := outs[0].Witness.Anchor
+	if anchor == (keys.Uint256{}) {
+		return errors.New("empty anchor")
+	}
+	for _, out := range outs {
+		if out.Witness.Anchor != anchor {
+			return fmt.Errorf("witness of %v has another anchor", hexutil.Encode(out.Out.Root[:]))
+		}
+		// The commitment is computed again rather than trusting the one sent
+		os := out.Out.State.OS.Clone()
+		os.RootCM = nil
+		cm := os.ToRootCM()
+		if insertionRoot(cm, uint64(out.Witness.Pos), &out.Witness.Paths) != out.Out.Root {
+			return fmt.Errorf("root %v does not match the output", hexutil.Encode(out.Out.Root[:]))
+		}
+		if txstate.CalcRoot(cm, uint64(out.Witness.Pos), &out.Witness.Paths) != anchor {
+			return fmt.Errorf("invalid witness of %v", hexutil.Encode(out.Out.Root[:]))
+		}
+	}
+	return verifyAnchor(root, &anchor, nodeSet(proof))
+}
@@ -0,0 +1,98 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+)
+
+// newStateTrie returns a state trie holding the given spent nils and Merkle
+// tree roots, added from SIP2 on and before.
+func newStateTrie(t *testing.T, nils, roots, roots0 []keys.Uint256) *trie.SecureTrie {
+	tr, err := trie.NewSecure(common.Hash{}, trie.NewDatabase(serodb.NewMemDatabase()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nils {
+		tr.Update(nilKey(&nils[i]), []byte{1})
+	}
+	for i := range roots {
+		tr.Update(rootKeys(&roots[i])[0], []byte{1})
+	}
+	for i := range roots0 {
+		tr.Update(rootKeys(&roots0[i])[1], []byte{0xc0})
+	}
+	// Some unrelated accounts so that the proofs go through several nodes
+	for i := byte(0); i < 64; i++ {
+		tr.Update([]byte{i}, []byte{i + 1})
+	}
+	return tr
+}
+
+func TestNilProofs(t *testing.T) {
+	spent, unspent := keys.Uint256{1}, keys.Uint256{2}
+	tr := newStateTrie(t, []keys.Uint256{spent}, nil, nil)
+
+	nodes := newNodeList()
+	if err := nodes.prove(tr, nilKey(&spent), nilKey(&unspent)); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := verifyNils(tr.Hash(), []keys.Uint256{spent, unspent}, nodes.nodes)
+	if err != nil {
+		t.Fatalf("proof rejected: %v", err)
+	}
+	if !flags[0] || flags[1] {
+		t.Errorf("spent flags mismatch: have %v, want [true false]", flags)
+	}
+	// The nodes of both proofs are shared, a missing one breaks them
+	if _, err := verifyNils(tr.Hash(), []keys.Uint256{spent}, nodes.nodes[1:]); err == nil {
+		t.Error("incomplete proof accepted")
+	}
+	if _, err := verifyNils(common.Hash{1}, []keys.Uint256{spent}, nodes.nodes); err == nil {
+		t.Error("proof against another root accepted")
+	}
+}
+
+func TestAnchorProofs(t *testing.T) {
+	anchor, anchor0, unknown := keys.Uint256{1}, keys.Uint256{2}, keys.Uint256{3}
+	tr := newStateTrie(t, []keys.Uint256{unknown}, []keys.Uint256{anchor}, []keys.Uint256{anchor0})
+
+	for _, test := range []struct {
+		anchor keys.Uint256
+		valid  bool
+	}{
+		{anchor, true},
+		{anchor0, true},
+		{unknown, false}, // Spent nils are not anchors
+	} {
+		nodes := newNodeList()
+		if err := nodes.prove(tr, rootKeys(&test.anchor)...); err != nil {
+			t.Fatal(err)
+		}
+		err := verifyAnchor(tr.Hash(), &test.anchor, nodeSet(nodes.nodes))
+		if test.valid && err != nil {
+			t.Errorf("anchor %x rejected: %v", test.anchor[:1], err)
+		} else if !test.valid && err == nil {
+			t.Errorf("anchor %x accepted", test.anchor[:1])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package zlight implements the zlight protocol, through which the full nodes
+// serve the zero wallets of the light clients: the block headers, the outputs
+// of their PKrs, the nil-spent checks and the Merkle witnesses of the outputs,
+// along with the state trie proofs binding them to the header roots.
+package zlight
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/light"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	zlight1 = 1
+)
+
+// ProtocolName is the official short name of the protocol used during capability negotiation.
+var ProtocolName = "zlight"
+
+// ProtocolVersions are the supported versions of the zlight protocol (first is primary).
+var ProtocolVersions = []uint{zlight1}
+
+// ProtocolLengths are the number of implemented message corresponding to different protocol versions.
+var ProtocolLengths = []uint64{10}
+
+const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+// zlight protocol message codes
+const (
+	StatusMsg          = 0x00
+	AnnounceMsg        = 0x01
+	GetBlockHeadersMsg = 0x02
+	BlockHeadersMsg    = 0x03
+	GetOutsMsg         = 0x04
+	OutsMsg            = 0x05
+	GetNilsMsg         = 0x06
+	NilsMsg            = 0x07
+	GetWitnessesMsg    = 0x08
+	WitnessesMsg       = 0x09
+)
+
+// Limits of the requests served
+const (
+	MaxHeaderFetch = 192  // Amount of block headers to be fetched per request
+	MaxOutBlocks   = 1000 // Amount of blocks to be looked up per outputs request
+	MaxOuts        = 1024 // Amount of outputs to be returned per outputs request
+	MaxPKrs        = 64   // Amount of PKrs to be looked up per outputs request
+	MaxNils        = 256  // Amount of nils to be checked per request
+	MaxRoots       = 256  // Amount of witnesses to be fetched per request
+)
+
+type errCode int
+
+const (
+	ErrMsgTooLarge = iota
+	ErrDecode
+	ErrInvalidMsgCode
+	ErrProtocolVersionMismatch
+	ErrNetworkIdMismatch
+	ErrGenesisBlockMismatch
+	ErrNoStatusMsg
+	ErrExtraStatusMsg
+	ErrUselessPeer
+	ErrRequestRejected
+	ErrUnexpectedResponse
+)
+
+func (e errCode) String() string {
+	return errorToString[int(e)]
+}
+
+var errorToString = map[int]string{
+	ErrMsgTooLarge:             "Message too long",
+	ErrDecode:                  "Invalid message",
+	ErrInvalidMsgCode:          "Invalid message code",
+	ErrProtocolVersionMismatch: "Protocol version mismatch",
+	ErrNetworkIdMismatch:       "NetworkId mismatch",
+	ErrGenesisBlockMismatch:    "Genesis block mismatch",
+	ErrNoStatusMsg:             "No status message",
+	ErrExtraStatusMsg:          "Extra status message",
+	ErrUselessPeer:             "Useless peer",
+	ErrRequestRejected:         "Request rejected",
+	ErrUnexpectedResponse:      "Unexpected response",
+}
+
+func errResp(code errCode, format string, v ...interface{}) error {
+	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+}
+
+// statusData is the network packet for the status message.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	CurrentNumber   uint64
+	GenesisBlock    common.Hash
+	Serve           bool // Whether the peer serves the light clients
+}
+
+// announceData is the network packet announcing a new head to the clients.
+type announceData struct {
+	Hash   common.Hash
+	Number uint64
+	TD     *big.Int
+}
+
+// getBlockHeadersData represents a canonical block header query.
+type getBlockHeadersData struct {
+	ReqID  uint64
+	Origin uint64 // Number of the first header
+	Amount uint64 // Maximum number of headers to retrieve
+}
+
+// blockHeadersData is the network packet for the block headers replies.
+type blockHeadersData struct {
+	ReqID   uint64
+	Headers []*types.Header
+}
+
+// getOutsData represents a query of the outputs received by some PKrs, proven
+// against the state of the given block.
+type getOutsData struct {
+	ReqID uint64
+	Block common.Hash
+	PKrs  []keys.PKr
+	Start uint64
+	End   uint64
+}
+
+// getWitnessesData represents a query of the Merkle witnesses of some outputs,
+// proven against the state of the given block.
+type getWitnessesData struct {
+	ReqID uint64
+	Block common.Hash
+	Roots []keys.Uint256
+}
+
+// provenOut is an output along with its witness in the Merkle tree.
+type provenOut struct {
+	Out     txtool.Out
+	Witness txtool.Witness
+}
+
+// outsData is the network packet for the outputs and witnesses replies. All
+// the witnesses share the same anchor, proven to be a root of the Merkle tree
+// by the state trie nodes of the proof.
+type outsData struct {
+	ReqID uint64
+	Error string
+	End   uint64 // Last block the outputs were looked up in
+	Outs  []provenOut
+	Proof [][]byte
+}
+
+// getNilsData represents a query of the nils spent in the state of the given
+// block.
+type getNilsData struct {
+	ReqID uint64
+	Block common.Hash
+	Nils  []keys.Uint256
+}
+
+// nilsData is the network packet for the nil checks replies. The state trie
+// nodes of the proof prove whether each nil is spent or not, while the spending
+// transactions are only hints from the index of the server, if any.
+type nilsData struct {
+	ReqID uint64
+	Error string
+	Spent []light.NilValue
+	Proof [][]byte
+}
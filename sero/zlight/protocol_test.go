@@ -0,0 +1,415 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+const testNetworkId = 1
+
+var (
+	testPKr  = keys.PKr{1} // Receiver of the only output of the genesis
+	otherPKr = keys.PKr{2}
+)
+
+func newTestGenesis() *core.Genesis {
+	cpt.ZeroInit("", cpt.NET_Dev)
+	return &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{common.BytesToAddress(testPKr[:]): {Balance: big.NewInt(1000)}},
+	}
+}
+
+// makeHeaders creates a chain of headers on top of the parent, differing from
+// the other chains by their extra data. They all share the state of the
+// parent, so that the outputs of the genesis can be proven against them.
+func makeHeaders(parent *types.Header, n int, extra byte) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		headers[i] = &types.Header{
+			ParentHash: parent.Hash(),
+			Root:       parent.Root,
+			Difficulty: big.NewInt(1),
+			Number:     new(big.Int).Add(parent.Number, common.Big1),
+			GasLimit:   parent.GasLimit,
+			Time:       new(big.Int).Add(parent.Time, big.NewInt(10)),
+			Extra:      []byte{extra},
+		}
+		parent = headers[i]
+	}
+	return headers
+}
+
+// newTestServer creates a zlight server of a chain of n headers on top of the
+// genesis. The blocks are not needed by the handlers, only the headers and the
+// state of the genesis.
+func newTestServer(t *testing.T, gspec *core.Genesis, n int) *Server {
+	db := serodb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFullFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := chain.InsertHeaderChain(makeHeaders(genesis.Header(), n, 0), 1); err != nil {
+		t.Fatalf("header %d: failed to insert: %v", i, err)
+	}
+	txtool.Ref_inst.SetBC(&core.State1BlockChain{Bc: chain})
+
+	return &Server{
+		blockchain: chain,
+		networkId:  testNetworkId,
+		maxPeers:   10,
+		peers:      newPeerSet(),
+		quit:       make(chan struct{}),
+	}
+}
+
+func newTestClient(t *testing.T, gspec *core.Genesis) *LightSero {
+	db := serodb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	client := &LightSero{
+		chainDb:   db,
+		engine:    ethash.NewFullFaker(),
+		networkId: testNetworkId,
+		peers:     newPeerSet(),
+		pending:   make(map[uint64]*pendingRequest),
+		syncCh:    make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+	}
+	hc, err := core.NewHeaderChain(db, gspec.Config, client.engine, client.interrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.hc = hc
+	return client
+}
+
+// connect runs a server handler and the client over a message pipe, and waits
+// until the client registered the server. The pipe is closed as soon as one of
+// the sides tears the connection down.
+func connect(t *testing.T, client *LightSero, handle func(p *peer) error, id byte) *p2p.MsgPipeRW {
+	app, net := p2p.MsgPipe()
+	go func() {
+		handle(newPeer(zlight1, p2p.NewPeer(discover.NodeID{id}, "client", nil), app))
+		app.Close()
+	}()
+	go func() {
+		client.handle(newPeer(zlight1, p2p.NewPeer(discover.NodeID{id}, "server", nil), net))
+		net.Close()
+	}()
+
+	for i := 0; client.peers.Peer(peerID(id)) == nil; i++ {
+		if i == 100 {
+			t.Fatalf("server %d not registered", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return app
+}
+
+func peerID(id byte) string {
+	node := discover.NodeID{id}
+	return newPeer(zlight1, p2p.NewPeer(node, "", nil), nil).id
+}
+
+// newSyncedClient returns a client synced with a server of n headers.
+func newSyncedClient(t *testing.T, n int) (*LightSero, *Server, *p2p.MsgPipeRW) {
+	gspec := newTestGenesis()
+	server := newTestServer(t, gspec, n)
+	client := newTestClient(t, gspec)
+
+	pipe := connect(t, client, server.handle, 1)
+	client.synchronise()
+	if head := client.CurrentHeader().Hash(); head != server.blockchain.CurrentHeader().Hash() {
+		pipe.Close()
+		t.Fatalf("head mismatch: have %x, want %x", head[:4], server.blockchain.CurrentHeader().Hash().Bytes()[:4])
+	}
+	return client, server, pipe
+}
+
+// Tests that the server serves the canonical headers from the requested number
+// on, up to its head.
+func TestServeHeaders(t *testing.T) {
+	gspec := newTestGenesis()
+	server := newTestServer(t, gspec, 10)
+
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	errc := make(chan error, 1)
+	go func() { errc <- server.handle(newPeer(zlight1, p2p.NewPeer(discover.NodeID{1}, "client", nil), app)) }()
+
+	p := newPeer(zlight1, p2p.NewPeer(discover.NodeID{1}, "server", nil), net)
+	genesis := server.blockchain.Genesis()
+	if err := p.Handshake(testNetworkId, genesis.Difficulty(), genesis.Hash(), 0, genesis.Hash(), false); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if _, number, _ := p.Head(); number != 10 {
+		t.Fatalf("head mismatch: have %d, want 10", number)
+	}
+	for i, test := range []struct {
+		origin, amount uint64
+		want           []uint64
+	}{
+		{3, 4, []uint64{3, 4, 5, 6}},
+		{8, 5, []uint64{8, 9, 10}},
+		{11, 1, nil},
+	} {
+		if err := p.RequestHeaders(uint64(i), test.origin, int(test.amount)); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := net.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp blockHeadersData
+		if err := msg.Decode(&resp); err != nil {
+			t.Fatalf("test %d: failed to decode: %v", i, err)
+		}
+		if resp.ReqID != uint64(i) {
+			t.Errorf("test %d: request id mismatch: have %d, want %d", i, resp.ReqID, i)
+		}
+		if len(resp.Headers) != len(test.want) {
+			t.Errorf("test %d: headers mismatch: have %d, want %d", i, len(resp.Headers), len(test.want))
+			continue
+		}
+		for j, header := range resp.Headers {
+			if header.Hash() != server.blockchain.GetHeaderByNumber(test.want[j]).Hash() {
+				t.Errorf("test %d: header %d is not canonical", i, j)
+			}
+		}
+	}
+	// Requests beyond the limits tear the connection down
+	if err := p2p.Send(net, GetOutsMsg, &getOutsData{PKrs: make([]keys.PKr, MaxPKrs+1)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("oversized request served")
+		}
+	case <-time.After(time.Second):
+		t.Error("oversized request served")
+	}
+}
+
+// Tests that a client syncs the headers of a server, stepping back to their
+// common ancestor when it is on another branch.
+func TestSync(t *testing.T) {
+	gspec := newTestGenesis()
+	server := newTestServer(t, gspec, 10)
+	client := newTestClient(t, gspec)
+
+	// The client is on a lighter branch of its own
+	fork := makeHeaders(client.CurrentHeader(), 5, 1)
+	if err := client.insertHeaders(fork); err != nil {
+		t.Fatal(err)
+	}
+	pipe := connect(t, client, server.handle, 1)
+	defer pipe.Close()
+
+	client.synchronise()
+	if head, want := client.CurrentHeader(), server.blockchain.CurrentHeader(); head.Hash() != want.Hash() {
+		t.Fatalf("head mismatch: have %d/%x, want %d/%x", head.Number, head.Hash().Bytes()[:4], want.Number, want.Hash().Bytes()[:4])
+	}
+	for _, header := range fork {
+		if client.hc.GetHeaderByNumber(header.Number.Uint64()).Hash() == header.Hash() {
+			t.Errorf("forked header %d still canonical", header.Number)
+		}
+	}
+}
+
+// Tests that the outputs and their witnesses are fetched and proven, and that
+// only the outputs of the requested PKrs are served.
+func TestGetOuts(t *testing.T) {
+	client, _, pipe := newSyncedClient(t, 10)
+	defer pipe.Close()
+
+	resp, err := client.GetOuts([]keys.PKr{testPKr}, 0, 10)
+	if err != nil {
+		t.Fatalf("failed to get outputs: %v", err)
+	}
+	if len(resp.BlockOuts) != 1 || len(resp.BlockOuts[0].Outs) != 1 || resp.BlockOuts[0].Num != 0 {
+		t.Fatalf("outputs mismatch: have %+v, want one of the genesis", resp.BlockOuts)
+	}
+	out := resp.BlockOuts[0].Outs[0]
+	if pkr := *out.State.OS.ToPKr(); pkr != testPKr {
+		t.Errorf("output pkr mismatch: have %x, want %x", pkr[:4], testPKr[:4])
+	}
+	if resp, err = client.GetOuts([]keys.PKr{otherPKr}, 0, 10); err != nil {
+		t.Fatalf("failed to get outputs: %v", err)
+	}
+	if len(resp.BlockOuts) != 0 {
+		t.Errorf("outputs of another pkr served: %+v", resp.BlockOuts)
+	}
+	if _, err := client.GetOuts(make([]keys.PKr, MaxPKrs+1), 0, 10); err == nil {
+		t.Error("too many pkrs requested")
+	}
+	if _, err := client.GetOuts([]keys.PKr{testPKr}, 5, 4); err == nil {
+		t.Error("invalid block range requested")
+	}
+	// The blocks of the server are not indexed, only its genesis is
+	if _, err := client.GetOuts([]keys.PKr{testPKr}, 2, 10); err == nil {
+		t.Error("outputs of blocks not indexed served")
+	}
+	if client.peers.Len() != 1 {
+		t.Error("server dropped for blocks not indexed")
+	}
+
+	wits, err := client.GetWitnesses([]keys.Uint256{out.Root})
+	if err != nil {
+		t.Fatalf("failed to get witnesses: %v", err)
+	}
+	if len(wits) != 1 || wits[0].Anchor == (keys.Uint256{}) {
+		t.Errorf("witnesses mismatch: have %+v", wits)
+	}
+	if _, err := client.GetWitnesses([]keys.Uint256{{0xff}}); err == nil {
+		t.Error("witness of an unknown root served")
+	}
+
+	spent, err := client.CheckNils([]keys.Uint256{{1}, {2}})
+	if err != nil {
+		t.Fatalf("failed to check nils: %v", err)
+	}
+	if len(spent) != 0 {
+		t.Errorf("unspent nils reported: %+v", spent)
+	}
+}
+
+// fakeServer is a zlight server answering the outputs requests with the
+// responses of an honest one, tampered with.
+type fakeServer struct {
+	server *Server
+	td     *big.Int
+	tamper func(server *Server, query *getOutsData, resp *outsData)
+}
+
+func (self *fakeServer) handle(p *peer) error {
+	var (
+		genesis = self.server.blockchain.Genesis()
+		head    = self.server.blockchain.CurrentHeader()
+	)
+	if err := p.Handshake(testNetworkId, self.td, head.Hash(), head.Number.Uint64(), genesis.Hash(), true); err != nil {
+		return err
+	}
+	for {
+		msg, err := p.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		switch msg.Code {
+		case GetOutsMsg:
+			var query getOutsData
+			if err := msg.Decode(&query); err != nil {
+				return err
+			}
+			resp := self.server.serveOuts(&query)
+			self.tamper(self.server, &query, resp)
+			err = p2p.Send(p.rw, OutsMsg, resp)
+		default:
+			msg.Discard()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Tests that the servers sending outputs which do not verify are dropped, and
+// the request retried on the other ones.
+func TestDropInvalidServer(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		pkr    keys.PKr
+		start  uint64
+		tamper func(server *Server, query *getOutsData, resp *outsData)
+	}{
+		{"unrequested output", otherPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			// The output of the genesis is sent whatever the PKr
+			*resp = *server.serveOuts(&getOutsData{ReqID: query.ReqID, Block: query.Block, PKrs: []keys.PKr{testPKr}, Start: query.Start, End: query.End})
+		}},
+		{"mismatched root", testPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			resp.Outs[0].Out.Root[0] ^= 0xff
+		}},
+		{"invalid witness", testPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			resp.Outs[0].Witness.Paths[0][0] ^= 0xff
+		}},
+		{"outputs beyond the end", testPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			resp.End = query.End + 1
+		}},
+		{"outputs before the start", testPKr, 2, func(server *Server, query *getOutsData, resp *outsData) {
+			*resp = outsData{ReqID: query.ReqID, End: query.Start - 1}
+		}},
+		{"output out of range", testPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			resp.Outs[0].Out.State.Num = resp.End + 1
+		}},
+		{"output before the start", testPKr, 2, func(server *Server, query *getOutsData, resp *outsData) {
+			// The output of the genesis is sent whatever the start
+			*resp = *server.serveOuts(&getOutsData{ReqID: query.ReqID, Block: query.Block, PKrs: query.PKrs, Start: 0, End: query.End})
+			resp.End = query.End
+		}},
+		{"outputs out of order", testPKr, 0, func(server *Server, query *getOutsData, resp *outsData) {
+			resp.Outs = append(resp.Outs, resp.Outs[0])
+			resp.Outs[0].Out.State.Num = 1
+		}},
+	} {
+		client, server, pipe := newSyncedClient(t, 10)
+
+		// The fake server is the heaviest one, the first one a request is sent to
+		head := server.blockchain.CurrentHeader()
+		td := new(big.Int).Add(server.blockchain.GetTd(head.Hash(), head.Number.Uint64()), common.Big1)
+		fake := &fakeServer{server: server, td: td, tamper: test.tamper}
+		fakePipe := connect(t, client, fake.handle, 2)
+
+		// The honest server only indexed its genesis
+		resp, err := client.GetOuts([]keys.PKr{test.pkr}, test.start, 10)
+		if test.start > 0 {
+			if err == nil || !strings.Contains(err.Error(), "not available") {
+				t.Errorf("%s: request not retried: %v", test.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: request not retried: %v", test.name, err)
+		} else if want := test.pkr == testPKr; (len(resp.BlockOuts) == 1) != want {
+			t.Errorf("%s: outputs mismatch: have %+v", test.name, resp.BlockOuts)
+		}
+		if client.peers.Peer(peerID(2)) != nil {
+			t.Errorf("%s: fake server not dropped", test.name)
+		}
+		if client.peers.Peer(peerID(1)) == nil {
+			t.Errorf("%s: honest server dropped", test.name)
+		}
+		fakePipe.Close()
+		pipe.Close()
+	}
+}
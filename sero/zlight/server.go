@@ -0,0 +1,425 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package zlight
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/sero"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/flight"
+	"github.com/sero-cash/go-sero/zero/wallet/light"
+)
+
+// Server serves the zlight protocol to the light clients from a full node. It
+// implements sero.LesServer.
+type Server struct {
+	blockchain *core.BlockChain
+	lightNode  *light.LightNode // Index of the outputs by PKr, nil if not running
+	networkId  uint64
+	maxPeers   int
+
+	peers   *peerSet
+	headCh  chan core.ChainHeadEvent
+	headSub event.Subscription
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewServer creates the zlight server of a full node. The outputs of the PKrs
+// are looked up in the index of the light node if it runs, or else by scanning
+// the blocks.
+func NewServer(sero *sero.Sero, config *sero.Config) *Server {
+	return &Server{
+		blockchain: sero.BlockChain(),
+		lightNode:  sero.LightNode(),
+		networkId:  config.NetworkId,
+		maxPeers:   config.LightPeers,
+		peers:      newPeerSet(),
+		headCh:     make(chan core.ChainHeadEvent, 10),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Protocols implements sero.LesServer.
+func (self *Server) Protocols() []p2p.Protocol {
+	return makeProtocols(self.peers, self.handle)
+}
+
+// Start implements sero.LesServer, announcing the new heads to the clients.
+func (self *Server) Start(srvr *p2p.Server) {
+	self.headSub = self.blockchain.SubscribeChainHeadEvent(self.headCh)
+	go self.announceLoop()
+	log.Info("Started zlight server", "maxpeers", self.maxPeers)
+}
+
+// Stop implements sero.LesServer.
+func (self *Server) Stop() {
+	self.headSub.Unsubscribe()
+	close(self.quit)
+	self.peers.Close()
+	self.wg.Wait()
+	log.Info("Zlight server stopped")
+}
+
+// SetBloomBitsIndexer implements sero.LesServer. The zlight protocol serves no
+// bloom bits.
+func (self *Server) SetBloomBitsIndexer(bbIndexer *core.ChainIndexer) {}
+
+func (self *Server) announceLoop() {
+	for {
+		select {
+		case ev := <-self.headCh:
+			header := ev.Block.Header()
+			hash, number := header.Hash(), header.Number.Uint64()
+			td := self.blockchain.GetTd(hash, number)
+			if td == nil {
+				continue
+			}
+			for _, p := range self.peers.AllPeers() {
+				if err := p.SendAnnounce(hash, number, td); err != nil {
+					p.Log().Debug("Zlight announce failed", "err", err)
+				}
+			}
+		case <-self.headSub.Err():
+			return
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// handle is the callback invoked to manage the life cycle of a light client.
+// When this function terminates, the peer is disconnected.
+func (self *Server) handle(p *peer) error {
+	// Ignore maxPeers if this is a trusted peer
+	if self.peers.Len() >= self.maxPeers && !p.Peer.Info().Network.Trusted {
+		return p2p.DiscTooManyPeers
+	}
+	var (
+		genesis = self.blockchain.Genesis()
+		head    = self.blockchain.CurrentHeader()
+		hash    = head.Hash()
+		number  = head.Number.Uint64()
+		td      = self.blockchain.GetTd(hash, number)
+	)
+	if err := p.Handshake(self.networkId, td, hash, number, genesis.Hash(), true); err != nil {
+		p.Log().Debug("Zlight handshake failed", "err", err)
+		return err
+	}
+	if err := self.peers.Register(p); err != nil {
+		return err
+	}
+	defer self.peers.Unregister(p.id)
+
+	self.wg.Add(1)
+	defer self.wg.Done()
+
+	p.Log().Debug("Zlight client connected", "name", p.Name())
+	for {
+		if err := self.handleMsg(p); err != nil {
+			p.Log().Debug("Zlight message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg serves the next request of a light client. The connection is torn
+// down upon returning any error.
+func (self *Server) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case StatusMsg:
+		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+
+	case GetBlockHeadersMsg:
+		var query getBlockHeadersData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if query.Amount > MaxHeaderFetch {
+			query.Amount = MaxHeaderFetch
+		}
+		headers := []*types.Header{}
+		for i := uint64(0); i < query.Amount; i++ {
+			header := self.blockchain.GetHeaderByNumber(query.Origin + i)
+			if header == nil {
+				break
+			}
+			headers = append(headers, header)
+		}
+		return p2p.Send(p.rw, BlockHeadersMsg, &blockHeadersData{ReqID: query.ReqID, Headers: headers})
+
+	case GetOutsMsg:
+		var query getOutsData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if len(query.PKrs) > MaxPKrs {
+			return errResp(ErrRequestRejected, "%d pkrs > %d", len(query.PKrs), MaxPKrs)
+		}
+		return p2p.Send(p.rw, OutsMsg, self.serveOuts(&query))
+
+	case GetWitnessesMsg:
+		var query getWitnessesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if len(query.Roots) > MaxRoots {
+			return errResp(ErrRequestRejected, "%d roots > %d", len(query.Roots), MaxRoots)
+		}
+		return p2p.Send(p.rw, WitnessesMsg, self.serveWitnesses(&query))
+
+	case GetNilsMsg:
+		var query getNilsData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if len(query.Nils) > MaxNils {
+			return errResp(ErrRequestRejected, "%d nils > %d", len(query.Nils), MaxNils)
+		}
+		return p2p.Send(p.rw, NilsMsg, self.serveNils(&query))
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+}
+
+// stateAt returns the state of a block, and its state trie to prove the keys
+// against.
+func (self *Server) stateAt(hash common.Hash) (*types.Header, *state.StateDB, state.Trie, error) {
+	header := self.blockchain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, nil, nil, fmt.Errorf("unknown block %x", hash[:8])
+	}
+	statedb, err := self.blockchain.StateAt(header)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("state of block %d not available: %v", header.Number, err)
+	}
+	tr, err := statedb.Database().OpenTrie(header.Root)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return header, statedb, tr, nil
+}
+
+func (self *Server) serveOuts(query *getOutsData) *outsData {
+	resp := &outsData{ReqID: query.ReqID}
+	header, statedb, tr, err := self.stateAt(query.Block)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	end := query.End
+	if number := header.Number.Uint64(); end > number {
+		end = number
+	}
+	if end < query.Start {
+		resp.Error = fmt.Sprintf("block %d not available", query.Start)
+		return resp
+	}
+	outs, end, err := self.outsByPKr(query.PKrs, query.Start, end)
+	if err == nil {
+		err = self.witness(resp, statedb, tr, outs)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Outs = nil
+		return resp
+	}
+	resp.End = end
+	return resp
+}
+
+// outsByPKr returns the outputs received by the PKrs from the start block to
+// the end one, along with the last block looked up, which is before the end
+// one if there were too many blocks or outputs.
+func (self *Server) outsByPKr(pkrs []keys.PKr, start, end uint64) ([]txtool.Out, uint64, error) {
+	var blocks []light.BlockOut
+	if self.lightNode != nil {
+		resp, err := self.lightNode.GetOutsByPKr(pkrs, start, end)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.CurrentNum < start {
+			return nil, 0, fmt.Errorf("block %d not available", start)
+		}
+		if resp.CurrentNum < end {
+			end = resp.CurrentNum
+		}
+		blocks = resp.BlockOuts
+	} else {
+		if end-start >= MaxOutBlocks {
+			end = start + MaxOutBlocks - 1
+		}
+		infos, err := flight.SRI_Inst.GetBlocksInfoByDelay(start, end-start+1, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(infos) == 0 {
+			return nil, 0, fmt.Errorf("block %d not available", start)
+		}
+		if uint64(len(infos)) < end-start+1 {
+			end = start + uint64(len(infos)) - 1
+		}
+		wanted := make(map[keys.PKr]bool)
+		for _, pkr := range pkrs {
+			wanted[pkr] = true
+		}
+		for _, info := range infos {
+			block := light.BlockOut{Num: uint64(info.Num)}
+			for _, out := range info.Outs {
+				if wanted[*out.State.OS.ToPKr()] {
+					block.Outs = append(block.Outs, out)
+				}
+			}
+			if len(block.Outs) > 0 {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Num < blocks[j].Num })
+
+	outs := []txtool.Out{}
+	for _, block := range blocks {
+		if block.Num > end {
+			break
+		}
+		// Blocks are never split so that the client can go on from the end one
+		if len(outs) > 0 && len(outs)+len(block.Outs) > MaxOuts {
+			end = block.Num - 1
+			break
+		}
+		outs = append(outs, block.Outs...)
+	}
+	return outs, end, nil
+}
+
+func (self *Server) serveWitnesses(query *getWitnessesData) *outsData {
+	resp := &outsData{ReqID: query.ReqID}
+	header, statedb, tr, err := self.stateAt(query.Block)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	outs := make([]txtool.Out, 0, len(query.Roots))
+	for _, root := range query.Roots {
+		rs := localdb.GetRoot(self.blockchain.GetDB(), &root)
+		if rs == nil {
+			resp.Error = fmt.Sprintf("unknown root %v", hexutil.Encode(root[:]))
+			return resp
+		}
+		outs = append(outs, txtool.Out{Root: root, State: *rs})
+	}
+	if err := self.witness(resp, statedb, tr, outs); err != nil {
+		resp.Error = err.Error()
+		resp.Outs = nil
+		return resp
+	}
+	resp.End = header.Number.Uint64()
+	return resp
+}
+
+// witness adds the outputs along with their witnesses in the Merkle tree of the
+// state into the response, and the proof of their anchor.
+func (self *Server) witness(resp *outsData, statedb *state.StateDB, tr state.Trie, outs []txtool.Out) error {
+	if len(outs) == 0 {
+		return nil
+	}
+	zstate := statedb.CurrentZState()
+	for _, out := range outs {
+		if zstate.State.GetOut(&out.Root) == nil {
+			return fmt.Errorf("root %v not in the state", hexutil.Encode(out.Root[:]))
+		}
+	}
+	roots := make([]keys.Uint256, len(outs))
+	for i, out := range outs {
+		roots[i] = out.Root
+	}
+	// The witnesses of the wallets are used if their anchor is known by the
+	// state, or else they are computed from the Merkle tree of the state.
+	wits, err := flight.SRI_Inst.GetAnchor(roots)
+	if err != nil || len(wits) != len(outs) || !sameAnchor(wits) || zstate.State.GetOut(&wits[0].Anchor) == nil {
+		wits = make([]txtool.Witness, len(outs))
+		for i, out := range outs {
+			os := out.State.OS.Clone()
+			pos, paths, anchor := zstate.State.MTree.GetPaths(*os.ToRootCM())
+			wits[i] = txtool.Witness{Pos: hexutil.Uint64(pos), Paths: paths, Anchor: anchor}
+		}
+	}
+	for i, out := range outs {
+		resp.Outs = append(resp.Outs, provenOut{Out: out, Witness: wits[i]})
+	}
+	nodes := newNodeList()
+	if err := nodes.prove(tr, rootKeys(&wits[0].Anchor)...); err != nil {
+		return err
+	}
+	resp.Proof = nodes.nodes
+	return nil
+}
+
+// sameAnchor returns whether the witnesses share their anchor.
+func sameAnchor(wits []txtool.Witness) bool {
+	for _, wit := range wits {
+		if wit.Anchor != wits[0].Anchor {
+			return false
+		}
+	}
+	return len(wits) > 0
+}
+
+func (self *Server) serveNils(query *getNilsData) *nilsData {
+	resp := &nilsData{ReqID: query.ReqID}
+	_, _, tr, err := self.stateAt(query.Block)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	nodes := newNodeList()
+	for _, in := range query.Nils {
+		if err := nodes.prove(tr, nilKey(&in)); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+	}
+	resp.Proof = nodes.nodes
+	if self.lightNode != nil {
+		resp.Spent, _ = self.lightNode.CheckNil(query.Nils)
+	}
+	return resp
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/utils"
 )
 
 // Client defines typed wrappers for the Ethereum RPC API.
@@ -477,5 +478,15 @@ func toCallArg(msg sero.CallMsg) interface{} {
 	if msg.GasPrice != nil {
 		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
 	}
+	if msg.Asset != nil {
+		if tkn := msg.Asset.Tkn; tkn != nil {
+			arg["cy"] = utils.Uint256ToCurrency(&tkn.Currency)
+			arg["value"] = (*hexutil.Big)(tkn.Value.ToInt())
+		}
+		if tkt := msg.Asset.Tkt; tkt != nil {
+			arg["catg"] = utils.Uint256ToCurrency(&tkt.Category)
+			arg["tkt"] = common.BytesToHash(tkt.Value[:])
+		}
+	}
 	return arg
 }
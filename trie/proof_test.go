@@ -0,0 +1,229 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+type rangeEntry struct {
+	k, v []byte
+}
+
+// makeRangeTrie creates a trie of n leaves with hashed keys and returns it
+// with its leaves sorted by key.
+func makeRangeTrie(t *testing.T, n int) (*Trie, []rangeEntry) {
+	tr, _ := New(common.Hash{}, NewDatabase(serodb.NewMemDatabase()))
+	var entries []rangeEntry
+	for i := 0; i < n; i++ {
+		k := crypto.Keccak256([]byte{byte(i >> 8), byte(i)})
+		v := []byte{byte(i), 1}
+		if err := tr.TryUpdate(k, v); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, rangeEntry{k, v})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return tr, entries
+}
+
+// proveRange returns the edge proofs of first and last.
+func proveRange(t *testing.T, tr *Trie, first, last []byte) *serodb.MemDatabase {
+	proof := serodb.NewMemDatabase()
+	if err := tr.Prove(first, 0, proof); err != nil {
+		t.Fatalf("failed to prove the first key: %v", err)
+	}
+	if err := tr.Prove(last, 0, proof); err != nil {
+		t.Fatalf("failed to prove the last key: %v", err)
+	}
+	return proof
+}
+
+func splitRange(entries []rangeEntry) (keys, values [][]byte) {
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		values = append(values, entry.v)
+	}
+	return keys, values
+}
+
+// increase returns the key right after key.
+func increase(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0 {
+			break
+		}
+	}
+	return key
+}
+
+// decrease returns the key right before key.
+func decrease(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
+// Tests that the ranges of a trie are proven by their edge proofs, and that
+// the trie is told to have more leaves on the right of all but the last one.
+func TestRangeProof(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 256)
+	for _, r := range [][2]int{{0, 10}, {0, 255}, {17, 99}, {100, 101}, {200, 255}} {
+		start, end := r[0], r[1]
+		keys, values := splitRange(entries[start : end+1])
+		proof := proveRange(t, tr, keys[0], keys[len(keys)-1])
+		more, err := VerifyRangeProof(tr.Hash(), keys[0], keys[len(keys)-1], keys, values, proof)
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify: %v", start, end, err)
+		}
+		if want := end != len(entries)-1; more != want {
+			t.Errorf("range %d-%d: more mismatch: have %v, want %v", start, end, more, want)
+		}
+	}
+}
+
+// Tests that the whole trie is proven without any proof, and that a partial
+// one isn't.
+func TestRangeProofNoProof(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 64)
+	keys, values := splitRange(entries)
+	if more, err := VerifyRangeProof(tr.Hash(), nil, nil, keys, values, nil); err != nil || more {
+		t.Fatalf("whole trie: have %v, %v, want false, nil", more, err)
+	}
+	if _, err := VerifyRangeProof(tr.Hash(), nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Errorf("partial trie proven without proof")
+	}
+}
+
+// Tests that a proof without leaves proves the absence of leaves on the right
+// of the first key only if there are none.
+func TestRangeProofEmpty(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 64)
+
+	last := increase(entries[len(entries)-1].k)
+	proof := serodb.NewMemDatabase()
+	tr.Prove(last, 0, proof)
+	if more, err := VerifyRangeProof(tr.Hash(), last, nil, nil, nil, proof); err != nil || more {
+		t.Fatalf("empty tail: have %v, %v, want false, nil", more, err)
+	}
+
+	first := decrease(entries[10].k)
+	proof = serodb.NewMemDatabase()
+	tr.Prove(first, 0, proof)
+	if _, err := VerifyRangeProof(tr.Hash(), first, nil, nil, nil, proof); err == nil {
+		t.Errorf("leaves on the right of the first key hidden by an empty range")
+	}
+}
+
+// Tests that a single leaf is proven by its own proof, but not with another
+// value.
+func TestRangeProofSingleElement(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 64)
+	for _, i := range []int{0, 30, 63} {
+		key, value := entries[i].k, entries[i].v
+		proof := serodb.NewMemDatabase()
+		tr.Prove(key, 0, proof)
+		more, err := VerifyRangeProof(tr.Hash(), key, key, [][]byte{key}, [][]byte{value}, proof)
+		if err != nil {
+			t.Fatalf("leaf %d: failed to verify: %v", i, err)
+		}
+		if want := i != len(entries)-1; more != want {
+			t.Errorf("leaf %d: more mismatch: have %v, want %v", i, more, want)
+		}
+		if _, err := VerifyRangeProof(tr.Hash(), key, key, [][]byte{key}, [][]byte{{0xff}}, proof); err == nil {
+			t.Errorf("leaf %d: wrong value proven", i)
+		}
+	}
+}
+
+// Tests that a range is rejected if an edge proof is not the one of its edge
+// key or misses a node.
+func TestRangeProofBadEdge(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 256)
+	keys, values := splitRange(entries[50:100])
+	first, last := keys[0], keys[len(keys)-1]
+
+	// The proof of another key than the first one
+	proof := proveRange(t, tr, entries[48].k, last)
+	if _, err := VerifyRangeProof(tr.Hash(), first, last, keys, values, proof); err == nil {
+		t.Errorf("range proven with the proof of another key")
+	}
+	// A node missing from the proof
+	proof = proveRange(t, tr, first, last)
+	proof.Delete(tr.Hash().Bytes())
+	if _, err := VerifyRangeProof(tr.Hash(), first, last, keys, values, proof); err == nil {
+		t.Errorf("range proven without the root node")
+	}
+	// A tampered value at the edge
+	proof = proveRange(t, tr, first, last)
+	tampered := append([][]byte{}, values...)
+	tampered[len(tampered)-1] = []byte{0xff}
+	if _, err := VerifyRangeProof(tr.Hash(), first, last, keys, tampered, proof); err == nil {
+		t.Errorf("range proven with a tampered edge value")
+	}
+}
+
+// Tests that a range missing a leaf between its edges is rejected.
+func TestRangeProofGapped(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 256)
+	keys, values := splitRange(entries[10:40])
+	proof := proveRange(t, tr, keys[0], keys[len(keys)-1])
+
+	gappedKeys := append(append([][]byte{}, keys[:15]...), keys[16:]...)
+	gappedValues := append(append([][]byte{}, values[:15]...), values[16:]...)
+	if _, err := VerifyRangeProof(tr.Hash(), keys[0], keys[len(keys)-1], gappedKeys, gappedValues, proof); err == nil {
+		t.Errorf("gapped range proven")
+	}
+}
+
+// Tests that a range starting at a key not in the trie is proven by the proof
+// of the absence of that key, and that it doesn't hide the leaves between the
+// edge and the first leaf.
+func TestRangeProofNonExistentEdge(t *testing.T) {
+	tr, entries := makeRangeTrie(t, 256)
+	keys, values := splitRange(entries[100:150])
+	first, last := decrease(keys[0]), keys[len(keys)-1]
+
+	proof := proveRange(t, tr, first, last)
+	more, err := VerifyRangeProof(tr.Hash(), first, last, keys, values, proof)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if !more {
+		t.Errorf("more mismatch: have false, want true")
+	}
+
+	// Starting before the previous leaf, the range misses it
+	first = decrease(entries[99].k)
+	proof = proveRange(t, tr, first, last)
+	if _, err := VerifyRangeProof(tr.Hash(), first, last, keys, values, proof); err == nil {
+		t.Errorf("range missing the leaf after its first key proven")
+	}
+}
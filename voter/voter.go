@@ -1,10 +1,12 @@
 package voter
 
 import (
+	"errors"
 	"math/big"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/golang-lru"
 	"github.com/sero-cash/go-sero/accounts"
 
 	"github.com/sero-cash/go-sero/serodb"
@@ -23,9 +25,38 @@ import (
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/core/state"
 	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/metrics"
 	"github.com/sero-cash/go-sero/params"
 )
 
+var (
+	// ErrKnownVote is returned if a vote or a lottery was already added.
+	ErrKnownVote = errors.New("known vote")
+
+	// ErrStaleVote is returned if a vote or a lottery is for a block which is
+	// already too old to be voted.
+	ErrStaleVote = errors.New("stale vote")
+
+	// ErrUnverifiedVote is returned if a vote or a lottery can't be checked
+	// yet, as the parent of its block is not known locally. It is kept without
+	// being relayed until it can be.
+	ErrUnverifiedVote = errors.New("unverified vote")
+
+	// ErrInvalidVote is returned if a vote is not signed by its share or names
+	// an unknown share, or a lottery doesn't match its parent block.
+	ErrInvalidVote = errors.New("invalid vote")
+)
+
+var (
+	voteInMeter         = metrics.NewRegisteredMeter("voter/votes/in", nil)
+	voteInvalidMeter    = metrics.NewRegisteredMeter("voter/votes/invalid", nil)
+	voteUnverifiedMeter = metrics.NewRegisteredMeter("voter/votes/unverified", nil)
+	voteLatencyTimer    = metrics.NewRegisteredTimer("voter/votes/latency", nil)
+	lotteryInMeter      = metrics.NewRegisteredMeter("voter/lotterys/in", nil)
+	lotteryDropMeter    = metrics.NewRegisteredMeter("voter/lotterys/dropped", nil)
+	lotteryInvalidMeter = metrics.NewRegisteredMeter("voter/lotterys/invalid", nil)
+)
+
 const (
 	evictionInterval = time.Minute
 	chainLotterySize = 300
@@ -33,6 +64,18 @@ const (
 
 	delayNum         = 1
 	lotteryQueueSize = 12
+
+	// stakeStateCacheSize is the number of stake states kept to check the votes
+	// against, the votes of a block all use the one of its parent.
+	stakeStateCacheSize = 8
+
+	// maxUnverifiedVotes is the number of votes kept until their parent or
+	// their lottery is known, the other ones are dropped.
+	maxUnverifiedVotes = 1024
+
+	// maxLotteryAhead is the number of blocks a lottery may be ahead of the
+	// local chain, the local shares can't vote for the farther ones.
+	maxLotteryAhead = 2
 )
 
 type blockChain interface {
@@ -58,10 +101,14 @@ type Voter struct {
 	voteMu    sync.RWMutex
 	lotteryMu sync.RWMutex
 
-	votes    map[common.Hash]time.Time
-	lotterys map[common.Hash]time.Time
+	votes      map[common.Hash]time.Time
+	unverified map[common.Hash]*types.Vote // Votes kept local until they can be checked, by hash
+	lotterys   map[common.Hash]*seenLottery
 
 	lotteryQueue *PriorityQueue
+
+	stakeStates *lru.Cache // Stake states the votes are checked against, by parent hash
+	stakeMu     sync.Mutex // Lock serializing the checks on the cached stake states
 }
 
 func NewVoter(chainconfig *params.ChainConfig, chain blockChain, sero Backend) *Voter {
@@ -73,9 +120,11 @@ func NewVoter(chainconfig *params.ChainConfig, chain blockChain, sero Backend) *
 		chain:        chain,
 		lotteryCh:    make(chan *types.Lottery, chainLotterySize),
 		votes:        make(map[common.Hash]time.Time),
-		lotterys:     make(map[common.Hash]time.Time),
+		unverified:   make(map[common.Hash]*types.Vote),
+		lotterys:     make(map[common.Hash]*seenLottery),
 		lotteryQueue: &PriorityQueue{},
 	}
+	voter.stakeStates, _ = lru.New(stakeStateCacheSize)
 	voter.lotteryQueue.Init(lotteryQueueSize)
 
 	// Subscribe events from blockchain
@@ -99,7 +148,7 @@ func (self *Voter) loop() {
 
 			dropLotterys := []common.Hash{}
 			for k, v := range self.lotterys {
-				if time.Since(v) > lifeTime {
+				if time.Since(v.seen) > lifeTime {
 					dropLotterys = append(dropLotterys, k)
 				}
 			}
@@ -190,6 +239,8 @@ func (self *Voter) voteLoop() {
 				}
 
 			}
+			self.relayLotterys()
+			self.promoteVotes(nil)
 		}
 	}
 }
@@ -364,19 +415,87 @@ func (self *Voter) SendVoteEvent(vote *types.Vote) {
 	go self.voteFeed.Send(core.NewVoteEvent{vote})
 }
 
-func (self *Voter) AddLottery(lottery *types.Lottery) {
-	self.lotteryMu.Lock()
-	defer self.lotteryMu.Unlock()
+// seenLottery is a lottery added to the voter, kept to check the votes of its
+// block and measure how long they take to come.
+type seenLottery struct {
+	lottery *types.Lottery
+	seen    time.Time
+	relayed bool // Whether its parent block is known and it was relayed
+}
+
+// AddLottery adds a lottery, voting for it with the local shares and relaying
+// it to the peers if it matches the known chain. A lottery whose parent block
+// is not known locally is kept without being relayed, returning
+// ErrUnverifiedVote, until the parent is imported.
+func (self *Voter) AddLottery(lottery *types.Lottery) error {
+	if lottery.ParentHash == (common.Hash{}) || lottery.PosHash == (common.Hash{}) {
+		lotteryInvalidMeter.Mark(1)
+		return ErrInvalidVote
+	}
 	current := self.chain.CurrentBlock().Number().Uint64()
 	if current > lottery.ParentNum+delayNum {
 		log.Trace("AddLottery droped", "current", current, "voteBlock", lottery.ParentNum+1)
-		return
+		return ErrStaleVote
+	}
+	if lottery.ParentNum > current+maxLotteryAhead {
+		log.Trace("AddLottery too far ahead", "current", current, "voteBlock", lottery.ParentNum+1)
+		return ErrUnverifiedVote
+	}
+	self.lotteryMu.Lock()
+	if _, exits := self.lotterys[lottery.PosHash]; exits {
+		self.lotteryMu.Unlock()
+		return ErrKnownVote
 	}
-	_, exits := self.lotterys[lottery.PosHash]
-	if !exits {
-		log.Trace("AddLottery", "poshas", lottery.PosHash, "block", lottery.ParentNum+1)
-		self.lotterys[lottery.PosHash] = time.Now()
-		self.lotteryCh <- lottery
+	lotteryInMeter.Mark(1)
+	parent := self.chain.GetHeaderByHash(lottery.ParentHash)
+	if parent != nil && parent.Number.Uint64() != lottery.ParentNum {
+		self.lotteryMu.Unlock()
+		log.Debug("AddLottery invalid parent", "poshash", lottery.PosHash, "number", lottery.ParentNum, "parent", parent.Number)
+		lotteryInvalidMeter.Mark(1)
+		return ErrInvalidVote
+	}
+	log.Trace("AddLottery", "poshas", lottery.PosHash, "block", lottery.ParentNum+1)
+	self.lotterys[lottery.PosHash] = &seenLottery{lottery: lottery, seen: time.Now(), relayed: parent != nil}
+	select {
+	case self.lotteryCh <- lottery:
+	default:
+		log.Debug("AddLottery queue full", "poshash", lottery.PosHash, "block", lottery.ParentNum+1)
+		lotteryDropMeter.Mark(1)
+	}
+	self.lotteryMu.Unlock()
+
+	if parent == nil {
+		return ErrUnverifiedVote
+	}
+	self.SendLotteryEvent(lottery)
+	self.promoteVotes(&lottery.PosHash)
+	return nil
+}
+
+// relayLotterys relays the lotteries whose parent block got imported since
+// they were added, dropping the ones not matching it.
+func (self *Voter) relayLotterys() {
+	var ready []*types.Lottery
+	self.lotteryMu.Lock()
+	for hash, seen := range self.lotterys {
+		if seen.relayed {
+			continue
+		}
+		parent := self.chain.GetHeaderByHash(seen.lottery.ParentHash)
+		if parent == nil {
+			continue
+		}
+		if parent.Number.Uint64() != seen.lottery.ParentNum {
+			lotteryInvalidMeter.Mark(1)
+			delete(self.lotterys, hash)
+			continue
+		}
+		seen.relayed = true
+		ready = append(ready, seen.lottery)
+	}
+	self.lotteryMu.Unlock()
+
+	for _, lottery := range ready {
 		self.SendLotteryEvent(lottery)
 	}
 }
@@ -390,20 +509,164 @@ func (self *Voter) getStateByNumber(num uint64) (*state.StateDB, error) {
 
 }
 
-func (self *Voter) AddVote(vote *types.Vote) {
+// AddVote adds a vote, relaying it to the peers if it is signed by its share.
+// Votes that can't be checked yet, as the parent of their block or their
+// lottery are not known locally, are kept without being relayed, returning
+// ErrUnverifiedVote, until the parent or the lottery confirms them.
+func (self *Voter) AddVote(vote *types.Vote) error {
+	current := self.chain.CurrentBlock().Number().Uint64()
+	if current > vote.ParentNum+delayNum {
+		log.Trace("AddVote droped", "current", current, "voteBlock", vote.ParentNum+1)
+		return ErrStaleVote
+	}
+	hash := vote.Hash()
+	self.voteMu.RLock()
+	_, exits := self.votes[hash]
+	_, pending := self.unverified[hash]
+	self.voteMu.RUnlock()
+	if exits || pending {
+		return ErrKnownVote
+	}
+	// Check the signature out of the lock, it needs the stake state of the block
+	voteInMeter.Mark(1)
+	switch err := self.verifyVote(vote); err {
+	case nil:
+		return self.relayVote(vote, hash)
+	case ErrUnverifiedVote:
+		voteUnverifiedMeter.Mark(1)
+
+		self.voteMu.Lock()
+		defer self.voteMu.Unlock()
+		if _, exits := self.votes[hash]; exits {
+			return ErrKnownVote
+		}
+		if len(self.unverified) >= maxUnverifiedVotes {
+			log.Debug("AddVote unverified votes full", "hashpos", vote.PosHash, "block", vote.ParentNum+1)
+			return err
+		}
+		self.unverified[hash] = vote
+		return err
+	default:
+		if err == ErrInvalidVote {
+			voteInvalidMeter.Mark(1)
+		}
+		return err
+	}
+}
+
+// relayVote records a verified vote, handing it to the worker and relaying it
+// to the peers.
+func (self *Voter) relayVote(vote *types.Vote, hash common.Hash) error {
 	self.voteMu.Lock()
 	defer self.voteMu.Unlock()
+	delete(self.unverified, hash)
+	if _, exits := self.votes[hash]; exits {
+		return ErrKnownVote
+	}
+	log.Trace("AddVote", "hashpos", vote.PosHash, "block", vote.ParentNum+1)
+	go self.voteWorkFeed.Send(core.NewVoteEvent{vote})
+	self.SendVoteEvent(vote)
+	self.votes[hash] = time.Now()
+	return nil
+}
+
+// promoteVotes checks again the unverified votes of a lottery, or all of them
+// if nil, relaying the ones confirmed and dropping the invalid or stale ones.
+func (self *Voter) promoteVotes(posHash *common.Hash) {
+	var votes []*types.Vote
+	self.voteMu.RLock()
+	for _, vote := range self.unverified {
+		if posHash == nil || vote.PosHash == *posHash {
+			votes = append(votes, vote)
+		}
+	}
+	self.voteMu.RUnlock()
 
 	current := self.chain.CurrentBlock().Number().Uint64()
-	if current > vote.ParentNum+delayNum {
-		log.Trace("AddVote droped", "current", current, "voteBlock", vote.ParentNum+1)
-		return
+	for _, vote := range votes {
+		hash := vote.Hash()
+		err := ErrStaleVote
+		if current <= vote.ParentNum+delayNum {
+			err = self.verifyVote(vote)
+		}
+		switch err {
+		case nil:
+			self.relayVote(vote, hash)
+		case ErrUnverifiedVote:
+		default:
+			if err == ErrInvalidVote {
+				voteInvalidMeter.Mark(1)
+			}
+			self.voteMu.Lock()
+			delete(self.unverified, hash)
+			self.voteMu.Unlock()
+		}
 	}
-	_, exits := self.votes[vote.Hash()]
-	if !exits {
-		log.Trace("AddVote", "hashpos", vote.PosHash, "block", vote.ParentNum+1)
-		go self.voteWorkFeed.Send(core.NewVoteEvent{vote})
-		self.SendVoteEvent(vote)
-		self.votes[vote.Hash()] = time.Now()
+}
+
+// verifyVote checks a vote against the stake state of its block. The parent of
+// the block is the one of its lottery if known, the canonical one otherwise, in
+// which case a vote of a known share failing the check may still be valid on
+// another branch.
+func (self *Voter) verifyVote(vote *types.Vote) error {
+	var parent *types.Header
+	self.lotteryMu.RLock()
+	lottery := self.lotterys[vote.PosHash]
+	self.lotteryMu.RUnlock()
+	if lottery != nil {
+		parent = self.chain.GetHeader(lottery.lottery.ParentHash, vote.ParentNum)
+	} else {
+		parent = self.chain.GetHeaderByNumber(vote.ParentNum)
+	}
+	if parent == nil {
+		return ErrUnverifiedVote
+	}
+	self.stakeMu.Lock()
+	defer self.stakeMu.Unlock()
+
+	stakeState, err := self.stakeStateAt(parent)
+	if err != nil {
+		return ErrUnverifiedVote
+	}
+	// Shares vote long after they are bought, whatever the branch of the block
+	// one unknown to its parent is forged
+	if stakeState.GetShare(vote.ShareId) == nil {
+		log.Debug("AddVote unknown share", "hashpos", vote.PosHash, "block", vote.ParentNum+1, "share", vote.ShareId)
+		return ErrInvalidVote
+	}
+	if err := stakeState.VerifyVote(vote, parent.HashPos()); err != nil {
+		log.Debug("AddVote verify failed", "hashpos", vote.PosHash, "block", vote.ParentNum+1, "share", vote.ShareId, "err", err)
+		if lottery == nil {
+			return ErrUnverifiedVote
+		}
+		return ErrInvalidVote
+	}
+	if lottery != nil {
+		voteLatencyTimer.UpdateSince(lottery.seen)
+	}
+	return nil
+}
+
+// stakeStateAt returns the stake state the block following the given parent is
+// voted with, caching it for the other votes of the block. The stake lock must be
+// held, as the state is not safe for concurrent use.
+func (self *Voter) stakeStateAt(parent *types.Header) (*stake.StakeState, error) {
+	hash := parent.Hash()
+	if cached, ok := self.stakeStates.Get(hash); ok {
+		return cached.(*stake.StakeState), nil
+	}
+	state, err := self.chain.StateAt(parent)
+	if err != nil {
+		return nil, err
+	}
+	stakeState := stake.NewStakeState(state)
+	header := &types.Header{
+		ParentHash: hash,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+	}
+	if err := stakeState.ProcessBeforeApply(self.chain, header); err != nil {
+		return nil, err
 	}
+	self.stakeStates.Add(hash, stakeState)
+	return stakeState, nil
 }
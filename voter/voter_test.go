@@ -0,0 +1,242 @@
+package voter
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+// testChain is a chain starting with a genesis block whose stake state holds a
+// single share bought without a pool. Its head stays the genesis, the headers
+// imported after it are only known as the parents of the votes.
+type testChain struct {
+	db      serodb.Database
+	genesis *types.Block
+	headers []*types.Header
+	share   *stake.Share
+}
+
+func newTestChain() *testChain {
+	share := &stake.Share{Value: big.NewInt(1), InitNum: 1}
+	copy(share.VotePKr[:], []byte("solo share vote key"))
+	genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})
+	return &testChain{
+		db:      serodb.NewMemDatabase(),
+		genesis: genesis,
+		headers: []*types.Header{genesis.Header()},
+		share:   share,
+	}
+}
+
+// importHeader adds a header on top of the chain.
+func (c *testChain) importHeader() *types.Header {
+	parent := c.headers[len(c.headers)-1]
+	header := &types.Header{ParentHash: parent.Hash(), Number: new(big.Int).Add(parent.Number, common.Big1)}
+	c.headers = append(c.headers, header)
+	return header
+}
+
+func (c *testChain) CurrentBlock() *types.Block { return c.genesis }
+func (c *testChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+func (c *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+func (c *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(c.headers)) {
+		return nil
+	}
+	return c.headers[number]
+}
+func (c *testChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if header := c.GetHeader(hash, number); header != nil {
+		return types.NewBlockWithHeader(header)
+	}
+	return nil
+}
+func (c *testChain) GetDB() serodb.Database { return c.db }
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return new(event.Feed).Subscribe(ch)
+}
+func (c *testChain) StateAt(header *types.Header) (*state.StateDB, error) {
+	statedb, err := state.New(state.NewDatabase(c.db), nil)
+	if err != nil {
+		return nil, err
+	}
+	stake.NewStakeState(statedb).AddPendingShare(c.share)
+	return statedb, nil
+}
+
+func newTestVoter(chain *testChain) *Voter {
+	voter := &Voter{
+		chain:      chain,
+		votes:      make(map[common.Hash]time.Time),
+		unverified: make(map[common.Hash]*types.Vote),
+		lotterys:   make(map[common.Hash]*seenLottery),
+	}
+	voter.stakeStates, _ = lru.New(stakeStateCacheSize)
+	return voter
+}
+
+// Tests that a gossiped pool vote naming a share bought without a pool is
+// rejected instead of crashing the node.
+func TestAddPoolVoteOfSoloShare(t *testing.T) {
+	chain := newTestChain()
+	voter := newTestVoter(chain)
+
+	vote := &types.Vote{
+		ShareId: common.BytesToHash(chain.share.Id()),
+		PosHash: common.Hash{0x01},
+		IsPool:  true,
+		Sign:    keys.Uint512{0x02},
+	}
+	lottery := &types.Lottery{ParentHash: chain.genesis.Hash(), PosHash: vote.PosHash}
+	voter.lotterys[vote.PosHash] = &seenLottery{lottery: lottery, seen: time.Now(), relayed: true}
+
+	if err := voter.AddVote(vote); err != ErrInvalidVote {
+		t.Fatalf("pool vote of a solo share: have %v, want %v", err, ErrInvalidVote)
+	}
+	if _, ok := voter.votes[vote.Hash()]; ok {
+		t.Error("invalid vote recorded for relay")
+	}
+}
+
+// Tests that votes which can't be checked yet are kept without being relayed,
+// until their parent block is imported.
+func TestAddUnverifiedVote(t *testing.T) {
+	chain := newTestChain()
+	voter := newTestVoter(chain)
+
+	vote := &types.Vote{ParentNum: 1, ShareId: common.BytesToHash(chain.share.Id()), PosHash: common.Hash{0x02}}
+	if err := voter.AddVote(vote); err != ErrUnverifiedVote {
+		t.Fatalf("vote of an unknown parent: have %v, want %v", err, ErrUnverifiedVote)
+	}
+	if _, ok := voter.votes[vote.Hash()]; ok {
+		t.Fatal("unverified vote recorded for relay")
+	}
+	if err := voter.AddVote(vote); err != ErrKnownVote {
+		t.Errorf("unverified vote added again: have %v, want %v", err, ErrKnownVote)
+	}
+	voter.promoteVotes(nil)
+	if _, ok := voter.unverified[vote.Hash()]; !ok {
+		t.Fatal("unverified vote dropped before its parent is known")
+	}
+	chain.importHeader()
+	voter.promoteVotes(nil)
+	if _, ok := voter.votes[vote.Hash()]; !ok {
+		t.Error("vote not relayed once its parent is known")
+	}
+	if _, ok := voter.unverified[vote.Hash()]; ok {
+		t.Error("relayed vote still unverified")
+	}
+}
+
+// Tests that votes naming a share unknown to the canonical parent are rejected.
+func TestAddVoteOfUnknownShare(t *testing.T) {
+	voter := newTestVoter(newTestChain())
+
+	vote := &types.Vote{ShareId: common.Hash{0x01}, PosHash: common.Hash{0x02}}
+	if err := voter.AddVote(vote); err != ErrInvalidVote {
+		t.Fatalf("vote of an unknown share: have %v, want %v", err, ErrInvalidVote)
+	}
+	if _, ok := voter.unverified[vote.Hash()]; ok {
+		t.Error("vote of an unknown share kept")
+	}
+}
+
+// Tests that a vote failing the check against the canonical parent is kept
+// local, and dropped once its lottery shows it is invalid.
+func TestAddVoteRejectedByLottery(t *testing.T) {
+	chain := newTestChain()
+	voter := newTestVoter(chain)
+
+	vote := &types.Vote{
+		ShareId: common.BytesToHash(chain.share.Id()),
+		PosHash: common.Hash{0x01},
+		IsPool:  true,
+	}
+	if err := voter.AddVote(vote); err != ErrUnverifiedVote {
+		t.Fatalf("vote without lottery: have %v, want %v", err, ErrUnverifiedVote)
+	}
+	if err := voter.AddLottery(&types.Lottery{ParentHash: chain.genesis.Hash(), PosHash: vote.PosHash}); err != nil {
+		t.Fatalf("failed to add lottery: %v", err)
+	}
+	if _, ok := voter.unverified[vote.Hash()]; ok {
+		t.Error("invalid vote still kept")
+	}
+	if _, ok := voter.votes[vote.Hash()]; ok {
+		t.Error("invalid vote relayed")
+	}
+}
+
+// Tests that lotteries are checked against their parent block, and relayed
+// only once it is known.
+func TestAddLottery(t *testing.T) {
+	chain := newTestChain()
+	voter := newTestVoter(chain)
+
+	lotteries := make(chan core.NewLotteryEvent, 1)
+	defer voter.SubscribeNewLotteryEvent(lotteries).Unsubscribe()
+
+	for _, test := range []struct {
+		lottery *types.Lottery
+		err     error
+	}{
+		{&types.Lottery{ParentHash: chain.genesis.Hash()}, ErrInvalidVote},
+		{&types.Lottery{PosHash: common.Hash{0x01}}, ErrInvalidVote},
+		{&types.Lottery{ParentHash: chain.genesis.Hash(), ParentNum: 1, PosHash: common.Hash{0x02}}, ErrInvalidVote},
+		{&types.Lottery{ParentHash: common.Hash{0x03}, ParentNum: maxLotteryAhead + 1, PosHash: common.Hash{0x03}}, ErrUnverifiedVote},
+	} {
+		if err := voter.AddLottery(test.lottery); err != test.err {
+			t.Errorf("lottery %x: have %v, want %v", test.lottery.PosHash[:1], err, test.err)
+		}
+		if _, ok := voter.lotterys[test.lottery.PosHash]; ok {
+			t.Errorf("lottery %x kept", test.lottery.PosHash[:1])
+		}
+	}
+
+	// A lottery of an unknown parent is kept, and relayed once it is imported
+	parent := &types.Header{ParentHash: chain.genesis.Hash(), Number: big.NewInt(1)}
+	lottery := &types.Lottery{ParentHash: parent.Hash(), ParentNum: 1, PosHash: common.Hash{0x04}}
+	if err := voter.AddLottery(lottery); err != ErrUnverifiedVote {
+		t.Fatalf("lottery of an unknown parent: have %v, want %v", err, ErrUnverifiedVote)
+	}
+	if err := voter.AddLottery(lottery); err != ErrKnownVote {
+		t.Errorf("lottery added again: have %v, want %v", err, ErrKnownVote)
+	}
+	voter.relayLotterys()
+	select {
+	case <-lotteries:
+		t.Fatal("lottery of an unknown parent relayed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	chain.importHeader()
+	voter.relayLotterys()
+	select {
+	case ev := <-lotteries:
+		if ev.Lottery.PosHash != lottery.PosHash {
+			t.Errorf("relayed lottery mismatch: have %x, want %x", ev.Lottery.PosHash, lottery.PosHash)
+		}
+	case <-time.After(time.Second):
+		t.Error("lottery not relayed once its parent is known")
+	}
+}
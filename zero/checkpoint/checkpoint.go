@@ -1 +1,141 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package checkpoint keeps the trusted (number, hash, stake state hash) points
+// of every known network. Chains conflicting with a checkpoint are rejected and
+// fast sync starts from the latest one.
 package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/params"
+)
+
+var (
+	ErrHashMismatch  = errors.New("checkpoint: block hash mismatch")
+	ErrStakeMismatch = errors.New("checkpoint: stake state mismatch")
+)
+
+// Set is an immutable list of checkpoints sorted by number.
+type Set struct {
+	list []params.Checkpoint
+}
+
+func NewSet(cps []params.Checkpoint) *Set {
+	list := make([]params.Checkpoint, 0, len(cps))
+	seen := make(map[uint64]int)
+	for _, cp := range cps {
+		if i, ok := seen[cp.Number]; ok {
+			list[i] = cp
+			continue
+		}
+		seen[cp.Number] = len(list)
+		list = append(list, cp)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Number < list[j].Number
+	})
+	return &Set{list}
+}
+
+func (self *Set) Len() int {
+	if self == nil {
+		return 0
+	}
+	return len(self.list)
+}
+
+func (self *Set) List() []params.Checkpoint {
+	if self == nil {
+		return nil
+	}
+	return append([]params.Checkpoint{}, self.list...)
+}
+
+func (self *Set) Latest() *params.Checkpoint {
+	if self.Len() == 0 {
+		return nil
+	}
+	cp := self.list[len(self.list)-1]
+	return &cp
+}
+
+// LatestBelow returns the highest checkpoint whose number is not above num.
+func (self *Set) LatestBelow(num uint64) *params.Checkpoint {
+	if self.Len() == 0 {
+		return nil
+	}
+	i := sort.Search(len(self.list), func(i int) bool {
+		return self.list[i].Number > num
+	})
+	if i == 0 {
+		return nil
+	}
+	cp := self.list[i-1]
+	return &cp
+}
+
+func (self *Set) Get(num uint64) *params.Checkpoint {
+	if cp := self.LatestBelow(num); cp != nil && cp.Number == num {
+		return cp
+	}
+	return nil
+}
+
+func (self *Set) VerifyHeader(header *types.Header) error {
+	cp := self.Get(header.Number.Uint64())
+	if cp == nil || cp.Hash == header.Hash() {
+		return nil
+	}
+	return fmt.Errorf("%v: number %v, have %x, want %x", ErrHashMismatch, cp.Number, header.Hash(), cp.Hash)
+}
+
+func (self *Set) VerifyStake(num uint64, stakeHash common.Hash) error {
+	cp := self.Get(num)
+	if cp == nil || cp.StakeHash == (common.Hash{}) || cp.StakeHash == stakeHash {
+		return nil
+	}
+	return fmt.Errorf("%v: number %v, have %x, want %x", ErrStakeMismatch, num, stakeHash, cp.StakeHash)
+}
+
+var (
+	sets = map[common.Hash]*Set{
+		params.MainnetGenesisHash:  NewSet(params.MainnetCheckpoints),
+		params.AlphanetGenesisHash: NewSet(params.AlphanetCheckpoints),
+	}
+	lock sync.RWMutex
+)
+
+// ForGenesis returns the checkpoints of the network starting at genesis, nil if it has none.
+func ForGenesis(genesis common.Hash) *Set {
+	lock.RLock()
+	defer lock.RUnlock()
+	return sets[genesis]
+}
+
+// Register adds checkpoints to the network starting at genesis. A checkpoint
+// replaces a known one of the same number.
+func Register(genesis common.Hash, cps []params.Checkpoint) {
+	lock.Lock()
+	defer lock.Unlock()
+	sets[genesis] = NewSet(append(sets[genesis].List(), cps...))
+}
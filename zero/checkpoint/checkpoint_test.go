@@ -0,0 +1,105 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/params"
+)
+
+func testHeader(num int64) *types.Header {
+	return &types.Header{Number: big.NewInt(num), Difficulty: big.NewInt(1), Time: big.NewInt(num)}
+}
+
+func TestSet(t *testing.T) {
+	h10, h20 := testHeader(10), testHeader(20)
+	set := NewSet([]params.Checkpoint{
+		{Number: 20, Hash: h20.Hash()},
+		{Number: 10, Hash: common.HexToHash("0x01")},
+		{Number: 10, Hash: h10.Hash(), StakeHash: common.HexToHash("0x02")},
+	})
+	if set.Len() != 2 {
+		t.Fatalf("checkpoint count mismatch: have %v, want 2", set.Len())
+	}
+	if latest := set.Latest(); latest.Number != 20 {
+		t.Errorf("latest checkpoint mismatch: have %v, want 20", latest.Number)
+	}
+	if cp := set.LatestBelow(15); cp == nil || cp.Number != 10 {
+		t.Errorf("checkpoint below 15 mismatch: have %v", cp)
+	}
+	if cp := set.LatestBelow(9); cp != nil {
+		t.Errorf("unexpected checkpoint below 9: %v", cp)
+	}
+	if err := set.VerifyHeader(h10); err != nil {
+		t.Errorf("checkpoint header rejected: %v", err)
+	}
+	if err := set.VerifyHeader(testHeader(11)); err != nil {
+		t.Errorf("header without checkpoint rejected: %v", err)
+	}
+	fork := testHeader(20)
+	fork.Extra = []byte("fork")
+	if err := set.VerifyHeader(fork); err == nil {
+		t.Errorf("conflicting header accepted")
+	}
+	if err := set.VerifyStake(10, common.HexToHash("0x03")); err == nil {
+		t.Errorf("conflicting stake state accepted")
+	}
+	if err := set.VerifyStake(20, common.HexToHash("0x03")); err != nil {
+		t.Errorf("checkpoint without stake hash rejected: %v", err)
+	}
+
+	var empty *Set
+	if empty.Latest() != nil || empty.VerifyHeader(h10) != nil {
+		t.Errorf("empty set should accept everything")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	genesis := common.HexToHash("0xfeed")
+
+	file := &File{
+		Genesis:     genesis,
+		Checkpoints: []params.Checkpoint{{Number: 100, Hash: common.HexToHash("0x64")}},
+	}
+	if err := file.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(file)
+	dir, _ := ioutil.TempDir("", "checkpoint-test")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoints.json")
+	ioutil.WriteFile(path, data, 0644)
+
+	if _, err := LoadFile(path, nil); err != ErrNoSigners {
+		t.Errorf("file loaded without signers: %v", err)
+	}
+	if _, err := LoadFile(path, [][]byte{crypto.FromECDSAPub(&other.PublicKey)}); err != ErrUntrustedSigner {
+		t.Errorf("file of untrusted signer loaded: %v", err)
+	}
+	if ForGenesis(genesis) != nil {
+		t.Fatalf("untrusted checkpoints registered")
+	}
+	if _, err := LoadFile(path, [][]byte{crypto.FromECDSAPub(&key.PublicKey)}); err != nil {
+		t.Fatalf("failed to load signed file: %v", err)
+	}
+	if cp := ForGenesis(genesis).Get(100); cp == nil || cp.Hash != common.HexToHash("0x64") {
+		t.Errorf("signed checkpoint not registered: %v", cp)
+	}
+}
+
+func TestBuiltinCheckpoints(t *testing.T) {
+	for _, genesis := range []common.Hash{params.MainnetGenesisHash, params.AlphanetGenesisHash} {
+		if cp := ForGenesis(genesis).Get(0); cp == nil || cp.Hash != genesis {
+			t.Errorf("genesis %x not pinned: %v", genesis, cp)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rlp"
+)
+
+var (
+	ErrNoSigners       = errors.New("checkpoint: no trusted signers")
+	ErrUntrustedSigner = errors.New("checkpoint: file is not signed by a trusted signer")
+)
+
+// File is a signed list of checkpoints for one network, stored as JSON.
+type File struct {
+	Genesis     common.Hash         `json:"genesis"`
+	Checkpoints []params.Checkpoint `json:"checkpoints"`
+	Signature   hexutil.Bytes       `json:"signature"`
+}
+
+// SigHash is the hash signed by the file signer.
+func (self *File) SigHash() common.Hash {
+	data, _ := rlp.EncodeToBytes([]interface{}{self.Genesis, self.Checkpoints})
+	return crypto.Keccak256Hash(data)
+}
+
+func (self *File) Sign(key *ecdsa.PrivateKey) (e error) {
+	hash := self.SigHash()
+	self.Signature, e = crypto.Sign(hash[:], key)
+	return
+}
+
+// Signer returns the uncompressed public key that signed the file.
+func (self *File) Signer() ([]byte, error) {
+	hash := self.SigHash()
+	pub, err := crypto.SigToPub(hash[:], self.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.FromECDSAPub(pub), nil
+}
+
+// Verify checks the file is signed by one of signers.
+func (self *File) Verify(signers [][]byte) error {
+	if len(signers) == 0 {
+		return ErrNoSigners
+	}
+	signer, err := self.Signer()
+	if err != nil {
+		return err
+	}
+	for _, trusted := range signers {
+		if bytes.Equal(trusted, signer) {
+			return nil
+		}
+	}
+	return ErrUntrustedSigner
+}
+
+// LoadFile reads a signed checkpoint file and registers its checkpoints once
+// the signature is verified against signers.
+func LoadFile(path string, signers [][]byte) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	if err := file.Verify(signers); err != nil {
+		return nil, err
+	}
+	Register(file.Genesis, file.Checkpoints)
+	log.Info("Loaded signed checkpoints", "genesis", file.Genesis, "count", len(file.Checkpoints))
+	return file, nil
+}
@@ -386,6 +386,12 @@ func (self *Cons) fetchBlockRecords() (ret []*Record) {
 	return
 }
 
+// BlockRecords returns the records of the objects changed by the block being
+// processed.
+func (self *Cons) BlockRecords() []*Record {
+	return self.fetchBlockRecords()
+}
+
 func (self *Cons) ReportConItems(name string, items consItems, num uint64) {
 	return
 	fmt.Printf("%v REPORT ITEMS: num=%v\n", name, num)
@@ -46,8 +46,11 @@ func (self *BlockGet) Unserial(v []byte) (e error) {
 		}
 	}
 }
+
+const BlockPrefix = "$SERO_ZSTATE_BLOCK_SHOOTCUT$"
+
 func BlockKey(num uint64, hash *keys.Uint256) []byte {
-	block_key := []byte("$SERO_ZSTATE_BLOCK_SHOOTCUT$")
+	block_key := []byte(BlockPrefix)
 	block_key = append(block_key, big.NewInt(int64(num)).Bytes()...)
 	block_key = append(block_key, []byte("$")...)
 	block_key = append(block_key, hash[:]...)
@@ -98,8 +98,10 @@ func (self *OutState0Get) Unserial(v []byte) (e error) {
 	}
 }
 
+const OutStatePrefix = "$SERO_LOCALDB_OUTSTATE$"
+
 func OutKey(root *keys.Uint256) []byte {
-	key := []byte("$SERO_LOCALDB_OUTSTATE$")
+	key := []byte(OutStatePrefix)
 	key = append(key, root[:]...)
 	return key
 }
@@ -43,8 +43,10 @@ func (self *OutStatGet) Unserial(v []byte) (e error) {
 	}
 }
 
+const OutStatPrefix = "$ZSTATE_OUT_STAT$"
+
 func outStatName(root *keys.Uint256) (ret []byte) {
-	ret = []byte("$ZSTATE_OUT_STAT$")
+	ret = []byte(OutStatPrefix)
 	ret = append(ret, root[:]...)
 	return
 }
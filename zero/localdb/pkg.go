@@ -56,8 +56,10 @@ func (self *PkgGet) Unserial(v []byte) (e error) {
 	}
 }
 
+const PkgPrefix = "$SERO_LOCALDB_PKG_HASH$"
+
 func PkgKey(root *keys.Uint256) []byte {
-	key := []byte("$SERO_LOCALDB_PKG_HASH$")
+	key := []byte(PkgPrefix)
 	key = append(key, root[:]...)
 	return key
 }
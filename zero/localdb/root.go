@@ -40,14 +40,19 @@ func (self *RootStateGet) Unserial(v []byte) (e error) {
 	}
 }
 
+const (
+	RootStatePrefix = "$SERO_LOCALDB_ROOTSTATE$"
+	RootCMPrefix    = "$SERO_LOCALDB_ROOTCM2ROOT$"
+)
+
 func Root2TxHashKey(root *keys.Uint256) []byte {
-	key := []byte("$SERO_LOCALDB_ROOTSTATE$")
+	key := []byte(RootStatePrefix)
 	key = append(key, root[:]...)
 	return key
 }
 
 func RootCM2RootKey(root_cm *keys.Uint256) []byte {
-	key := []byte("$SERO_LOCALDB_ROOTCM2ROOT$")
+	key := []byte(RootCMPrefix)
 	key = append(key, root_cm[:]...)
 	return key
 }
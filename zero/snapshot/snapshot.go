@@ -0,0 +1,571 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot exports and imports the full state of the head block, so a
+// new node can start syncing from that block instead of from genesis.
+//
+// A snapshot is an RLP stream: a Meta item followed by Entry items and a final
+// entryEnd item carrying the keccak256 content hash of everything before it.
+// The entries start with the ancestors of the block the stake processing of
+// the next blocks reads, from the parent down.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/crypto/sha3"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
+	"github.com/sero-cash/go-sero/zero/consensus"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+const Version = 1
+
+const (
+	entryNode   = 0 // trie node or contract code, keyed by its hash
+	entryGlobal = 1 // zero and stake data stored outside of the trie
+	entryHeader = 2 // header of an ancestor, keyed by its hash
+	entryBlock  = 3 // the parent block, keyed by its hash
+	entryEnd    = 0xff
+)
+
+var (
+	errNoMeta      = errors.New("snapshot: missing meta")
+	errNoEnd       = errors.New("snapshot: unexpected end of stream")
+	errContentHash = errors.New("snapshot: content hash mismatch")
+	errGenesis     = errors.New("snapshot: the genesis block has no snapshot")
+)
+
+// global is a database prefix of the zstate, pkgstate and stake objects that
+// the state trie references by hash, with the check an imported entry of it
+// has to pass. The check of a prefix may read the entries of earlier ones.
+// A prefix with a key function only has the entry of the snapshot block.
+type global struct {
+	prefix string
+	check  func(db serodb.Getter, id []byte, value []byte) error
+	key    func(block *types.Block) []byte
+}
+
+var globals = []global{
+	{localdb.RootStatePrefix, checkRootState, nil},
+	{localdb.RootCMPrefix, checkRootCM, nil},
+	{localdb.OutStatePrefix, checkOutState, nil},
+	{localdb.PkgPrefix, checkPkg, nil},
+	{localdb.OutStatPrefix, checkOutStat, nil},
+	{stake.ShareDB.Pre, checkShare, nil},
+	{stake.StakePoolDB.Pre, checkStakePool, nil},
+	{stake.BlockVotesPrefix, checkBlockVotes, nil},
+	{state.StakeDB.Pre, checkBlockRecords, nil},
+	{localdb.BlockPrefix, checkBlock, blockKey},
+}
+
+// blockKey is the key of the roots, nils and packages the block added to the
+// zstate.
+func blockKey(block *types.Block) []byte {
+	return localdb.BlockKey(block.NumberU64(), block.Hash().HashToUint256())
+}
+
+// globalIndex returns the index of the global prefix of key, or -1.
+func globalIndex(key []byte) int {
+	for i, g := range globals {
+		if bytes.HasPrefix(key, []byte(g.prefix)) {
+			return i
+		}
+	}
+	return -1
+}
+
+type Meta struct {
+	Version uint64
+	Genesis common.Hash
+	Block   *types.Block
+	Td      *big.Int
+}
+
+type Entry struct {
+	Kind  uint8
+	Key   []byte
+	Value []byte
+}
+
+// Database is the chain database a snapshot is exported from.
+type Database interface {
+	serodb.Database
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+}
+
+type hashWriter struct {
+	w      io.Writer
+	hasher hash.Hash
+	count  uint64
+}
+
+func (self *hashWriter) write(val interface{}) error {
+	b, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	self.hasher.Write(b)
+	self.count++
+	_, err = self.w.Write(b)
+	return err
+}
+
+// Export writes the state of block and returns the content hash of the snapshot.
+// The globals are not stored per block, so block has to be the head of db.
+func Export(db Database, block *types.Block, td *big.Int, w io.Writer) (hash common.Hash, err error) {
+	if head := rawdb.ReadHeadBlockHash(db); head != block.Hash() {
+		err = fmt.Errorf("snapshot: block %v is not the head %x", block.NumberU64(), head)
+		return
+	}
+	if block.NumberU64() == 0 {
+		err = errGenesis
+		return
+	}
+	statedb, err := state.New(state.NewDatabase(db), block.Header())
+	if err != nil {
+		return
+	}
+	hw := &hashWriter{w: w, hasher: sha3.NewKeccak256()}
+	if err = hw.write(&Meta{Version, rawdb.ReadCanonicalHash(db, 0), block, td}); err != nil {
+		return
+	}
+	if err = exportAncestors(db, block, hw); err != nil {
+		return
+	}
+	ancestors := hw.count - 1
+
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		blob, e := statedb.Database().TrieDB().Node(it.Hash)
+		if e != nil {
+			return hash, e
+		}
+		if err = hw.write(&Entry{entryNode, it.Hash[:], blob}); err != nil {
+			return
+		}
+	}
+	if it.Error != nil {
+		return hash, it.Error
+	}
+	nodes := hw.count - 1 - ancestors
+
+	for _, g := range globals {
+		if g.key != nil {
+			key := g.key(block)
+			value, e := db.Get(key)
+			if e != nil {
+				return hash, fmt.Errorf("snapshot: missing global %x: %v", key, e)
+			}
+			if err = hw.write(&Entry{entryGlobal, key, value}); err != nil {
+				return
+			}
+			continue
+		}
+		dbit := db.NewIteratorWithPrefix([]byte(g.prefix))
+		for dbit.Next() {
+			if err = hw.write(&Entry{entryGlobal, dbit.Key(), dbit.Value()}); err != nil {
+				dbit.Release()
+				return
+			}
+		}
+		dbit.Release()
+		if err = dbit.Error(); err != nil {
+			return
+		}
+	}
+
+	hw.hasher.Sum(hash[:0])
+	if err = rlp.Encode(w, &Entry{entryEnd, hash[:], nil}); err != nil {
+		return
+	}
+	log.Info("Exported snapshot", "number", block.NumberU64(), "hash", block.Hash(), "ancestors", ancestors, "nodes", nodes, "globals", hw.count-1-ancestors-nodes, "content", hash)
+	return
+}
+
+// exportAncestors writes the parent of block and the headers of the ancestors
+// before it, down to the stake ancestor window or the genesis.
+func exportAncestors(db Database, block *types.Block, hw *hashWriter) error {
+	hash, number := block.ParentHash(), block.NumberU64()-1
+	for i := uint64(0); i < stake.GetAncestorWindow() && number > 0; i++ {
+		var entry *Entry
+		if i == 0 {
+			parent := rawdb.ReadBlock(db, hash, number)
+			if parent == nil {
+				return fmt.Errorf("snapshot: missing block %v", number)
+			}
+			value, err := rlp.EncodeToBytes(parent)
+			if err != nil {
+				return err
+			}
+			entry = &Entry{entryBlock, hash[:], value}
+		} else {
+			value := rawdb.ReadHeaderRLP(db, hash, number)
+			if len(value) == 0 {
+				return fmt.Errorf("snapshot: missing header %v", number)
+			}
+			entry = &Entry{entryHeader, hash[:], value}
+		}
+		if err := hw.write(entry); err != nil {
+			return err
+		}
+		header := rawdb.ReadHeader(db, hash, number)
+		hash, number = header.ParentHash, number-1
+	}
+	return nil
+}
+
+// Import reads a snapshot into db, verifies every trie node against the
+// header's state root and makes the snapshot block the head of the chain.
+// Global entries must come in the order of their prefixes and are checked
+// before they are written, so a bad entry never reaches the database.
+//
+// The content hash only proves the snapshot intact: its block has to be the
+// trusted one, or a checkpoint of the network if trusted is zero, before
+// anything is written.
+func Import(db serodb.Database, r io.Reader, trusted common.Hash) (block *types.Block, hash common.Hash, err error) {
+	stream := rlp.NewStream(r, 0)
+	hasher := sha3.NewKeccak256()
+
+	var raw rlp.RawValue
+	if err = stream.Decode(&raw); err != nil {
+		if err == io.EOF {
+			err = errNoMeta
+		}
+		return
+	}
+	hasher.Write(raw)
+	meta := Meta{}
+	if err = rlp.DecodeBytes(raw, &meta); err != nil {
+		return
+	}
+	if meta.Version != Version {
+		err = fmt.Errorf("snapshot: unsupported version %v", meta.Version)
+		return
+	}
+	if genesis := rawdb.ReadCanonicalHash(db, 0); genesis != meta.Genesis {
+		err = fmt.Errorf("snapshot: genesis mismatch: have %x, want %x", genesis, meta.Genesis)
+		return
+	}
+	block = meta.Block
+	if block == nil {
+		err = errNoMeta
+		return
+	}
+	if block.NumberU64() == 0 {
+		err = errGenesis
+		return
+	}
+	checkpoints := checkpoint.ForGenesis(meta.Genesis)
+	if err = authenticate(checkpoints, block, trusted); err != nil {
+		return
+	}
+
+	batch := db.NewBatch()
+	current := 0
+	parent, number := block.ParentHash(), block.NumberU64()-1
+	for {
+		if err = stream.Decode(&raw); err != nil {
+			if err == io.EOF {
+				err = errNoEnd
+			}
+			return
+		}
+		entry := Entry{}
+		if err = rlp.DecodeBytes(raw, &entry); err != nil {
+			return
+		}
+		if entry.Kind == entryEnd {
+			hasher.Sum(hash[:0])
+			if !bytes.Equal(hash[:], entry.Key) {
+				err = errContentHash
+				return
+			}
+			break
+		}
+		hasher.Write(raw)
+
+		switch entry.Kind {
+		case entryHeader, entryBlock:
+			// The ancestors come from the parent down, only the parent is
+			// a full block
+			header := new(types.Header)
+			var ancestor *types.Block
+			if entry.Kind == entryBlock {
+				ancestor = new(types.Block)
+				if err = rlp.DecodeBytes(entry.Value, ancestor); err != nil {
+					return
+				}
+				header = ancestor.Header()
+			} else if err = rlp.DecodeBytes(entry.Value, header); err != nil {
+				return
+			}
+			if number == 0 || header.Number.Uint64() != number || header.Hash() != parent || !bytes.Equal(entry.Key, parent[:]) ||
+				(ancestor != nil) != (number+1 == block.NumberU64()) {
+				err = fmt.Errorf("snapshot: unexpected ancestor %x", entry.Key)
+				return
+			}
+			if ancestor != nil {
+				if types.DeriveSha(ancestor.Transactions()) != header.TxHash {
+					err = fmt.Errorf("snapshot: transactions of block %v do not match its header", number)
+					return
+				}
+				rawdb.WriteBlock(batch, ancestor)
+			} else {
+				rawdb.WriteHeader(batch, header)
+			}
+			rawdb.WriteCanonicalHash(batch, parent, number)
+			parent, number = header.ParentHash, number-1
+			continue
+		case entryNode:
+			if !bytes.Equal(crypto.Keccak256(entry.Value), entry.Key) {
+				err = fmt.Errorf("snapshot: node %x does not match its hash", entry.Key)
+				return
+			}
+		case entryGlobal:
+			i := globalIndex(entry.Key)
+			if i < 0 {
+				err = fmt.Errorf("snapshot: unexpected global key %x", entry.Key)
+				return
+			}
+			if i < current {
+				err = fmt.Errorf("snapshot: global key %x out of order", entry.Key)
+				return
+			}
+			if g := globals[i]; g.key != nil && !bytes.Equal(entry.Key, g.key(block)) {
+				err = fmt.Errorf("snapshot: global key %x of another block", entry.Key)
+				return
+			}
+			if i > current {
+				// Later checks read the entries of earlier prefixes.
+				if err = batch.Write(); err != nil {
+					return
+				}
+				batch.Reset()
+				current = i
+			}
+			if e := globals[i].check(db, entry.Key[len(globals[i].prefix):], entry.Value); e != nil {
+				err = fmt.Errorf("snapshot: invalid global %x: %v", entry.Key, e)
+				return
+			}
+		default:
+			err = fmt.Errorf("snapshot: unknown entry kind %v", entry.Kind)
+			return
+		}
+		if err = batch.Put(entry.Key, entry.Value); err != nil {
+			return
+		}
+		if batch.ValueSize() >= serodb.IdealBatchSize {
+			if err = batch.Write(); err != nil {
+				return
+			}
+			batch.Reset()
+		}
+	}
+	if err = batch.Write(); err != nil {
+		return
+	}
+
+	if err = Verify(db, block.Header()); err != nil {
+		return
+	}
+	if checkpoints.Get(block.NumberU64()) != nil {
+		statedb, e := state.New(state.NewDatabase(db), block.Header())
+		if e != nil {
+			return block, hash, e
+		}
+		if err = checkpoints.VerifyStake(block.NumberU64(), stake.NewStakeState(statedb).StateHash()); err != nil {
+			return
+		}
+	}
+
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), meta.Td)
+	rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	rawdb.WriteHeadBlockHash(db, block.Hash())
+	rawdb.WriteHeadFastBlockHash(db, block.Hash())
+	rawdb.WriteHeadHeaderHash(db, block.Hash())
+	log.Info("Imported snapshot", "number", block.NumberU64(), "hash", block.Hash(), "content", hash)
+	return
+}
+
+// authenticate makes sure the snapshot block is the trusted one or, if trusted
+// is zero, the one of a checkpoint.
+func authenticate(checkpoints *checkpoint.Set, block *types.Block, trusted common.Hash) error {
+	if trusted != (common.Hash{}) {
+		if block.Hash() != trusted {
+			return fmt.Errorf("snapshot: block %x is not the trusted %x", block.Hash(), trusted)
+		}
+		return nil
+	}
+	if checkpoints.Get(block.NumberU64()) == nil {
+		return fmt.Errorf("snapshot: block %v is not a checkpoint, its hash has to be trusted", block.NumberU64())
+	}
+	return checkpoints.VerifyHeader(block.Header())
+}
+
+// Verify walks the whole state of header, failing on the first missing node,
+// and makes sure the stake shares and pools it references are in db.
+func Verify(db serodb.Database, header *types.Header) error {
+	statedb, err := state.New(state.NewDatabase(db), header)
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return fmt.Errorf("snapshot: state of block %v is incomplete: %v", header.Number, it.Error)
+	}
+	if err := stake.NewStakeState(statedb).CheckShares(); err != nil {
+		return fmt.Errorf("snapshot: stake of block %v is incomplete: %v", header.Number, err)
+	}
+	return nil
+}
+
+func checkRootState(db serodb.Getter, id []byte, value []byte) error {
+	if len(id) != len(keys.Uint256{}) {
+		return errors.New("bad root")
+	}
+	return rlp.DecodeBytes(value, &localdb.RootState{})
+}
+
+// checkRootCM makes sure the out of the mapped root has the root commitment id.
+func checkRootCM(db serodb.Getter, id []byte, value []byte) error {
+	if len(value) != len(keys.Uint256{}) {
+		return errors.New("bad root")
+	}
+	root := keys.Uint256{}
+	copy(root[:], value)
+	rs := localdb.GetRoot(db, &root)
+	if rs == nil {
+		return fmt.Errorf("missing root %x", root)
+	}
+	if !bytes.Equal(rs.OS.ToRootCM()[:], id) {
+		return fmt.Errorf("root %x has another commitment", root)
+	}
+	return nil
+}
+
+func checkOutState(db serodb.Getter, id []byte, value []byte) error {
+	return rlp.DecodeBytes(value, &localdb.OutState{})
+}
+
+func checkPkg(db serodb.Getter, id []byte, value []byte) error {
+	pkg := localdb.ZPkg{}
+	if err := rlp.DecodeBytes(value, &pkg); err != nil {
+		return err
+	}
+	if hash := pkg.ToHash(); !bytes.Equal(hash[:], id) {
+		return errors.New("pkg does not match its hash")
+	}
+	return nil
+}
+
+func checkOutStat(db serodb.Getter, id []byte, value []byte) error {
+	return rlp.DecodeBytes(value, &localdb.OutStat{})
+}
+
+// checkBlock makes sure the roots the block added to the zstate are in db.
+func checkBlock(db serodb.Getter, id []byte, value []byte) error {
+	block := localdb.Block{}
+	if err := rlp.DecodeBytes(value, &block); err != nil {
+		return err
+	}
+	for _, root := range block.Roots {
+		if localdb.GetRoot(db, &root) == nil {
+			return fmt.Errorf("missing root %x", root)
+		}
+	}
+	return nil
+}
+
+func checkShare(db serodb.Getter, id []byte, value []byte) error {
+	share := stake.Share{}
+	if err := rlp.DecodeBytes(value, &share); err != nil {
+		return err
+	}
+	if !bytes.Equal(share.State(), id) {
+		return errors.New("share does not match its state hash")
+	}
+	return nil
+}
+
+func checkStakePool(db serodb.Getter, id []byte, value []byte) error {
+	pool := stake.StakePool{}
+	if err := rlp.DecodeBytes(value, &pool); err != nil {
+		return err
+	}
+	if !bytes.Equal(pool.State(), id) {
+		return errors.New("stake pool does not match its state hash")
+	}
+	return nil
+}
+
+func checkBlockVotes(db serodb.Getter, id []byte, value []byte) error {
+	if len(id) != common.HashLength {
+		return errors.New("bad block hash")
+	}
+	return stake.CheckBlockVotes(db, value)
+}
+
+// checkBlockRecords makes sure the stake objects a block recorded are in db.
+func checkBlockRecords(db serodb.Getter, id []byte, value []byte) error {
+	var records []*consensus.Record
+	if err := rlp.DecodeBytes(value, &records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		var obj consensus.DBObj
+		switch record.Name {
+		case "share":
+			obj = stake.ShareDB
+		case "pool":
+			obj = stake.StakePoolDB
+		default:
+			return fmt.Errorf("unknown record %v", record.Name)
+		}
+		for _, pair := range record.Pairs {
+			if len(pair.Hash) == 0 {
+				continue
+			}
+			if ok, _ := db.Has(append([]byte(obj.Pre), pair.Hash...)); !ok {
+				return fmt.Errorf("missing %v %x", record.Name, pair.Hash)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto/sha3"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/checkpoint"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+var testGenesis = common.HexToHash("0x01")
+
+func newTestDB(t *testing.T) (*serodb.LDBDatabase, func()) {
+	dir, err := ioutil.TempDir("", "snapshot-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := serodb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawdb.WriteCanonicalHash(db, testGenesis, 0)
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func makeTestBlock(t *testing.T, db serodb.Database) *types.Block {
+	statedb, _ := state.New(state.NewDatabase(db), nil)
+	for i := byte(1); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, "SERO", big.NewInt(int64(i)*100))
+		if i%4 == 0 {
+			statedb.SetCode(addr, []byte{i, i, i})
+		}
+		statedb.SetState(state.EmptyAddress, common.BytesToHash([]byte{i}), common.BytesToHash([]byte{i, i}))
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	share := testShare()
+	value, _ := rlp.EncodeToBytes(share)
+	db.Put(append([]byte(stake.ShareDB.Pre), share.State()...), value)
+	value, _ = rlp.EncodeToBytes(&localdb.RootState{Num: 3})
+	db.Put(append([]byte(localdb.RootStatePrefix), common.HexToHash("0x0405").Bytes()...), value)
+	db.Put([]byte("unrelated"), []byte("skip"))
+
+	// The ancestors of the block, the parent being a full block
+	parent := testGenesis
+	for i := int64(1); i < 10; i++ {
+		ancestor := types.NewBlock(&types.Header{ParentHash: parent, Number: big.NewInt(i), Difficulty: big.NewInt(1)}, nil, nil)
+		rawdb.WriteBlock(db, ancestor)
+		parent = ancestor.Hash()
+	}
+	header := &types.Header{ParentHash: parent, Number: big.NewInt(10), Root: root, Difficulty: big.NewInt(1)}
+	block := types.NewBlock(header, nil, nil)
+	localdb.PutBlock(db, 10, block.Hash().HashToUint256(), &localdb.Block{Roots: []keys.Uint256{*common.HexToHash("0x0405").HashToUint256()}})
+	rawdb.WriteHeadBlockHash(db, block.Hash())
+	return block
+}
+
+func testShare() *stake.Share {
+	return &stake.Share{TransactionHash: common.HexToHash("0x0102"), Value: big.NewInt(100), Num: 2}
+}
+
+// rewrite decodes a snapshot, lets fn change its entries and encodes it again
+// with a valid content hash.
+func rewrite(t *testing.T, data []byte, fn func([]*Entry) []*Entry) []byte {
+	stream := rlp.NewStream(bytes.NewReader(data), 0)
+	meta := Meta{}
+	if err := stream.Decode(&meta); err != nil {
+		t.Fatal(err)
+	}
+	var entries []*Entry
+	for {
+		entry := new(Entry)
+		if err := stream.Decode(entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Kind == entryEnd {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	buf := new(bytes.Buffer)
+	hw := &hashWriter{w: buf, hasher: sha3.NewKeccak256()}
+	hw.write(&meta)
+	for _, entry := range fn(entries) {
+		hw.write(entry)
+	}
+	hash := common.Hash{}
+	hw.hasher.Sum(hash[:0])
+	rlp.Encode(buf, &Entry{entryEnd, hash[:], nil})
+	return buf.Bytes()
+}
+
+func TestExportImport(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	hash, err := Export(src, block, big.NewInt(42), buf)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	imported, ihash, err := Import(dst, bytes.NewReader(buf.Bytes()), block.Hash())
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if ihash != hash {
+		t.Errorf("content hash mismatch: have %x, want %x", ihash, hash)
+	}
+	if imported.Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", imported.Hash(), block.Hash())
+	}
+	if head := rawdb.ReadHeadBlockHash(dst); head != block.Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head, block.Hash())
+	}
+	if td := rawdb.ReadTd(dst, block.Hash(), block.NumberU64()); td == nil || td.Int64() != 42 {
+		t.Errorf("td mismatch: have %v, want 42", td)
+	}
+
+	statedb, err := state.New(state.NewDatabase(dst), block.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := common.BytesToAddress([]byte{8})
+	if balance := statedb.GetBalance(addr, "SERO"); balance.Int64() != 800 {
+		t.Errorf("balance mismatch: have %v, want 800", balance)
+	}
+	if code := statedb.GetCode(addr); !bytes.Equal(code, []byte{8, 8, 8}) {
+		t.Errorf("code mismatch: have %x", code)
+	}
+	if share := stake.GetShare(dst, common.BytesToHash(testShare().State())); share == nil || share.Num != 2 {
+		t.Errorf("stake share not imported")
+	}
+	if zblock := localdb.GetBlock(dst, 10, block.Hash().HashToUint256()); zblock == nil || len(zblock.Roots) != 1 {
+		t.Errorf("zstate block not imported")
+	}
+	if ok, _ := dst.Has([]byte("unrelated")); ok {
+		t.Errorf("unrelated key should not be exported")
+	}
+}
+
+func TestExportNotHead(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+	rawdb.WriteHeadBlockHash(src, common.HexToHash("0x0b"))
+
+	if _, err := Export(src, block, big.NewInt(1), new(bytes.Buffer)); err == nil {
+		t.Errorf("block below the head exported")
+	}
+}
+
+func TestImportGenesisMismatch(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, block, big.NewInt(1), buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	rawdb.WriteCanonicalHash(dst, common.HexToHash("0x02"), 0)
+	if _, _, err := Import(dst, buf, block.Hash()); err == nil {
+		t.Errorf("snapshot of another network imported")
+	}
+}
+
+func TestImportUntrusted(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, block, big.NewInt(1), buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	for _, trusted := range []common.Hash{{}, common.HexToHash("0x0b")} {
+		dst, closeDst := newTestDB(t)
+		if _, _, err := Import(dst, bytes.NewReader(buf.Bytes()), trusted); err == nil {
+			t.Errorf("trusted %x: untrusted snapshot imported", trusted)
+		}
+		if rawdb.ReadHeader(dst, block.Hash(), block.NumberU64()) != nil {
+			t.Errorf("trusted %x: header of an untrusted snapshot written", trusted)
+		}
+		closeDst()
+	}
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	genesis := common.HexToHash("0x0c")
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	rawdb.WriteCanonicalHash(src, genesis, 0)
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, block, big.NewInt(1), buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	checkpoint.Register(genesis, []params.Checkpoint{{Number: block.NumberU64(), Hash: block.Hash()}})
+
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	rawdb.WriteCanonicalHash(dst, genesis, 0)
+	if _, _, err := Import(dst, bytes.NewReader(buf.Bytes()), common.Hash{}); err != nil {
+		t.Fatalf("checkpointed snapshot rejected: %v", err)
+	}
+	if head := rawdb.ReadHeadBlockHash(dst); head != block.Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head, block.Hash())
+	}
+}
+
+func TestImportGenesis(t *testing.T) {
+	genesis := types.NewBlock(&types.Header{Number: new(big.Int), Difficulty: big.NewInt(1)}, nil, nil)
+	buf := new(bytes.Buffer)
+	hw := &hashWriter{w: buf, hasher: sha3.NewKeccak256()}
+	hw.write(&Meta{Version, testGenesis, genesis, big.NewInt(1)})
+	hash := common.Hash{}
+	hw.hasher.Sum(hash[:0])
+	rlp.Encode(buf, &Entry{entryEnd, hash[:], nil})
+
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	if _, _, err := Import(dst, buf, genesis.Hash()); err != errGenesis {
+		t.Errorf("genesis snapshot error mismatch: have %v, want %v", err, errGenesis)
+	}
+}
+
+func TestImportCorrupted(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, block, big.NewInt(1), buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Drop the trailing content hash entry.
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	if _, _, err := Import(dst, bytes.NewReader(data[:len(data)-35]), block.Hash()); err == nil {
+		t.Errorf("truncated snapshot imported")
+	}
+	if head := rawdb.ReadHeadBlockHash(dst); head == block.Hash() {
+		t.Errorf("truncated snapshot became the head")
+	}
+
+	// Flip a byte in the last value of the stream.
+	corrupted := common.CopyBytes(data)
+	corrupted[len(corrupted)-36] ^= 0xff
+	dst2, closeDst2 := newTestDB(t)
+	defer closeDst2()
+	if _, _, err := Import(dst2, bytes.NewReader(corrupted), block.Hash()); err == nil {
+		t.Errorf("corrupted snapshot imported")
+	}
+}
+
+func TestImportBadGlobals(t *testing.T) {
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	block := makeTestBlock(t, src)
+
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, block, big.NewInt(1), buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	share := testShare()
+	shareKey := append([]byte(stake.ShareDB.Pre), share.State()...)
+
+	tests := []struct {
+		name string
+		fn   func([]*Entry) []*Entry
+	}{
+		{"unexpected key", func(entries []*Entry) []*Entry {
+			return append(entries, &Entry{entryGlobal, []byte("LastBlock"), common.HexToHash("0x01").Bytes()})
+		}},
+		{"tampered share", func(entries []*Entry) []*Entry {
+			share.Num = 100
+			value, _ := rlp.EncodeToBytes(share)
+			for _, entry := range entries {
+				if bytes.Equal(entry.Key, shareKey) {
+					entry.Value = value
+				}
+			}
+			return entries
+		}},
+		{"zstate block of another block", func(entries []*Entry) []*Entry {
+			value, _ := rlp.EncodeToBytes(&localdb.Block{})
+			return append(entries, &Entry{entryGlobal, localdb.BlockKey(9, block.Hash().HashToUint256()), value})
+		}},
+		{"out of order", func(entries []*Entry) []*Entry {
+			value, _ := rlp.EncodeToBytes(&localdb.RootState{})
+			return append(entries, &Entry{entryGlobal, append([]byte(localdb.RootStatePrefix), make([]byte, 32)...), value})
+		}},
+		{"missing voted share", func(entries []*Entry) []*Entry {
+			value, _ := rlp.EncodeToBytes(&struct {
+				Idx    []uint32
+				Shares []common.Hash
+			}{[]uint32{0}, []common.Hash{common.HexToHash("0x09")}})
+			return append(entries, &Entry{entryGlobal, append([]byte(stake.BlockVotesPrefix), block.Hash().Bytes()...), value})
+		}},
+	}
+	for _, tt := range tests {
+		data := rewrite(t, buf.Bytes(), tt.fn)
+		dst, closeDst := newTestDB(t)
+		if _, _, err := Import(dst, bytes.NewReader(data), block.Hash()); err == nil {
+			t.Errorf("%s: snapshot imported", tt.name)
+		}
+		if head := rawdb.ReadHeadBlockHash(dst); head == block.Hash() {
+			t.Errorf("%s: snapshot became the head", tt.name)
+		}
+		if ok, _ := dst.Has([]byte("LastBlock")); ok {
+			t.Errorf("%s: unexpected key written", tt.name)
+		}
+		closeDst()
+	}
+}
@@ -0,0 +1,124 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/vm"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+// Tests that a node importing the snapshot of a chain with staking keeps
+// syncing the blocks after the snapshot block.
+func TestSyncAfterImport(t *testing.T) {
+	cpt.ZeroInit("", cpt.NET_Dev)
+	seroparam.Init_Dev(true)
+	defer seroparam.Init_Dev(false)
+	var (
+		seed   = keys.Uint256{1}
+		sk     = keys.Seed2Sk(&seed)
+		addr   = keys.Seed2Addr(&seed)
+		owner  = keys.Addr2PKr(&addr, &keys.Uint256{1})
+		holder = keys.Addr2PKr(&addr, &keys.Uint256{2})
+		funds  = new(big.Int).Mul(big.NewInt(10000), big.NewInt(1e18))
+		gendb  = serodb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				common.BytesToAddress(owner[:]):  {Balance: funds},
+				common.BytesToAddress(holder[:]): {Balance: funds},
+			},
+		}
+		genesis = gspec.MustCommit(gendb)
+	)
+	rootOf := func(pkr keys.PKr) keys.Uint256 {
+		for _, root := range localdb.GetBlock(gendb, 0, genesis.Hash().HashToUint256()).Roots {
+			if out := localdb.GetRoot(gendb, &root); out != nil && out.OS.Out_O != nil && out.OS.Out_O.Addr == pkr {
+				return root
+			}
+		}
+		t.Fatalf("no genesis output of %s", common.BytesToAddress(pkr[:]).String())
+		return keys.Uint256{}
+	}
+	pool := common.BytesToHash(crypto.Keccak256(owner[:])).HashToUint256()
+	blocks, _ := core.GenerateZeroChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, 16, func(i int, b *core.BlockGen) {
+		switch i {
+		case 0:
+			b.RegistPool(&sk, owner, []keys.Uint256{rootOf(owner)}, owner, seroparam.LOWEST_STAKING_NODE_FEE_RATE, stake.GetPoolValueThreshold())
+			b.BuyShare(&sk, holder, []keys.Uint256{rootOf(holder)}, holder, pool, new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18)))
+		default:
+			for j, share := range b.Lottery() {
+				b.AddVote(&seed, common.BytesToHash(share.Id()), j == 0)
+			}
+		}
+	})
+
+	// Export the state after the shares were bought and voted
+	src, closeSrc := newTestDB(t)
+	defer closeSrc()
+	gspec.MustCommit(src)
+	chain, _ := core.NewBlockChain(src, &core.CacheConfig{Disabled: true}, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	if n, err := chain.InsertChain(blocks[:12]); err != nil {
+		t.Fatalf("block %d: failed to insert into the source chain: %v", n, err)
+	}
+	head := chain.CurrentBlock()
+	td := chain.GetTd(head.Hash(), head.NumberU64())
+	chain.Stop()
+	buf := new(bytes.Buffer)
+	if _, err := Export(src, head, td, buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	// A fresh node imports it and syncs the remaining blocks
+	dst, closeDst := newTestDB(t)
+	defer closeDst()
+	gspec.MustCommit(dst)
+	if _, _, err := Import(dst, buf, head.Hash()); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	chain, _ = core.NewBlockChain(dst, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+	if current := chain.CurrentBlock(); current.Hash() != head.Hash() {
+		t.Fatalf("head mismatch after import: have %d, want %d", current.NumberU64(), head.NumberU64())
+	}
+	if n, err := chain.InsertChain(blocks[12:]); err != nil {
+		t.Fatalf("block %d: failed to insert after the snapshot: %v", n, err)
+	}
+	if current := chain.CurrentBlock(); current.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Errorf("head mismatch after sync: have %d, want %d", current.NumberU64(), len(blocks))
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to get head state: %v", err)
+	}
+	if stake.NewStakeState(statedb).GetStakePool(common.BytesToHash(pool[:])) == nil {
+		t.Errorf("stake pool missing")
+	}
+}
@@ -0,0 +1,114 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the share pool.
+
+package stake
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/metrics"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+var (
+	shareSizeGauge         = metrics.NewRegisteredGauge("stake/shares/size", nil)
+	sharePriceGauge        = metrics.NewRegisteredGaugeFloat64("stake/shares/price", nil)
+	voteParticipationGauge = metrics.NewRegisteredGaugeFloat64("stake/votes/participation", nil)
+	parentVoteMeter        = metrics.NewRegisteredMeter("stake/votes/parent", nil)
+	currentVoteMeter       = metrics.NewRegisteredMeter("stake/votes/current", nil)
+	stakePoolGauge         = metrics.NewRegisteredGauge("stake/pools", nil)
+)
+
+// seroUnit is the number of ta in one SERO.
+var seroUnit = new(big.Float).SetInt(big.NewInt(1e18))
+
+var (
+	stakePoolsLock sync.Mutex
+	stakePools     map[common.Hash]struct{} // Ids of the registered stake pools, nil until counted
+)
+
+// prefixDatabase is a database iterating over the keys of a prefix.
+type prefixDatabase interface {
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+}
+
+// CountStakePools counts the stake pools stored in the chain database for the
+// pool gauge, ReportMetrics then adding the ones registered by the new blocks.
+func CountStakePools(db serodb.Database) {
+	if !metrics.Enabled {
+		return
+	}
+	pools := make(map[common.Hash]struct{})
+	if db, ok := db.(prefixDatabase); ok {
+		it := db.NewIteratorWithPrefix([]byte(StakePoolDB.Pre))
+		for it.Next() {
+			pool := new(StakePool)
+			if err := rlp.DecodeBytes(it.Value(), pool); err == nil {
+				pools[common.BytesToHash(pool.Id())] = struct{}{}
+			}
+		}
+		it.Release()
+	}
+	stakePoolsLock.Lock()
+	defer stakePoolsLock.Unlock()
+
+	stakePools = pools
+	stakePoolGauge.Update(int64(len(stakePools)))
+}
+
+// ReportMetrics updates the share pool gauges with the state after the
+// canonical block of the header.
+func (self *StakeState) ReportMetrics(header *types.Header) {
+	if !metrics.Enabled {
+		return
+	}
+	shareSizeGauge.Update(int64(self.ShareSize()))
+	self.reportStakePools()
+
+	price, _ := new(big.Float).Quo(new(big.Float).SetInt(self.CurrentPrice()), seroUnit).Float64()
+	sharePriceGauge.Update(price)
+
+	voteParticipationGauge.Update(float64(len(header.CurrentVotes)) / MaxVoteCount)
+	currentVoteMeter.Mark(int64(len(header.CurrentVotes)))
+	parentVoteMeter.Mark(int64(len(header.ParentVotes)))
+}
+
+// reportStakePools adds the stake pools changed by the block to the counted
+// ones.
+func (self *StakeState) reportStakePools() {
+	stakePoolsLock.Lock()
+	defer stakePoolsLock.Unlock()
+
+	if stakePools == nil {
+		return
+	}
+	for _, record := range self.statedb.GetStakeCons().BlockRecords() {
+		if record.Name != "pool" {
+			continue
+		}
+		for _, each := range record.Pairs {
+			stakePools[common.BytesToHash(each.Ref)] = struct{}{}
+		}
+	}
+	stakePoolGauge.Update(int64(len(stakePools)))
+}
@@ -275,19 +275,20 @@ type StakeState struct {
 }
 
 var (
-	ShareDB          = consensus.DBObj{"STAKE$SHARE$"}
-	StakePoolDB      = consensus.DBObj{"STAKE$POOL$"}
-	missedNumKey     = []byte("missednum")
-	blockVotesPrefix = []byte("STAKE$BLOCKVOTES$")
+	ShareDB      = consensus.DBObj{"STAKE$SHARE$"}
+	StakePoolDB  = consensus.DBObj{"STAKE$POOL$"}
+	missedNumKey = []byte("missednum")
 )
 
+const BlockVotesPrefix = "STAKE$BLOCKVOTES$"
+
 type selectShare struct {
 	Idx    []uint32
 	Shares []common.Hash
 }
 
 func blockVotesKey(hash common.Hash) []byte {
-	return append(blockVotesPrefix, hash[:]...)
+	return append([]byte(BlockVotesPrefix), hash[:]...)
 }
 
 func (self *StakeState) RecordVotes(batch serodb.Batch, block *types.Block) error {
@@ -333,6 +334,21 @@ func SeleteBlockShare(getter serodb.Getter, block common.Hash) (idx []uint32, sh
 	return
 }
 
+// CheckBlockVotes makes sure data is a votes record of a block whose shares
+// are all in the database.
+func CheckBlockVotes(getter serodb.Getter, data []byte) error {
+	ss := selectShare{}
+	if err := rlp.DecodeBytes(data, &ss); err != nil {
+		return err
+	}
+	for _, hash := range ss.Shares {
+		if ShareDB.GetObject(getter, hash[:], &Share{}) == nil {
+			return fmt.Errorf("missing voted share %x", hash)
+		}
+	}
+	return nil
+}
+
 func NewStakeState(statedb *state.StateDB) *StakeState {
 	cons := statedb.GetStakeCons()
 	stakeState := &StakeState{statedb: statedb}
@@ -437,6 +453,20 @@ func (self *StakeState) ShareSize() uint32 {
 	return tree.size()
 }
 
+// StateHash summarises the share pool, it is pinned by the sync checkpoints.
+func (self *StakeState) StateHash() common.Hash {
+	hw := sha3.NewKeccak256()
+	hash := common.Hash{}
+	rlp.Encode(hw, []interface{}{
+		self.GetStakeState(rootKey),
+		self.ShareSize(),
+		self.getNewShareNum(),
+		self.missedNum.GetValue(missedNumKey),
+	})
+	hw.Sum(hash[:0])
+	return hash
+}
+
 func (self *StakeState) SeleteShare(seed common.Hash) (ints []uint32, shares []*Share, err error) {
 	tree := NewTree(self)
 	//tree.MiddleOrder()
@@ -462,6 +492,24 @@ func (self *StakeState) SeleteShare(seed common.Hash) (ints []uint32, shares []*
 	return
 }
 
+// CheckShares makes sure every share of the share pool and the pools they
+// vote for can be loaded.
+func (self *StakeState) CheckShares() (err error) {
+	NewTree(self).walk(func(key common.Hash) bool {
+		share := self.GetShare(key)
+		if share == nil {
+			err = fmt.Errorf("missing share %x", key)
+			return false
+		}
+		if share.PoolId != nil && self.GetStakePool(*share.PoolId) == nil {
+			err = fmt.Errorf("missing stake pool %x of share %x", *share.PoolId, key)
+			return false
+		}
+		return true
+	})
+	return
+}
+
 func (self *StakeState) GetShare(key common.Hash) *Share {
 	item := self.shareObj.GetObj(key.Bytes(), &Share{})
 	if item == nil {
@@ -786,6 +834,9 @@ func (self *StakeState) verifyVote(vote types.HeaderVote, stakeHash common.Hash)
 		return errors.New("the share num is 0")
 	}
 	if vote.IsPool {
+		if share.PoolId == nil {
+			return errors.New("the share has no pool")
+		}
 		pool := self.GetStakePool(*share.PoolId)
 		if pool == nil {
 			return errors.New("not found pool by poolId")
@@ -805,6 +856,13 @@ func (self *StakeState) verifyVote(vote types.HeaderVote, stakeHash common.Hash)
 
 }
 
+// VerifyVote checks that a gossiped vote is signed with the vote key of its
+// share, or of the pool of the share, for the block of the given parent.
+func (self *StakeState) VerifyVote(vote *types.Vote, parentPosHash common.Hash) error {
+	stakeHash := types.StakeHash(&vote.PosHash, &parentPosHash, vote.IsPool)
+	return self.verifyVote(types.HeaderVote{Id: vote.ShareId, IsPool: vote.IsPool, Sign: vote.Sign}, stakeHash)
+}
+
 func (self *StakeState) processRemedyRewards(bc blockChain, header *types.Header) {
 	if header.Number.Uint64() > 0 {
 		parentHeader := bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
@@ -1041,8 +1099,7 @@ func (self *StakeState) processOutDate(header *types.Header, bc blockChain) (err
 		return
 	}
 
-	preHeader := bc.GetHeader(self.getBlockHash(preNumber), preNumber)
-	shares, e := self.getShares(bc.GetDB(), preHeader.Hash(), preHeader.Number.Uint64())
+	shares, e := self.getShares(bc.GetDB(), self.getBlockHash(preNumber), preNumber)
 	if e != nil {
 		err = e
 		return
@@ -1050,7 +1107,7 @@ func (self *StakeState) processOutDate(header *types.Header, bc blockChain) (err
 	if len(shares) > 0 {
 		tree := NewTree(self)
 		for _, share := range shares {
-			if share.BlockNumber == preHeader.Number.Uint64() {
+			if share.BlockNumber == preNumber {
 				if share.Status == STATUS_OUTOFDATE {
 					continue
 				}
@@ -1107,15 +1164,14 @@ func (self *StakeState) processMissVoted(header *types.Header, bc blockChain) (e
 	if preNumber < seroparam.SIP4() {
 		return
 	}
-	preHeader := bc.GetHeader(self.getBlockHash(preNumber), preNumber)
-	shares, e := self.getShares(bc.GetDB(), preHeader.Hash(), preHeader.Number.Uint64())
+	shares, e := self.getShares(bc.GetDB(), self.getBlockHash(preNumber), preNumber)
 	if e != nil {
 		err = e
 		return
 	}
 	if len(shares) > 0 {
 		for _, share := range shares {
-			if share.BlockNumber == preHeader.Number.Uint64() {
+			if share.BlockNumber == preNumber {
 				if share.Status == STATUS_FINISHED {
 					continue
 				}
@@ -1194,8 +1250,7 @@ func (self *StakeState) payIncome(bc blockChain, header *types.Header) (err erro
 	if preNumber < seroparam.SIP4() {
 		return
 	}
-	preHeader := bc.GetHeader(self.getBlockHash(preNumber), preNumber)
-	shares, pools, e := self.getBlockRecords(bc.GetDB(), preHeader.Hash(), preHeader.Number.Uint64())
+	shares, pools, e := self.getBlockRecords(bc.GetDB(), self.getBlockHash(preNumber), preNumber)
 	if e != nil {
 		err = e
 		return
@@ -2,13 +2,17 @@ package stake
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
 
 	"github.com/sero-cash/go-czero-import/keys"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/metrics"
 	"github.com/sero-cash/go-sero/serodb"
 )
 
@@ -114,3 +118,77 @@ func TestPosDif(t *testing.T) {
 	fmt.Println(amount)
 	fmt.Println(state.CaleAvgPrice(amount))
 }
+
+func TestCheckShares(t *testing.T) {
+	state, _ := newState()
+	if err := state.CheckShares(); err != nil {
+		t.Fatalf("empty share pool: %v", err)
+	}
+
+	var pkr keys.PKr
+	copy(pkr[:], crypto.Keccak512([]byte("123")))
+	share := &Share{PKr: pkr, Value: big.NewInt(10000), InitNum: 10, Num: 10, Income: new(big.Int), Profit: new(big.Int)}
+	state.updateShare(share)
+	NewTree(state).insert(&SNode{key: common.BytesToHash(share.Id()), num: share.Num, total: share.Num, nodeNum: 1})
+	if err := state.CheckShares(); err != nil {
+		t.Fatalf("share pool with one share: %v", err)
+	}
+
+	pool := &StakePool{PKr: pkr, Amount: big.NewInt(100), Income: new(big.Int), Profit: new(big.Int)}
+	poolId := common.BytesToHash(pool.Id())
+	pooled := &Share{PKr: pkr, PoolId: &poolId, Value: big.NewInt(10001), InitNum: 5, Num: 5, Income: new(big.Int), Profit: new(big.Int)}
+	state.updateShare(pooled)
+	NewTree(state).insert(&SNode{key: common.BytesToHash(pooled.Id()), num: pooled.Num, total: pooled.Num, nodeNum: 1})
+	if err := state.CheckShares(); err == nil {
+		t.Fatalf("share of a missing pool passed")
+	}
+
+	missing := &Share{PKr: pkr, Value: big.NewInt(10002), InitNum: 1, Num: 1, Income: new(big.Int), Profit: new(big.Int)}
+	NewTree(state).insert(&SNode{key: common.BytesToHash(missing.Id()), num: missing.Num, total: missing.Num, nodeNum: 1})
+	state.AddStakePool(pool)
+	if err := state.CheckShares(); err == nil {
+		t.Fatalf("missing share passed")
+	}
+}
+
+// Tests that the stake pools are counted from the database, then from the pools
+// changed by the blocks, each pool once.
+func TestStakePoolMetrics(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	dir, err := ioutil.TempDir("", "stakepools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var first, second keys.PKr
+	copy(first[:], crypto.Keccak512([]byte("first")))
+	copy(second[:], crypto.Keccak512([]byte("second")))
+
+	// A pool stored by a previous block
+	state, stateDB := newState()
+	state.AddStakePool(&StakePool{PKr: first, Amount: big.NewInt(1), BlockNumber: 1})
+	batch := db.NewBatch()
+	stateDB.GetStakeCons().Record(&types.Header{Number: big.NewInt(1)}, batch)
+	batch.Write()
+
+	CountStakePools(db)
+	if len(stakePools) != 1 {
+		t.Fatalf("stored pool count mismatch: have %d, want 1", len(stakePools))
+	}
+	// A new block registering a pool and updating the stored one
+	state, _ = newState()
+	state.AddStakePool(&StakePool{PKr: first, Amount: big.NewInt(1), BlockNumber: 1, Closed: true})
+	state.AddStakePool(&StakePool{PKr: second, Amount: big.NewInt(1), BlockNumber: 2})
+	state.ReportMetrics(&types.Header{Number: big.NewInt(2)})
+	if len(stakePools) != 2 {
+		t.Errorf("pool count mismatch: have %d, want 2", len(stakePools))
+	}
+}
@@ -71,6 +71,12 @@ func getStatisticsMissWindow() uint64 {
 	return statisticsMissWindow
 }
 
+// GetAncestorWindow returns how many ancestors of a block the processing of
+// the stake reads the headers of.
+func GetAncestorWindow() uint64 {
+	return getStatisticsMissWindow()
+}
+
 func getOutOfDateWindow() uint64 {
 	if seroparam.Is_Dev() {
 		return 100
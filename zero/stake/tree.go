@@ -128,6 +128,35 @@ func (tree *STree) MiddleOrder() {
 	rootNode := &SNode{key: hash}
 	rootNode.MiddleOrder(tree.state)
 }
+func (node *SNode) walk(state State, fn func(key common.Hash) bool) bool {
+	leftHash := state.GetStakeState(node.leftKey())
+	if leftHash != emptyHash {
+		left := &SNode{key: leftHash}
+		if !left.walk(state, fn) {
+			return false
+		}
+	}
+	if !fn(node.key) {
+		return false
+	}
+	rightHash := state.GetStakeState(node.rightKey())
+	if rightHash != emptyHash {
+		right := &SNode{key: rightHash}
+		return right.walk(state, fn)
+	}
+	return true
+}
+
+// walk calls fn with the key of every node in order, until fn returns false.
+func (tree *STree) walk(fn func(key common.Hash) bool) {
+	hash := tree.state.GetStakeState(rootKey)
+	if hash == emptyHash {
+		return
+	}
+	rootNode := &SNode{key: hash}
+	rootNode.walk(tree.state, fn)
+}
+
 func (tree *STree) size() uint32 {
 	parentHash := tree.state.GetStakeState(rootKey)
 	parent := &SNode{key: parentHash}
@@ -1,6 +1,8 @@
 package assets
 
 import (
+	"math/big"
+
 	"github.com/sero-cash/go-czero-import/keys"
 	"github.com/sero-cash/go-sero/crypto/sha3"
 	"github.com/sero-cash/go-sero/zero/utils"
@@ -41,6 +43,18 @@ func NewAsset(tkn *Token, tkt *Ticket) (ret Asset) {
 	return
 }
 
+// NewAssetByName makes the asset of a token and a ticket given by their names,
+// the empty parts are left out.
+func NewAssetByName(currency string, value *big.Int, category string, ticket keys.Uint256) (ret Asset) {
+	if len(currency) != 0 && value != nil && value.Sign() > 0 {
+		ret.Tkn = &Token{utils.CurrencyToUint256(currency), utils.U256(*new(big.Int).Set(value))}
+	}
+	if len(category) != 0 && ticket != keys.Empty_Uint256 {
+		ret.Tkt = &Ticket{utils.CurrencyToUint256(category), ticket}
+	}
+	return
+}
+
 func (self Asset) ToRef() (ret *Asset) {
 	return &self
 }
@@ -1,6 +1,8 @@
 package verify
 
 import (
+	"time"
+
 	"github.com/sero-cash/go-sero/zero/zconfig"
 
 	"github.com/sero-cash/go-czero-import/keys"
@@ -28,7 +30,10 @@ func (self *verify_input_o_desc) Run() error {
 	g.RootCM = *self.src.ToRootCM()
 	g.Sign = self.in.Sign
 	g.Pkr = *self.src.ToPKr()
-	if err := cpt.VerifyInputS(&g); err != nil {
+	start := time.Now()
+	err := cpt.VerifyInputS(&g)
+	inputOVerifyTimer.UpdateSince(start)
+	if err != nil {
 		self.e = err
 		return err
 	} else {
@@ -0,0 +1,31 @@
+// copyright 2018 The sero.cash Authors
+// This file is part of the go-sero library.
+//
+// The go-sero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-sero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-sero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the proof verification.
+
+package verify
+
+import (
+	"github.com/sero-cash/go-sero/metrics"
+)
+
+var (
+	inputZVerifyTimer  = metrics.NewRegisteredTimer("zero/verify/input/z", nil)
+	inputOVerifyTimer  = metrics.NewRegisteredTimer("zero/verify/input/o", nil)
+	outputVerifyTimer  = metrics.NewRegisteredTimer("zero/verify/output", nil)
+	pkgVerifyTimer     = metrics.NewRegisteredTimer("zero/verify/pkg", nil)
+	balanceVerifyTimer = metrics.NewRegisteredTimer("zero/verify/balance", nil)
+)
@@ -2,6 +2,7 @@ package verify
 
 import (
 	"errors"
+	"time"
 
 	"github.com/sero-cash/go-sero/zero/zconfig"
 
@@ -20,7 +21,10 @@ type verify_output_desc struct {
 
 func (self *verify_output_desc) Run() error {
 	if keys.PKrValid(&self.pkr) {
-		if err := cpt.VerifyOutput(&self.desc); err != nil {
+		start := time.Now()
+		err := cpt.VerifyOutput(&self.desc)
+		outputVerifyTimer.UpdateSince(start)
+		if err != nil {
 			self.e = err
 			return err
 		} else {
@@ -1,6 +1,8 @@
 package verify
 
 import (
+	"time"
+
 	"github.com/sero-cash/go-czero-import/cpt"
 	"github.com/sero-cash/go-sero/zero/utils"
 	"github.com/sero-cash/go-sero/zero/zconfig"
@@ -14,7 +16,10 @@ type verify_pkg_desc struct {
 }
 
 func (self *verify_pkg_desc) Run() error {
-	if err := cpt.VerifyPkg(&self.desc); err != nil {
+	start := time.Now()
+	err := cpt.VerifyPkg(&self.desc)
+	pkgVerifyTimer.UpdateSince(start)
+	if err != nil {
 		self.e = err
 		return err
 	} else {
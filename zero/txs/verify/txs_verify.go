@@ -19,6 +19,7 @@ package verify
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/sero-cash/go-czero-import/seroparam"
 
@@ -282,7 +283,10 @@ func Verify(s *stx.T, state *zstate.ZState) (e error) {
 			return
 		}
 	}
-	if err := cpt.VerifyBalance(&balance_desc); err != nil {
+	start := time.Now()
+	err := cpt.VerifyBalance(&balance_desc)
+	balanceVerifyTimer.UpdateSince(start)
+	if err != nil {
 		e = err
 		return
 	} else {
@@ -146,6 +146,24 @@ func (self *MerkleTree) GetPaths(value keys.Uint256) (pos uint64, paths [DEPTH]k
 	return
 }
 
+func (self *MerkleTree) GetFrontier() (ret IncrementalTree) {
+	leafIndex := self.getCurrentLeafIndex()
+	ret.Tree = self.geCurrentTreeIndex()
+	ret.Size = leafIndex - startIndex
+	if ret.Size == 0 {
+		ret.Root = cpt.EmptyRoots()[DEPTH]
+		return
+	}
+	for depth := 0; depth < DEPTH; depth++ {
+		if (ret.Size>>uint(depth))&1 == 1 {
+			index := (leafIndex >> uint(depth)) - 1
+			ret.Frontier[depth] = self.db.GetState(indexPathKey(index, ret.Tree).NewRef())
+		}
+	}
+	ret.Root = self.db.GetState(indexPathKey(1, ret.Tree).NewRef())
+	return
+}
+
 func (self *MerkleTree) nextLeafIndex() uint64 {
 	leafIndex := self.getCurrentLeafIndex()
 	if leafIndex == cap {
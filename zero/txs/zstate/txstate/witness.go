@@ -0,0 +1,95 @@
+package txstate
+
+import (
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+)
+
+// IncrementalTree keeps only the right edge of the commitment tree,
+// so leaves can be appended without reading the tri.
+type IncrementalTree struct {
+	Tree     uint64
+	Size     uint64
+	Frontier [DEPTH]keys.Uint256
+	Root     keys.Uint256
+}
+
+// IncrementalWitness is the merkle path of one leaf, refreshed by every
+// leaf appended after it in the same tree.
+type IncrementalWitness struct {
+	Tree   uint64
+	Pos    uint64
+	Value  keys.Uint256
+	Paths  [DEPTH]keys.Uint256
+	Anchor keys.Uint256
+}
+
+func NewIncrementalWitness(index uint64, value keys.Uint256, paths [DEPTH]keys.Uint256, anchor keys.Uint256) (ret IncrementalWitness) {
+	ret.Tree = index / leafcap
+	ret.Pos = index % leafcap
+	ret.Value = value
+	ret.Paths = paths
+	ret.Anchor = anchor
+	return
+}
+
+func (self *IncrementalTree) GetLeafSize() uint64 {
+	return self.Tree*leafcap + self.Size
+}
+
+func (self *IncrementalTree) Append(value keys.Uint256) (pos uint64, nodes [DEPTH]keys.Uint256) {
+	if self.Size == leafcap {
+		self.Tree++
+		self.Size = 0
+		self.Frontier = [DEPTH]keys.Uint256{}
+	}
+	pos = self.Size
+	current_value := value
+	for depth := 0; depth < DEPTH; depth++ {
+		nodes[depth] = current_value
+		if (pos>>uint(depth))&1 == 0 {
+			self.Frontier[depth] = current_value
+			current_value = Combine(&current_value, &cpt.EmptyRoots()[depth])
+		} else {
+			current_value = Combine(&self.Frontier[depth], &current_value)
+		}
+	}
+	self.Size++
+	self.Root = current_value
+	return
+}
+
+// Witness must be called right after the Append of the leaf at pos.
+func (self *IncrementalTree) Witness(value keys.Uint256, pos uint64) (ret IncrementalWitness) {
+	ret.Tree = self.Tree
+	ret.Pos = pos
+	ret.Value = value
+	for depth := 0; depth < DEPTH; depth++ {
+		if (pos>>uint(depth))&1 == 1 {
+			ret.Paths[depth] = self.Frontier[depth]
+		} else {
+			ret.Paths[depth] = cpt.EmptyRoots()[depth]
+		}
+	}
+	ret.Anchor = self.Root
+	return
+}
+
+// Update applies the nodes returned by IncrementalTree.Append.
+// Only the path entry where the two leaves meet changes.
+func (self *IncrementalWitness) Update(tree uint64, pos uint64, nodes *[DEPTH]keys.Uint256, root *keys.Uint256) {
+	if tree != self.Tree || pos <= self.Pos {
+		return
+	}
+	depth := 0
+	for diff := (self.Pos ^ pos) >> 1; diff != 0; diff >>= 1 {
+		depth++
+	}
+	self.Paths[depth] = nodes[depth]
+	self.Anchor = *root
+}
+
+func (self *IncrementalWitness) Clone() (ret IncrementalWitness) {
+	ret = *self
+	return
+}
@@ -0,0 +1,104 @@
+package txstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/zero/consensus"
+)
+
+func leafValue(i int) keys.Uint256 {
+	return *crypto.Keccak256Hash(big.NewInt(int64(i)).Bytes()).HashToUint256()
+}
+
+func TestIncrementalWitness(t *testing.T) {
+	cpt.ZeroInit("", 0)
+
+	ft := consensus.NewFakeTri()
+	tree := NewMerkleTree(&TreeState{db: &ft})
+	inc := tree.GetFrontier()
+
+	wits := []IncrementalWitness{}
+	for i := 1; i <= 100; i++ {
+		value := leafValue(i)
+		root := tree.AppendLeaf(value)
+		pos, nodes := inc.Append(value)
+		if inc.Root != root {
+			t.Fatalf("leaf %v: incremental root %v != tree root %v", i, inc.Root, root)
+		}
+		for j := range wits {
+			wits[j].Update(inc.Tree, pos, &nodes, &inc.Root)
+		}
+		if i%7 == 1 {
+			wits = append(wits, inc.Witness(value, pos))
+		}
+
+		for _, wit := range wits {
+			index, paths, anchor := tree.GetPaths(wit.Value)
+			if index != wit.Pos || paths != wit.Paths || anchor != wit.Anchor {
+				t.Fatalf("leaf %v: witness of pos %v differs from GetPaths", i, wit.Pos)
+			}
+			if CalcRoot(&wit.Value, wit.Pos, &wit.Paths) != wit.Anchor {
+				t.Fatalf("leaf %v: witness of pos %v does not hash to anchor", i, wit.Pos)
+			}
+		}
+	}
+
+	frontier := tree.GetFrontier()
+	if frontier.Tree != inc.Tree || frontier.Size != inc.Size || frontier.Root != inc.Root {
+		t.Fatalf("frontier read from tree differs from incremental tree")
+	}
+	value := leafValue(101)
+	frontier.Append(value)
+	if frontier.Root != tree.AppendLeaf(value) {
+		t.Fatalf("frontier read from tree appends to a wrong root")
+	}
+}
+
+func newBenchTree(b *testing.B, size int) (MerkleTree, []keys.Uint256) {
+	cpt.ZeroInit("", 0)
+	ft := consensus.NewFakeTri()
+	tree := NewMerkleTree(&TreeState{db: &ft})
+	values := []keys.Uint256{}
+	for i := 1; i <= size; i++ {
+		value := leafValue(i)
+		tree.AppendLeaf(value)
+		values = append(values, value)
+	}
+	b.ResetTimer()
+	return tree, values
+}
+
+// BenchmarkGetPaths recomputes the paths of 64 roots after each new leaf,
+// as flight.SRI.GetAnchor does for every spend.
+func BenchmarkGetPaths(b *testing.B) {
+	tree, values := newBenchTree(b, 1024)
+	for i := 0; i < b.N; i++ {
+		tree.AppendLeaf(leafValue(1024 + i + 1))
+		for _, value := range values[:64] {
+			tree.GetPaths(value)
+		}
+	}
+}
+
+// BenchmarkIncrementalWitness keeps the same 64 witnesses up to date.
+func BenchmarkIncrementalWitness(b *testing.B) {
+	tree, values := newBenchTree(b, 1024)
+	b.StopTimer()
+	inc := tree.GetFrontier()
+	wits := []IncrementalWitness{}
+	for _, value := range values[:64] {
+		pos, paths, anchor := tree.GetPaths(value)
+		wits = append(wits, IncrementalWitness{Pos: pos, Value: value, Paths: paths, Anchor: anchor})
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		pos, nodes := inc.Append(leafValue(1024 + i + 1))
+		for j := range wits {
+			wits[j].Update(inc.Tree, pos, &nodes, &inc.Root)
+		}
+	}
+}
@@ -121,11 +121,23 @@ func (self *SRI) GetBlocksInfo(start uint64, count uint64) (blocks []txtool.Bloc
 }
 
 func (self *SRI) GetAnchor(roots []keys.Uint256) (wits []txtool.Witness, e error) {
+	tracked, err := Witness_Inst.GetWitnesses(roots)
+	if err != nil {
+		log.Error("GetAnchor use witness tracker failed", "error", err)
+	}
+	if len(tracked) == len(roots) {
+		for _, root := range roots {
+			wits = append(wits, tracked[root])
+		}
+		return
+	}
 	state := txtool.Ref_inst.CurrentState()
 	if state != nil {
 		for _, root := range roots {
 			wit := txtool.Witness{}
-			if out := GetOut(&root, 0); out == nil {
+			if tracked_wit, ok := tracked[root]; ok {
+				wits = append(wits, tracked_wit)
+			} else if out := GetOut(&root, 0); out == nil {
 				e = errors.New("GetAnchor use root but out is nil !!!")
 				return
 			} else {
@@ -0,0 +1,243 @@
+package flight
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sero-cash/go-czero-import/keys"
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/zero/txs/zstate/txstate"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+// Number of confirmed blocks whose anchors are kept by the tracker. A tracker
+// that falls further behind is rebuilt from the tree instead of replayed.
+const witnessKeepBlocks = 128
+
+type WitnessAnchor struct {
+	Num    uint64
+	Hash   keys.Uint256
+	Anchor keys.Uint256
+}
+
+// WitnessTracker keeps the witnesses of a registered set of roots and updates
+// them incrementally with the outs of every confirmed block, so building a
+// transaction does not walk the commitment tree for each input.
+type WitnessTracker struct {
+	tree    *txstate.IncrementalTree
+	anchors []WitnessAnchor
+	wits    map[keys.Uint256]*txstate.IncrementalWitness
+	pending map[keys.Uint256]bool
+	lock    sync.Mutex
+}
+
+var Witness_Inst = NewWitnessTracker()
+
+func NewWitnessTracker() *WitnessTracker {
+	return &WitnessTracker{
+		wits:    make(map[keys.Uint256]*txstate.IncrementalWitness),
+		pending: make(map[keys.Uint256]bool),
+	}
+}
+
+func (self *WitnessTracker) Register(roots []keys.Uint256) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, root := range roots {
+		if _, ok := self.wits[root]; !ok {
+			self.pending[root] = true
+		}
+	}
+}
+
+func (self *WitnessTracker) Unregister(roots []keys.Uint256) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, root := range roots {
+		delete(self.wits, root)
+		delete(self.pending, root)
+	}
+}
+
+func (self *WitnessTracker) Count() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return len(self.wits) + len(self.pending)
+}
+
+// RecentAnchors returns the tree roots of the last synced blocks, oldest first.
+func (self *WitnessTracker) RecentAnchors() (ret []WitnessAnchor) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ret = append(ret, self.anchors...)
+	return
+}
+
+// GetWitnesses returns the witnesses of the tracked roots, roots that are not
+// tracked yet are left out of the result.
+func (self *WitnessTracker) GetWitnesses(roots []keys.Uint256) (wits map[keys.Uint256]txtool.Witness, e error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if e = self.sync(); e != nil {
+		return
+	}
+	wits = make(map[keys.Uint256]txtool.Witness)
+	for _, root := range roots {
+		if wit, ok := self.wits[root]; ok {
+			wits[root] = txtool.Witness{
+				Pos:    hexutil.Uint64(wit.Pos),
+				Paths:  wit.Paths,
+				Anchor: wit.Anchor,
+			}
+		}
+	}
+	return
+}
+
+func (self *WitnessTracker) Sync() (e error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.sync()
+}
+
+func (self *WitnessTracker) sync() (e error) {
+	if txtool.Ref_inst.Bc == nil || !txtool.Ref_inst.Bc.IsValid() {
+		return
+	}
+	if len(self.wits) == 0 && len(self.pending) == 0 {
+		self.tree = nil
+		self.anchors = nil
+		return
+	}
+
+	target := txtool.Ref_inst.GetDelayedNum(seroparam.DefaultConfirmedBlock())
+	if self.tree != nil {
+		head := self.anchors[len(self.anchors)-1]
+		if block := txtool.Ref_inst.Bc.GetBlockByNumber(head.Num); block == nil || *block.Hash().HashToUint256() != head.Hash {
+			log.Info("WitnessTracker: head is not canonical, rebuild", "num", head.Num)
+			self.reset()
+		} else if target > head.Num+witnessKeepBlocks {
+			log.Info("WitnessTracker: too far behind, rebuild", "num", head.Num, "target", target)
+			self.reset()
+		}
+	}
+
+	if self.tree == nil {
+		block := txtool.Ref_inst.Bc.GetBlockByNumber(target)
+		if block == nil {
+			return fmt.Errorf("WitnessTracker: can not find block %v", target)
+		}
+		hash := block.Hash()
+		state := txtool.Ref_inst.Bc.CurrentState(&hash)
+		if state == nil {
+			return fmt.Errorf("WitnessTracker: can not find state of block %v", target)
+		}
+		frontier := state.State.MTree.GetFrontier()
+		self.tree = &frontier
+		self.pushAnchor(target, *hash.HashToUint256())
+	} else {
+		for num := self.anchors[len(self.anchors)-1].Num + 1; num <= target; num++ {
+			if e = self.appendBlock(num); e != nil {
+				self.reset()
+				return
+			}
+		}
+	}
+
+	return self.resolvePending()
+}
+
+func (self *WitnessTracker) reset() {
+	for root := range self.wits {
+		self.pending[root] = true
+	}
+	self.wits = make(map[keys.Uint256]*txstate.IncrementalWitness)
+	self.tree = nil
+	self.anchors = nil
+}
+
+func (self *WitnessTracker) pushAnchor(num uint64, hash keys.Uint256) {
+	self.anchors = append(self.anchors, WitnessAnchor{num, hash, self.tree.Root})
+	if len(self.anchors) > witnessKeepBlocks {
+		self.anchors = self.anchors[len(self.anchors)-witnessKeepBlocks:]
+	}
+}
+
+type witnessLeaf struct {
+	root  keys.Uint256
+	index uint64
+	value keys.Uint256
+}
+
+func (self *WitnessTracker) appendBlock(num uint64) (e error) {
+	chain_block := txtool.Ref_inst.Bc.GetBlockByNumber(num)
+	if chain_block == nil {
+		return fmt.Errorf("WitnessTracker: can not find block %v", num)
+	}
+	hash := chain_block.Hash()
+	block := GetBlock(num, &hash)
+
+	leaves := []witnessLeaf{}
+	for _, root := range block.Roots {
+		out := GetOut(&root, num)
+		if out == nil {
+			return fmt.Errorf("WitnessTracker: can not find out %v of block %v", hexutil.Encode(root[:]), num)
+		}
+		leaves = append(leaves, witnessLeaf{root, out.OS.Index, *out.OS.ToRootCM()})
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return leaves[i].index < leaves[j].index
+	})
+
+	for _, leaf := range leaves {
+		if leaf.index != self.tree.GetLeafSize() {
+			return fmt.Errorf("WitnessTracker: out index %v of block %v, expect %v", leaf.index, num, self.tree.GetLeafSize())
+		}
+		pos, nodes := self.tree.Append(leaf.value)
+		for _, wit := range self.wits {
+			wit.Update(self.tree.Tree, pos, &nodes, &self.tree.Root)
+		}
+		if self.pending[leaf.root] {
+			wit := self.tree.Witness(leaf.value, pos)
+			self.wits[leaf.root] = &wit
+			delete(self.pending, leaf.root)
+		}
+	}
+	self.pushAnchor(num, *hash.HashToUint256())
+	return
+}
+
+func (self *WitnessTracker) resolvePending() (e error) {
+	if len(self.pending) == 0 {
+		return
+	}
+	head := self.anchors[len(self.anchors)-1]
+	hash := common.BytesToHash(head.Hash[:])
+	state := txtool.Ref_inst.Bc.CurrentState(&hash)
+	if state == nil {
+		return fmt.Errorf("WitnessTracker: can not find state of block %v", head.Num)
+	}
+	for root := range self.pending {
+		out := GetOut(&root, 0)
+		if out == nil {
+			log.Debug("WitnessTracker: drop unknown root", "root", hexutil.Encode(root[:]))
+			delete(self.pending, root)
+			continue
+		}
+		if out.OS.Index >= self.tree.GetLeafSize() {
+			continue
+		}
+		pos, paths, anchor := state.State.MTree.GetPaths(*out.OS.ToRootCM())
+		wit := txstate.NewIncrementalWitness(out.OS.Index, *out.OS.ToRootCM(), paths, anchor)
+		if uint64(wit.Pos) != pos {
+			return fmt.Errorf("WitnessTracker: out index %v mismatch the tree position %v", out.OS.Index, pos)
+		}
+		self.wits[root] = &wit
+		delete(self.pending, root)
+	}
+	return
+}
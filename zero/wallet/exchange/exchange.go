@@ -688,6 +688,9 @@ func (self *Exchange) fetchBlockInfo() {
 	if txtool.Ref_inst.Bc == nil || !txtool.Ref_inst.Bc.IsValid() {
 		return
 	}
+	if err := flight.Witness_Inst.Sync(); err != nil {
+		log.Error("Exchange sync witnesses", "error", err)
+	}
 	for {
 		indexs := map[uint64][]keys.Uint512{}
 		orders := uint64Slice{}
@@ -708,6 +711,11 @@ func (self *Exchange) fetchBlockInfo() {
 
 		sort.Sort(orders)
 		start := orders[0]
+		if head := txtool.Ref_inst.Bc.GetCurrenHeader().Number.Uint64(); head > start {
+			indexLagGauge.Update(int64(head - start))
+		} else {
+			indexLagGauge.Update(0)
+		}
 		end := start + fetchCount
 		if orders.Len() > 1 {
 			end = orders[1]
@@ -843,6 +851,14 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []keys.Uin
 		for _, pk := range pks {
 			self.numbers.Store(pk, num)
 		}
+		newRoots := []keys.Uint256{}
+		for _, list := range utxosMap {
+			for _, utxo := range list {
+				newRoots = append(newRoots, utxo.Root)
+			}
+		}
+		flight.Witness_Inst.Register(newRoots)
+		flight.Witness_Inst.Unregister(roots)
 	}
 
 	for _, root := range roots {
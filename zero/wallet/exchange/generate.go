@@ -9,10 +9,22 @@ import (
 
 	"github.com/sero-cash/go-czero-import/keys"
 	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/flight"
 )
 
+// txParamState keeps the witnesses of the spent utxos in the tracker, so
+// retried or merged transactions do not walk the commitment tree again.
+type txParamState struct {
+	prepare.DefaultTxParamState
+}
+
+func (self *txParamState) GetAnchor(roots []keys.Uint256) (wits []txtool.Witness, e error) {
+	flight.Witness_Inst.Register(roots)
+	return self.DefaultTxParamState.GetAnchor(roots)
+}
+
 func (self *Exchange) GenTx(param prepare.PreTxParam) (txParam *txtool.GTxParam, e error) {
-	txParam, e = prepare.GenTxParam(&param, self, &prepare.DefaultTxParamState{})
+	txParam, e = prepare.GenTxParam(&param, self, &txParamState{})
 	if e == nil && txParam != nil {
 		for _, in := range txParam.Ins {
 			self.usedFlag.Store(in.Out.Root, 1)
@@ -29,7 +41,7 @@ func (self *Exchange) buildTxParam(
 	fee *assets.Token,
 	gasPrice *big.Int) (txParam *txtool.GTxParam, e error) {
 
-	txParam, e = prepare.BuildTxParam(&prepare.DefaultTxParamState{}, utxos, refundTo, receptions, cmds, fee, gasPrice)
+	txParam, e = prepare.BuildTxParam(&txParamState{}, utxos, refundTo, receptions, cmds, fee, gasPrice)
 
 	if e == nil && txParam != nil {
 		for _, in := range txParam.Ins {